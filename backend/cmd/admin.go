@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"discord-backend/internal/app/factory"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"discord-backend/internal/config"
+	"discord-backend/internal/db"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+const (
+	inviteUsage   = "usage: main invite [flags] revoke <server-id>"
+	sessionsUsage = "usage: main sessions [flags] list <user> | revoke <user> [session-id]"
+	statsUsage    = "usage: main stats [flags]"
+)
+
+// openFactory loads the config, connects to the database and returns the
+// factory the admin commands get their services from, with the arguments left
+// after the flags. needArgs is how many arguments the command needs at least
+func openFactory(args []string, usage string, needArgs int) (*factory.Factory, []string) {
+	cfg, args, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) < needArgs {
+		log.Fatal(usage)
+	}
+
+	database, err := db.ConnectToDB(cfg.Database)
+	if err != nil {
+		log.Fatal("Could not connect to database: ", err)
+	}
+
+	return factory.NewFactory(cfg, database), args
+}
+
+// findProfile takes a user id, email or name
+func findProfile(f *factory.Factory, ref string) *models.Profile {
+	profile, err := f.NewAdminService().FindProfile(ref)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Fatalf("No user %q", ref)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return profile
+}
+
+func parseID(kind, raw string) uuid.UUID {
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		log.Fatalf("%q is not a %s id", raw, kind)
+	}
+	return id
+}
+
+// readPassword reads the password from the first line of stdin when it is
+// piped, so it stays out of the shell history, and otherwise generates one
+func readPassword() (string, bool) {
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice == 0 {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Fatal("Could not read the password: ", err)
+		}
+		return strings.TrimRight(line, "\r\n"), false
+	}
+
+	password, err := utils.GenerateToken(12)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return password, true
+}
+
+func runInvite(args []string) {
+	f, args := openFactory(args, inviteUsage, 2)
+	if args[0] != "revoke" {
+		log.Fatal(inviteUsage)
+	}
+
+	serverID := parseID("server", args[1])
+	server, err := f.NewAdminService().GetServer(serverID)
+	if err != nil {
+		log.Fatal("Could not find the server: ", err)
+	}
+
+	// A new code invalidates every link shared with the old one
+	server, err = f.NewServerService().UpdateServerInviteCode(server.ID, server.ProfileID)
+	if err != nil {
+		log.Fatal("Could not revoke the invite: ", err)
+	}
+
+	fmt.Printf("Revoked the invite of %s, the new invite code is %s\n", server.Name, server.InviteCode)
+}
+
+func runSessions(args []string) {
+	f, args := openFactory(args, sessionsUsage, 2)
+	profile := findProfile(f, args[1])
+	tokenService := f.NewTokenService()
+
+	switch args[0] {
+	case "list":
+		sessions, err := tokenService.GetSessions(profile.ID)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tDEVICE\tIP\tLAST USED\tEXPIRES")
+		for _, session := range sessions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", session.ID, session.DeviceName, session.IP,
+				session.LastUsedAt.Local().Format(timeLayout), session.ExpiresAt.Local().Format(timeLayout))
+		}
+		w.Flush()
+	case "revoke":
+		if len(args) > 2 {
+			if _, err := tokenService.RevokeSession(profile.ID, parseID("session", args[2])); err != nil {
+				log.Fatal("Could not revoke the session: ", err)
+			}
+			fmt.Printf("Revoked session %s of %s\n", args[2], profile.Name)
+			return
+		}
+
+		revoked, err := tokenService.RevokeSessions(profile.ID)
+		if err != nil {
+			log.Fatal("Could not revoke the sessions: ", err)
+		}
+		fmt.Printf("Revoked %d sessions of %s\n", len(revoked), profile.Name)
+	default:
+		log.Fatal(sessionsUsage)
+	}
+}
+
+func runStats(args []string) {
+	f, _ := openFactory(args, statsUsage, 0)
+	stats, err := f.NewAdminService().Stats()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Users\t%d\n", stats.Profiles)
+	fmt.Fprintf(w, "Disabled users\t%d\n", stats.DisabledProfiles)
+	fmt.Fprintf(w, "Bots\t%d\n", stats.Bots)
+	fmt.Fprintf(w, "Servers\t%d\n", stats.Servers)
+	fmt.Fprintf(w, "Deleted servers\t%d\n", stats.DeletedServers)
+	fmt.Fprintf(w, "Channels\t%d\n", stats.Channels)
+	fmt.Fprintf(w, "Messages\t%d\n", stats.Messages)
+	fmt.Fprintf(w, "Direct messages\t%d\n", stats.DirectMessages)
+	fmt.Fprintf(w, "Active sessions\t%d\n", stats.ActiveSessions)
+	w.Flush()
+}
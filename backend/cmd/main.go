@@ -1,52 +1,50 @@
 package main
 
 import (
-	"discord-backend/internal/app/factory"
-	"discord-backend/internal/db"
-	"discord-backend/internal/routes"
+	"fmt"
 	"log"
 	"os"
 	"strings"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
 )
 
-func main() {
-	r := gin.Default()
-
-	// Get allowed origins from environment variable
-	allowedOrigins := os.Getenv("CORS_ALLOWED_ORIGINS")
-	if allowedOrigins == "" {
-		allowedOrigins = "http://localhost:3000" // Fallback to default if not set
-	}
-
-	log.Printf("Allowed origins: %s", allowedOrigins)
+const usage = `usage: main [command] [flags] [arguments]
 
-	// Split the origins into a slice
-	origins := strings.Split(allowedOrigins, ",")
+commands:
+  serve                 run the server, the default when no command is given
+  migrate               apply, roll back, list or create migrations
+  user                  create, disable, enable or reset the password of a user
+  server                list, inspect, transfer or delete servers
+  invite revoke         replace the invite code of a server
+  sessions              list or revoke the sessions of a user
+  stats                 count the users, servers and messages of the instance
 
-	// Configure CORS to allow your frontend domain, e.g., http://localhost:3000
-	config := cors.DefaultConfig()
-	config.AllowOrigins = origins
-	config.AllowCredentials = true // Important for cookies
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
-	r.Use(cors.New(config))
+Every command reads the same config, flags go before the arguments. Run
+main <command> -h for the flags.`
 
-	database, err := db.ConnectToDB()
-
-	if err != nil {
-		log.Fatal("Could not connect to database: ", err)
+func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	if err := db.AutoMigrate(database); err != nil {
-		log.Fatal("AutoMigrate failed: ", err)
+	switch command {
+	case "serve":
+		runServe(args)
+	case "migrate":
+		runMigrate(args)
+	case "user":
+		runUser(args)
+	case "server":
+		runServer(args)
+	case "invite":
+		runInvite(args)
+	case "sessions":
+		runSessions(args)
+	case "stats":
+		runStats(args)
+	case "help":
+		fmt.Println(usage)
+	default:
+		log.Fatalf("unknown command %q\n%s", command, usage)
 	}
-
-	appFactory := factory.NewFactory(database)
-
-	routes.SetupRoutes(r, appFactory)
-
-	r.Run()
 }
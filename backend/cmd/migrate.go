@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"discord-backend/internal/config"
+	"discord-backend/internal/db"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const migrateUsage = "usage: main migrate [flags] up | down [steps] | status | create <name>"
+
+// runMigrate is the migrate subcommand, create only writes files so it works
+// without a database or config
+func runMigrate(args []string) {
+	if len(args) > 0 && args[0] == "create" {
+		up, down, err := db.CreateMigration(db.MIGRATIONS_DIR, strings.Join(args[1:], "_"))
+		if err != nil {
+			log.Fatal("Could not create migration: ", err)
+		}
+		fmt.Printf("Created %s\nCreated %s\n", up, down)
+		return
+	}
+
+	cfg, args, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Println(migrateUsage)
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	database, err := db.ConnectToDB(cfg.Database)
+	if err != nil {
+		log.Fatal("Could not connect to database: ", err)
+	}
+
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		log.Fatal("Could not load migrations: ", err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("Applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Already up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatal("steps must be a positive number")
+			}
+		}
+
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, migration := range rolledBack {
+			fmt.Printf("Rolled back %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Local().Format(timeLayout)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"discord-backend/internal/app/factory"
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/websocket"
+	"discord-backend/internal/app/workers"
+	"discord-backend/internal/config"
+	"discord-backend/internal/db"
+	"discord-backend/internal/routes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// runServe is the serve command, it migrates the database and runs the API
+func runServe(args []string) {
+	cfg, _, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Configuration:\n%s", cfg)
+
+	r := gin.Default()
+	// Without trusted proxies X-Forwarded-For is ignored, rate limits and
+	// sessions see the address of the connection
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid trusted proxies: ", err)
+	}
+
+	// Configure CORS to allow your frontend domain, e.g., http://localhost:3000
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = cfg.Server.CORSAllowedOrigins
+	corsConfig.AllowCredentials = true // Important for cookies
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	r.Use(cors.New(corsConfig))
+
+	database, err := db.ConnectToDB(cfg.Database)
+
+	if err != nil {
+		log.Fatal("Could not connect to database: ", err)
+	}
+
+	if err := db.Migrate(database); err != nil {
+		log.Fatal("Migrating failed: ", err)
+	}
+
+	if err := services.LoadSigningKeys(database, cfg.SigningKeyKEK()); err != nil {
+		log.Fatal("Could not load signing keys: ", err)
+	}
+
+	appFactory := factory.NewFactory(cfg, database)
+
+	wsHub := appFactory.NewHub()
+	wsHub.OnPresence = appFactory.NewRelationshipHandler().NotifyPresence(wsHub)
+	wsHub.BlockedProfiles = appFactory.NewRelationshipService().GetBlockedIDs
+	go wsHub.Run()
+
+	background := workers.NewGroup()
+	background.Go(appFactory.NewPurger(time.Hour).Run)
+	background.Go(appFactory.NewKeyRotator(time.Minute * 10).Run)
+	background.Go(appFactory.NewScheduler(wsHub, time.Second*10).Run)
+	background.Go(appFactory.NewSessionSweeper(wsHub, time.Second*30).Run)
+
+	webhookDispatcher := appFactory.NewWebhookDispatcher(time.Second * 5)
+	appFactory.Events().Subscribe(webhookDispatcher.Enqueue)
+	background.Go(webhookDispatcher.Run)
+
+	unfurler := appFactory.NewUnfurler(wsHub)
+	appFactory.Events().Subscribe(unfurler.Enqueue)
+	background.Go(unfurler.Run)
+
+	gateway := appFactory.NewGateway()
+	appFactory.Events().Subscribe(gateway.Dispatch)
+
+	routes.SetupRoutes(r, appFactory, wsHub, gateway)
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler: r.Handler(),
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Server failed: ", err)
+		}
+	}()
+
+	// /metrics gets its own listener when it has an internal address
+	var metricsSrv *http.Server
+	if cfg.Server.MetricsAddress != "" {
+		metricsRouter := gin.New()
+		metricsRouter.Use(gin.Recovery())
+		routes.MetricsRoutes(metricsRouter, appFactory.NewHealthHandler(wsHub, gateway))
+
+		metricsSrv = &http.Server{
+			Addr:    cfg.Server.MetricsAddress,
+			Handler: metricsRouter.Handler(),
+		}
+
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal("Metrics server failed: ", err)
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	// A second signal kills the process without waiting
+	stop()
+
+	timeout := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+	log.Printf("Shutting down, waiting up to %s", timeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Metrics are scraped until the rest has shut down
+	if metricsSrv != nil {
+		defer metricsSrv.Close()
+	}
+
+	if err := shutdown(shutdownCtx, srv, background, wsHub, gateway, database); err != nil {
+		log.Printf("Shutdown did not finish: %v", err)
+		return
+	}
+
+	log.Println("Shut down")
+}
+
+// shutdown stops accepting connections and waits for the requests in flight,
+// then stops the background workers, asks the bots and websocket clients to
+// reconnect, closes the voice connections and stops the hub, and finally waits
+// for the database work still running before closing the pool
+func shutdown(ctx context.Context, srv *http.Server, background *workers.Group, hub *websocket.Hub, gateway *websocket.Gateway, database *gorm.DB) error {
+	// Websockets are hijacked, Shutdown neither waits for them nor closes them
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("closing the HTTP server: %w", err)
+	}
+
+	// Workers broadcast to the hub and publish to the gateway, they stop first
+	if err := background.Stop(ctx); err != nil {
+		return fmt.Errorf("stopping the workers: %w", err)
+	}
+
+	if err := gateway.Shutdown(ctx); err != nil {
+		return fmt.Errorf("closing the gateway: %w", err)
+	}
+
+	if err := hub.Shutdown(ctx); err != nil {
+		return fmt.Errorf("closing the websocket hub: %w", err)
+	}
+
+	if err := db.Drain(ctx, database); err != nil {
+		return fmt.Errorf("draining the database: %w", err)
+	}
+
+	return nil
+}
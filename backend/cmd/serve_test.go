@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"discord-backend/internal/app/websocket"
+	"discord-backend/internal/app/workers"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	gorillaws "github.com/gorilla/websocket"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// idleDriver accepts every statement without doing anything, a connection
+// stays in use for as long as a transaction is open on it
+type idleDriver struct{}
+
+type idleConn struct{}
+
+type idleStmt struct{}
+
+type idleTx struct{}
+
+func (idleDriver) Open(string) (driver.Conn, error)         { return idleConn{}, nil }
+func (idleConn) Prepare(string) (driver.Stmt, error)        { return idleStmt{}, nil }
+func (idleConn) Close() error                               { return nil }
+func (idleConn) Begin() (driver.Tx, error)                  { return idleTx{}, nil }
+func (idleStmt) Close() error                               { return nil }
+func (idleStmt) NumInput() int                              { return -1 }
+func (idleStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (idleStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("queries are not supported")
+}
+func (idleTx) Commit() error   { return nil }
+func (idleTx) Rollback() error { return nil }
+
+func init() {
+	sql.Register("idle", idleDriver{})
+}
+
+func openIdleDB(t *testing.T) (*gorm.DB, *sql.DB) {
+	t.Helper()
+
+	sqlDB, err := sql.Open("idle", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	database, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return database, sqlDB
+}
+
+type testServer struct {
+	srv     *http.Server
+	url     string
+	started chan struct{}
+	release chan struct{}
+}
+
+// startServer serves the websocket of the app at /ws, the bot gateway at
+// /gateway and a request that waits for release at /slow
+func startServer(t *testing.T, hub *websocket.Hub, gateway *websocket.Gateway) *testServer {
+	t.Helper()
+
+	server := &testServer{started: make(chan struct{}, 1), release: make(chan struct{})}
+	upgrader := gorillaws.Upgrader{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		client := &websocket.Client{Hub: hub, Conn: conn, Send: make(chan websocket.Message), ID: r.RemoteAddr, ProfileID: uuid.New()}
+		hub.Register <- client
+
+		go client.ReadPump()
+		go client.WritePump()
+	})
+	mux.HandleFunc("/gateway", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if err := gateway.Connect(conn, uuid.New(), uuid.New(), nil); err != nil {
+			conn.Close()
+		}
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		server.started <- struct{}{}
+		<-server.release
+		io.WriteString(w, "done")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.srv = &http.Server{Handler: mux}
+	server.url = "127.0.0.1:" + strings.Split(listener.Addr().String(), ":")[1]
+	go server.srv.Serve(listener)
+	t.Cleanup(func() { server.srv.Close() })
+
+	return server
+}
+
+func (s *testServer) dial(t *testing.T, path string) *gorillaws.Conn {
+	t.Helper()
+
+	conn, _, err := gorillaws.DefaultDialer.Dial("ws://"+s.url+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// waitFor polls until ok, the sockets register after the handshake
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// frames reads the socket until it is closed, the app socket may batch
+// messages in a frame one per line
+func frames(t *testing.T, conn *gorillaws.Conn) []string {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var messages []string
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if !gorillaws.IsCloseError(err, gorillaws.CloseNormalClosure, gorillaws.CloseNoStatusReceived) {
+				t.Errorf("the socket was not closed: %v", err)
+			}
+			return messages
+		}
+		for _, message := range strings.Split(string(data), "\n") {
+			if strings.TrimSpace(message) != "" {
+				messages = append(messages, message)
+			}
+		}
+	}
+}
+
+func hasType(t *testing.T, messages []string, field, value string) bool {
+	t.Helper()
+
+	for _, message := range messages {
+		var decoded map[string]interface{}
+		if err := json.Unmarshal([]byte(message), &decoded); err != nil {
+			t.Fatalf("invalid frame %q: %v", message, err)
+		}
+		if decoded[field] == value {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestHub(t *testing.T) (*websocket.Hub, *websocket.Gateway) {
+	t.Helper()
+
+	hub := websocket.NewHub()
+	go hub.Run()
+
+	gateway := websocket.NewGateway(func(uuid.UUID) ([]uuid.UUID, error) { return nil, nil })
+	return hub, gateway
+}
+
+func TestShutdown(t *testing.T) {
+	hub, gateway := newTestHub(t)
+	server := startServer(t, hub, gateway)
+	database, sqlDB := openIdleDB(t)
+
+	clients := []*gorillaws.Conn{server.dial(t, "/ws"), server.dial(t, "/ws")}
+	bot := server.dial(t, "/gateway")
+
+	waitFor(t, "the clients", func() bool {
+		stats, err := hub.Stats(context.Background())
+		return err == nil && stats.Clients == len(clients)
+	})
+	waitFor(t, "the bot", func() bool { return gateway.Stats().Clients == 1 })
+
+	response := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + server.url + "/slow")
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		response <- string(body)
+	}()
+	<-server.started
+
+	tx := database.Begin()
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+
+	// The request and then the transaction finish while shutting down
+	var committed atomic.Bool
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(server.release)
+		time.Sleep(100 * time.Millisecond)
+		committed.Store(true)
+		tx.Commit()
+	}()
+
+	// A worker finishes its iteration once cancelled, it may still use the pool
+	background := workers.NewGroup()
+	var workerStopped atomic.Bool
+	background.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(100 * time.Millisecond)
+		workerStopped.Store(true)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := shutdown(ctx, server.srv, background, hub, gateway, database); err != nil {
+		t.Fatal(err)
+	}
+
+	if !committed.Load() {
+		t.Error("shutdown returned before the transaction finished")
+	}
+	if !workerStopped.Load() {
+		t.Error("shutdown returned before the worker stopped")
+	}
+	if err := sqlDB.Ping(); err == nil {
+		t.Error("the database pool is still open")
+	}
+	if body := <-response; body != "done" {
+		t.Errorf("the request in flight got %q", body)
+	}
+
+	for i, client := range clients {
+		if !hasType(t, frames(t, client), "type", "reconnect") {
+			t.Errorf("client %d was not asked to reconnect", i)
+		}
+	}
+	if !hasType(t, frames(t, bot), "op", "reconnect") {
+		t.Error("the bot was not asked to reconnect")
+	}
+
+	if _, err := hub.Stats(context.Background()); !errors.Is(err, websocket.ErrHubStopped) {
+		t.Errorf("hub stats err = %v, want the hub stopped", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	hub, gateway := newTestHub(t)
+	server := startServer(t, hub, gateway)
+	database, _ := openIdleDB(t)
+
+	tx := database.Begin()
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	defer tx.Rollback()
+
+	const timeout = 200 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := shutdown(ctx, server.srv, workers.NewGroup(), hub, gateway, database)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "draining the database") {
+		t.Errorf("err = %v, want the drain to time out", err)
+	}
+	if elapsed < timeout || elapsed > timeout+time.Second {
+		t.Errorf("shutdown took %s with a timeout of %s", elapsed, timeout)
+	}
+}
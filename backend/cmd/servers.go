@@ -0,0 +1,119 @@
+package main
+
+import (
+	"discord-backend/internal/app/services"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const serverUsage = `usage: main server [flags] list [limit] [offset] | inspect <server-id> | transfer <server-id> <user> | delete <server-id>
+
+<user> is an id, email or name. Deleted servers can be restored by their owner
+until the retention period is over.`
+
+// SERVER_LIST_LIMIT is how many servers list prints without a limit
+const SERVER_LIST_LIMIT = 100
+
+func runServer(args []string) {
+	f, args := openFactory(args, serverUsage, 1)
+	adminService := f.NewAdminService()
+
+	switch args[0] {
+	case "list":
+		limit, offset := SERVER_LIST_LIMIT, 0
+		numbers := []*int{&limit, &offset}
+		for i, arg := range args[1:] {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 || i >= len(numbers) {
+				log.Fatal(serverUsage)
+			}
+			*numbers[i] = n
+		}
+
+		servers, err := adminService.ListServers(limit, offset)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tOWNER\tMEMBERS\tCHANNELS\tCREATED AT\tDELETED AT")
+		for _, server := range servers {
+			deletedAt := "-"
+			if server.DeletedAt != nil {
+				deletedAt = server.DeletedAt.Local().Format(timeLayout)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n", server.ID, server.Name, server.OwnerName,
+				server.Members, server.Channels, server.CreatedAt.Local().Format(timeLayout), deletedAt)
+		}
+		w.Flush()
+	case "inspect":
+		if len(args) < 2 {
+			log.Fatal(serverUsage)
+		}
+
+		server, err := adminService.GetServer(parseID("server", args[1]))
+		if err != nil {
+			log.Fatal("Could not find the server: ", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "ID\t%s\n", server.ID)
+		fmt.Fprintf(w, "Name\t%s\n", server.Name)
+		fmt.Fprintf(w, "Owner\t%s (%s)\n", server.Profile.Name, server.ProfileID)
+		fmt.Fprintf(w, "Invite code\t%s\n", server.InviteCode)
+		fmt.Fprintf(w, "Require MFA\t%t\n", server.RequireMFA)
+		fmt.Fprintf(w, "Created at\t%s\n", server.CreatedAt.Local().Format(timeLayout))
+		if server.DeletedAt.Valid {
+			fmt.Fprintf(w, "Deleted at\t%s\n", server.DeletedAt.Time.Local().Format(timeLayout))
+			fmt.Fprintf(w, "Purged at\t%s\n", server.DeletedAt.Time.Add(services.SOFT_DELETE_RETENTION).Local().Format(timeLayout))
+		}
+
+		fmt.Fprintf(w, "\nCHANNEL\tTYPE\tID\n")
+		for _, channel := range server.Channels {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", channel.Name, channel.Type, channel.ID)
+		}
+
+		fmt.Fprintf(w, "\nMEMBER\tROLE\tPROFILE ID\n")
+		for _, member := range server.Members {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", member.Profile.Name, member.Role, member.ProfileID)
+		}
+		w.Flush()
+	case "transfer":
+		if len(args) < 3 {
+			log.Fatal(serverUsage)
+		}
+
+		serverID := parseID("server", args[1])
+		profile := findProfile(f, args[2])
+		server, err := adminService.TransferServer(serverID, profile.ID)
+		if err != nil {
+			log.Fatal("Could not transfer the server: ", err)
+		}
+		fmt.Printf("Transferred %s to %s\n", server.Name, profile.Name)
+	case "delete":
+		if len(args) < 2 {
+			log.Fatal(serverUsage)
+		}
+
+		server, err := adminService.GetServer(parseID("server", args[1]))
+		if err != nil {
+			log.Fatal("Could not find the server: ", err)
+		}
+
+		if server.DeletedAt.Valid {
+			log.Fatalf("%s is already deleted", server.Name)
+		}
+
+		if _, err := f.NewServerService().DeleteServer(server.ProfileID, server.ID); err != nil {
+			log.Fatal("Could not delete the server: ", err)
+		}
+		fmt.Printf("Deleted %s, its owner can restore it until %s\n", server.Name,
+			time.Now().Add(services.SOFT_DELETE_RETENTION).Local().Format(timeLayout))
+	default:
+		log.Fatal(serverUsage)
+	}
+}
@@ -0,0 +1,101 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	MessageCreated EventType = "message.created"
+	MessageUpdated EventType = "message.updated"
+	MessageDeleted EventType = "message.deleted"
+	MemberJoined   EventType = "member.joined"
+	MemberLeft     EventType = "member.left"
+	MemberKicked   EventType = "member.kicked"
+	ChannelCreated EventType = "channel.created"
+	ChannelDeleted EventType = "channel.deleted"
+)
+
+var AllEventTypes = []EventType{
+	MessageCreated,
+	MessageUpdated,
+	MessageDeleted,
+	MemberJoined,
+	MemberLeft,
+	MemberKicked,
+	ChannelCreated,
+	ChannelDeleted,
+}
+
+func IsValidEventType(eventType EventType) bool {
+	for _, t := range AllEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	ID         uuid.UUID   `json:"id"`
+	Type       EventType   `json:"type"`
+	ServerID   uuid.UUID   `json:"serverId"`
+	Data       interface{} `json:"data"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	// ProfileID is the profile that joined or left on member events
+	ProfileID uuid.UUID `json:"-"`
+}
+
+type Handler func(Event)
+
+// Bus fans server events out to the subscribers, handlers are called on the
+// publishing goroutine so they must not block
+type Bus struct {
+	handlers []Handler
+	sync.RWMutex
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(handler Handler) {
+	b.Lock()
+	defer b.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *Bus) Publish(eventType EventType, serverID uuid.UUID, data interface{}) {
+	b.publish(Event{
+		ID:         uuid.New(),
+		Type:       eventType,
+		ServerID:   serverID,
+		Data:       data,
+		OccurredAt: time.Now().UTC(),
+	})
+}
+
+// PublishMember publishes a member event about profileID, subscribers can
+// tell who joined or left without knowing the type of data
+func (b *Bus) PublishMember(eventType EventType, serverID, profileID uuid.UUID, data interface{}) {
+	b.publish(Event{
+		ID:         uuid.New(),
+		Type:       eventType,
+		ServerID:   serverID,
+		Data:       data,
+		OccurredAt: time.Now().UTC(),
+		ProfileID:  profileID,
+	})
+}
+
+func (b *Bus) publish(event Event) {
+	b.RLock()
+	defer b.RUnlock()
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+}
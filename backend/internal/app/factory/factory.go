@@ -1,18 +1,169 @@
 package factory
 
 import (
+	"discord-backend/internal/app/events"
 	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/mail"
+	"discord-backend/internal/app/metrics"
+	"discord-backend/internal/app/oidc"
+	"discord-backend/internal/app/ratelimit"
 	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/storage"
+	"discord-backend/internal/app/unfurl"
+	"discord-backend/internal/app/websocket"
+	"discord-backend/internal/app/workers"
+	"discord-backend/internal/config"
+	"time"
 
+	"github.com/pion/webrtc/v3"
 	"gorm.io/gorm"
 )
 
+// Incoming webhooks can post 5 messages every 2 seconds each
+const (
+	INCOMING_WEBHOOK_RATE_LIMIT  = 5
+	INCOMING_WEBHOOK_RATE_PERIOD = time.Second * 2
+)
+
+// An address gets at most 5 verification or reset emails an hour
+const (
+	MAIL_RATE_LIMIT  = 5
+	MAIL_RATE_PERIOD = time.Hour
+)
+
+// A profile gets 5 tries at the second sign in step every 5 minutes
+const (
+	MFA_RATE_LIMIT  = 5
+	MFA_RATE_PERIOD = time.Minute * 5
+)
+
+// An address gets 10 tries a minute at signing in, signing up and recovering
+// an account
+const (
+	AUTH_RATE_LIMIT  = 10
+	AUTH_RATE_PERIOD = time.Minute
+)
+
+// A profile or bot token gets 300 requests a minute on the authenticated API
+const (
+	API_RATE_LIMIT  = 300
+	API_RATE_PERIOD = time.Minute
+)
+
+// A profile or bot token can send 5 messages every 5 seconds, on top of the
+// slowmode of the channel
+const (
+	MESSAGE_RATE_LIMIT  = 5
+	MESSAGE_RATE_PERIOD = time.Second * 5
+)
+
 type Factory struct {
-	db *gorm.DB
+	config                 *config.Config
+	db                     *gorm.DB
+	bus                    *events.Bus
+	incomingWebhookLimiter *ratelimit.Limiter
+	mailLimiter            *ratelimit.Limiter
+	mfaLimiter             *ratelimit.Limiter
+	rateLimitStore         ratelimit.Store
+	storage                storage.Storage
+	mailer                 mail.Mailer
+	oidcProviders          map[string]*oidc.Provider
+	metrics                *metrics.Registry
+}
+
+func NewFactory(cfg *config.Config, db *gorm.DB) *Factory {
+	rateLimitStore := newRateLimitStore(cfg.RedisURL)
+	incomingWebhookLimiter := ratelimit.NewStoreLimiter(rateLimitStore, "incoming-webhook", INCOMING_WEBHOOK_RATE_LIMIT, INCOMING_WEBHOOK_RATE_PERIOD)
+	mailLimiter := ratelimit.NewStoreLimiter(rateLimitStore, "mail", MAIL_RATE_LIMIT, MAIL_RATE_PERIOD)
+	mfaLimiter := ratelimit.NewStoreLimiter(rateLimitStore, "mfa", MFA_RATE_LIMIT, MFA_RATE_PERIOD)
+
+	return &Factory{
+		config:                 cfg,
+		db:                     db,
+		bus:                    events.NewBus(),
+		incomingWebhookLimiter: incomingWebhookLimiter,
+		mailLimiter:            mailLimiter,
+		mfaLimiter:             mfaLimiter,
+		rateLimitStore:         rateLimitStore,
+		storage:                storage.NewLocalStorage(cfg.Uploads.Dir, cfg.Uploads.URL),
+		mailer:                 newMailer(cfg.Mail),
+		oidcProviders:          newOIDCProviders(cfg.OIDC),
+		metrics:                metrics.NewRegistry(),
+	}
+}
+
+// newRateLimitStore shares the rate limits of every instance through Redis
+// when it is configured, otherwise each instance counts on its own
+func newRateLimitStore(redisURL string) ratelimit.Store {
+	if redisURL != "" {
+		return ratelimit.NewRedisStore(redisURL)
+	}
+
+	store := ratelimit.NewMemoryStore()
+	go store.RunCleanup(time.Minute)
+	return store
+}
+
+// newMailer sends through SMTP when a host is configured, otherwise emails are
+// written to the mail directory or the log
+func newMailer(cfg config.MailConfig) mail.Mailer {
+	if cfg.SMTP.Host == "" {
+		return mail.NewLogMailer(cfg.Dir, cfg.From)
+	}
+
+	return mail.NewSMTPMailer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.From)
+}
+
+func newOIDCProviders(providers []config.OIDCProvider) map[string]*oidc.Provider {
+	result := make(map[string]*oidc.Provider, len(providers))
+	for _, provider := range providers {
+		result[provider.Name] = oidc.NewProvider(oidc.Config{
+			Name:         provider.Name,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+			Scopes:       provider.Scopes,
+		})
+	}
+
+	return result
+}
+
+func (f *Factory) Config() *config.Config {
+	return f.config
+}
+
+func (f *Factory) Events() *events.Bus {
+	return f.bus
+}
+
+func (f *Factory) Storage() storage.Storage {
+	return f.storage
+}
+
+func (f *Factory) Metrics() *metrics.Registry {
+	return f.metrics
+}
+
+// NewRateLimiter counts in the shared store, name keeps its buckets apart
+func (f *Factory) NewRateLimiter(name string, limit int, period time.Duration) *ratelimit.Limiter {
+	return ratelimit.NewStoreLimiter(f.rateLimitStore, name, limit, period)
 }
 
-func NewFactory(db *gorm.DB) *Factory {
-	return &Factory{db: db}
+// NewHub relays WebRTC media through the configured TURN servers and counts
+// the socket messages of each profile in the shared store
+func (f *Factory) NewHub() *websocket.Hub {
+	hub := websocket.NewHub()
+	hub.Limiter = f.NewRateLimiter("socket", websocket.SOCKET_RATE_LIMIT, websocket.SOCKET_RATE_PERIOD)
+	if len(f.config.TURN.URLs) > 0 {
+		hub.ICEServers = []webrtc.ICEServer{{
+			URLs:       f.config.TURN.URLs,
+			Username:   f.config.TURN.Username,
+			Credential: f.config.TURN.Password,
+		}}
+	}
+	return hub
 }
 
 func (f *Factory) NewProfileService() *services.ProfileService {
@@ -47,6 +198,98 @@ func (f *Factory) NewDirectMessageService() *services.DirectMessageService {
 	return services.NewDirectMessageService(f.db)
 }
 
+func (f *Factory) NewRetentionService() *services.RetentionService {
+	return services.NewRetentionService(f.db)
+}
+
+func (f *Factory) NewPurger(interval time.Duration) *workers.Purger {
+	retentionService := f.NewRetentionService()
+	return workers.NewPurger(retentionService, interval)
+}
+
+func (f *Factory) NewKeyRotator(interval time.Duration) *workers.KeyRotator {
+	return workers.NewKeyRotator(interval)
+}
+
+func (f *Factory) NewSessionSweeper(hub *websocket.Hub, interval time.Duration) *workers.SessionSweeper {
+	tokenService := f.NewTokenService()
+	return workers.NewSessionSweeper(tokenService, hub, interval)
+}
+
+func (f *Factory) NewScheduledMessageService() *services.ScheduledMessageService {
+	return services.NewScheduledMessageService(f.db)
+}
+
+func (f *Factory) NewScheduler(hub *websocket.Hub, interval time.Duration) *workers.Scheduler {
+	scheduledMessageService := f.NewScheduledMessageService()
+	return workers.NewScheduler(scheduledMessageService, hub, f.bus, interval)
+}
+
+func (f *Factory) NewWebhookService() *services.WebhookService {
+	return services.NewWebhookService(f.db)
+}
+
+func (f *Factory) NewIncomingWebhookService() *services.IncomingWebhookService {
+	return services.NewIncomingWebhookService(f.db)
+}
+
+func (f *Factory) NewBotService() *services.BotService {
+	return services.NewBotService(f.db)
+}
+
+func (f *Factory) NewInteractionService() *services.InteractionService {
+	return services.NewInteractionService(f.db)
+}
+
+func (f *Factory) NewRelationshipService() *services.RelationshipService {
+	return services.NewRelationshipService(f.db)
+}
+
+func (f *Factory) NewPrivacyService() *services.PrivacyService {
+	return services.NewPrivacyService(f.db)
+}
+
+func (f *Factory) NewAccountService() *services.AccountService {
+	return services.NewAccountService(f.db, f.mailer, f.config.AppURL, f.config.SecretKey)
+}
+
+func (f *Factory) NewMFAService() *services.MFAService {
+	return services.NewMFAService(f.db)
+}
+
+func (f *Factory) NewSSOService() *services.SSOService {
+	return services.NewSSOService(f.db, f.oidcProviders)
+}
+
+func (f *Factory) NewAdminService() *services.AdminService {
+	return services.NewAdminService(f.db)
+}
+
+func (f *Factory) NewEmojiService() *services.EmojiService {
+	return services.NewEmojiService(f.db, f.storage)
+}
+
+func (f *Factory) NewLinkPreviewService() *services.LinkPreviewService {
+	return services.NewLinkPreviewService(f.db)
+}
+
+func (f *Factory) NewUnfurler(hub *websocket.Hub) *workers.Unfurler {
+	linkPreviewService := f.NewLinkPreviewService()
+	return workers.NewUnfurler(linkPreviewService, unfurl.NewFetcher(), hub)
+}
+
+func (f *Factory) NewGateway() *websocket.Gateway {
+	botService := f.NewBotService()
+	gateway := websocket.NewGateway(botService.GetBotServerIDs)
+	gateway.Limiter = f.NewRateLimiter("gateway", websocket.SOCKET_RATE_LIMIT, websocket.SOCKET_RATE_PERIOD)
+	return gateway
+}
+
+func (f *Factory) NewWebhookDispatcher(interval time.Duration) *workers.WebhookDispatcher {
+	webhookService := f.NewWebhookService()
+	return workers.NewWebhookDispatcher(webhookService, interval)
+}
+
 func (f *Factory) NewProfileHandler() *handlers.ProfileHandler {
 	profileService := f.NewProfileService()
 	return handlers.NewProfileHandler(profileService)
@@ -55,22 +298,24 @@ func (f *Factory) NewProfileHandler() *handlers.ProfileHandler {
 func (f *Factory) NewAuthHandler() *handlers.AuthHandler {
 	profileService := f.NewProfileService()
 	tokenService := f.NewTokenService()
-	return handlers.NewAuthHandler(profileService, tokenService)
+	accountService := f.NewAccountService()
+	mfaService := f.NewMFAService()
+	return handlers.NewAuthHandler(profileService, tokenService, accountService, mfaService, f.mailLimiter, f.mfaLimiter)
 }
 
 func (f *Factory) NewServerHandler() *handlers.ServerHandler {
 	serverService := f.NewServerService()
-	return handlers.NewServerHandler(serverService)
+	return handlers.NewServerHandler(serverService, f.bus)
 }
 
 func (f *Factory) NewMemberHandler() *handlers.MemberHandler {
 	memberService := f.NewMemberService()
-	return handlers.NewMemberHandler(memberService)
+	return handlers.NewMemberHandler(memberService, f.bus)
 }
 
 func (f *Factory) NewChannelHandler() *handlers.ChannelHandler {
 	channelService := f.NewChannelService()
-	return handlers.NewChannelHandler(channelService)
+	return handlers.NewChannelHandler(channelService, f.bus)
 }
 
 func (f *Factory) NewConversationHandler() *handlers.ConversationHandler {
@@ -85,15 +330,77 @@ func (f *Factory) NewWebsocketHandler() *handlers.WebsocketHandler {
 	messageService := f.NewMessageService()
 	directMessageService := f.NewDirectMessageService()
 	profileService := f.NewProfileService()
-	return handlers.NewWebsocketHandler(serverService, conversationService, channelService, messageService, directMessageService, profileService)
+	return handlers.NewWebsocketHandler(serverService, conversationService, channelService, messageService, directMessageService, profileService, f.bus)
 }
 
 func (f *Factory) NewMessageHandler() *handlers.MessageHandler {
 	messageService := f.NewMessageService()
-	return handlers.NewMessageHandler(messageService)
+	memberService := f.NewMemberService()
+	return handlers.NewMessageHandler(messageService, memberService)
 }
 
 func (f *Factory) NewDirectMessageHandler() *handlers.DirectMessageHandler {
 	directMessageService := f.NewDirectMessageService()
-	return handlers.NewDirectMessageHandler(directMessageService)
+	conversationService := f.NewConversationService()
+	return handlers.NewDirectMessageHandler(directMessageService, conversationService)
+}
+
+func (f *Factory) NewScheduledMessageHandler() *handlers.ScheduledMessageHandler {
+	scheduledMessageService := f.NewScheduledMessageService()
+	memberService := f.NewMemberService()
+	channelService := f.NewChannelService()
+	conversationService := f.NewConversationService()
+	return handlers.NewScheduledMessageHandler(scheduledMessageService, memberService, channelService, conversationService)
+}
+
+func (f *Factory) NewWebhookHandler() *handlers.WebhookHandler {
+	webhookService := f.NewWebhookService()
+	return handlers.NewWebhookHandler(webhookService)
+}
+
+func (f *Factory) NewIncomingWebhookHandler() *handlers.IncomingWebhookHandler {
+	incomingWebhookService := f.NewIncomingWebhookService()
+	return handlers.NewIncomingWebhookHandler(incomingWebhookService, f.bus, f.incomingWebhookLimiter)
+}
+
+func (f *Factory) NewBotHandler() *handlers.BotHandler {
+	botService := f.NewBotService()
+	profileService := f.NewProfileService()
+	return handlers.NewBotHandler(botService, profileService, f.bus)
+}
+
+func (f *Factory) NewInteractionHandler() *handlers.InteractionHandler {
+	interactionService := f.NewInteractionService()
+	return handlers.NewInteractionHandler(interactionService, f.bus)
+}
+
+func (f *Factory) NewEmojiHandler() *handlers.EmojiHandler {
+	emojiService := f.NewEmojiService()
+	return handlers.NewEmojiHandler(emojiService)
+}
+
+func (f *Factory) NewRelationshipHandler() *handlers.RelationshipHandler {
+	relationshipService := f.NewRelationshipService()
+	return handlers.NewRelationshipHandler(relationshipService)
+}
+
+func (f *Factory) NewPrivacyHandler() *handlers.PrivacyHandler {
+	privacyService := f.NewPrivacyService()
+	return handlers.NewPrivacyHandler(privacyService)
+}
+
+func (f *Factory) NewMFAHandler() *handlers.MFAHandler {
+	mfaService := f.NewMFAService()
+	return handlers.NewMFAHandler(mfaService)
+}
+
+func (f *Factory) NewSSOHandler() *handlers.SSOHandler {
+	ssoService := f.NewSSOService()
+	profileService := f.NewProfileService()
+	tokenService := f.NewTokenService()
+	return handlers.NewSSOHandler(ssoService, profileService, tokenService, f.config.AppURL)
+}
+
+func (f *Factory) NewHealthHandler(hub *websocket.Hub, gateway *websocket.Gateway) *handlers.HealthHandler {
+	return handlers.NewHealthHandler(f.db, hub, gateway, f.metrics, f.config.Server.MetricsToken)
 }
@@ -1,37 +1,91 @@
 package handlers
 
 import (
+	"discord-backend/internal/app/middleware"
 	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/ratelimit"
 	"discord-backend/internal/app/services"
 	"discord-backend/internal/app/utils"
 	customErrors "discord-backend/internal/app/utils"
+	ws "discord-backend/internal/app/websocket"
 	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
 	ProfileService *services.ProfileService
 	TokenService   *services.TokenService
+	AccountService *services.AccountService
+	MFAService     *services.MFAService
+	// MailLimiter caps the emails sent to one address
+	MailLimiter *ratelimit.Limiter
+	// MFALimiter caps the second step attempts of one profile
+	MFALimiter *ratelimit.Limiter
 }
 
-func NewAuthHandler(profileService *services.ProfileService, tokenService *services.TokenService) *AuthHandler {
+func NewAuthHandler(
+	profileService *services.ProfileService,
+	tokenService *services.TokenService,
+	accountService *services.AccountService,
+	mfaService *services.MFAService,
+	mailLimiter *ratelimit.Limiter,
+	mfaLimiter *ratelimit.Limiter,
+) *AuthHandler {
 	return &AuthHandler{
 		ProfileService: profileService,
 		TokenService:   tokenService,
+		AccountService: accountService,
+		MFAService:     mfaService,
+		MailLimiter:    mailLimiter,
+		MFALimiter:     mfaLimiter,
+	}
+}
+
+func accountError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, customErrors.ErrInvalidAccountToken), errors.Is(err, customErrors.ErrInvalidPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + ": " + err.Error()})
+	}
+}
+
+// allowMail answers 429 when too many emails went to the address lately
+func (h *AuthHandler) allowMail(c *gin.Context, email string) bool {
+	allowed, retryAfter := h.MailLimiter.Allow(strings.ToLower(email))
+	if !allowed {
+		middleware.TooManyRequests(c, retryAfter, "Too many emails, try again later")
+	}
+	return allowed
+}
+
+// deviceInfo describes the device of the request for its session, clients
+// can name it, the user agent is used otherwise
+func deviceInfo(c *gin.Context, deviceName string) services.DeviceInfo {
+	return services.DeviceInfo{
+		Name:      strings.TrimSpace(deviceName),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
 	}
 }
 
 func (h *AuthHandler) SignUp(c *gin.Context) {
 	// var profile models.Profile
 	var profile struct {
-		Name     string `json:"name"`
-		ImageURL string `json:"imageUrl"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Name       string `json:"name"`
+		ImageURL   string `json:"imageUrl"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		DeviceName string `json:"deviceName"`
 	}
 	if err := c.ShouldBindJSON(&profile); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -54,20 +108,22 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 		return
 	}
 
-	tokens, err := services.GenerateTokens(currentProfile.ID, currentProfile.Name)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating JWT token"})
-		return
+	// Signing up still works when the email cannot be sent, the user can ask again
+	if allowed, _ := h.MailLimiter.Allow(strings.ToLower(currentProfile.Email)); allowed {
+		if err := h.AccountService.SendVerificationEmail(currentProfile.ID); err != nil {
+			log.Printf("Error sending verification email to %s: %v", currentProfile.ID, err)
+		}
 	}
 
-	if err := h.TokenService.UpsertRefreshToken(currentProfile.ID, tokens["refreshToken"]); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error upsert JWT token to database"})
+	tokens, _, err := h.TokenService.StartSession(currentProfile.ID, currentProfile.Name, deviceInfo(c, profile.DeviceName))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error starting session"})
 		return
 	}
 
 	host := c.Request.Host
 	domain := utils.ExtractBaseDomain(host)
-	maxAge := int(time.Hour * 72 / time.Second)
+	maxAge := int(services.REFRESH_TOKEN_TTL / time.Second)
 	c.SetCookie("refresh_token", tokens["refreshToken"], maxAge, "/", domain, true, true)
 	c.SetCookie("access_token", tokens["accessToken"], maxAge, "/", domain, false, true)
 	c.JSON(http.StatusOK, gin.H{"message": "Registration successful", "profile": currentProfile})
@@ -75,8 +131,9 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 
 func (h *AuthHandler) SignIn(c *gin.Context) {
 	var credentials struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		DeviceName string `json:"deviceName"`
 	}
 	if err := c.ShouldBindJSON(&credentials); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -85,18 +142,30 @@ func (h *AuthHandler) SignIn(c *gin.Context) {
 
 	profile, err := h.ProfileService.Authenticate(credentials.Email, credentials.Password)
 	if err != nil {
+		if errors.Is(err, customErrors.ErrAccountDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	tokens, err := services.GenerateTokens(profile.ID, profile.Name)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating JWT token"})
+	// The session only starts after the second step
+	if profile.MFAEnabled {
+		challenge, err := services.GenerateMFAChallenge(profile.ID, credentials.DeviceName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating MFA challenge"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication required", "mfaRequired": true, "challenge": challenge})
 		return
 	}
 
-	if err := h.TokenService.UpsertRefreshToken(profile.ID, tokens["refreshToken"]); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error upsert JWT token to database"})
+	// Every sign in is a new session, other devices stay signed in
+	tokens, _, err := h.TokenService.StartSession(profile.ID, profile.Name, deviceInfo(c, credentials.DeviceName))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error starting session"})
 		return
 	}
 
@@ -104,135 +173,390 @@ func (h *AuthHandler) SignIn(c *gin.Context) {
 
 	host := c.Request.Host
 	domain := utils.ExtractBaseDomain(host)
-	maxAge := int(time.Hour * 72 / time.Second)
+	maxAge := int(services.REFRESH_TOKEN_TTL / time.Second)
 	c.SetCookie("refresh_token", tokens["refreshToken"], maxAge, "/", domain, true, true)
 	c.SetCookie("access_token", tokens["accessToken"], maxAge, "/", domain, false, true)
 	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "profile": profile})
 }
 
-func (h *AuthHandler) SignOut(c *gin.Context) {
-	var signOutRequest struct {
-		ProfileID string `json:"profileId"`
+// SignInMFA finishes signing in with the challenge from SignIn and a TOTP or
+// recovery code
+func (h *AuthHandler) SignInMFA(c *gin.Context) {
+	var input struct {
+		Challenge    string `json:"challenge" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
 	}
-
-	if err := c.ShouldBindJSON(&signOutRequest); err != nil {
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	profileID, err := uuid.Parse(signOutRequest.ProfileID)
+	profileID, deviceName, err := services.VerifyMFAChallenge(input.Challenge)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse UUID from claims"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	refreshTokenCookie, err := c.Request.Cookie("refresh_token")
-	if err == nil {
-		h.TokenService.DeleteRefreshToken(profileID, refreshTokenCookie.Value)
+	if allowed, retryAfter := h.MFALimiter.Allow(profileID.String()); !allowed {
+		middleware.TooManyRequests(c, retryAfter, "Too many attempts, try again later")
+		return
+	}
+
+	if err := h.MFAService.Verify(profileID, input.Code, input.RecoveryCode); err != nil {
+		if errors.Is(err, customErrors.ErrInvalidMFA) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verifying code: " + err.Error()})
+		return
+	}
+
+	profile, err := h.ProfileService.GetProfileByID(profileID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	tokens, _, err := h.TokenService.StartSession(profile.ID, profile.Name, deviceInfo(c, deviceName))
+	if errors.Is(err, customErrors.ErrAccountDisabled) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error starting session"})
+		return
 	}
 
 	host := c.Request.Host
 	domain := utils.ExtractBaseDomain(host)
-	c.SetCookie("access_token", "", -1, "/", domain, false, true)
-	c.SetCookie("refresh_token", "", -1, "/", domain, true, true)
+	maxAge := int(services.REFRESH_TOKEN_TTL / time.Second)
+	c.SetCookie("refresh_token", tokens["refreshToken"], maxAge, "/", domain, true, true)
+	c.SetCookie("access_token", tokens["accessToken"], maxAge, "/", domain, false, true)
+	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "profile": profile})
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+// SignOut ends the session of the refresh cookie and closes its sockets
+func (h *AuthHandler) SignOut(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		refreshTokenCookie, err := c.Request.Cookie("refresh_token")
+		if err == nil {
+			session, err := h.TokenService.EndSession(refreshTokenCookie.Value)
+			if err != nil {
+				log.Printf("Error ending session: %v", err)
+			}
+
+			if session != nil {
+				hub.DisconnectSessions(session.ProfileID, session.ID)
+			}
+		}
+
+		host := c.Request.Host
+		domain := utils.ExtractBaseDomain(host)
+		c.SetCookie("access_token", "", -1, "/", domain, false, true)
+		c.SetCookie("refresh_token", "", -1, "/", domain, true, true)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	}
 }
 
-func (h *AuthHandler) Refresh(c *gin.Context) {
-	tx := h.TokenService.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+// Refresh rotates the refresh token. Presenting one that was already rotated
+// revokes its whole session, whoever holds the newer token is signed out too
+func (h *AuthHandler) Refresh(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		refreshTokenCookie, err := c.Request.Cookie("refresh_token")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing refresh token"})
+			return
 		}
-	}()
 
-	if err := tx.Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction start error"})
-		return
+		host := c.Request.Host
+		domain := utils.ExtractBaseDomain(host)
+
+		tokens, session, err := h.TokenService.RotateRefreshToken(refreshTokenCookie.Value, deviceInfo(c, ""))
+		if err != nil {
+			switch {
+			case errors.Is(err, customErrors.ErrRefreshTokenReused):
+				hub.DisconnectSessions(session.ProfileID, session.ID)
+				c.SetCookie("access_token", "", -1, "/", domain, false, true)
+				c.SetCookie("refresh_token", "", -1, "/", domain, true, true)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reused, session revoked"})
+			case errors.Is(err, customErrors.ErrInvalidSession):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh tokens: " + err.Error()})
+			}
+			return
+		}
+
+		maxAge := int(services.REFRESH_TOKEN_TTL / time.Second)
+		c.SetCookie("refresh_token", tokens["refreshToken"], maxAge, "/", domain, true, true)
+		c.SetCookie("access_token", tokens["accessToken"], maxAge, "/", domain, false, true)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Tokens refreshed"})
 	}
+}
 
+func (h *AuthHandler) ServerRefresh(c *gin.Context) {
 	refreshTokenCookie, err := c.Request.Cookie("refresh_token")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing refresh token"})
 		return
 	}
 
-	claims, err := services.VerifyToken(refreshTokenCookie.Value)
+	tokens, err := h.TokenService.AccessTokens(refreshTokenCookie.Value)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		if errors.Is(err, customErrors.ErrInvalidSession) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 		return
 	}
 
-	profileIDString := claims["profile_id"].(string)
-	name := claims["name"].(string)
-	profileID, err := uuid.Parse(profileIDString)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse UUID from claims"})
+	c.JSON(http.StatusOK, gin.H{"message": "Tokens refreshed", "access_token": tokens})
+}
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	if err := h.TokenService.FindRefreshToken(profileID, refreshTokenCookie.Value); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refresh token"})
+	if err := h.AccountService.VerifyEmail(input.Token); err != nil {
+		accountError(c, err, "verifying email")
 		return
 	}
 
-	tokens, err := services.GenerateTokens(profileID, name)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
 		return
 	}
 
-	if err := h.TokenService.UpsertRefreshToken(profileID, tokens["refreshToken"]); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error upsert JWT token to database"})
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
 		return
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit error"})
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
 		return
 	}
 
-	maxAge := int(time.Hour * 72 / time.Second)
-	host := c.Request.Host
-	domain := utils.ExtractBaseDomain(host)
-	c.SetCookie("refresh_token", tokens["refreshToken"], maxAge, "/", domain, true, true)
-	c.SetCookie("access_token", tokens["accessToken"], maxAge, "/", domain, false, true)
+	if !h.allowMail(c, profileID.String()) {
+		return
+	}
+
+	if err := h.AccountService.SendVerificationEmail(profileID); err != nil {
+		accountError(c, err, "sending verification email")
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Tokens refreshed"})
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
 }
 
-func (h *AuthHandler) ServerRefresh(c *gin.Context) {
-	refreshTokenCookie, err := c.Request.Cookie("refresh_token")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing refresh token"})
+// ForgotPassword answers the same whether or not the email has an account
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	claims, err := services.VerifyToken(refreshTokenCookie.Value)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+	if !h.allowMail(c, input.Email) {
 		return
 	}
 
-	profileIDString := claims["profile_id"].(string)
-	name := claims["name"].(string)
-	profileID, err := uuid.Parse(profileIDString)
+	if err := h.AccountService.RequestPasswordReset(input.Email); err != nil {
+		accountError(c, err, "requesting password reset")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email belongs to an account, a reset link has been sent"})
+}
+
+func (h *AuthHandler) ResetPassword(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Token    string `json:"token" binding:"required"`
+			Password string `json:"password" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		profileID, err := h.AccountService.ResetPassword(input.Token, input.Password)
+		if err != nil {
+			accountError(c, err, "resetting password")
+			return
+		}
+
+		hub.DisconnectSessions(profileID)
+
+		host := c.Request.Host
+		domain := utils.ExtractBaseDomain(host)
+		c.SetCookie("access_token", "", -1, "/", domain, false, true)
+		c.SetCookie("refresh_token", "", -1, "/", domain, true, true)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+	}
+}
+
+// currentSession is the session of the access token, nil for bots
+func currentSession(c *gin.Context) uuid.UUID {
+	sessionIDInterface, exists := c.Get("session_id")
+	if !exists {
+		return uuid.Nil
+	}
+
+	sessionIDStr, ok := sessionIDInterface.(string)
+	if !ok {
+		return uuid.Nil
+	}
+
+	sessionID, err := uuid.Parse(sessionIDStr)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse UUID from claims"})
+		return uuid.Nil
+	}
+
+	return sessionID
+}
+
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
 		return
 	}
 
-	if err := h.TokenService.FindRefreshToken(profileID, refreshTokenCookie.Value); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refresh token"})
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
 		return
 	}
 
-	tokens, err := services.GenerateTokens(profileID, name)
+	profileID, err := uuid.Parse(profileIDStr)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Tokens refreshed", "access_token": tokens})
+	sessions, err := h.TokenService.GetSessions(profileID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting sessions: " + err.Error()})
+		return
+	}
+
+	current := currentSession(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get sessions successfully", "sessions": sessions})
+}
+
+func (h *AuthHandler) RevokeSession(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		sessionID, err := uuid.Parse(c.Param("sessionId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sessionId"})
+			return
+		}
+
+		session, err := h.TokenService.RevokeSession(profileID, sessionID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking session: " + err.Error()})
+			return
+		}
+
+		hub.DisconnectSessions(profileID, session.ID)
+
+		if session.ID == currentSession(c) {
+			host := c.Request.Host
+			domain := utils.ExtractBaseDomain(host)
+			c.SetCookie("access_token", "", -1, "/", domain, false, true)
+			c.SetCookie("refresh_token", "", -1, "/", domain, true, true)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully", "session": session})
+	}
+}
+
+// RevokeSessions signs the profile out of every device, this one included
+func (h *AuthHandler) RevokeSessions(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		sessionIDs, err := h.TokenService.RevokeSessions(profileID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking sessions: " + err.Error()})
+			return
+		}
+
+		hub.DisconnectSessions(profileID)
+
+		host := c.Request.Host
+		domain := utils.ExtractBaseDomain(host)
+		c.SetCookie("access_token", "", -1, "/", domain, false, true)
+		c.SetCookie("refresh_token", "", -1, "/", domain, true, true)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully", "revoked": len(sessionIDs)})
+	}
+}
+
+// JWKS publishes the public keys access tokens are signed with
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": services.JWKS()})
 }
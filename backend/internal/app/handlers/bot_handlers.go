@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"discord-backend/internal/app/events"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"log"
+	"net/http"
+
+	ws "discord-backend/internal/app/websocket"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BotHandler struct {
+	BotService     *services.BotService
+	ProfileService *services.ProfileService
+	Events         *events.Bus
+}
+
+func NewBotHandler(botService *services.BotService, profileService *services.ProfileService, bus *events.Bus) *BotHandler {
+	return &BotHandler{BotService: botService, ProfileService: profileService, Events: bus}
+}
+
+func botError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, utils.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only server admins can add bots"})
+	case errors.Is(err, utils.ErrInvalidBot):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, utils.ErrEmailOrUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bot not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + ": " + err.Error()})
+	}
+}
+
+func (h *BotHandler) CreateBot(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	var input struct {
+		Name     string `json:"name"`
+		ImageURL string `json:"imageUrl"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	bot, botToken, token, err := h.BotService.CreateBot(profileID, input.Name, input.ImageURL)
+	if err != nil {
+		botError(c, err, "creating bot")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bot created successfully", "bot": bot, "botToken": botToken, "token": token})
+}
+
+func (h *BotHandler) GetBots(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	bots, err := h.BotService.GetBots(profileID)
+	if err != nil {
+		botError(c, err, "getting bots")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get bots successfully", "bots": bots})
+}
+
+func (h *BotHandler) CreateBotToken(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	botID, err := uuid.Parse(c.Param("botId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Bot UUID format"})
+		return
+	}
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	botToken, token, err := h.BotService.CreateBotToken(profileID, botID, input.Name)
+	if err != nil {
+		botError(c, err, "creating bot token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bot token created successfully", "botToken": botToken, "token": token})
+}
+
+func (h *BotHandler) GetBotTokens(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	botID, err := uuid.Parse(c.Param("botId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Bot UUID format"})
+		return
+	}
+
+	botTokens, err := h.BotService.GetBotTokens(profileID, botID)
+	if err != nil {
+		botError(c, err, "getting bot tokens")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get bot tokens successfully", "botTokens": botTokens})
+}
+
+func (h *BotHandler) RevokeBotToken(gateway *ws.Gateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDString, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDString)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		botID, err := uuid.Parse(c.Param("botId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Bot UUID format"})
+			return
+		}
+
+		tokenID, err := uuid.Parse(c.Param("tokenId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Token UUID format"})
+			return
+		}
+
+		if err := h.BotService.RevokeBotToken(profileID, botID, tokenID); err != nil {
+			botError(c, err, "revoking bot token")
+			return
+		}
+
+		gateway.DisconnectToken(tokenID)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Bot token revoked successfully"})
+	}
+}
+
+func (h *BotHandler) AddBotToServer(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	var input struct {
+		BotID uuid.UUID `json:"botId"`
+		Role  string    `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var role models.MemberRole
+	switch input.Role {
+	case string(models.Admin):
+		role = models.Admin
+	case string(models.Moderator):
+		role = models.Moderator
+	case string(models.Guest), "":
+		role = models.Guest
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role value"})
+		return
+	}
+
+	member, created, err := h.BotService.AddBotToServer(serverID, profileID, input.BotID, role)
+	if err != nil {
+		botError(c, err, "adding bot to server")
+		return
+	}
+
+	if created {
+		h.Events.PublishMember(events.MemberJoined, serverID, member.ProfileID, member)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bot added to server successfully", "member": member})
+}
+
+// Gateway upgrades a bot connection to the event stream, there is no
+// joined/participants handshake like on /ws
+func (h *BotHandler) Gateway(gateway *ws.Gateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileID, err := uuid.Parse(c.GetString("profile_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		tokenID, err := uuid.Parse(c.GetString("bot_token_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "bot token not found"})
+			return
+		}
+
+		profile, err := h.ProfileService.GetProfileByID(profileID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bot not found"})
+			return
+		}
+
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			http.Error(c.Writer, "Could not upgrade to WebSocket", http.StatusBadRequest)
+			return
+		}
+
+		if err := gateway.Connect(conn, profileID, tokenID, profile); err != nil {
+			log.Printf("Error connecting bot %s to the gateway: %v", profileID, err)
+			conn.Close()
+		}
+	}
+}
@@ -1,20 +1,25 @@
 package handlers
 
 import (
+	"discord-backend/internal/app/events"
 	"discord-backend/internal/app/models"
 	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type ChannelHandler struct {
 	ChannelService *services.ChannelService
+	Events         *events.Bus
 }
 
-func NewChannelHandler(channelService *services.ChannelService) *ChannelHandler {
-	return &ChannelHandler{ChannelService: channelService}
+func NewChannelHandler(channelService *services.ChannelService, bus *events.Bus) *ChannelHandler {
+	return &ChannelHandler{ChannelService: channelService, Events: bus}
 }
 
 func (h *ChannelHandler) CreateChannel(c *gin.Context) {
@@ -70,12 +75,14 @@ func (h *ChannelHandler) CreateChannel(c *gin.Context) {
 		return
 	}
 
-	server, err := h.ChannelService.CreateChannel(serverID, profileID, channelData.Name, channelType)
+	server, channel, err := h.ChannelService.CreateChannel(serverID, profileID, channelData.Name, channelType)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	h.Events.Publish(events.ChannelCreated, serverID, channel)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Channel created successfully", "server": server})
 }
 
@@ -118,9 +125,58 @@ func (h *ChannelHandler) DeleteChannel(c *gin.Context) {
 		return
 	}
 
+	h.Events.Publish(events.ChannelDeleted, serverID, gin.H{"channelId": channelID, "deletedBy": profileID})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Channel delete successfully", "server": server})
 }
 
+func (h *ChannelHandler) RestoreChannel(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	paramServerID := c.Param("serverId")
+	serverID, err := uuid.Parse(paramServerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	paramChannelID := c.Param("channelId")
+	channelID, err := uuid.Parse(paramChannelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Channel UUID format"})
+		return
+	}
+
+	server, err := h.ChannelService.RestoreChannel(serverID, profileID, channelID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found or retention period is over"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Channel restored successfully", "server": server})
+}
+
 func (h *ChannelHandler) UpdateChannel(c *gin.Context) {
 	profileIDInterface, exists := c.Get("profile_id")
 	if !exists {
@@ -154,14 +210,23 @@ func (h *ChannelHandler) UpdateChannel(c *gin.Context) {
 		return
 	}
 
-	var updateData models.Channel
-	if err := c.ShouldBindJSON(&updateData); err != nil {
+	var input struct {
+		Name     string             `json:"name"`
+		Type     models.ChannelType `json:"type"`
+		Slowmode *int               `json:"slowmode"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	server, err := h.ChannelService.UpdateChannel(serverID, profileID, channelID, updateData)
+	updateData := models.Channel{Name: input.Name, Type: input.Type}
+	server, err := h.ChannelService.UpdateChannel(serverID, profileID, channelID, updateData, input.Slowmode)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidSlowmode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
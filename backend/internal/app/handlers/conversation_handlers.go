@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"discord-backend/internal/app/models"
 	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	ws "discord-backend/internal/app/websocket"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -18,8 +22,130 @@ func NewConversationHandler(conversationService *services.ConversationService) *
 	return &ConversationHandler{ConversationService: conversationService}
 }
 
+func conversationError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, utils.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, utils.ErrInvalidConversation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + ": " + err.Error()})
+	}
+}
+
+// notifyParticipants pushes the new state of a conversation to every
+// participant, and tells the ones that were removed
+func notifyParticipants(hub *ws.Hub, conversation *models.Conversation, removed ...uuid.UUID) {
+	channelKey := fmt.Sprintf("conversation:%s", conversation.ID)
+
+	for _, participant := range conversation.Participants {
+		hub.SendToProfile(participant.ProfileID, ws.Message{
+			Type:    "conversation:update",
+			Channel: channelKey,
+			Content: conversation,
+		})
+	}
+
+	for _, profileID := range removed {
+		hub.SendToProfile(profileID, ws.Message{
+			Type:    "conversation:delete",
+			Channel: channelKey,
+			Content: gin.H{"id": conversation.ID},
+		})
+	}
+}
+
+func (s *ConversationHandler) GetConversations(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	// Message requests are kept out of the inbox and listed on their own
+	requests := c.Query("requests") == "true"
+
+	conversations, err := s.ConversationService.GetConversations(profileID, requests)
+	if err != nil {
+		conversationError(c, err, "getting conversations")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get conversations successfully", "conversations": conversations})
+}
+
+func (s *ConversationHandler) GetConversation(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	conversationID, err := uuid.Parse(c.Param("conversationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversationId"})
+		return
+	}
+
+	conversation, err := s.ConversationService.GetConversation(conversationID, profileID)
+	if err != nil {
+		conversationError(c, err, "getting conversation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get conversation successfully", "conversation": conversation})
+}
+
+// GetOrCreateConversation opens the 1:1 conversation with profileId. The
+// memberOneId and memberTwoId of server members are still accepted and
+// resolved to their profiles
 func (s *ConversationHandler) GetOrCreateConversation(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
 	var req struct {
+		ProfileID   uuid.UUID `json:"profileId"`
 		MemberOneID uuid.UUID `json:"memberOneId"`
 		MemberTwoID uuid.UUID `json:"memberTwoId"`
 	}
@@ -28,27 +154,58 @@ func (s *ConversationHandler) GetOrCreateConversation(c *gin.Context) {
 		return
 	}
 
-	conversation, err := s.ConversationService.FindConversation(req.MemberOneID, req.MemberTwoID)
-	if err == nil {
-		c.JSON(http.StatusOK, gin.H{"message": "Get conversation successfully", "conversation": conversation})
-		return
-	}
-
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		conversation, err := s.ConversationService.CreateConversation(req.MemberOneID, req.MemberTwoID)
+	otherProfileID := req.ProfileID
+	if otherProfileID == uuid.Nil {
+		profileIDs, err := s.ConversationService.GetMemberProfileIDs(req.MemberOneID, req.MemberTwoID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			conversationError(c, err, "getting members")
+			return
+		}
+
+		switch profileID {
+		case profileIDs[0]:
+			otherProfileID = profileIDs[1]
+		case profileIDs[1]:
+			otherProfileID = profileIDs[0]
+		default:
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only open your own conversations"})
 			return
 		}
+	}
 
+	conversation, created, err := s.ConversationService.GetOrCreateConversation(profileID, otherProfileID)
+	if err != nil {
+		conversationError(c, err, "getting conversation")
+		return
+	}
+
+	if created {
 		c.JSON(http.StatusOK, gin.H{"message": "Create conversation successfully", "conversation": conversation})
 		return
 	}
 
-	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	c.JSON(http.StatusOK, gin.H{"message": "Get conversation successfully", "conversation": conversation})
 }
 
 func (s *ConversationHandler) FindConversation(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
 	memberOneIdStr := c.Param("memberOneId")
 	memberTwoIdStr := c.Param("memberTwoId")
 
@@ -64,11 +221,285 @@ func (s *ConversationHandler) FindConversation(c *gin.Context) {
 		return
 	}
 
-	conversation, err := s.ConversationService.FindConversation(memberOneId, memberTwoId)
+	profileIDs, err := s.ConversationService.GetMemberProfileIDs(memberOneId, memberTwoId)
+	if err != nil {
+		conversationError(c, err, "getting members")
+		return
+	}
+
+	if profileIDs[0] != profileID && profileIDs[1] != profileID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only open your own conversations"})
+		return
+	}
+
+	conversation, err := s.ConversationService.FindConversation(profileIDs[0], profileIDs[1])
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		conversationError(c, err, "getting conversation")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Get conversation successfully", "conversation": conversation})
 }
+
+func (s *ConversationHandler) CreateGroupConversation(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		var input struct {
+			Name       string      `json:"name"`
+			ImageURL   string      `json:"imageUrl"`
+			ProfileIDs []uuid.UUID `json:"profileIds"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		conversation, err := s.ConversationService.CreateGroupConversation(profileID, input.Name, input.ImageURL, input.ProfileIDs)
+		if err != nil {
+			conversationError(c, err, "creating conversation")
+			return
+		}
+
+		notifyParticipants(hub, conversation)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Create conversation successfully", "conversation": conversation})
+	}
+}
+
+func (s *ConversationHandler) UpdateConversation(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		conversationID, err := uuid.Parse(c.Param("conversationId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversationId"})
+			return
+		}
+
+		var input struct {
+			Name     *string `json:"name"`
+			ImageURL *string `json:"imageUrl"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		conversation, err := s.ConversationService.UpdateConversation(conversationID, profileID, input.Name, input.ImageURL)
+		if err != nil {
+			conversationError(c, err, "updating conversation")
+			return
+		}
+
+		notifyParticipants(hub, conversation)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Conversation updated successfully", "conversation": conversation})
+	}
+}
+
+func (s *ConversationHandler) AddParticipant(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		conversationID, err := uuid.Parse(c.Param("conversationId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversationId"})
+			return
+		}
+
+		var input struct {
+			ProfileID uuid.UUID `json:"profileId" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		conversation, err := s.ConversationService.AddParticipant(conversationID, profileID, input.ProfileID)
+		if err != nil {
+			conversationError(c, err, "adding participant")
+			return
+		}
+
+		notifyParticipants(hub, conversation)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Participant added successfully", "conversation": conversation})
+	}
+}
+
+func (s *ConversationHandler) RemoveParticipant(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		conversationID, err := uuid.Parse(c.Param("conversationId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversationId"})
+			return
+		}
+
+		targetID, err := uuid.Parse(c.Param("profileId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profileId"})
+			return
+		}
+
+		conversation, err := s.ConversationService.RemoveParticipant(conversationID, profileID, targetID)
+		if err != nil {
+			conversationError(c, err, "removing participant")
+			return
+		}
+
+		if conversation == nil {
+			notifyParticipants(hub, &models.Conversation{ID: conversationID}, targetID)
+		} else {
+			notifyParticipants(hub, conversation, targetID)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Participant removed successfully", "conversation": conversation})
+	}
+}
+
+func (s *ConversationHandler) AcceptConversation(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		conversationID, err := uuid.Parse(c.Param("conversationId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversationId"})
+			return
+		}
+
+		conversation, err := s.ConversationService.AcceptConversation(conversationID, profileID)
+		if err != nil {
+			conversationError(c, err, "accepting conversation")
+			return
+		}
+
+		notifyParticipants(hub, conversation)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Conversation accepted successfully", "conversation": conversation})
+	}
+}
+
+func (s *ConversationHandler) LeaveConversation(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		conversationID, err := uuid.Parse(c.Param("conversationId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversationId"})
+			return
+		}
+
+		conversation, err := s.ConversationService.LeaveConversation(conversationID, profileID)
+		if err != nil {
+			conversationError(c, err, "leaving conversation")
+			return
+		}
+
+		if conversation == nil {
+			notifyParticipants(hub, &models.Conversation{ID: conversationID}, profileID)
+		} else {
+			notifyParticipants(hub, conversation, profileID)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Left conversation successfully"})
+	}
+}
@@ -11,10 +11,14 @@ import (
 
 type DirectMessageHandler struct {
 	DirectMessageService *services.DirectMessageService
+	ConversationService  *services.ConversationService
 }
 
-func NewDirectMessageHandler(directMessageService *services.DirectMessageService) *DirectMessageHandler {
-	return &DirectMessageHandler{DirectMessageService: directMessageService}
+func NewDirectMessageHandler(directMessageService *services.DirectMessageService, conversationService *services.ConversationService) *DirectMessageHandler {
+	return &DirectMessageHandler{
+		DirectMessageService: directMessageService,
+		ConversationService:  conversationService,
+	}
 }
 
 func (h *DirectMessageHandler) GetDirectMessages(c *gin.Context) {
@@ -31,7 +35,36 @@ func (h *DirectMessageHandler) GetDirectMessages(c *gin.Context) {
 		return
 	}
 
-	directMessages, nextCursor, err := h.DirectMessageService.GetDirectMessages(conversationID, cursor)
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	// People who left a group no longer see its history
+	if _, err := h.ConversationService.GetConversation(conversationID, profileID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting conversation: " + err.Error()})
+		return
+	}
+
+	directMessages, nextCursor, err := h.DirectMessageService.GetDirectMessages(conversationID, profileID, cursor)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err == gorm.ErrRecordNotFound {
@@ -47,3 +80,111 @@ func (h *DirectMessageHandler) GetDirectMessages(c *gin.Context) {
 		"nextCursor": nextCursor,
 	})
 }
+
+func (h *DirectMessageHandler) GetDirectMessageRevisions(c *gin.Context) {
+	paramDirectMessageID := c.Param("directMessageId")
+	directMessageID, err := uuid.Parse(paramDirectMessageID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Direct Message UUID format"})
+		return
+	}
+
+	conversationIDStr := c.Query("conversationId")
+	if conversationIDStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing conversationId"})
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversationId"})
+		return
+	}
+
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	// Every participant of the conversation can read the history
+	if _, err := h.ConversationService.GetConversation(conversationID, profileID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting conversation: " + err.Error()})
+		return
+	}
+
+	revisions, err := h.DirectMessageService.GetDirectMessageRevisions(conversationID, directMessageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get direct message revisions successfully", "revisions": revisions})
+}
+
+func (h *DirectMessageHandler) GetPinnedDirectMessages(c *gin.Context) {
+	conversationIDStr := c.Query("conversationId")
+	if conversationIDStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing conversationId"})
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversationId"})
+		return
+	}
+
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	if _, err := h.ConversationService.GetConversation(conversationID, profileID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting conversation: " + err.Error()})
+		return
+	}
+
+	directMessages, err := h.DirectMessageService.GetPinnedDirectMessages(conversationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get pinned direct messages successfully", "items": directMessages})
+}
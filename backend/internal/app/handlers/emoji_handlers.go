@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type EmojiHandler struct {
+	EmojiService *services.EmojiService
+}
+
+func NewEmojiHandler(emojiService *services.EmojiService) *EmojiHandler {
+	return &EmojiHandler{EmojiService: emojiService}
+}
+
+func emojiError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, utils.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to manage emojis"})
+	case errors.Is(err, utils.ErrInvalidEmoji):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Emoji not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + ": " + err.Error()})
+	}
+}
+
+// CreateEmoji takes a multipart form with a name and an image file
+func (h *EmojiHandler) CreateEmoji(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	// Room for the form fields on top of the image itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MAX_EMOJI_SIZE+64*1024)
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An image of at most %d KB is required", services.MAX_EMOJI_SIZE/1024)})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, services.MAX_EMOJI_SIZE+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image"})
+		return
+	}
+
+	emoji, err := h.EmojiService.CreateEmoji(serverID, profileID, c.PostForm("name"), data)
+	if err != nil {
+		emojiError(c, err, "creating emoji")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Emoji created successfully", "emoji": emoji})
+}
+
+func (h *EmojiHandler) GetEmojis(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	emojis, err := h.EmojiService.GetEmojis(serverID, profileID)
+	if err != nil {
+		emojiError(c, err, "getting emojis")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get emojis successfully", "emojis": emojis})
+}
+
+func (h *EmojiHandler) UpdateEmoji(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	emojiID, err := uuid.Parse(c.Param("emojiId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Emoji UUID format"})
+		return
+	}
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	emoji, err := h.EmojiService.UpdateEmoji(serverID, profileID, emojiID, input.Name)
+	if err != nil {
+		emojiError(c, err, "updating emoji")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Emoji updated successfully", "emoji": emoji})
+}
+
+func (h *EmojiHandler) DeleteEmoji(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	emojiID, err := uuid.Parse(c.Param("emojiId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Emoji UUID format"})
+		return
+	}
+
+	if err := h.EmojiService.DeleteEmoji(serverID, profileID, emojiID); err != nil {
+		emojiError(c, err, "deleting emoji")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Emoji deleted successfully"})
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"discord-backend/internal/app/metrics"
+	ws "discord-backend/internal/app/websocket"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// READY_TIMEOUT bounds each readiness check and the hub snapshot of a scrape
+const READY_TIMEOUT = time.Second * 2
+
+type HealthHandler struct {
+	DB           *gorm.DB
+	Hub          *ws.Hub
+	Gateway      *ws.Gateway
+	Registry     *metrics.Registry
+	MetricsToken string
+}
+
+func NewHealthHandler(db *gorm.DB, hub *ws.Hub, gateway *ws.Gateway, registry *metrics.Registry, metricsToken string) *HealthHandler {
+	return &HealthHandler{DB: db, Hub: hub, Gateway: gateway, Registry: registry, MetricsToken: metricsToken}
+}
+
+// Healthz answers as long as the process serves requests
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz checks the database answers and the hub loop is running, a load
+// balancer stops sending traffic while it fails
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), READY_TIMEOUT)
+	defer cancel()
+
+	checks := gin.H{"database": "ok", "hub": "ok"}
+	ready := true
+
+	sqlDB, err := h.DB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		checks["database"] = err.Error()
+		ready = false
+	}
+
+	if _, err := h.Hub.Stats(ctx); err != nil {
+		checks["hub"] = err.Error()
+		ready = false
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "checks": checks})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+}
+
+func (h *HealthHandler) Metrics(c *gin.Context) {
+	if h.MetricsToken != "" {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.MetricsToken)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid metrics token"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), READY_TIMEOUT)
+	defer cancel()
+
+	// A stopped hub still lets the other metrics through
+	hubStats, err := h.Hub.Stats(ctx)
+	if err != nil {
+		log.Printf("Error reading hub stats: %v", err)
+	}
+
+	c.Header("Content-Type", metrics.CONTENT_TYPE)
+	c.Status(http.StatusOK)
+	if err := h.Registry.Write(c.Writer, h.collectHub(hubStats, err == nil), h.collectGateway, h.collectDB); err != nil {
+		log.Printf("Error writing metrics: %v", err)
+	}
+}
+
+func (h *HealthHandler) collectHub(stats ws.HubStats, running bool) metrics.Collector {
+	return func(w *metrics.Writer) {
+		up := 0.0
+		if running {
+			up = 1
+		}
+		w.Gauge("websocket_hub_up", "Whether the hub loop answered the scrape.", up)
+		if !running {
+			return
+		}
+
+		w.Gauge("websocket_clients", "Connected websocket clients.", float64(stats.Clients))
+		w.GaugeBy("websocket_channel_subscriptions", "Clients subscribed to each channel.", "channel", stats.Subscriptions)
+		w.GaugeBy("websocket_hub_queue_depth", "Messages waiting for the hub loop, by queue.", "queue", stats.Queues)
+		w.Counter("websocket_dropped_messages_total", "Messages dropped because the client was too slow, the client is disconnected.", float64(stats.DroppedMessages))
+		w.GaugeBy("webrtc_peer_connections", "Active peer connections by voice channel.", "channel", stats.PeerConnections)
+		w.GaugeBy("webrtc_tracks", "Tracks fanned out by voice channel.", "channel", stats.Tracks)
+		w.Counter("webrtc_rtp_packets_forwarded_total", "RTP packets forwarded from a sender to its voice channel.", float64(stats.RTPPacketsForwarded))
+	}
+}
+
+func (h *HealthHandler) collectGateway(w *metrics.Writer) {
+	stats := h.Gateway.Stats()
+	w.Gauge("gateway_clients", "Connected bot gateway clients.", float64(stats.Clients))
+	w.Counter("gateway_dropped_messages_total", "Events dropped because the bot was too slow, the bot is disconnected.", float64(stats.DroppedMessages))
+}
+
+func (h *HealthHandler) collectDB(w *metrics.Writer) {
+	sqlDB, err := h.DB.DB()
+	if err != nil {
+		return
+	}
+
+	stats := sqlDB.Stats()
+	w.Gauge("db_max_open_connections", "Maximum number of open database connections, 0 is unlimited.", float64(stats.MaxOpenConnections))
+	w.GaugeBy("db_connections", "Open database connections by state.", "state", map[string]int{
+		"in_use": stats.InUse,
+		"idle":   stats.Idle,
+	})
+	w.Counter("db_wait_count_total", "Times a query waited for a free connection.", float64(stats.WaitCount))
+	w.Counter("db_wait_duration_seconds_total", "Time spent waiting for a free connection.", stats.WaitDuration.Seconds())
+	w.Counter("db_max_idle_closed_total", "Connections closed because the idle pool was full.", float64(stats.MaxIdleClosed))
+	w.Counter("db_max_idle_time_closed_total", "Connections closed because they were idle too long.", float64(stats.MaxIdleTimeClosed))
+	w.Counter("db_max_lifetime_closed_total", "Connections closed because they reached their maximum lifetime.", float64(stats.MaxLifetimeClosed))
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"discord-backend/internal/app/events"
+	"discord-backend/internal/app/middleware"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/ratelimit"
+	"discord-backend/internal/app/services"
+	"errors"
+	"fmt"
+	"net/http"
+
+	ws "discord-backend/internal/app/websocket"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type IncomingWebhookHandler struct {
+	IncomingWebhookService *services.IncomingWebhookService
+	Events                 *events.Bus
+	Limiter                *ratelimit.Limiter
+}
+
+func NewIncomingWebhookHandler(
+	incomingWebhookService *services.IncomingWebhookService,
+	bus *events.Bus,
+	limiter *ratelimit.Limiter,
+) *IncomingWebhookHandler {
+	return &IncomingWebhookHandler{
+		IncomingWebhookService: incomingWebhookService,
+		Events:                 bus,
+		Limiter:                limiter,
+	}
+}
+
+func (h *IncomingWebhookHandler) CreateIncomingWebhook(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	channelID, err := uuid.Parse(c.Param("channelId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Channel UUID format"})
+		return
+	}
+
+	var input struct {
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatarUrl"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	webhook, token, err := h.IncomingWebhookService.CreateIncomingWebhook(serverID, channelID, profileID, input.Name, input.AvatarURL)
+	if err != nil {
+		webhookError(c, err, "creating webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook created successfully",
+		"webhook": webhook,
+		"token":   token,
+		"url":     fmt.Sprintf("/webhooks/%s/%s", webhook.ID, token),
+	})
+}
+
+func (h *IncomingWebhookHandler) GetIncomingWebhooks(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	channelID, err := uuid.Parse(c.Param("channelId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Channel UUID format"})
+		return
+	}
+
+	webhooks, err := h.IncomingWebhookService.GetIncomingWebhooks(serverID, channelID, profileID)
+	if err != nil {
+		webhookError(c, err, "getting webhooks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get webhooks successfully", "webhooks": webhooks})
+}
+
+func (h *IncomingWebhookHandler) UpdateIncomingWebhook(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	channelID, err := uuid.Parse(c.Param("channelId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Channel UUID format"})
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Webhook UUID format"})
+		return
+	}
+
+	var input struct {
+		Name      *string `json:"name"`
+		AvatarURL *string `json:"avatarUrl"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	webhook, err := h.IncomingWebhookService.UpdateIncomingWebhook(serverID, channelID, profileID, webhookID, input.Name, input.AvatarURL)
+	if err != nil {
+		webhookError(c, err, "updating webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook updated successfully", "webhook": webhook})
+}
+
+func (h *IncomingWebhookHandler) DeleteIncomingWebhook(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	channelID, err := uuid.Parse(c.Param("channelId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Channel UUID format"})
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Webhook UUID format"})
+		return
+	}
+
+	if err := h.IncomingWebhookService.DeleteIncomingWebhook(serverID, channelID, profileID, webhookID); err != nil {
+		webhookError(c, err, "deleting webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// ExecuteIncomingWebhook is not behind the AuthMiddleware, the token in the
+// url is the credential
+func (h *IncomingWebhookHandler) ExecuteIncomingWebhook(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhookID, err := uuid.Parse(c.Param("webhookId"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+
+		webhook, err := h.IncomingWebhookService.GetIncomingWebhookByToken(webhookID, c.Param("token"))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting webhook: " + err.Error()})
+			return
+		}
+
+		if allowed, retryAfter := h.Limiter.Allow(webhook.ID.String()); !allowed {
+			middleware.TooManyRequests(c, retryAfter, "Rate limited")
+			return
+		}
+
+		var input struct {
+			Content   string         `json:"content"`
+			Username  string         `json:"username"`
+			AvatarURL string         `json:"avatarUrl"`
+			Embeds    []models.Embed `json:"embeds"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		message, err := h.IncomingWebhookService.ExecuteIncomingWebhook(webhook, input.Content, input.Username, input.AvatarURL, input.Embeds)
+		if err != nil {
+			webhookError(c, err, "executing webhook")
+			return
+		}
+
+		// Same broadcast as WebSocketMessageHandler
+		hub.BroadcastToChannel(ws.Message{
+			Type:    "message",
+			Channel: fmt.Sprintf("chat:%s:messages", message.ChannelID),
+			Content: message,
+		})
+		h.Events.Publish(events.MessageCreated, webhook.ServerID, message)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Message created successfully", "data": message})
+	}
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"discord-backend/internal/app/events"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+	"net/http"
+
+	ws "discord-backend/internal/app/websocket"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type InteractionHandler struct {
+	InteractionService *services.InteractionService
+	Events             *events.Bus
+}
+
+func NewInteractionHandler(interactionService *services.InteractionService, bus *events.Bus) *InteractionHandler {
+	return &InteractionHandler{InteractionService: interactionService, Events: bus}
+}
+
+func interactionError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, utils.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to " + action})
+	case errors.Is(err, utils.ErrInvalidCommand), errors.Is(err, utils.ErrInvalidInteraction), errors.Is(err, utils.ErrInvalidBot):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + ": " + err.Error()})
+	}
+}
+
+// deliverResponse shows the recorded response to the server, or only to the
+// invoker for an ephemeral one
+func (h *InteractionHandler) deliverResponse(hub *ws.Hub, interaction *models.Interaction, response *models.InteractionResponse, message *models.Message) {
+	channelKey := fmt.Sprintf("chat:%s:messages", interaction.ChannelID)
+
+	switch response.Type {
+	case models.ResponseMessage:
+		hub.BroadcastToChannel(ws.Message{
+			Type:     "message",
+			Channel:  channelKey,
+			Content:  message,
+			SenderID: message.AuthorProfileID(),
+		})
+		h.Events.Publish(events.MessageCreated, interaction.ServerID, message)
+	case models.ResponseEphemeral:
+		hub.SendToProfile(interaction.ProfileID, ws.Message{
+			Type:     "interaction:ephemeral",
+			Channel:  channelKey,
+			ServerID: interaction.ServerID.String(),
+			Content: gin.H{
+				"interactionId": interaction.ID,
+				"content":       response.Data.Content,
+				"embeds":        response.Data.Embeds,
+			},
+		})
+	}
+}
+
+func (h *InteractionHandler) UpsertApplication(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	botID, err := uuid.Parse(c.Param("botId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Bot UUID format"})
+		return
+	}
+
+	var input struct {
+		CallbackURL string `json:"callbackUrl"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	application, secret, err := h.InteractionService.UpsertApplication(profileID, botID, input.CallbackURL)
+	if err != nil {
+		interactionError(c, err, "saving application")
+		return
+	}
+
+	response := gin.H{"message": "Application saved successfully", "application": application}
+	if secret != "" {
+		response["secret"] = secret
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *InteractionHandler) GetApplication(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	botID, err := uuid.Parse(c.Param("botId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Bot UUID format"})
+		return
+	}
+
+	application, err := h.InteractionService.GetApplication(profileID, botID)
+	if err != nil {
+		interactionError(c, err, "getting application")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get application successfully", "application": application})
+}
+
+func (h *InteractionHandler) RotateApplicationSecret(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	botID, err := uuid.Parse(c.Param("botId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Bot UUID format"})
+		return
+	}
+
+	secret, err := h.InteractionService.RotateApplicationSecret(profileID, botID)
+	if err != nil {
+		interactionError(c, err, "rotating application secret")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Application secret rotated successfully", "secret": secret})
+}
+
+func (h *InteractionHandler) RegisterCommand(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	var input struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Options     []models.CommandOption `json:"options"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	command, err := h.InteractionService.RegisterCommand(profileID, serverID, input.Name, input.Description, input.Options)
+	if err != nil {
+		interactionError(c, err, "registering command")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Command registered successfully", "command": command})
+}
+
+func (h *InteractionHandler) GetCommands(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	commands, err := h.InteractionService.GetCommands(serverID, profileID)
+	if err != nil {
+		interactionError(c, err, "getting commands")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get commands successfully", "commands": commands})
+}
+
+func (h *InteractionHandler) DeleteCommand(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	commandID, err := uuid.Parse(c.Param("commandId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Command UUID format"})
+		return
+	}
+
+	if err := h.InteractionService.DeleteCommand(serverID, profileID, commandID); err != nil {
+		interactionError(c, err, "deleting command")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Command deleted successfully"})
+}
+
+// WebSocketInteractionHandler is the slash command counterpart of
+// WebSocketMessageHandler, the application has INTERACTION_CALLBACK_TIMEOUT
+// to answer or defer before the interaction fails
+func (h *InteractionHandler) WebSocketInteractionHandler(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Command string                 `json:"command"`
+			Options map[string]interface{} `json:"options"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		serverIDStr := c.Query("serverId")
+		channelIDStr := c.Query("channelId")
+		if serverIDStr == "" || channelIDStr == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing serverId or channelId"})
+			return
+		}
+
+		serverID, err := uuid.Parse(serverIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid serverId"})
+			return
+		}
+
+		channelID, err := uuid.Parse(channelIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channelId"})
+			return
+		}
+
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		interaction, payload, err := h.InteractionService.CreateInteraction(serverID, channelID, profileID, input.Command, input.Options)
+		if err != nil {
+			interactionError(c, err, "using this command")
+			return
+		}
+
+		response, err := h.InteractionService.SendInteraction(c.Request.Context(), payload)
+		if err != nil {
+			h.InteractionService.FailInteraction(interaction, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+
+		message, err := h.InteractionService.RecordResponse(interaction, response)
+		if err != nil {
+			h.InteractionService.FailInteraction(interaction, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+
+		h.deliverResponse(hub, interaction, response, message)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Interaction sent successfully",
+			"interaction": interaction.ID,
+			"response":    response,
+			"data":        message,
+		})
+	}
+}
+
+// RespondToInteraction is called with the bot token to answer an interaction
+// it deferred
+func (h *InteractionHandler) RespondToInteraction(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileID, err := uuid.Parse(c.GetString("profile_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		interactionID, err := uuid.Parse(c.Param("interactionId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Interaction UUID format"})
+			return
+		}
+
+		var response models.InteractionResponse
+		if err := c.ShouldBindJSON(&response); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		interaction, err := h.InteractionService.GetDeferredInteraction(profileID, interactionID)
+		if err != nil {
+			interactionError(c, err, "responding to interaction")
+			return
+		}
+
+		message, err := h.InteractionService.RecordResponse(interaction, &response)
+		if err != nil {
+			interactionError(c, err, "responding to interaction")
+			return
+		}
+
+		h.deliverResponse(hub, interaction, &response, message)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Interaction responded successfully", "data": message})
+	}
+}
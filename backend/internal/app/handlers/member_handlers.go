@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"discord-backend/internal/app/events"
 	"discord-backend/internal/app/models"
 	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,10 +14,11 @@ import (
 
 type MemberHandler struct {
 	MemberService *services.MemberService
+	Events        *events.Bus
 }
 
-func NewMemberHandler(memberService *services.MemberService) *MemberHandler {
-	return &MemberHandler{MemberService: memberService}
+func NewMemberHandler(memberService *services.MemberService, bus *events.Bus) *MemberHandler {
+	return &MemberHandler{MemberService: memberService, Events: bus}
 }
 
 func (m *MemberHandler) UpdateMemberRole(c *gin.Context) {
@@ -113,12 +117,18 @@ func (m *MemberHandler) KickMember(c *gin.Context) {
 		return
 	}
 
-	server, err := m.MemberService.KickMember(serverID, profileID, memberID)
+	server, kickedProfileID, err := m.MemberService.KickMember(serverID, profileID, memberID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, utils.ErrMFARequired) {
+			statusCode = http.StatusForbidden
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
 		return
 	}
 
+	m.Events.PublishMember(events.MemberKicked, serverID, kickedProfileID, gin.H{"memberId": memberID, "kickedBy": profileID})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Delete member successfully", "server": server})
 }
 
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"discord-backend/internal/app/models"
 	"discord-backend/internal/app/services"
 	"net/http"
 
@@ -11,10 +12,14 @@ import (
 
 type MessageHandler struct {
 	MessageService *services.MessageService
+	MemberService  *services.MemberService
 }
 
-func NewMessageHandler(messageService *services.MessageService) *MessageHandler {
-	return &MessageHandler{MessageService: messageService}
+func NewMessageHandler(messageService *services.MessageService, memberService *services.MemberService) *MessageHandler {
+	return &MessageHandler{
+		MessageService: messageService,
+		MemberService:  memberService,
+	}
 }
 
 func (h *MessageHandler) GetMessages(c *gin.Context) {
@@ -31,7 +36,25 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 		return
 	}
 
-	messages, nextCursor, err := h.MessageService.GetMessages(channelID, cursor)
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	messages, nextCursor, err := h.MessageService.GetMessages(channelID, profileID, cursor)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err == gorm.ErrRecordNotFound {
@@ -47,3 +70,131 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 		"nextCursor": nextCursor,
 	})
 }
+
+func (h *MessageHandler) GetMessageRevisions(c *gin.Context) {
+	paramMessageID := c.Param("messageId")
+	messageID, err := uuid.Parse(paramMessageID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Message UUID format"})
+		return
+	}
+
+	serverIDStr := c.Query("serverId")
+	channelIDStr := c.Query("channelId")
+	if serverIDStr == "" || channelIDStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing serverId or channelId"})
+		return
+	}
+
+	serverID, err := uuid.Parse(serverIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid serverId"})
+		return
+	}
+
+	channelID, err := uuid.Parse(channelIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channelId"})
+		return
+	}
+
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	member, err := h.MemberService.GetMember(serverID, profileID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	// Moderators can read the history of any message, members only their own
+	isAdmin := member.Role == models.Admin
+	isModerator := member.Role == models.Moderator
+	if !isAdmin && !isModerator {
+		message, err := h.MessageService.GetMessage(channelID, messageID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+
+		if !message.IsAuthor(member.ID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+	}
+
+	revisions, err := h.MessageService.GetMessageRevisions(serverID, channelID, messageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get message revisions successfully", "revisions": revisions})
+}
+
+func (h *MessageHandler) GetPinnedMessages(c *gin.Context) {
+	serverIDStr := c.Query("serverId")
+	channelIDStr := c.Query("channelId")
+	if serverIDStr == "" || channelIDStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing serverId or channelId"})
+		return
+	}
+
+	serverID, err := uuid.Parse(serverIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid serverId"})
+		return
+	}
+
+	channelID, err := uuid.Parse(channelIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channelId"})
+		return
+	}
+
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	if _, err := h.MemberService.GetMember(serverID, profileID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	messages, err := h.MessageService.GetPinnedMessages(serverID, channelID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get pinned messages successfully", "items": messages})
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type MFAHandler struct {
+	MFAService *services.MFAService
+}
+
+func NewMFAHandler(mfaService *services.MFAService) *MFAHandler {
+	return &MFAHandler{MFAService: mfaService}
+}
+
+func mfaError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidMFA):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + ": " + err.Error()})
+	}
+}
+
+func (h *MFAHandler) GetMFA(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	status, err := h.MFAService.GetStatus(profileID)
+	if err != nil {
+		mfaError(c, err, "getting two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get two-factor authentication successfully", "mfa": status})
+}
+
+// EnrollTOTP returns a new secret and its otpauth URI, it only takes effect
+// after ActivateTOTP
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	secret, uri, err := h.MFAService.EnrollTOTP(profileID)
+	if err != nil {
+		mfaError(c, err, "enrolling authenticator")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scan the code and confirm it to enable two-factor authentication", "secret": secret, "uri": uri})
+}
+
+func (h *MFAHandler) ActivateTOTP(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	recoveryCodes, err := h.MFAService.ActivateTOTP(profileID, input.Code)
+	if err != nil {
+		mfaError(c, err, "enabling two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled successfully", "recoveryCodes": recoveryCodes})
+}
+
+func (h *MFAHandler) DisableMFA(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.MFAService.Disable(profileID, input.Code, input.RecoveryCode); err != nil {
+		mfaError(c, err, "disabling two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled successfully"})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	recoveryCodes, err := h.MFAService.RegenerateRecoveryCodes(profileID, input.Code)
+	if err != nil {
+		mfaError(c, err, "regenerating recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recovery codes regenerated successfully", "recoveryCodes": recoveryCodes})
+}
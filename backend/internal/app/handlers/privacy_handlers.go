@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PrivacyHandler struct {
+	PrivacyService *services.PrivacyService
+}
+
+func NewPrivacyHandler(privacyService *services.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{PrivacyService: privacyService}
+}
+
+func privacyError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidPrivacySettings):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + ": " + err.Error()})
+	}
+}
+
+func (h *PrivacyHandler) GetPrivacySettings(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	settings, err := h.PrivacyService.GetPrivacySettings(profileID)
+	if err != nil {
+		privacyError(c, err, "getting privacy settings")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get privacy settings successfully", "privacy": settings})
+}
+
+func (h *PrivacyHandler) UpdatePrivacySettings(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	var input struct {
+		AllowServerDMs  *bool                       `json:"allowServerDMs"`
+		FriendRequests  *models.FriendRequestPolicy `json:"friendRequests"`
+		MessageRequests *bool                       `json:"messageRequests"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	settings, err := h.PrivacyService.UpdatePrivacySettings(profileID, input.AllowServerDMs, input.FriendRequests, input.MessageRequests)
+	if err != nil {
+		privacyError(c, err, "updating privacy settings")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Privacy settings updated successfully", "privacy": settings})
+}
+
+func (h *PrivacyHandler) SetServerOverride(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid serverId"})
+		return
+	}
+
+	var input struct {
+		AllowDMs *bool `json:"allowDMs" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	settings, err := h.PrivacyService.SetServerOverride(profileID, serverID, *input.AllowDMs)
+	if err != nil {
+		privacyError(c, err, "updating server privacy")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Server privacy updated successfully", "privacy": settings})
+}
+
+func (h *PrivacyHandler) DeleteServerOverride(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid serverId"})
+		return
+	}
+
+	settings, err := h.PrivacyService.DeleteServerOverride(profileID, serverID)
+	if err != nil {
+		privacyError(c, err, "resetting server privacy")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Server privacy reset successfully", "privacy": settings})
+}
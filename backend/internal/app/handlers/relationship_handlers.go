@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	ws "discord-backend/internal/app/websocket"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RelationshipHandler struct {
+	RelationshipService *services.RelationshipService
+}
+
+func NewRelationshipHandler(relationshipService *services.RelationshipService) *RelationshipHandler {
+	return &RelationshipHandler{RelationshipService: relationshipService}
+}
+
+func relationshipError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidRelationship):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + ": " + err.Error()})
+	}
+}
+
+// pushRelationship sends the new state of a relationship to one side, a nil
+// relationship tells the client to drop it
+func pushRelationship(hub *ws.Hub, profileID, targetID uuid.UUID, relationship *models.Relationship) {
+	if relationship == nil {
+		hub.SendToProfile(profileID, ws.Message{
+			Type:    "relationship:remove",
+			Content: gin.H{"targetID": targetID},
+		})
+		return
+	}
+
+	relationship.Online = relationship.Type == models.RelationshipFriend && hub.IsOnline(targetID)
+	hub.SendToProfile(profileID, ws.Message{
+		Type:    "relationship:update",
+		Content: relationship,
+	})
+}
+
+// NotifyPresence is meant for the hub OnPresence hook, friends of the profile
+// are told when it comes online and when its last connection closes
+func (h *RelationshipHandler) NotifyPresence(hub *ws.Hub) func(profileID uuid.UUID, online bool) {
+	return func(profileID uuid.UUID, online bool) {
+		friendIDs, err := h.RelationshipService.GetFriendIDs(profileID)
+		if err != nil {
+			log.Printf("Error getting friends of %s: %v", profileID, err)
+			return
+		}
+
+		for _, friendID := range friendIDs {
+			hub.SendToProfile(friendID, ws.Message{
+				Type:    "presence:update",
+				Content: gin.H{"profileID": profileID, "online": online},
+			})
+		}
+	}
+}
+
+func (h *RelationshipHandler) getRelationships(hub *ws.Hub, key string, types ...models.RelationshipType) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		relationships, err := h.RelationshipService.GetRelationships(profileID, types...)
+		if err != nil {
+			relationshipError(c, err, "getting "+key)
+			return
+		}
+
+		for i := range relationships {
+			if relationships[i].Type == models.RelationshipFriend {
+				relationships[i].Online = hub.IsOnline(relationships[i].TargetID)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Get " + key + " successfully", key: relationships})
+	}
+}
+
+func (h *RelationshipHandler) GetFriends(hub *ws.Hub) gin.HandlerFunc {
+	return h.getRelationships(hub, "friends", models.RelationshipFriend)
+}
+
+func (h *RelationshipHandler) GetFriendRequests(hub *ws.Hub) gin.HandlerFunc {
+	return h.getRelationships(hub, "requests", models.RelationshipIncoming, models.RelationshipOutgoing)
+}
+
+func (h *RelationshipHandler) GetBlocks(hub *ws.Hub) gin.HandlerFunc {
+	return h.getRelationships(hub, "blocks", models.RelationshipBlocked)
+}
+
+// relationshipAction applies a change towards the profile in the path and
+// pushes the result to whoever can see it
+func (h *RelationshipHandler) relationshipAction(hub *ws.Hub, action func(profileID, targetID uuid.UUID) (*services.RelationshipUpdate, error), message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		targetID, err := uuid.Parse(c.Param("profileId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profileId"})
+			return
+		}
+
+		update, err := action(profileID, targetID)
+		if err != nil {
+			relationshipError(c, err, "updating relationship")
+			return
+		}
+
+		// Live messages of a blocked profile are dropped per connection
+		hub.SetBlocked(profileID, targetID, update.Own != nil && update.Own.Type == models.RelationshipBlocked)
+
+		pushRelationship(hub, profileID, targetID, update.Own)
+		if update.NotifyTarget {
+			pushRelationship(hub, targetID, profileID, update.Their)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": message, "relationship": update.Own})
+	}
+}
+
+func (h *RelationshipHandler) SendFriendRequest(hub *ws.Hub) gin.HandlerFunc {
+	return h.relationshipAction(hub, h.RelationshipService.SendFriendRequest, "Friend request sent successfully")
+}
+
+func (h *RelationshipHandler) AcceptFriendRequest(hub *ws.Hub) gin.HandlerFunc {
+	return h.relationshipAction(hub, h.RelationshipService.AcceptFriendRequest, "Friend request accepted successfully")
+}
+
+func (h *RelationshipHandler) DeclineFriendRequest(hub *ws.Hub) gin.HandlerFunc {
+	return h.relationshipAction(hub, h.RelationshipService.DeclineFriendRequest, "Friend request declined successfully")
+}
+
+func (h *RelationshipHandler) CancelFriendRequest(hub *ws.Hub) gin.HandlerFunc {
+	return h.relationshipAction(hub, h.RelationshipService.CancelFriendRequest, "Friend request cancelled successfully")
+}
+
+func (h *RelationshipHandler) RemoveFriend(hub *ws.Hub) gin.HandlerFunc {
+	return h.relationshipAction(hub, h.RelationshipService.RemoveFriend, "Friend removed successfully")
+}
+
+func (h *RelationshipHandler) Block(hub *ws.Hub) gin.HandlerFunc {
+	return h.relationshipAction(hub, h.RelationshipService.Block, "User blocked successfully")
+}
+
+func (h *RelationshipHandler) Unblock(hub *ws.Hub) gin.HandlerFunc {
+	return h.relationshipAction(hub, h.RelationshipService.Unblock, "User unblocked successfully")
+}
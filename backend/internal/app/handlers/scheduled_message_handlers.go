@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ScheduledMessageHandler struct {
+	ScheduledMessageService *services.ScheduledMessageService
+	MemberService           *services.MemberService
+	ChannelService          *services.ChannelService
+	ConversationService     *services.ConversationService
+}
+
+func NewScheduledMessageHandler(
+	scheduledMessageService *services.ScheduledMessageService,
+	memberService *services.MemberService,
+	channelService *services.ChannelService,
+	conversationService *services.ConversationService,
+) *ScheduledMessageHandler {
+	return &ScheduledMessageHandler{
+		ScheduledMessageService: scheduledMessageService,
+		MemberService:           memberService,
+		ChannelService:          channelService,
+		ConversationService:     conversationService,
+	}
+}
+
+func (h *ScheduledMessageHandler) CreateScheduledMessage(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	var input struct {
+		ServerID       *uuid.UUID `json:"serverId"`
+		ChannelID      *uuid.UUID `json:"channelId"`
+		ConversationID *uuid.UUID `json:"conversationId"`
+		Content        string     `json:"content"`
+		FileURL        *string    `json:"fileUrl"`
+		SendAt         time.Time  `json:"sendAt"`
+		Recurrence     string     `json:"recurrence"`
+		Timezone       string     `json:"timezone"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if input.ChannelID != nil && input.ServerID != nil {
+		if _, err := h.MemberService.GetMember(*input.ServerID, profileID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+
+		channel, err := h.ChannelService.GetChannel(*input.ChannelID)
+		if err != nil || channel.ServerID != *input.ServerID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+	} else if input.ConversationID != nil {
+		if _, err := h.ConversationService.GetConversation(*input.ConversationID, profileID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+	}
+
+	scheduledMessage, err := h.ScheduledMessageService.CreateScheduledMessage(&models.ScheduledMessage{
+		ProfileID:      profileID,
+		ServerID:       input.ServerID,
+		ChannelID:      input.ChannelID,
+		ConversationID: input.ConversationID,
+		Content:        input.Content,
+		FileURL:        input.FileURL,
+		SendAt:         input.SendAt,
+		Recurrence:     input.Recurrence,
+		Timezone:       input.Timezone,
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidSchedule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating scheduled message: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scheduled message created successfully", "scheduledMessage": scheduledMessage})
+}
+
+func (h *ScheduledMessageHandler) GetScheduledMessages(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	scheduledMessages, err := h.ScheduledMessageService.GetScheduledMessages(profileID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting scheduled messages: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get scheduled messages successfully", "scheduledMessages": scheduledMessages})
+}
+
+func (h *ScheduledMessageHandler) UpdateScheduledMessage(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	paramScheduledMessageID := c.Param("scheduledMessageId")
+	scheduledMessageID, err := uuid.Parse(paramScheduledMessageID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Scheduled Message UUID format"})
+		return
+	}
+
+	var input struct {
+		Content    *string    `json:"content"`
+		SendAt     *time.Time `json:"sendAt"`
+		Recurrence *string    `json:"recurrence"`
+		Timezone   *string    `json:"timezone"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	scheduledMessage, err := h.ScheduledMessageService.UpdateScheduledMessage(profileID, scheduledMessageID, input.Content, input.SendAt, input.Recurrence, input.Timezone)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidSchedule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled message not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating scheduled message: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scheduled message updated successfully", "scheduledMessage": scheduledMessage})
+}
+
+func (h *ScheduledMessageHandler) CancelScheduledMessage(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	paramScheduledMessageID := c.Param("scheduledMessageId")
+	scheduledMessageID, err := uuid.Parse(paramScheduledMessageID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Scheduled Message UUID format"})
+		return
+	}
+
+	scheduledMessage, err := h.ScheduledMessageService.CancelScheduledMessage(profileID, scheduledMessageID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled message not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error canceling scheduled message: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scheduled message canceled successfully", "scheduledMessage": scheduledMessage})
+}
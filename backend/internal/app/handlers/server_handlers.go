@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"discord-backend/internal/app/events"
 	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	ws "discord-backend/internal/app/websocket"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,10 +15,11 @@ import (
 
 type ServerHandler struct {
 	ServerService *services.ServerService
+	Events        *events.Bus
 }
 
-func NewServerHandler(serverService *services.ServerService) *ServerHandler {
-	return &ServerHandler{ServerService: serverService}
+func NewServerHandler(serverService *services.ServerService, bus *events.Bus) *ServerHandler {
+	return &ServerHandler{ServerService: serverService, Events: bus}
 }
 
 func (s *ServerHandler) CreateServer(c *gin.Context) {
@@ -259,6 +264,10 @@ func (s *ServerHandler) UpdateServerMember(c *gin.Context) {
 		return
 	}
 
+	if member, err := s.ServerService.GetMember(server.ID, profileID); err == nil {
+		s.Events.PublishMember(events.MemberJoined, server.ID, member.ProfileID, member)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Update server member successfully", "server": server})
 }
 
@@ -317,19 +326,23 @@ func (s *ServerHandler) UpdateServer(c *gin.Context) {
 	}
 
 	var updateData struct {
-		Name     string `json:"name"`
-		ImageURL string `json:"imageUrl"`
+		Name       string `json:"name"`
+		ImageURL   string `json:"imageUrl"`
+		RequireMFA *bool  `json:"requireMFA"`
 	}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse request body: " + err.Error()})
 		return
 	}
 
-	server, err := s.ServerService.UpdateServer(profileID, serverID, updateData.Name, updateData.ImageURL)
+	server, err := s.ServerService.UpdateServer(profileID, serverID, updateData.Name, updateData.ImageURL, updateData.RequireMFA)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
 			return
+		} else if errors.Is(err, utils.ErrMFARequired) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update server invite code"})
 			return
@@ -376,10 +389,95 @@ func (s *ServerHandler) LeaveServer(c *gin.Context) {
 		}
 	}
 
+	s.Events.PublishMember(events.MemberLeft, serverID, profileID, gin.H{"profileId": profileID})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Update leave server successfully", "server": server})
 }
 
-func (s *ServerHandler) DeleteServer(c *gin.Context) {
+func (s *ServerHandler) DeleteServer(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDString, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDString)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		paramServerID := c.Param("serverId")
+		serverID, err := uuid.Parse(paramServerID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+			return
+		}
+
+		server, err := s.ServerService.DeleteServer(profileID, serverID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete server : " + err.Error()})
+			return
+		}
+
+		channelIDs := make([]string, 0, len(server.Channels))
+		for _, channel := range server.Channels {
+			channelIDs = append(channelIDs, channel.ID.String())
+		}
+
+		hub.CloseServer <- ws.ServerTeardown{
+			ServerID:   server.ID.String(),
+			ChannelIDs: channelIDs,
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":         "Delete server successfully",
+			"restorableUntil": server.DeletedAt.Time.Add(services.SOFT_DELETE_RETENTION),
+		})
+	}
+}
+
+func (s *ServerHandler) GetDeletedServers(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	servers, err := s.ServerService.GetDeletedServers(profileID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting deleted servers: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get deleted servers successfully", "servers": servers})
+}
+
+func (s *ServerHandler) RestoreServer(c *gin.Context) {
 	profileIDInterface, exists := c.Get("profile_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
@@ -405,12 +503,18 @@ func (s *ServerHandler) DeleteServer(c *gin.Context) {
 		return
 	}
 
-	if err := s.ServerService.DeleteServer(profileID, serverID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete server : " + err.Error()})
+	server, err := s.ServerService.RestoreServer(profileID, serverID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Server not found or retention period is over"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore server : " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Delete server successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Restore server successfully", "server": server})
 }
 
 func (s *ServerHandler) GetServerDefaultChannel(c *gin.Context) {
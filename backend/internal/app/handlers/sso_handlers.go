@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	ws "discord-backend/internal/app/websocket"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SSO_FLOW_COOKIE keeps the flow token between the login redirect and the callback
+const SSO_FLOW_COOKIE = "oidc_flow"
+
+type SSOHandler struct {
+	SSOService     *services.SSOService
+	ProfileService *services.ProfileService
+	TokenService   *services.TokenService
+	// AppURL is where the browser goes back to after signing in
+	AppURL string
+}
+
+func NewSSOHandler(ssoService *services.SSOService, profileService *services.ProfileService, tokenService *services.TokenService, appURL string) *SSOHandler {
+	return &SSOHandler{
+		SSOService:     ssoService,
+		ProfileService: profileService,
+		TokenService:   tokenService,
+		AppURL:         appURL,
+	}
+}
+
+func ssoError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidSSO):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provider not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + ": " + err.Error()})
+	}
+}
+
+func (h *SSOHandler) GetProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"message": "Get providers successfully", "providers": h.SSOService.ProviderNames()})
+}
+
+// Login redirects to the provider, the browser comes back to Callback
+func (h *SSOHandler) Login(c *gin.Context) {
+	authURL, flow, err := h.SSOService.BeginLogin(c.Request.Context(), c.Param("provider"), c.Query("deviceName"))
+	if err != nil {
+		ssoError(c, err, "starting sign in")
+		return
+	}
+
+	domain := utils.ExtractBaseDomain(c.Request.Host)
+	c.SetCookie(SSO_FLOW_COOKIE, flow, int(services.SSO_FLOW_TTL/time.Second), "/", domain, true, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback finishes the sign in like SignIn does, profiles with two-factor
+// authentication are sent back to the app with a challenge instead
+func (h *SSOHandler) Callback(hub *ws.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		domain := utils.ExtractBaseDomain(c.Request.Host)
+		flow, err := c.Cookie(SSO_FLOW_COOKIE)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign in expired, try again"})
+			return
+		}
+		c.SetCookie(SSO_FLOW_COOKIE, "", -1, "/", domain, true, true)
+
+		if providerError := c.Query("error"); providerError != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign in failed: " + providerError + " " + c.Query("error_description")})
+			return
+		}
+
+		login, err := h.SSOService.CompleteLogin(c.Request.Context(), c.Param("provider"), flow, c.Query("state"), c.Query("code"))
+		if err != nil {
+			ssoError(c, err, "signing in")
+			return
+		}
+
+		if len(login.RevokedSessions) > 0 {
+			hub.DisconnectSessions(login.ProfileID, login.RevokedSessions...)
+		}
+
+		profile, err := h.ProfileService.GetProfileByID(login.ProfileID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting profile: " + err.Error()})
+			return
+		}
+
+		if profile.MFAEnabled {
+			challenge, err := services.GenerateMFAChallenge(profile.ID, login.DeviceName)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating MFA challenge"})
+				return
+			}
+
+			c.Redirect(http.StatusFound, h.AppURL+"/sign-in?mfaChallenge="+url.QueryEscape(challenge))
+			return
+		}
+
+		tokens, _, err := h.TokenService.StartSession(profile.ID, profile.Name, deviceInfo(c, login.DeviceName))
+		if errors.Is(err, utils.ErrAccountDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error starting session"})
+			return
+		}
+
+		maxAge := int(services.REFRESH_TOKEN_TTL / time.Second)
+		c.SetCookie("refresh_token", tokens["refreshToken"], maxAge, "/", domain, true, true)
+		c.SetCookie("access_token", tokens["accessToken"], maxAge, "/", domain, false, true)
+		c.Redirect(http.StatusFound, h.AppURL)
+	}
+}
+
+func (h *SSOHandler) GetIdentities(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDStr, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	identities, err := h.SSOService.GetIdentities(profileID)
+	if err != nil {
+		ssoError(c, err, "getting identities")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get identities successfully", "identities": identities})
+}
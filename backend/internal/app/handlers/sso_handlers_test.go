@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/oidc"
+	"discord-backend/internal/app/oidc/oidctest"
+	"discord-backend/internal/app/services"
+	ws "discord-backend/internal/app/websocket"
+	"discord-backend/internal/db/dbtest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const testAppURL = "https://app.example.com"
+
+// testDB is a dbtest transaction with freshly loaded signing keys
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	tx := dbtest.Open(t)
+
+	// Keys left by a run with another key encryption key cannot be read
+	if err := tx.Exec("DELETE FROM signing_keys").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	kek := make([]byte, 32)
+	if _, err := rand.Read(kek); err != nil {
+		t.Fatal(err)
+	}
+	if err := services.LoadSigningKeys(tx, kek); err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+// callback signs in at the issuer with claims and returns the response of
+// the callback the browser is redirected to
+func callback(t *testing.T, tx *gorm.DB, claims jwt.MapClaims) *httptest.ResponseRecorder {
+	t.Helper()
+
+	issuer := oidctest.NewIssuer(t, "client", "secret")
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "test",
+		Issuer:       issuer.URL,
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  testAppURL + "/api/auth/oidc/test/callback",
+	})
+
+	ssoService := services.NewSSOService(tx, map[string]*oidc.Provider{"test": provider})
+	handler := NewSSOHandler(ssoService, services.NewProfileService(tx), services.NewTokenService(tx), testAppURL)
+
+	authURL, flow, err := ssoService.BeginLogin(context.Background(), "test", "Test device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := issuer.Authorize(t, authURL, claims)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/auth/oidc/:provider/callback", handler.Callback(ws.NewHub()))
+
+	query := url.Values{"state": {state}, "code": {code}}
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/test/callback?"+query.Encode(), nil)
+	req.AddCookie(&http.Cookie{Name: SSO_FLOW_COOKIE, Value: flow})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func createVerifiedProfile(t *testing.T, tx *gorm.DB, mfaEnabled bool) *models.Profile {
+	t.Helper()
+
+	now := time.Now()
+	profile := models.Profile{
+		Name:            "test-" + uuid.NewString()[:8],
+		Email:           uuid.NewString() + "@example.com",
+		Password:        "password hash",
+		EmailVerifiedAt: &now,
+		MFAEnabled:      mfaEnabled,
+	}
+	if err := tx.Create(&profile).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &profile
+}
+
+func cookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name && cookie.MaxAge >= 0 {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestCallbackSendsMFAChallenge(t *testing.T) {
+	tx := testDB(t)
+	profile := createVerifiedProfile(t, tx, true)
+
+	w := callback(t, tx, jwt.MapClaims{"email": profile.Email, "email_verified": true})
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	if cookie(w, "refresh_token") != nil || cookie(w, "access_token") != nil {
+		t.Error("a session was started before the second factor")
+	}
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Scheme+"://"+location.Host+location.Path != testAppURL+"/sign-in" {
+		t.Errorf("redirected to %s", location)
+	}
+
+	profileID, deviceName, err := services.VerifyMFAChallenge(location.Query().Get("mfaChallenge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profileID != profile.ID || deviceName != "Test device" {
+		t.Errorf("challenge for %s on %q, want %s on the device of the flow", profileID, deviceName, profile.ID)
+	}
+}
+
+func TestCallbackStartsSession(t *testing.T) {
+	tx := testDB(t)
+	profile := createVerifiedProfile(t, tx, false)
+
+	w := callback(t, tx, jwt.MapClaims{"email": profile.Email, "email_verified": true})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != testAppURL {
+		t.Fatalf("status = %d, location %q, body %s", w.Code, w.Header().Get("Location"), w.Body)
+	}
+
+	if cookie(w, "refresh_token") == nil || cookie(w, "access_token") == nil {
+		t.Error("the session cookies were not set")
+	}
+}
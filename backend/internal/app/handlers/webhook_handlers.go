@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct {
+	WebhookService *services.WebhookService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{WebhookService: webhookService}
+}
+
+func webhookError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, utils.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to manage webhooks"})
+	case errors.Is(err, utils.ErrInvalidWebhook):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + ": " + err.Error()})
+	}
+}
+
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	var input struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	webhook, secret, err := h.WebhookService.CreateWebhook(serverID, profileID, input.URL, input.Events)
+	if err != nil {
+		webhookError(c, err, "creating webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook created successfully", "webhook": webhook, "secret": secret})
+}
+
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	webhooks, err := h.WebhookService.GetWebhooks(serverID, profileID)
+	if err != nil {
+		webhookError(c, err, "getting webhooks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get webhooks successfully", "webhooks": webhooks})
+}
+
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Webhook UUID format"})
+		return
+	}
+
+	var input struct {
+		URL    *string  `json:"url"`
+		Events []string `json:"events"`
+		Active *bool    `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	webhook, err := h.WebhookService.UpdateWebhook(serverID, profileID, webhookID, input.URL, input.Events, input.Active)
+	if err != nil {
+		webhookError(c, err, "updating webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook updated successfully", "webhook": webhook})
+}
+
+func (h *WebhookHandler) RotateWebhookSecret(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Webhook UUID format"})
+		return
+	}
+
+	webhook, secret, err := h.WebhookService.RotateWebhookSecret(serverID, profileID, webhookID)
+	if err != nil {
+		webhookError(c, err, "rotating webhook secret")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook secret rotated successfully", "webhook": webhook, "secret": secret})
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Webhook UUID format"})
+		return
+	}
+
+	if err := h.WebhookService.DeleteWebhook(serverID, profileID, webhookID); err != nil {
+		webhookError(c, err, "deleting webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	profileIDInterface, exists := c.Get("profile_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+		return
+	}
+
+	profileIDString, ok := profileIDInterface.(string)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+		return
+	}
+
+	profileID, err := uuid.Parse(profileIDString)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	serverID, err := uuid.Parse(c.Param("serverId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Server UUID format"})
+		return
+	}
+
+	webhookID, err := uuid.Parse(c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Webhook UUID format"})
+		return
+	}
+
+	deliveries, nextCursor, err := h.WebhookService.GetDeliveries(serverID, profileID, webhookID, c.Query("cursor"))
+	if err != nil {
+		webhookError(c, err, "getting webhook deliveries")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Get webhook deliveries successfully", "deliveries": deliveries, "nextCursor": nextCursor})
+}
@@ -11,8 +11,12 @@ import (
 	"github.com/gorilla/websocket"
 	"gorm.io/gorm"
 
+	"discord-backend/internal/app/events"
+	"discord-backend/internal/app/markdown"
+	"discord-backend/internal/app/middleware"
 	"discord-backend/internal/app/models"
 	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
 	ws "discord-backend/internal/app/websocket"
 )
 
@@ -23,6 +27,7 @@ type WebsocketHandler struct {
 	MessageService       *services.MessageService
 	DirectMessageService *services.DirectMessageService
 	ProfileService       *services.ProfileService
+	Events               *events.Bus
 }
 
 func NewWebsocketHandler(
@@ -32,6 +37,7 @@ func NewWebsocketHandler(
 	messageService *services.MessageService,
 	directMessageService *services.DirectMessageService,
 	profileService *services.ProfileService,
+	bus *events.Bus,
 ) *WebsocketHandler {
 	return &WebsocketHandler{
 		ServerService:        serverService,
@@ -40,6 +46,7 @@ func NewWebsocketHandler(
 		MessageService:       messageService,
 		DirectMessageService: directMessageService,
 		ProfileService:       profileService,
+		Events:               bus,
 	}
 }
 
@@ -49,6 +56,15 @@ var upgrader = websocket.Upgrader{
 
 func (h *WebsocketHandler) WebSocketHandler(hub *ws.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// AuthMiddleware already refused revoked sessions, the socket remembers
+		// its session so revoking it later closes the socket
+		var sessionID uuid.UUID
+		if sessionIDStr, ok := c.Get("session_id"); ok {
+			if parsed, err := uuid.Parse(fmt.Sprint(sessionIDStr)); err == nil {
+				sessionID = parsed
+			}
+		}
+
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
 			http.Error(c.Writer, "Could not upgrade to WebSocket", http.StatusBadRequest)
@@ -91,7 +107,10 @@ func (h *WebsocketHandler) WebSocketHandler(hub *ws.Hub) gin.HandlerFunc {
 			return
 		}
 
-		client := &ws.Client{Hub: hub, Conn: conn, Send: make(chan ws.Message), ID: c.Request.RemoteAddr, ProfileID: profileID, Username: name, ImageURL: profile.ImageURL}
+		client := &ws.Client{Hub: hub, Conn: conn, Send: make(chan ws.Message), ID: c.Request.RemoteAddr, ProfileID: profileID, SessionID: sessionID, Username: name, ImageURL: profile.ImageURL}
+		if err := hub.LoadBlocked(client); err != nil {
+			log.Printf("Error loading the blocked profiles of %s: %v", profileID, err)
+		}
 		hub.Register <- client
 
 		go client.ReadPump()
@@ -158,7 +177,7 @@ func (h *WebsocketHandler) WebSocketMessageHandler(hub *ws.Hub) gin.HandlerFunc
 			return
 		}
 
-		_, err = h.ChannelService.GetChannel(channelID)
+		channel, err := h.ChannelService.GetChannel(channelID)
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
@@ -175,12 +194,22 @@ func (h *WebsocketHandler) WebSocketMessageHandler(hub *ws.Hub) gin.HandlerFunc
 			return
 		}
 
-		message, err := h.MessageService.CreateMessage(channelID, member.ID, input.Content, input.FileURL)
+		message, retryAfter, err := h.MessageService.PostMessage(channel, member, input.Content, input.FileURL)
 		if err != nil {
+			if errors.Is(err, markdown.ErrTooLong) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
 			return
 		}
 
+		if retryAfter > 0 {
+			middleware.TooManyRequests(c, retryAfter, "Slowmode is enabled in this channel")
+			return
+		}
+
 		if err := message.Validate(); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "content in message is missing"})
 			return
@@ -188,11 +217,13 @@ func (h *WebsocketHandler) WebSocketMessageHandler(hub *ws.Hub) gin.HandlerFunc
 
 		channelKey := fmt.Sprintf("chat:%s:messages", channelIDStr)
 		msg := ws.Message{
-			Type:    "message",
-			Channel: channelKey,
-			Content: message,
+			Type:     "message",
+			Channel:  channelKey,
+			Content:  message,
+			SenderID: message.AuthorProfileID(),
 		}
 		hub.BroadcastToChannel(msg)
+		h.Events.Publish(events.MessageCreated, serverID, message)
 
 		c.JSON(http.StatusOK, gin.H{"message": "Message created successfully", "data": message})
 	}
@@ -278,7 +309,7 @@ func (h *WebsocketHandler) WebScoketEditMessageHandler(hub *ws.Hub) gin.HandlerF
 			return
 		}
 
-		isMessageOwner := message.MemberID == member.ID
+		isMessageOwner := message.IsAuthor(member.ID)
 		// isAdmin := member.Role == models.Admin
 		// isModerator := member.Role == models.Moderator
 		// canModify := isMessageOwner || isAdmin || isModerator
@@ -298,6 +329,11 @@ func (h *WebsocketHandler) WebScoketEditMessageHandler(hub *ws.Hub) gin.HandlerF
 
 		message, err = h.MessageService.UpdateMessage(channelID, messageID, input.Content)
 		if err != nil {
+			if errors.Is(err, markdown.ErrTooLong) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating message: " + err.Error()})
 			return
 		}
@@ -309,11 +345,13 @@ func (h *WebsocketHandler) WebScoketEditMessageHandler(hub *ws.Hub) gin.HandlerF
 
 		channelKey := fmt.Sprintf("chat:%s:messages:update", channelIDStr)
 		msg := ws.Message{
-			Type:    "message",
-			Channel: channelKey,
-			Content: message,
+			Type:     "message",
+			Channel:  channelKey,
+			Content:  message,
+			SenderID: message.AuthorProfileID(),
 		}
 		hub.BroadcastToChannel(msg)
+		h.Events.Publish(events.MessageUpdated, serverID, message)
 
 		c.JSON(http.StatusOK, gin.H{"message": "Message updated successfully", "data": message})
 	}
@@ -399,7 +437,7 @@ func (h *WebsocketHandler) WebScoketDeleteMessageHandler(hub *ws.Hub) gin.Handle
 			return
 		}
 
-		isMessageOwner := message.MemberID == member.ID
+		isMessageOwner := message.IsAuthor(member.ID)
 		isAdmin := member.Role == models.Admin
 		isModerator := member.Role == models.Moderator
 		canModify := isMessageOwner || isAdmin || isModerator
@@ -409,6 +447,13 @@ func (h *WebsocketHandler) WebScoketDeleteMessageHandler(hub *ws.Hub) gin.Handle
 			return
 		}
 
+		if !isMessageOwner {
+			if err := h.ServerService.EnsureModeratorMFA(serverID, profileID); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
 		message, err = h.MessageService.DeleteMessage(channelID, messageID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating message: " + err.Error()})
@@ -422,11 +467,13 @@ func (h *WebsocketHandler) WebScoketDeleteMessageHandler(hub *ws.Hub) gin.Handle
 
 		channelKey := fmt.Sprintf("chat:%s:messages:update", channelIDStr)
 		msg := ws.Message{
-			Type:    "message",
-			Channel: channelKey,
-			Content: message,
+			Type:     "message",
+			Channel:  channelKey,
+			Content:  message,
+			SenderID: message.AuthorProfileID(),
 		}
 		hub.BroadcastToChannel(msg)
+		h.Events.Publish(events.MessageDeleted, serverID, message)
 
 		c.JSON(http.StatusOK, gin.H{"message": "Message deleted successfully", "data": message})
 	}
@@ -473,8 +520,7 @@ func (h *WebsocketHandler) WebSocketDirectMessageHandler(hub *ws.Hub) gin.Handle
 			return
 		}
 
-		conversation, err := h.ConversationService.GetConversation(conversationID, profileID)
-		if err != nil {
+		if _, err := h.ConversationService.GetConversation(conversationID, profileID); err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
 				return
@@ -484,18 +530,18 @@ func (h *WebsocketHandler) WebSocketDirectMessageHandler(hub *ws.Hub) gin.Handle
 			return
 		}
 
-		var member models.Member
-		if conversation.MemberOne.ProfileID == profileID {
-			member = conversation.MemberOne
-		} else if conversation.MemberTwo.ProfileID == profileID {
-			member = conversation.MemberTwo
-		} else {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
-			return
-		}
-
-		directMessage, err := h.DirectMessageService.CreateDirectMessage(conversationID, member.ID, input.Content, input.FileURL)
+		directMessage, err := h.DirectMessageService.CreateDirectMessage(conversationID, profileID, input.Content, input.FileURL)
 		if err != nil {
+			if errors.Is(err, utils.ErrForbidden) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+
+			if errors.Is(err, markdown.ErrTooLong) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create direct message"})
 			return
 		}
@@ -507,9 +553,10 @@ func (h *WebsocketHandler) WebSocketDirectMessageHandler(hub *ws.Hub) gin.Handle
 
 		channelKey := fmt.Sprintf("chat:%s:messages", conversationIDStr)
 		msg := ws.Message{
-			Type:    "message",
-			Channel: channelKey,
-			Content: directMessage,
+			Type:     "message",
+			Channel:  channelKey,
+			Content:  directMessage,
+			SenderID: directMessage.ProfileID,
 		}
 		hub.BroadcastToChannel(msg)
 
@@ -556,8 +603,7 @@ func (h *WebsocketHandler) WebSocketEditDirectMessageHandler(hub *ws.Hub) gin.Ha
 			return
 		}
 
-		conversation, err := h.ConversationService.GetConversation(conversationID, profileID)
-		if err != nil {
+		if _, err := h.ConversationService.GetConversation(conversationID, profileID); err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
 				return
@@ -567,23 +613,13 @@ func (h *WebsocketHandler) WebSocketEditDirectMessageHandler(hub *ws.Hub) gin.Ha
 			return
 		}
 
-		var member models.Member
-		if conversation.MemberOne.ProfileID == profileID {
-			member = conversation.MemberOne
-		} else if conversation.MemberTwo.ProfileID == profileID {
-			member = conversation.MemberTwo
-		} else {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
-			return
-		}
-
 		directMessage, err := h.DirectMessageService.GetDirectMessage(conversationID, directMessageID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create direct message"})
 			return
 		}
 
-		isMessageOwner := directMessage.MemberID == member.ID
+		isMessageOwner := directMessage.ProfileID == profileID
 		canModify := isMessageOwner
 
 		var input struct {
@@ -601,6 +637,11 @@ func (h *WebsocketHandler) WebSocketEditDirectMessageHandler(hub *ws.Hub) gin.Ha
 
 		directMessage, err = h.DirectMessageService.UpdateDirectMessage(conversationID, directMessageID, input.Content)
 		if err != nil {
+			if errors.Is(err, markdown.ErrTooLong) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating direct message: " + err.Error()})
 			return
 		}
@@ -612,9 +653,10 @@ func (h *WebsocketHandler) WebSocketEditDirectMessageHandler(hub *ws.Hub) gin.Ha
 
 		channelKey := fmt.Sprintf("chat:%s:messages:update", conversationIDStr)
 		msg := ws.Message{
-			Type:    "message",
-			Channel: channelKey,
-			Content: directMessage,
+			Type:     "message",
+			Channel:  channelKey,
+			Content:  directMessage,
+			SenderID: directMessage.ProfileID,
 		}
 		hub.BroadcastToChannel(msg)
 
@@ -661,8 +703,7 @@ func (h *WebsocketHandler) WebSocketDeleteDirectMessageHandler(hub *ws.Hub) gin.
 			return
 		}
 
-		conversation, err := h.ConversationService.GetConversation(conversationID, profileID)
-		if err != nil {
+		if _, err := h.ConversationService.GetConversation(conversationID, profileID); err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
 				return
@@ -672,23 +713,13 @@ func (h *WebsocketHandler) WebSocketDeleteDirectMessageHandler(hub *ws.Hub) gin.
 			return
 		}
 
-		var member models.Member
-		if conversation.MemberOne.ProfileID == profileID {
-			member = conversation.MemberOne
-		} else if conversation.MemberTwo.ProfileID == profileID {
-			member = conversation.MemberTwo
-		} else {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
-			return
-		}
-
 		directMessage, err := h.DirectMessageService.GetDirectMessage(conversationID, directMessageID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create direct message"})
 			return
 		}
 
-		isMessageOwner := directMessage.MemberID == member.ID
+		isMessageOwner := directMessage.ProfileID == profileID
 		canModify := isMessageOwner
 
 		if !canModify {
@@ -709,9 +740,10 @@ func (h *WebsocketHandler) WebSocketDeleteDirectMessageHandler(hub *ws.Hub) gin.
 
 		channelKey := fmt.Sprintf("chat:%s:messages:update", conversationIDStr)
 		msg := ws.Message{
-			Type:    "message",
-			Channel: channelKey,
-			Content: directMessage,
+			Type:     "message",
+			Channel:  channelKey,
+			Content:  directMessage,
+			SenderID: directMessage.ProfileID,
 		}
 		hub.BroadcastToChannel(msg)
 
@@ -719,6 +751,383 @@ func (h *WebsocketHandler) WebSocketDeleteDirectMessageHandler(hub *ws.Hub) gin.
 	}
 }
 
+func (h *WebsocketHandler) WebSocketPinMessageHandler(hub *ws.Hub) gin.HandlerFunc {
+	return h.messagePinHandler(hub, true)
+}
+
+func (h *WebsocketHandler) WebSocketUnpinMessageHandler(hub *ws.Hub) gin.HandlerFunc {
+	return h.messagePinHandler(hub, false)
+}
+
+func (h *WebsocketHandler) messagePinHandler(hub *ws.Hub, pinned bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		paramMessageID := c.Param("messageId")
+		messageID, err := uuid.Parse(paramMessageID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Message UUID format"})
+			return
+		}
+
+		serverIDStr := c.Query("serverId")
+		channelIDStr := c.Query("channelId")
+		if serverIDStr == "" || channelIDStr == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing serverId or channelId"})
+			return
+		}
+
+		serverID, err := uuid.Parse(serverIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid serverId"})
+			return
+		}
+
+		channelID, err := uuid.Parse(channelIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channelId"})
+			return
+		}
+
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		server, err := h.ServerService.GetServer(profileID, serverID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting server: " + err.Error()})
+			return
+		}
+
+		channel, err := h.ChannelService.GetChannel(channelID)
+		if err != nil || channel.ServerID != server.ID {
+			if err == nil || err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting channel: " + err.Error()})
+			return
+		}
+
+		member, err := FindMember(server.Members, profileID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+
+		isAdmin := member.Role == models.Admin
+		isModerator := member.Role == models.Moderator
+		if !isAdmin && !isModerator {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var message *models.Message
+		if pinned {
+			message, err = h.MessageService.PinMessage(channelID, messageID)
+		} else {
+			message, err = h.MessageService.UnpinMessage(channelID, messageID)
+		}
+		if err != nil {
+			if errors.Is(err, utils.ErrPinLimitReached) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A channel can have at most %d pinned messages", services.MAX_PINNED_MESSAGES)})
+				return
+			}
+
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating message: " + err.Error()})
+			return
+		}
+
+		hub.BroadcastToChannel(ws.Message{
+			Type:     "message",
+			Channel:  fmt.Sprintf("chat:%s:messages:update", channelIDStr),
+			Content:  message,
+			SenderID: message.AuthorProfileID(),
+		})
+		hub.BroadcastToChannel(ws.Message{
+			Type:     "message",
+			Channel:  fmt.Sprintf("chat:%s:pins", channelIDStr),
+			Content:  message,
+			SenderID: message.AuthorProfileID(),
+		})
+
+		if pinned {
+			c.JSON(http.StatusOK, gin.H{"message": "Message pinned successfully", "data": message})
+		} else {
+			c.JSON(http.StatusOK, gin.H{"message": "Message unpinned successfully", "data": message})
+		}
+	}
+}
+
+func (h *WebsocketHandler) WebSocketAddReactionHandler(hub *ws.Hub) gin.HandlerFunc {
+	return h.messageReactionHandler(hub, true)
+}
+
+func (h *WebsocketHandler) WebSocketRemoveReactionHandler(hub *ws.Hub) gin.HandlerFunc {
+	return h.messageReactionHandler(hub, false)
+}
+
+// messageReactionHandler adds the reaction from the body, or removes the one
+// named by the emoji or emojiId query
+func (h *WebsocketHandler) messageReactionHandler(hub *ws.Hub, added bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		paramMessageID := c.Param("messageId")
+		messageID, err := uuid.Parse(paramMessageID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Message UUID format"})
+			return
+		}
+
+		serverIDStr := c.Query("serverId")
+		channelIDStr := c.Query("channelId")
+		if serverIDStr == "" || channelIDStr == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing serverId or channelId"})
+			return
+		}
+
+		serverID, err := uuid.Parse(serverIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid serverId"})
+			return
+		}
+
+		channelID, err := uuid.Parse(channelIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channelId"})
+			return
+		}
+
+		var input struct {
+			Emoji   string     `json:"emoji"`
+			EmojiID *uuid.UUID `json:"emojiId"`
+		}
+		if added {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+				return
+			}
+		} else {
+			input.Emoji = c.Query("emoji")
+			if emojiID := c.Query("emojiId"); emojiID != "" {
+				id, err := uuid.Parse(emojiID)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid emojiId"})
+					return
+				}
+				input.EmojiID = &id
+			}
+		}
+
+		if input.Emoji == "" && input.EmojiID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing emoji"})
+			return
+		}
+
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		server, err := h.ServerService.GetServer(profileID, serverID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting server: " + err.Error()})
+			return
+		}
+
+		channel, err := h.ChannelService.GetChannel(channelID)
+		if err != nil || channel.ServerID != server.ID {
+			if err == nil || err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting channel: " + err.Error()})
+			return
+		}
+
+		member, err := FindMember(server.Members, profileID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+
+		var message *models.Message
+		if added {
+			message, err = h.MessageService.AddReaction(serverID, channelID, messageID, member.ID, input.Emoji, input.EmojiID)
+		} else {
+			message, err = h.MessageService.RemoveReaction(channelID, messageID, member.ID, input.Emoji, input.EmojiID)
+		}
+		if err != nil {
+			if errors.Is(err, utils.ErrInvalidEmoji) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating reactions: " + err.Error()})
+			return
+		}
+
+		hub.BroadcastToChannel(ws.Message{
+			Type:     "message",
+			Channel:  fmt.Sprintf("chat:%s:messages:update", channelIDStr),
+			Content:  message,
+			SenderID: message.AuthorProfileID(),
+		})
+
+		if added {
+			c.JSON(http.StatusOK, gin.H{"message": "Reaction added successfully", "data": message})
+		} else {
+			c.JSON(http.StatusOK, gin.H{"message": "Reaction removed successfully", "data": message})
+		}
+	}
+}
+
+func (h *WebsocketHandler) WebSocketPinDirectMessageHandler(hub *ws.Hub) gin.HandlerFunc {
+	return h.directMessagePinHandler(hub, true)
+}
+
+func (h *WebsocketHandler) WebSocketUnpinDirectMessageHandler(hub *ws.Hub) gin.HandlerFunc {
+	return h.directMessagePinHandler(hub, false)
+}
+
+func (h *WebsocketHandler) directMessagePinHandler(hub *ws.Hub, pinned bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		paramDirectMessageID := c.Param("directMessageId")
+		directMessageID, err := uuid.Parse(paramDirectMessageID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Direct Message UUID format"})
+			return
+		}
+
+		conversationIDStr := c.Query("conversationId")
+		if conversationIDStr == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing conversationId"})
+			return
+		}
+
+		conversationID, err := uuid.Parse(conversationIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversationId"})
+			return
+		}
+
+		profileIDInterface, exists := c.Get("profile_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "profile_id not found"})
+			return
+		}
+
+		profileIDStr, ok := profileIDInterface.(string)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID format"})
+			return
+		}
+
+		profileID, err := uuid.Parse(profileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+			return
+		}
+
+		// Both participants of a conversation can pin messages
+		if _, err := h.ConversationService.GetConversation(conversationID, profileID); err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting conversation: " + err.Error()})
+			return
+		}
+
+		var directMessage *models.DirectMessage
+		if pinned {
+			directMessage, err = h.DirectMessageService.PinDirectMessage(conversationID, directMessageID)
+		} else {
+			directMessage, err = h.DirectMessageService.UnpinDirectMessage(conversationID, directMessageID)
+		}
+		if err != nil {
+			if errors.Is(err, utils.ErrPinLimitReached) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A conversation can have at most %d pinned messages", services.MAX_PINNED_DIRECT_MESSAGES)})
+				return
+			}
+
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Direct message not found"})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating direct message: " + err.Error()})
+			return
+		}
+
+		hub.BroadcastToChannel(ws.Message{
+			Type:     "message",
+			Channel:  fmt.Sprintf("chat:%s:messages:update", conversationIDStr),
+			Content:  directMessage,
+			SenderID: directMessage.ProfileID,
+		})
+		hub.BroadcastToChannel(ws.Message{
+			Type:     "message",
+			Channel:  fmt.Sprintf("chat:%s:pins", conversationIDStr),
+			Content:  directMessage,
+			SenderID: directMessage.ProfileID,
+		})
+
+		if pinned {
+			c.JSON(http.StatusOK, gin.H{"message": "Direct message pinned successfully", "data": directMessage})
+		} else {
+			c.JSON(http.StatusOK, gin.H{"message": "Direct message unpinned successfully", "data": directMessage})
+		}
+	}
+}
+
 func (h *WebsocketHandler) WebSocketGetParticipants(hub *ws.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		paramServerID := c.Param("serverId")
@@ -0,0 +1,106 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers plain text emails, SMTPMailer in production and LogMailer
+// when no SMTP server is configured
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// SMTPMailer sends through an SMTP server, with PLAIN auth when a username is set
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{Host: host, Port: port, Username: username, Password: password, From: from}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	if strings.ContainsAny(message.To, "\r\n") || strings.ContainsAny(message.Subject, "\r\n") {
+		return fmt.Errorf("invalid header in email to %q", message.To)
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(m.Host, strconv.Itoa(m.Port)), auth, m.From, []string{message.To}, format(m.From, message))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LogMailer writes every email to a file under Dir, or to the log when Dir is
+// empty. Meant for development and tests where links are read from the output
+type LogMailer struct {
+	Dir  string
+	From string
+}
+
+func NewLogMailer(dir, from string) *LogMailer {
+	return &LogMailer{Dir: dir, From: from}
+}
+
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	data := format(m.From, message)
+	if m.Dir == "" {
+		log.Printf("Email to %s:\n%s", message.To, data)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, message.To))
+
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0o644)
+}
+
+func format(from string, message Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", message.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,103 @@
+package markdown
+
+import "github.com/google/uuid"
+
+type NodeType string
+
+const (
+	Document        NodeType = "document"
+	Text            NodeType = "text"
+	Bold            NodeType = "bold"
+	Italic          NodeType = "italic"
+	Underline       NodeType = "underline"
+	Strikethrough   NodeType = "strikethrough"
+	Spoiler         NodeType = "spoiler"
+	InlineCode      NodeType = "inline_code"
+	CodeBlock       NodeType = "code_block"
+	Blockquote      NodeType = "blockquote"
+	UserMention     NodeType = "user_mention"
+	RoleMention     NodeType = "role_mention"
+	ChannelMention  NodeType = "channel_mention"
+	EveryoneMention NodeType = "everyone_mention"
+	HereMention     NodeType = "here_mention"
+	CustomEmoji     NodeType = "custom_emoji"
+)
+
+// Node is one element of the rich text tree. Text and code nodes carry
+// Content, mentions and custom emoji carry ID and every other node only has Children
+type Node struct {
+	Type     NodeType `json:"type"`
+	Content  string   `json:"content,omitempty"`
+	Language string   `json:"language,omitempty"`
+	ID       string   `json:"id,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	Animated bool     `json:"animated,omitempty"`
+	Children []*Node  `json:"children,omitempty"`
+}
+
+func (n *Node) walk(visit func(node *Node)) {
+	visit(n)
+	for _, child := range n.Children {
+		child.walk(visit)
+	}
+}
+
+type Mentions struct {
+	Users    []uuid.UUID `json:"users"`
+	Roles    []string    `json:"roles"`
+	Channels []uuid.UUID `json:"channels"`
+	Everyone bool        `json:"everyone"`
+	Here     bool        `json:"here"`
+}
+
+// ExtractMentions lists the distinct mentions of a tree, mentions written in
+// code are plain text and never show up here
+func ExtractMentions(root *Node) Mentions {
+	mentions := Mentions{Users: []uuid.UUID{}, Roles: []string{}, Channels: []uuid.UUID{}}
+	if root == nil {
+		return mentions
+	}
+
+	seen := make(map[string]bool)
+
+	root.walk(func(node *Node) {
+		key := string(node.Type) + ":" + node.ID
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		switch node.Type {
+		case UserMention:
+			mentions.Users = append(mentions.Users, uuid.MustParse(node.ID))
+		case RoleMention:
+			mentions.Roles = append(mentions.Roles, node.ID)
+		case ChannelMention:
+			mentions.Channels = append(mentions.Channels, uuid.MustParse(node.ID))
+		case EveryoneMention:
+			mentions.Everyone = true
+		case HereMention:
+			mentions.Here = true
+		}
+	})
+
+	return mentions
+}
+
+// ExtractEmojis lists the distinct custom emoji ids used in a tree
+func ExtractEmojis(root *Node) []uuid.UUID {
+	emojis := []uuid.UUID{}
+	if root == nil {
+		return emojis
+	}
+
+	seen := make(map[string]bool)
+	root.walk(func(node *Node) {
+		if node.Type == CustomEmoji && !seen[node.ID] {
+			seen[node.ID] = true
+			emojis = append(emojis, uuid.MustParse(node.ID))
+		}
+	})
+
+	return emojis
+}
@@ -0,0 +1,380 @@
+package markdown
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MAX_CONTENT_LENGTH = 4000
+	// Formatting nested deeper than this is kept as literal text
+	MAX_DEPTH = 5
+	// Once a tree has this many nodes the rest of the content is a single text node
+	MAX_NODES = 1000
+)
+
+var ErrTooLong = errors.New("content is too long")
+
+var (
+	languagePattern = regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,20}$`)
+	mentionPattern  = regexp.MustCompile(`^<(@&|@|#)([A-Za-z0-9_-]{1,36})>`)
+	rolePattern     = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+	emojiPattern    = regexp.MustCompile(`^<(a?):([A-Za-z0-9_]{2,32}):([0-9A-Fa-f-]{36})>`)
+)
+
+type delimiter struct {
+	token    string
+	nodeType NodeType
+}
+
+// Longer tokens first so ** is not read as two *
+var delimiters = []delimiter{
+	{"**", Bold},
+	{"__", Underline},
+	{"~~", Strikethrough},
+	{"||", Spoiler},
+	{"*", Italic},
+	{"_", Italic},
+}
+
+type parser struct {
+	nodes int
+}
+
+// Parse turns message content into a Discord flavored markdown tree: bold,
+// italics, underline, strikethrough, spoilers, inline code, fenced code blocks
+// with a language, blockquotes, user, role and channel mentions and custom emoji
+func Parse(content string) (*Node, error) {
+	if len(content) > MAX_CONTENT_LENGTH {
+		return nil, ErrTooLong
+	}
+
+	p := &parser{}
+	root := p.node(Document)
+	root.Children = p.parseBlocks(content)
+
+	return root, nil
+}
+
+func (p *parser) node(nodeType NodeType) *Node {
+	p.nodes++
+	return &Node{Type: nodeType}
+}
+
+func (p *parser) text(content string) *Node {
+	node := p.node(Text)
+	node.Content = content
+	return node
+}
+
+func (p *parser) full() bool {
+	return p.nodes >= MAX_NODES
+}
+
+// parseBlocks splits out fenced code blocks first, their content is never
+// parsed any further
+func (p *parser) parseBlocks(s string) []*Node {
+	var nodes []*Node
+
+	for s != "" {
+		start := strings.Index(s, "```")
+		if start < 0 || p.full() {
+			return append(nodes, p.parseLines(s)...)
+		}
+
+		end := strings.Index(s[start+3:], "```")
+		if end < 0 {
+			return append(nodes, p.parseLines(s)...)
+		}
+
+		nodes = append(nodes, p.parseLines(s[:start])...)
+
+		body := s[start+3 : start+3+end]
+		block := p.node(CodeBlock)
+		if newline := strings.IndexByte(body, '\n'); newline > 0 && languagePattern.MatchString(body[:newline]) {
+			block.Language = body[:newline]
+			body = body[newline+1:]
+		}
+		block.Content = strings.TrimPrefix(body, "\n")
+		nodes = append(nodes, block)
+
+		s = strings.TrimPrefix(s[start+3+end+3:], "\n")
+	}
+
+	return nodes
+}
+
+// parseLines groups "> " lines into blockquotes, ">>> " quotes everything
+// until the end of the segment
+func (p *parser) parseLines(s string) []*Node {
+	if s == "" {
+		return nil
+	}
+
+	var nodes []*Node
+	var paragraph, quote []string
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			nodes = append(nodes, p.parseInline(strings.Join(paragraph, "\n"), 0)...)
+			paragraph = nil
+		}
+	}
+
+	flushQuote := func() {
+		if len(quote) > 0 {
+			block := p.node(Blockquote)
+			block.Children = p.parseInline(strings.Join(quote, "\n"), 1)
+			nodes = append(nodes, block)
+			quote = nil
+		}
+	}
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, ">>> "):
+			flushParagraph()
+			quote = append(quote, line[4:])
+			quote = append(quote, lines[i+1:]...)
+			flushQuote()
+			return nodes
+		case strings.HasPrefix(line, "> "):
+			flushParagraph()
+			quote = append(quote, line[2:])
+		default:
+			flushQuote()
+			paragraph = append(paragraph, line)
+		}
+	}
+
+	flushParagraph()
+	flushQuote()
+
+	return nodes
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isEscapable(c byte) bool {
+	return strings.IndexByte("\\*_~|`<>#@", c) >= 0
+}
+
+// closing returns the index of the token that closes the one at start, or -1
+func closing(s string, start int, token string) int {
+	from := start + len(token)
+	for from < len(s) {
+		k := strings.Index(s[from:], token)
+		if k < 0 {
+			return -1
+		}
+		k += from
+
+		// A single * or _ that is really half of a double one belongs to it
+		if len(token) == 1 && k+1 < len(s) && s[k+1] == token[0] {
+			from = k + 2
+			continue
+		}
+
+		// In a run like "***" the closing token is the rightmost one
+		for k+len(token) < len(s) && s[k+len(token)] == token[0] {
+			k++
+		}
+
+		inner := s[start+len(token) : k]
+		if inner == "" || strings.TrimSpace(inner) == "" {
+			from = k + len(token)
+			continue
+		}
+
+		if token == "*" && (inner[0] == ' ' || inner[len(inner)-1] == ' ') {
+			from = k + len(token)
+			continue
+		}
+
+		// snake_case words are not italics
+		if token == "_" && k+1 < len(s) && isWordChar(s[k+1]) {
+			from = k + 1
+			continue
+		}
+
+		return k
+	}
+
+	return -1
+}
+
+func (p *parser) parseInline(s string, depth int) []*Node {
+	var nodes []*Node
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, p.text(text.String()))
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if p.full() {
+			text.WriteString(s[i:])
+			break
+		}
+
+		c := s[i]
+
+		if c == '\\' && i+1 < len(s) && isEscapable(s[i+1]) {
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		}
+
+		if c == '`' {
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				flush()
+				code := p.node(InlineCode)
+				code.Content = s[i+1 : i+1+end]
+				nodes = append(nodes, code)
+				i += end + 2
+				continue
+			}
+		}
+
+		if c == '<' {
+			if emoji := p.emoji(s[i:]); emoji != nil {
+				flush()
+				nodes = append(nodes, emoji)
+				i += len(emoji.Content)
+				emoji.Content = ""
+				continue
+			}
+
+			if mention := p.mention(s[i:]); mention != nil {
+				flush()
+				nodes = append(nodes, mention)
+				i += len(mention.Content)
+				mention.Content = ""
+				continue
+			}
+		}
+
+		if c == '@' && (i == 0 || !isWordChar(s[i-1])) {
+			matched := false
+			for _, broadcast := range []struct {
+				token    string
+				nodeType NodeType
+			}{{"@everyone", EveryoneMention}, {"@here", HereMention}} {
+				end := i + len(broadcast.token)
+				if strings.HasPrefix(s[i:], broadcast.token) && (end == len(s) || !isWordChar(s[end])) {
+					flush()
+					nodes = append(nodes, p.node(broadcast.nodeType))
+					i = end
+					matched = true
+					break
+				}
+			}
+
+			if matched {
+				continue
+			}
+		}
+
+		matched := false
+		if depth < MAX_DEPTH {
+			for _, d := range delimiters {
+				if !strings.HasPrefix(s[i:], d.token) {
+					continue
+				}
+
+				if d.token == "_" && i > 0 && isWordChar(s[i-1]) {
+					continue
+				}
+
+				end := closing(s, i, d.token)
+				if end < 0 {
+					continue
+				}
+
+				flush()
+				node := p.node(d.nodeType)
+				node.Children = p.parseInline(s[i+len(d.token):end], depth+1)
+				nodes = append(nodes, node)
+				i = end + len(d.token)
+				matched = true
+				break
+			}
+		}
+
+		if matched {
+			continue
+		}
+
+		text.WriteByte(c)
+		i++
+	}
+
+	flush()
+
+	return nodes
+}
+
+// mention parses <@id>, <@&role> and <#id> at the start of s, Content is set
+// to the raw match so the caller knows how much was consumed
+func (p *parser) mention(s string) *Node {
+	match := mentionPattern.FindStringSubmatch(s)
+	if match == nil {
+		return nil
+	}
+
+	var nodeType NodeType
+	switch match[1] {
+	case "@":
+		nodeType = UserMention
+	case "@&":
+		nodeType = RoleMention
+	case "#":
+		nodeType = ChannelMention
+	}
+
+	id := match[2]
+	if nodeType == RoleMention {
+		if !rolePattern.MatchString(id) {
+			return nil
+		}
+	} else if parsed, err := uuid.Parse(id); err != nil {
+		return nil
+	} else {
+		id = parsed.String()
+	}
+
+	node := p.node(nodeType)
+	node.ID = id
+	node.Content = match[0]
+	return node
+}
+
+// emoji parses <:name:id> and <a:name:id> at the start of s, like mention the
+// raw match is left in Content
+func (p *parser) emoji(s string) *Node {
+	match := emojiPattern.FindStringSubmatch(s)
+	if match == nil {
+		return nil
+	}
+
+	id, err := uuid.Parse(match[3])
+	if err != nil {
+		return nil
+	}
+
+	node := p.node(CustomEmoji)
+	node.ID = id.String()
+	node.Name = match[2]
+	node.Animated = match[1] == "a"
+	node.Content = match[0]
+	return node
+}
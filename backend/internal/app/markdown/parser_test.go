@@ -0,0 +1,142 @@
+package markdown
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// tree writes a node as type(children), text nodes as their quoted content
+func tree(node *Node) string {
+	switch node.Type {
+	case Text:
+		return `"` + node.Content + `"`
+	case InlineCode:
+		return "code`" + node.Content + "`"
+	case CodeBlock:
+		return "block[" + node.Language + "]`" + node.Content + "`"
+	case UserMention, RoleMention, ChannelMention, CustomEmoji:
+		return string(node.Type) + ":" + node.ID
+	}
+
+	children := make([]string, 0, len(node.Children))
+	for _, child := range node.Children {
+		children = append(children, tree(child))
+	}
+	return string(node.Type) + "(" + strings.Join(children, " ") + ")"
+}
+
+func TestParse(t *testing.T) {
+	const id = "5b1c6a4e-2f7d-4a3b-9c8e-1d2f3a4b5c6d"
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain", "hello", `document("hello")`},
+		{"bold", "**hi**", `document(bold("hi"))`},
+		{"italics", "*a* _b_", `document(italic("a") " " italic("b"))`},
+		{"underline and strikethrough", "__u__ ~~s~~", `document(underline("u") " " strikethrough("s"))`},
+		{"spoiler", "||secret||", `document(spoiler("secret"))`},
+		{"nested", "**bold *italic* __under__**", `document(bold("bold " italic("italic") " " underline("under")))`},
+		{"triple star", "***both***", `document(bold(italic("both")))`},
+		{"unclosed", "**open", `document("**open")`},
+		{"snake case", "snake_case_name", `document("snake_case_name")`},
+		{"escaped", `\*not italic\*`, `document("*not italic*")`},
+		{"code span", "`**raw** <@" + id + ">`", "document(code`**raw** <@" + id + ">`)"},
+		{"code span between text", "a `b` c", "document(\"a \" code`b` \" c\")"},
+		{"unclosed code span", "a `b", "document(\"a `b\")"},
+		{"code block", "```go\nfmt.Println()\n```", "document(block[go]`fmt.Println()\n`)"},
+		{"code block without language", "```\n**x**```", "document(block[]`**x**`)"},
+		{"blockquote", "> quoted\nafter", `document(blockquote("quoted") "after")`},
+		{"block quote to the end", ">>> one\ntwo", "document(blockquote(\"one\ntwo\"))"},
+		{"user mention", "hi <@" + id + ">", `document("hi " user_mention:` + id + `)`},
+		{"role mention", "<@&moderators>", `document(role_mention:moderators)`},
+		{"channel mention", "<#" + id + ">", `document(channel_mention:` + id + `)`},
+		{"invalid user mention", "<@not-a-uuid>", `document("<@not-a-uuid>")`},
+		{"everyone and here", "@everyone @here", `document(everyone_mention() " " here_mention())`},
+		{"email is not a mention", "me@here.com", `document("me@here.com")`},
+		{"mention in bold", "**<@" + id + ">**", `document(bold(user_mention:` + id + `))`},
+		{"custom emoji", "<:party:" + id + ">", `document(custom_emoji:` + id + `)`},
+	}
+
+	for _, test := range tests {
+		root, err := Parse(test.content)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if got := tree(root); got != test.want {
+			t.Errorf("%s: Parse(%q) = %s, want %s", test.name, test.content, got, test.want)
+		}
+	}
+}
+
+func TestParseNestingLimit(t *testing.T) {
+	root, err := Parse("**a __b ~~c ||d *e _f_ e* d|| c~~ b__ a**")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sixth level is past MAX_DEPTH and kept as literal text
+	want := `document(bold("a " underline("b " strikethrough("c " spoiler("d " italic("e _f_ e") " d") " c") " b") " a"))`
+	if got := tree(root); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseNodeLimit(t *testing.T) {
+	root, err := Parse(strings.Repeat("*a* ", MAX_CONTENT_LENGTH/4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := 0
+	root.walk(func(*Node) { nodes++ })
+	// A node opened right before the limit still gets its text
+	if nodes > MAX_NODES+MAX_DEPTH {
+		t.Errorf("%d nodes, want about %d", nodes, MAX_NODES)
+	}
+
+	last := root.Children[len(root.Children)-1]
+	if last.Type != Text || !strings.HasSuffix(last.Content, "*a* ") {
+		t.Errorf("the content past the limit is not kept as text: %s", tree(last))
+	}
+}
+
+func TestParseLengthLimit(t *testing.T) {
+	if _, err := Parse(strings.Repeat("a", MAX_CONTENT_LENGTH)); err != nil {
+		t.Errorf("content at the limit: %v", err)
+	}
+
+	if _, err := Parse(strings.Repeat("a", MAX_CONTENT_LENGTH+1)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("err = %v, want ErrTooLong", err)
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	user, channel := uuid.New(), uuid.New()
+
+	root, err := Parse("<@" + user.String() + "> <@" + user.String() + "> <@&mods> <#" + channel.String() + "> @here `@everyone <@" + uuid.NewString() + ">`")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mentions := ExtractMentions(root)
+	if len(mentions.Users) != 1 || mentions.Users[0] != user {
+		t.Errorf("users = %v, want %s once", mentions.Users, user)
+	}
+	if len(mentions.Roles) != 1 || mentions.Roles[0] != "mods" {
+		t.Errorf("roles = %v", mentions.Roles)
+	}
+	if len(mentions.Channels) != 1 || mentions.Channels[0] != channel {
+		t.Errorf("channels = %v", mentions.Channels)
+	}
+	if !mentions.Here || mentions.Everyone {
+		t.Errorf("here = %v, everyone = %v, the code span must not count", mentions.Here, mentions.Everyone)
+	}
+}
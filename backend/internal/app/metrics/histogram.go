@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HistogramVec counts observations in buckets, with a series for every
+// combination of label values
+type HistogramVec struct {
+	name    string
+	help    string
+	buckets []float64
+	labels  []string
+	mu      sync.Mutex
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	// counts are per bucket, they are summed up when written
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec registers a histogram, buckets are upper bounds in
+// increasing order and +Inf is added to them
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		labels:  labels,
+		series:  make(map[string]*histogram),
+	}
+	r.Register(h.collect)
+	return h
+}
+
+// Observe records a value, labelValues are given in the order of the labels
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", h.name, len(h.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogram{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = series
+	}
+
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		series.counts[i]++
+	}
+	series.sum += value
+	series.count++
+}
+
+func (h *HistogramVec) collect(w *Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w.Family(h.name, h.help, "histogram")
+	for _, key := range keys {
+		series := h.series[key]
+		labels := make([]Label, len(h.labels), len(h.labels)+1)
+		for i, name := range h.labels {
+			labels[i] = Label{Name: name, Value: series.labelValues[i]}
+		}
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i]
+			w.Sample(h.name+"_bucket", float64(cumulative), append(labels, Label{Name: "le", Value: formatFloat(bound)})...)
+		}
+		w.Sample(h.name+"_bucket", float64(series.count), append(labels, Label{Name: "le", Value: formatFloat(math.Inf(1))})...)
+		w.Sample(h.name+"_sum", series.sum, labels...)
+		w.Sample(h.name+"_count", float64(series.count), labels...)
+	}
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CONTENT_TYPE is the Prometheus text exposition format the registry writes
+const CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets suit request latencies in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Collector writes metrics read when they are scraped, like the size of a pool
+type Collector func(w *Writer)
+
+// Registry holds the metrics recorded as things happen, collectors passed to
+// Write add the ones read at scrape time
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) Register(collector Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, collector)
+}
+
+// Write writes every registered metric and then the extra collectors in the
+// text exposition format
+func (r *Registry) Write(out io.Writer, extra ...Collector) error {
+	r.mu.Lock()
+	collectors := append(append([]Collector(nil), r.collectors...), extra...)
+	r.mu.Unlock()
+
+	w := &Writer{out: bufio.NewWriter(out)}
+	for _, collect := range collectors {
+		collect(w)
+	}
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.out.Flush()
+}
+
+// Writer writes metric families, each Family call is followed by the samples
+// of that family
+type Writer struct {
+	out *bufio.Writer
+	err error
+}
+
+func (w *Writer) printf(format string, args ...interface{}) {
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.out, format, args...)
+	}
+}
+
+// Family starts a metric, kind is counter, gauge or histogram
+func (w *Writer) Family(name, help, kind string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (w *Writer) Sample(name string, value float64, labels ...Label) {
+	if len(labels) == 0 {
+		w.printf("%s %s\n", name, formatFloat(value))
+		return
+	}
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, label.Name, escape.Replace(label.Value))
+	}
+	w.printf("%s{%s} %s\n", name, strings.Join(pairs, ","), formatFloat(value))
+}
+
+func (w *Writer) Gauge(name, help string, value float64) {
+	w.Family(name, help, "gauge")
+	w.Sample(name, value)
+}
+
+func (w *Writer) Counter(name, help string, value float64) {
+	w.Family(name, help, "counter")
+	w.Sample(name, value)
+}
+
+// GaugeBy writes a series for every key of values, the key is the value of
+// the label
+func (w *Writer) GaugeBy(name, help, label string, values map[string]int) {
+	w.Family(name, help, "gauge")
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		w.Sample(name, float64(values[key]), Label{Name: label, Value: key})
+	}
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -2,28 +2,107 @@ package middleware
 
 import (
 	"discord-backend/internal/app/services"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
-func AuthMiddleware(c *gin.Context) {
-	tokenString, err := c.Cookie("access_token")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "token not found"})
+// AuthMiddleware accepts the access_token cookie of a signed in user, or an
+// "Authorization: Bot <token>" header for bot profiles. The session of the
+// cookie is looked up so revoking it or disabling the profile takes effect
+// before the access token expires
+func AuthMiddleware(botService *services.BotService, tokenService *services.TokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authorization := c.GetHeader("Authorization"); strings.HasPrefix(authorization, "Bot ") {
+			botToken, err := botService.AuthenticateBotToken(strings.TrimPrefix(authorization, "Bot "))
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+				c.Abort()
+				return
+			}
+
+			c.Set("profile_id", botToken.BotID.String())
+			c.Set("name", botToken.Bot.Name)
+			c.Set("is_bot", true)
+			c.Set("bot_token_id", botToken.ID.String())
+
+			c.Next()
+			return
+		}
+
+		tokenString, err := c.Cookie("access_token")
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token not found"})
+			c.Abort()
+			return
+		}
+
+		// Refresh tokens and sign in challenges have their own audience and are refused
+		claims, err := services.VerifyToken(tokenString, services.AccessTokenType)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			c.Abort()
+			return
+		}
+
+		profileID, err := uuid.Parse(fmt.Sprint(claims["profile_id"]))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			c.Abort()
+			return
+		}
+
+		sessionID, err := uuid.Parse(fmt.Sprint(claims["session_id"]))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			c.Abort()
+			return
+		}
+
+		active, err := tokenService.IsSessionActive(profileID, sessionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking session: " + err.Error()})
+			c.Abort()
+			return
+		}
+
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session revoked"})
+			c.Abort()
+			return
+		}
+
+		c.Set("profile_id", claims["profile_id"])
+		c.Set("name", claims["name"])
+		c.Set("session_id", claims["session_id"])
+		c.Set("is_bot", false)
+
+		c.Next()
+	}
+}
+
+// UserOnly rejects bots on routes that only make sense for people, like
+// managing bots and their tokens
+func UserOnly(c *gin.Context) {
+	if c.GetBool("is_bot") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "bots cannot use this endpoint"})
 		c.Abort()
 		return
 	}
 
-	claims, err := services.VerifyToken(tokenString)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	c.Next()
+}
+
+// BotOnly is the opposite of UserOnly, for the bot gateway
+func BotOnly(c *gin.Context) {
+	if !c.GetBool("is_bot") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only bots can use this endpoint"})
 		c.Abort()
 		return
 	}
 
-	c.Set("profile_id", claims["profile_id"])
-	c.Set("name", claims["name"])
-
 	c.Next()
 }
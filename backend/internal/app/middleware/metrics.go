@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"discord-backend/internal/app/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records the latency and status of every request by route, the route
+// is the pattern so ids in paths do not make a series each
+func Metrics(registry *metrics.Registry) gin.HandlerFunc {
+	duration := registry.NewHistogramVec("http_request_duration_seconds",
+		"Time taken to answer HTTP requests, by method, route and status.",
+		metrics.DefaultBuckets, "method", "route", "status")
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		duration.Observe(time.Since(start).Seconds(), c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+	}
+}
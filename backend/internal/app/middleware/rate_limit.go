@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"discord-backend/internal/app/ratelimit"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc picks the principal a request is counted against
+type KeyFunc func(c *gin.Context) string
+
+// ByIP counts requests per client address, for routes used before signing in
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByPrincipal counts requests per bot token or profile, falling back to the
+// address when the request is not authenticated
+func ByPrincipal(c *gin.Context) string {
+	if botTokenID := c.GetString("bot_token_id"); botTokenID != "" {
+		return "bot:" + botTokenID
+	}
+
+	if profileID, ok := c.Get("profile_id"); ok {
+		return fmt.Sprintf("profile:%v", profileID)
+	}
+
+	return ByIP(c)
+}
+
+// TooManyRequests answers 429 with the seconds to wait in Retry-After
+func TooManyRequests(c *gin.Context, retryAfter time.Duration, message string) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": message, "retryAfter": retryAfter.Seconds()})
+}
+
+// RateLimit takes a token from the bucket of the principal for every request
+func RateLimit(limiter *ratelimit.Limiter, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if allowed, retryAfter := limiter.Allow(key(c)); !allowed {
+			TooManyRequests(c, retryAfter, "Too many requests, try again later")
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AccountTokenPurpose string
+
+const (
+	AccountTokenVerifyEmail   AccountTokenPurpose = "VERIFY_EMAIL"
+	AccountTokenResetPassword AccountTokenPurpose = "RESET_PASSWORD"
+)
+
+// AccountToken is a single use token mailed to a profile, only its hash is
+// kept. Email is the address it was sent to so a verification link stops
+// working once the email changes
+type AccountToken struct {
+	ID        uuid.UUID           `gorm:"type:uuid;primary_key;"`
+	ProfileID uuid.UUID           `gorm:"type:uuid;index;not null"`
+	Profile   Profile             `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;"`
+	Purpose   AccountTokenPurpose `gorm:"type:varchar(20);not null"`
+	Email     string              `gorm:"type:text"`
+	TokenHash string              `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time           `gorm:"index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (accountToken *AccountToken) BeforeCreate(tx *gorm.DB) (err error) {
+	accountToken.ID = uuid.New()
+	return
+}
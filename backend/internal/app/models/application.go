@@ -0,0 +1,118 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Application is the integration side of a bot, interactions for its commands
+// are posted to CallbackURL signed with Secret
+type Application struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	BotID       uuid.UUID `gorm:"uniqueIndex" json:"botID"`
+	Bot         Profile   `gorm:"foreignKey:BotID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	CallbackURL string    `gorm:"type:text" json:"callbackUrl"`
+	Secret      string    `json:"-"`
+	CreatedAt   time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (application *Application) BeforeCreate(tx *gorm.DB) (err error) {
+	application.ID = uuid.New()
+	return
+}
+
+type CommandOptionType string
+
+const (
+	OptionString  CommandOptionType = "STRING"
+	OptionInteger CommandOptionType = "INTEGER"
+	OptionNumber  CommandOptionType = "NUMBER"
+	OptionBoolean CommandOptionType = "BOOLEAN"
+	OptionUser    CommandOptionType = "USER"
+	OptionChannel CommandOptionType = "CHANNEL"
+)
+
+type CommandChoice struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+type CommandOption struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Type        CommandOptionType `json:"type"`
+	Required    bool              `json:"required"`
+	Choices     []CommandChoice   `json:"choices,omitempty"`
+}
+
+type Command struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primary_key;" json:"id"`
+	ApplicationID uuid.UUID       `gorm:"index" json:"applicationID"`
+	Application   Application     `gorm:"foreignKey:ApplicationID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ServerID      uuid.UUID       `gorm:"uniqueIndex:idx_commands_server_name" json:"serverID"`
+	Server        Server          `gorm:"foreignKey:ServerID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	Name          string          `gorm:"uniqueIndex:idx_commands_server_name" json:"name"`
+	Description   string          `json:"description"`
+	Options       []CommandOption `gorm:"serializer:json" json:"options"`
+	CreatedAt     time.Time       `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+func (command *Command) BeforeCreate(tx *gorm.DB) (err error) {
+	command.ID = uuid.New()
+	return
+}
+
+type InteractionStatus string
+
+const (
+	InteractionPending   InteractionStatus = "PENDING"
+	InteractionDeferred  InteractionStatus = "DEFERRED"
+	InteractionResponded InteractionStatus = "RESPONDED"
+	InteractionFailed    InteractionStatus = "FAILED"
+)
+
+type InteractionResponseType string
+
+const (
+	ResponseMessage   InteractionResponseType = "MESSAGE"
+	ResponseEphemeral InteractionResponseType = "EPHEMERAL"
+	ResponseDeferred  InteractionResponseType = "DEFERRED"
+)
+
+type Interaction struct {
+	ID            uuid.UUID              `gorm:"type:uuid;primary_key;" json:"id"`
+	CommandID     uuid.UUID              `gorm:"index" json:"commandID"`
+	Command       Command                `gorm:"foreignKey:CommandID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ApplicationID uuid.UUID              `gorm:"index" json:"applicationID"`
+	ServerID      uuid.UUID              `gorm:"index" json:"serverID"`
+	ChannelID     uuid.UUID              `json:"channelID"`
+	MemberID      uuid.UUID              `json:"memberID"`
+	ProfileID     uuid.UUID              `json:"profileID"`
+	Options       map[string]interface{} `gorm:"serializer:json" json:"options"`
+	Status        InteractionStatus      `gorm:"type:varchar(100);default:'PENDING'" json:"status"`
+	ResponseType  string                 `json:"responseType,omitempty"`
+	MessageID     *uuid.UUID             `json:"messageID,omitempty"`
+	Error         string                 `gorm:"type:text" json:"error,omitempty"`
+	RespondedAt   *time.Time             `json:"respondedAt"`
+	CreatedAt     time.Time              `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+}
+
+func (interaction *Interaction) BeforeCreate(tx *gorm.DB) (err error) {
+	interaction.ID = uuid.New()
+	return
+}
+
+// InteractionResponse is what the application answers, either in the body of
+// the callback or later for a deferred interaction
+type InteractionResponse struct {
+	Type InteractionResponseType `json:"type"`
+	Data struct {
+		Content string  `json:"content"`
+		Embeds  []Embed `json:"embeds,omitempty"`
+	} `json:"data"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BotToken struct {
+	ID    uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	BotID uuid.UUID `gorm:"index" json:"botID"`
+	Bot   Profile   `gorm:"foreignKey:BotID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	Name  string    `json:"name"`
+	// Only the sha256 of the token is stored, Prefix lets owners tell tokens apart
+	TokenHash  string     `gorm:"uniqueIndex" json:"-"`
+	Prefix     string     `json:"prefix"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (botToken *BotToken) BeforeCreate(tx *gorm.DB) (err error) {
+	botToken.ID = uuid.New()
+	return
+}
@@ -17,16 +17,18 @@ const (
 )
 
 type Channel struct {
-	ID        uuid.UUID   `gorm:"type:uuid;primary_key;" json:"id"`
-	Name      string      `json:"name"`
-	Type      ChannelType `gorm:"type:varchar(100);default:'TEXT'" json:"type"`
-	ProfileID uuid.UUID   `json:"profileID"`
-	Profile   Profile     `gorm:"foreignKey:ProfileID;references:ID;onDelete:CASCADE" json:"profile"`
-	ServerID  uuid.UUID   `json:"serverID"`
-	Server    Server      `gorm:"foreignKey:ServerID;references:ID;onDelete:CASCADE" json:"server"`
-	Messages  []Message   `json:"messages"`
-	CreatedAt time.Time   `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;" json:"id"`
+	Name      string         `json:"name"`
+	Type      ChannelType    `gorm:"type:varchar(100);default:'TEXT'" json:"type"`
+	Slowmode  int            `gorm:"default:0" json:"slowmode"`
+	ProfileID uuid.UUID      `json:"profileID"`
+	Profile   Profile        `gorm:"foreignKey:ProfileID;references:ID;onDelete:CASCADE" json:"profile"`
+	ServerID  uuid.UUID      `json:"serverID"`
+	Server    Server         `gorm:"foreignKey:ServerID;references:ID;onDelete:CASCADE" json:"server"`
+	Messages  []Message      `json:"messages"`
+	CreatedAt time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 func (channel *Channel) BeforeCreate(tx *gorm.DB) (err error) {
@@ -7,18 +7,62 @@ import (
 	"gorm.io/gorm"
 )
 
+// Conversation is a 1:1 or group DM between profiles, it does not belong to any server
 type Conversation struct {
-	ID             uuid.UUID       `gorm:"type:uuid;primary_key;" json:"id"`
-	MemberOneID    uuid.UUID       `gorm:"index:,unique" json:"memberOneID"`
-	MemberOne      Member          `gorm:"foreignKey:MemberOneID;references:ID;onDelete:CASCADE" json:"memberOne"`
-	MemberTwoID    uuid.UUID       `gorm:"index:,unique;index" json:"memberTwoID"`
-	MemberTwo      Member          `gorm:"foreignKey:MemberTwoID;references:ID;onDelete:CASCADE" json:"memberTwo"`
-	DirectMessages []DirectMessage `json:"directMessages"`
-	CreatedAt      time.Time       `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt      time.Time       `json:"updated_at"`
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	Name     string    `gorm:"type:varchar(100)" json:"name"`
+	ImageURL string    `gorm:"type:text" json:"imageUrl"`
+	IsGroup  bool      `gorm:"default:false" json:"isGroup"`
+	// OwnerID is the profile that can remove participants of a group
+	OwnerID *uuid.UUID `gorm:"type:uuid" json:"ownerId"`
+	// DirectKey is set on 1:1 conversations to the two sorted profile ids,
+	// there can only be one conversation per pair
+	DirectKey      *string                   `gorm:"uniqueIndex" json:"-"`
+	Participants   []ConversationParticipant `gorm:"foreignKey:ConversationID" json:"participants"`
+	DirectMessages []DirectMessage           `json:"directMessages"`
+	CreatedAt      time.Time                 `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt      time.Time                 `json:"updated_at"`
 }
 
 func (conversation *Conversation) BeforeCreate(tx *gorm.DB) (err error) {
 	conversation.ID = uuid.New()
 	return
 }
+
+// DirectKey identifies the 1:1 conversation of two profiles whatever their order
+func DirectKey(profileOneID, profileTwoID uuid.UUID) string {
+	one, two := profileOneID.String(), profileTwoID.String()
+	if one > two {
+		one, two = two, one
+	}
+	return one + ":" + two
+}
+
+// HasParticipant only looks at the participants already loaded
+func (conversation *Conversation) HasParticipant(profileID uuid.UUID) bool {
+	for _, participant := range conversation.Participants {
+		if participant.ProfileID == profileID {
+			return true
+		}
+	}
+	return false
+}
+
+type ConversationParticipant struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primary_key;" json:"id"`
+	ConversationID uuid.UUID    `gorm:"uniqueIndex:idx_conversation_participants_conversation_profile" json:"conversationId"`
+	Conversation   Conversation `gorm:"foreignKey:ConversationID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ProfileID      uuid.UUID    `gorm:"uniqueIndex:idx_conversation_participants_conversation_profile;index" json:"profileID"`
+	Profile        Profile      `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;" json:"profile"`
+	// Request is set while a conversation started by a non friend waits in the
+	// requests inbox of this participant
+	Request   bool      `gorm:"default:false" json:"request"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (participant *ConversationParticipant) BeforeCreate(tx *gorm.DB) (err error) {
+	if participant.ID == uuid.Nil {
+		participant.ID = uuid.New()
+	}
+	return
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"discord-backend/internal/app/markdown"
 	"fmt"
 	"time"
 
@@ -12,13 +13,19 @@ type DirectMessage struct {
 	ID             uuid.UUID    `gorm:"type:uuid;primary_key;" json:"id"`
 	Content        string       `gorm:"type:text" json:"content"`
 	FileURL        *string      `gorm:"type:text" json:"fileUrl"`
-	MemberID       uuid.UUID    `json:"memberID"`
-	Member         Member       `gorm:"foreignKey:MemberID;references:ID;constraint:OnDelete:CASCADE;" json:"member"`
+	ProfileID      uuid.UUID    `gorm:"index" json:"profileID"`
+	Profile        Profile      `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;" json:"profile"`
 	ConversationID uuid.UUID    `json:"conversationId"`
 	Conversation   Conversation `gorm:"foreignKey:ConversationID;references:ID;constraint:OnDelete:CASCADE;" json:"conversation"`
 	Deleted        bool         `gorm:"default:false" json:"deleted"`
+	EditedAt       *time.Time   `json:"edited_at"`
+	Pinned         bool         `gorm:"default:false" json:"pinned"`
+	PinnedAt       *time.Time   `gorm:"index" json:"pinned_at"`
 	CreatedAt      time.Time    `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt      time.Time    `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	// AST and Mentions are parsed from Content by SetContent and stored with it
+	AST      *markdown.Node     `gorm:"serializer:json" json:"ast,omitempty"`
+	Mentions *markdown.Mentions `gorm:"serializer:json" json:"mentions,omitempty"`
 }
 
 func (directMessage *DirectMessage) BeforeCreate(tx *gorm.DB) (err error) {
@@ -32,6 +39,18 @@ func (directMessage *DirectMessage) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// SetContent is Message.SetContent for direct messages
+func (directMessage *DirectMessage) SetContent(content string) error {
+	ast, err := markdown.Parse(content)
+	if err != nil {
+		return err
+	}
+
+	mentions := markdown.ExtractMentions(ast)
+	directMessage.Content, directMessage.AST, directMessage.Mentions = content, ast, &mentions
+	return nil
+}
+
 func (directMessage *DirectMessage) Validate() error {
 	if directMessage.Content == "" {
 		return fmt.Errorf("Content cannot be empty")
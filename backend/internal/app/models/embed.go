@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	MAX_EMBEDS                 = 10
+	MAX_EMBED_TITLE            = 256
+	MAX_EMBED_DESCRIPTION      = 4096
+	MAX_EMBED_FIELDS           = 25
+	MAX_EMBED_FIELD_NAME       = 256
+	MAX_EMBED_FIELD_VALUE      = 1024
+	MAX_EMBED_FOOTER           = 2048
+	MAX_EMBED_TOTAL_CHARACTERS = 6000
+)
+
+type EmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Embed is a simple rich card attached to a message, stored as json on the message row
+type Embed struct {
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	URL         string       `json:"url,omitempty"`
+	Color       int          `json:"color,omitempty"`
+	ImageURL    string       `json:"imageUrl,omitempty"`
+	Footer      string       `json:"footer,omitempty"`
+	Timestamp   *time.Time   `json:"timestamp,omitempty"`
+	Fields      []EmbedField `json:"fields,omitempty"`
+}
+
+func ValidateEmbeds(embeds []Embed) error {
+	if len(embeds) > MAX_EMBEDS {
+		return fmt.Errorf("at most %d embeds are allowed", MAX_EMBEDS)
+	}
+
+	total := 0
+	for i, embed := range embeds {
+		if len(embed.Title) > MAX_EMBED_TITLE {
+			return fmt.Errorf("embed %d: title is longer than %d characters", i, MAX_EMBED_TITLE)
+		}
+
+		if len(embed.Description) > MAX_EMBED_DESCRIPTION {
+			return fmt.Errorf("embed %d: description is longer than %d characters", i, MAX_EMBED_DESCRIPTION)
+		}
+
+		if len(embed.Footer) > MAX_EMBED_FOOTER {
+			return fmt.Errorf("embed %d: footer is longer than %d characters", i, MAX_EMBED_FOOTER)
+		}
+
+		if embed.Color < 0 || embed.Color > 0xFFFFFF {
+			return fmt.Errorf("embed %d: color must be between 0 and 0xFFFFFF", i)
+		}
+
+		if len(embed.Fields) > MAX_EMBED_FIELDS {
+			return fmt.Errorf("embed %d: at most %d fields are allowed", i, MAX_EMBED_FIELDS)
+		}
+
+		total += len(embed.Title) + len(embed.Description) + len(embed.Footer)
+		for j, field := range embed.Fields {
+			if field.Name == "" || field.Value == "" {
+				return fmt.Errorf("embed %d field %d: name and value are required", i, j)
+			}
+
+			if len(field.Name) > MAX_EMBED_FIELD_NAME || len(field.Value) > MAX_EMBED_FIELD_VALUE {
+				return fmt.Errorf("embed %d field %d: name or value is too long", i, j)
+			}
+
+			total += len(field.Name) + len(field.Value)
+		}
+	}
+
+	if total > MAX_EMBED_TOTAL_CHARACTERS {
+		return fmt.Errorf("embeds cannot contain more than %d characters in total", MAX_EMBED_TOTAL_CHARACTERS)
+	}
+
+	return nil
+}
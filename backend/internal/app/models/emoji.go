@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Emoji is a custom server emoji, used in content as <:name:id> or
+// <a:name:id> when animated
+type Emoji struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	ServerID   uuid.UUID `gorm:"uniqueIndex:idx_emojis_server_name" json:"serverID"`
+	Server     Server    `gorm:"foreignKey:ServerID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	Name       string    `gorm:"uniqueIndex:idx_emojis_server_name" json:"name"`
+	Animated   bool      `gorm:"default:false" json:"animated"`
+	ImageURL   string    `gorm:"type:text" json:"imageUrl"`
+	StorageKey string    `gorm:"type:text" json:"-"`
+	ProfileID  uuid.UUID `json:"profileID"`
+	CreatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (emoji *Emoji) BeforeCreate(tx *gorm.DB) (err error) {
+	if emoji.ID == uuid.Nil {
+		emoji.ID = uuid.New()
+	}
+	return
+}
+
+// Reaction is one member reacting to a message with either a unicode Emoji or
+// the custom emoji of EmojiID, Emoji is empty for custom ones so renaming the
+// emoji does not change the reaction
+type Reaction struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	MessageID uuid.UUID  `gorm:"uniqueIndex:idx_reactions_message_member_emoji,where:emoji_id IS NULL;uniqueIndex:idx_reactions_message_member_emoji_id" json:"messageID"`
+	Message   Message    `gorm:"foreignKey:MessageID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	MemberID  uuid.UUID  `gorm:"uniqueIndex:idx_reactions_message_member_emoji,where:emoji_id IS NULL;uniqueIndex:idx_reactions_message_member_emoji_id" json:"memberID"`
+	Member    Member     `gorm:"foreignKey:MemberID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	Emoji     string     `gorm:"uniqueIndex:idx_reactions_message_member_emoji,where:emoji_id IS NULL" json:"emoji"`
+	EmojiID   *uuid.UUID `gorm:"index;uniqueIndex:idx_reactions_message_member_emoji_id" json:"emojiID,omitempty"`
+	Custom    *Emoji     `gorm:"foreignKey:EmojiID;references:ID;constraint:OnDelete:CASCADE;" json:"customEmoji,omitempty"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// Key tells reactions with the same emoji apart from the others
+func (reaction *Reaction) Key() string {
+	if reaction.EmojiID != nil {
+		return reaction.EmojiID.String()
+	}
+	return reaction.Emoji
+}
+
+func (reaction *Reaction) BeforeCreate(tx *gorm.DB) (err error) {
+	reaction.ID = uuid.New()
+	return
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ExternalIdentity links a profile to its account at an OpenID Connect
+// provider, Subject is the provider's stable id for the user
+type ExternalIdentity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	ProfileID uuid.UUID `gorm:"type:uuid;index;not null" json:"profileId"`
+	Profile   Profile   `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	Provider  string    `gorm:"type:varchar(50);uniqueIndex:idx_external_identity_subject;not null" json:"provider"`
+	Subject   string    `gorm:"type:varchar(255);uniqueIndex:idx_external_identity_subject;not null" json:"-"`
+	Email     string    `gorm:"type:text" json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (identity *ExternalIdentity) BeforeCreate(tx *gorm.DB) (err error) {
+	identity.ID = uuid.New()
+	return
+}
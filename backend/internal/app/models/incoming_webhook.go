@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type IncomingWebhook struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	Name      string    `json:"name"`
+	AvatarURL string    `gorm:"type:text" json:"avatarUrl"`
+	// Only the sha256 of the token is stored, the token itself is shown once
+	TokenHash string    `gorm:"uniqueIndex" json:"-"`
+	ServerID  uuid.UUID `gorm:"index" json:"serverID"`
+	Server    Server    `gorm:"foreignKey:ServerID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ChannelID uuid.UUID `gorm:"index" json:"channelID"`
+	Channel   Channel   `gorm:"foreignKey:ChannelID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ProfileID uuid.UUID `json:"profileID"`
+	Profile   Profile   `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (webhook *IncomingWebhook) BeforeCreate(tx *gorm.DB) (err error) {
+	webhook.ID = uuid.New()
+	return
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LinkPreview caches the unfurled embed of a URL, Embed is nil when the page
+// had nothing to show or could not be fetched, so it is not retried every message
+type LinkPreview struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	URL       string    `gorm:"type:text;uniqueIndex" json:"url"`
+	Embed     *Embed    `gorm:"serializer:json" json:"embed"`
+	Error     string    `gorm:"type:text" json:"error,omitempty"`
+	FetchedAt time.Time `gorm:"index" json:"fetchedAt"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (linkPreview *LinkPreview) BeforeCreate(tx *gorm.DB) (err error) {
+	linkPreview.ID = uuid.New()
+	return
+}
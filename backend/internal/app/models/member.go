@@ -17,18 +17,15 @@ const (
 )
 
 type Member struct {
-	ID                     uuid.UUID       `gorm:"type:uuid;primary_key;" json:"id"`
-	Role                   MemberRole      `gorm:"type:varchar(100);default:'GUEST'" json:"role"`
-	ProfileID              uuid.UUID       `json:"profileID"`
-	Profile                Profile         `gorm:"foreignKey:ProfileID;references:ID;onDelete:CASCADE" json:"profile"`
-	ServerID               uuid.UUID       `json:"serverID"`
-	Server                 Server          `gorm:"foreignKey:ServerID;references:ID;onDelete:CASCADE" json:"server"`
-	Messages               []Message       `json:"messages"`
-	DirectMessages         []DirectMessage `json:"directMessages"`
-	ConversationsInitiated []Conversation  `gorm:"foreignKey:MemberOneID" json:"conversationsInitiated"`
-	ConversationsReceived  []Conversation  `gorm:"foreignKey:MemberTwoID" json:"conversationsReceived"`
-	CreatedAt              time.Time       `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt              time.Time       `json:"updated_at"`
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	Role      MemberRole `gorm:"type:varchar(100);default:'GUEST'" json:"role"`
+	ProfileID uuid.UUID  `json:"profileID"`
+	Profile   Profile    `gorm:"foreignKey:ProfileID;references:ID;onDelete:CASCADE" json:"profile"`
+	ServerID  uuid.UUID  `json:"serverID"`
+	Server    Server     `gorm:"foreignKey:ServerID;references:ID;onDelete:CASCADE" json:"server"`
+	Messages  []Message  `json:"messages"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
 }
 
 func (member *Member) BeforeCreate(tx *gorm.DB) (err error) {
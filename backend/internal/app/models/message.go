@@ -1,6 +1,7 @@
 package models
 
 import (
+	"discord-backend/internal/app/markdown"
 	"fmt"
 	"time"
 
@@ -9,16 +10,30 @@ import (
 )
 
 type Message struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	Content   string    `gorm:"type:text" json:"content"`
-	FileURL   *string   `gorm:"type:text" json:"fileUrl"`
-	MemberID  uuid.UUID `json:"memberID"`
-	Member    Member    `gorm:"foreignKey:MemberID;references:ID;onDelete:CASCADE" json:"member"`
-	ChannelID uuid.UUID `json:"channelID"`
-	Channel   Channel   `gorm:"foreignKey:ChannelID;references:ID;onDelete:CASCADE" json:"channel"`
-	Deleted   bool      `gorm:"default:false" json:"deleted"`
-	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	Content   string     `gorm:"type:text" json:"content"`
+	FileURL   *string    `gorm:"type:text" json:"fileUrl"`
+	MemberID  *uuid.UUID `json:"memberID"`
+	Member    *Member    `gorm:"foreignKey:MemberID;references:ID;onDelete:CASCADE" json:"member,omitempty"`
+	ChannelID uuid.UUID  `json:"channelID"`
+	Channel   Channel    `gorm:"foreignKey:ChannelID;references:ID;onDelete:CASCADE" json:"channel"`
+	// Messages posted through an incoming webhook have no member, the author is
+	// the webhook identity with optional per message overrides
+	WebhookID       *uuid.UUID       `gorm:"index" json:"webhookID,omitempty"`
+	Webhook         *IncomingWebhook `gorm:"foreignKey:WebhookID;references:ID;constraint:OnDelete:SET NULL;" json:"-"`
+	AuthorName      string           `json:"authorName,omitempty"`
+	AuthorAvatarURL string           `gorm:"type:text" json:"authorAvatarUrl,omitempty"`
+	Embeds          []Embed          `gorm:"serializer:json" json:"embeds,omitempty"`
+	Reactions       []Reaction       `gorm:"foreignKey:MessageID" json:"reactions,omitempty"`
+	Deleted         bool             `gorm:"default:false" json:"deleted"`
+	EditedAt        *time.Time       `json:"edited_at"`
+	Pinned          bool             `gorm:"default:false" json:"pinned"`
+	PinnedAt        *time.Time       `gorm:"index" json:"pinned_at"`
+	CreatedAt       time.Time        `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt       time.Time        `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	// AST and Mentions are parsed from Content by SetContent and stored with it
+	AST      *markdown.Node     `gorm:"serializer:json" json:"ast,omitempty"`
+	Mentions *markdown.Mentions `gorm:"serializer:json" json:"mentions,omitempty"`
 }
 
 func (message *Message) BeforeCreate(tx *gorm.DB) (err error) {
@@ -32,8 +47,33 @@ func (message *Message) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (message *Message) IsAuthor(memberID uuid.UUID) bool {
+	return message.MemberID != nil && *message.MemberID == memberID
+}
+
+// AuthorProfileID is the profile that wrote the message, nil for webhooks
+func (message *Message) AuthorProfileID() uuid.UUID {
+	if message.Member == nil {
+		return uuid.Nil
+	}
+	return message.Member.ProfileID
+}
+
+// SetContent parses content into the tree and mentions stored next to it,
+// content over the markdown limit is refused with markdown.ErrTooLong
+func (message *Message) SetContent(content string) error {
+	ast, err := markdown.Parse(content)
+	if err != nil {
+		return err
+	}
+
+	mentions := markdown.ExtractMentions(ast)
+	message.Content, message.AST, message.Mentions = content, ast, &mentions
+	return nil
+}
+
 func (message *Message) Validate() error {
-	if message.Content == "" {
+	if message.Content == "" && len(message.Embeds) == 0 {
 		return fmt.Errorf("Content cannot be empty")
 	}
 	return nil
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type MessageRevision struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	MessageID uuid.UUID `gorm:"index" json:"messageID"`
+	Message   Message   `gorm:"foreignKey:MessageID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	Content   string    `gorm:"type:text" json:"content"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (revision *MessageRevision) BeforeCreate(tx *gorm.DB) (err error) {
+	revision.ID = uuid.New()
+	return
+}
+
+type DirectMessageRevision struct {
+	ID              uuid.UUID     `gorm:"type:uuid;primary_key;" json:"id"`
+	DirectMessageID uuid.UUID     `gorm:"index" json:"directMessageID"`
+	DirectMessage   DirectMessage `gorm:"foreignKey:DirectMessageID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	Content         string        `gorm:"type:text" json:"content"`
+	CreatedAt       time.Time     `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (revision *DirectMessageRevision) BeforeCreate(tx *gorm.DB) (err error) {
+	revision.ID = uuid.New()
+	return
+}
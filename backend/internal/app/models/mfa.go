@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MFASecret is the TOTP secret of a profile. It is pending until EnabledAt is
+// set by a first valid code, LastCounter is the last time step used so a code
+// cannot be replayed
+type MFASecret struct {
+	ProfileID   uuid.UUID `gorm:"type:uuid;primary_key;"`
+	Profile     Profile   `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;"`
+	Secret      string    `gorm:"type:varchar(64);not null"`
+	LastCounter int64     `gorm:"not null"`
+	EnabledAt   *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// RecoveryCode signs in once when the authenticator is lost, only its hash is kept
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;"`
+	ProfileID uuid.UUID `gorm:"type:uuid;index;not null"`
+	Profile   Profile   `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;"`
+	CodeHash  string    `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (recoveryCode *RecoveryCode) BeforeCreate(tx *gorm.DB) (err error) {
+	recoveryCode.ID = uuid.New()
+	return
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FriendRequestPolicy string
+
+const (
+	FriendRequestsEveryone         FriendRequestPolicy = "EVERYONE"
+	FriendRequestsFriendsOfFriends FriendRequestPolicy = "FRIENDS_OF_FRIENDS"
+	FriendRequestsNobody           FriendRequestPolicy = "NOBODY"
+)
+
+// PrivacySettings decide who can reach a profile. Profiles without a row use
+// DefaultPrivacySettings, the columns have no database default so false is
+// always written
+type PrivacySettings struct {
+	ProfileID uuid.UUID `gorm:"type:uuid;primary_key;" json:"profileID"`
+	Profile   Profile   `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	// AllowServerDMs lets members of shared servers start a conversation, a
+	// ServerPrivacyOverride takes precedence for its server
+	AllowServerDMs bool                `json:"allowServerDMs"`
+	FriendRequests FriendRequestPolicy `gorm:"type:varchar(20)" json:"friendRequests"`
+	// MessageRequests puts conversations started by non friends in the requests inbox
+	MessageRequests bool                    `json:"messageRequests"`
+	ServerOverrides []ServerPrivacyOverride `gorm:"foreignKey:ProfileID;references:ProfileID" json:"serverOverrides"`
+	UpdatedAt       time.Time               `json:"updated_at"`
+}
+
+func DefaultPrivacySettings(profileID uuid.UUID) PrivacySettings {
+	return PrivacySettings{
+		ProfileID:       profileID,
+		AllowServerDMs:  true,
+		FriendRequests:  FriendRequestsEveryone,
+		MessageRequests: true,
+		ServerOverrides: []ServerPrivacyOverride{},
+	}
+}
+
+type ServerPrivacyOverride struct {
+	ProfileID uuid.UUID `gorm:"type:uuid;primary_key;" json:"profileID"`
+	Profile   Profile   `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ServerID  uuid.UUID `gorm:"type:uuid;primary_key;index" json:"serverID"`
+	Server    Server    `gorm:"foreignKey:ServerID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	AllowDMs  bool      `json:"allowDMs"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -8,16 +8,25 @@ import (
 )
 
 type Profile struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	Name      string    `json:"name"`
-	ImageURL  string    `gorm:"type:text" json:"imageUrl"`
-	Email     string    `gorm:"type:text" json:"email"`
-	Password  string    `json:"-"`
-	Servers   []Server  `gorm:"foreignKey:ProfileID" json:"servers"`
-	Members   []Member  `gorm:"foreignKey:ProfileID" json:"members"`
-	Channels  []Channel `gorm:"foreignKey:ProfileID" json:"channels"`
-	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	Name     string    `json:"name"`
+	ImageURL string    `gorm:"type:text" json:"imageUrl"`
+	Email    string    `gorm:"type:text" json:"email"`
+	Password string    `json:"-"`
+	// EmailVerifiedAt is set once the profile opens the link mailed to Email
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	// MFAEnabled asks for a TOTP or recovery code after the password
+	MFAEnabled bool      `json:"mfaEnabled"`
+	Servers    []Server  `gorm:"foreignKey:ProfileID" json:"servers"`
+	Members    []Member  `gorm:"foreignKey:ProfileID" json:"members"`
+	Channels   []Channel `gorm:"foreignKey:ProfileID" json:"channels"`
+	// Bot profiles cannot sign in, they authenticate with a BotToken
+	IsBot      bool       `gorm:"default:false" json:"isBot"`
+	BotOwnerID *uuid.UUID `gorm:"index" json:"botOwnerID,omitempty"`
+	// DisabledAt is set by an operator, the profile cannot sign in until it is cleared
+	DisabledAt *time.Time `json:"-"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
 }
 
 func (profile *Profile) BeforeCreate(tx *gorm.DB) (err error) {
@@ -26,13 +35,16 @@ func (profile *Profile) BeforeCreate(tx *gorm.DB) (err error) {
 }
 
 type ProfileResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	ImageURL  string    `json:"imageUrl"`
-	Email     string    `json:"email"`
-	Servers   []Server  `json:"servers"`
-	Members   []Member  `json:"members"`
-	Channels  []Channel `json:"channels"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              uuid.UUID  `json:"id"`
+	Name            string     `json:"name"`
+	ImageURL        string     `json:"imageUrl"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	MFAEnabled      bool       `json:"mfaEnabled"`
+	Servers         []Server   `json:"servers"`
+	Members         []Member   `json:"members"`
+	Channels        []Channel  `json:"channels"`
+	IsBot           bool       `json:"isBot"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
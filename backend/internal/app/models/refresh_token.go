@@ -7,12 +7,17 @@ import (
 	"gorm.io/gorm"
 )
 
+// RefreshToken is one token of a session family, only its hash is kept.
+// UsedAt is set when it is exchanged for the next one
 type RefreshToken struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;"`
-	ProfileID uuid.UUID `gorm:"unique;"`
-	Profile   Profile   `gorm:"foreignKey:ProfileID;references:ID;onDelete:CASCADE"`
-	Token     string    `gorm:"size:500"`
+	ProfileID uuid.UUID `gorm:"type:uuid;index;not null"`
+	Profile   Profile   `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;"`
+	SessionID uuid.UUID `gorm:"type:uuid;index;not null"`
+	Session   Session   `gorm:"foreignKey:SessionID;references:ID;constraint:OnDelete:CASCADE;"`
+	TokenHash string    `gorm:"type:varchar(64);uniqueIndex;not null"`
 	ExpiresAt time.Time
+	UsedAt    *time.Time
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
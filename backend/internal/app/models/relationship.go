@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RelationshipType string
+
+const (
+	RelationshipFriend   RelationshipType = "FRIEND"
+	RelationshipIncoming RelationshipType = "PENDING_INCOMING"
+	RelationshipOutgoing RelationshipType = "PENDING_OUTGOING"
+	RelationshipBlocked  RelationshipType = "BLOCKED"
+)
+
+// Relationship is how ProfileID sees TargetID. Friendships and pending
+// requests have a row on both sides, a block only on the side of the blocker
+type Relationship struct {
+	ID        uuid.UUID        `gorm:"type:uuid;primary_key;" json:"id"`
+	ProfileID uuid.UUID        `gorm:"uniqueIndex:idx_relationships_profile_target" json:"profileID"`
+	Profile   Profile          `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	TargetID  uuid.UUID        `gorm:"uniqueIndex:idx_relationships_profile_target;index" json:"targetID"`
+	Target    Profile          `gorm:"foreignKey:TargetID;references:ID;constraint:OnDelete:CASCADE;" json:"target"`
+	Type      RelationshipType `gorm:"type:varchar(20)" json:"type"`
+	// Online is filled in from the websocket hub for friends, it is not stored
+	Online    bool      `gorm:"-" json:"online"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (relationship *Relationship) BeforeCreate(tx *gorm.DB) (err error) {
+	if relationship.ID == uuid.Nil {
+		relationship.ID = uuid.New()
+	}
+	return
+}
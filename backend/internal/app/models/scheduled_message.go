@@ -0,0 +1,62 @@
+package models
+
+import (
+	"discord-backend/internal/app/markdown"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ScheduledMessageStatus string
+
+const (
+	ScheduledPending  ScheduledMessageStatus = "PENDING"
+	ScheduledSent     ScheduledMessageStatus = "SENT"
+	ScheduledCanceled ScheduledMessageStatus = "CANCELED"
+	ScheduledFailed   ScheduledMessageStatus = "FAILED"
+)
+
+type ScheduledMessage struct {
+	ID             uuid.UUID              `gorm:"type:uuid;primary_key;" json:"id"`
+	ProfileID      uuid.UUID              `gorm:"index" json:"profileID"`
+	Profile        Profile                `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ServerID       *uuid.UUID             `json:"serverID"`
+	ChannelID      *uuid.UUID             `json:"channelID"`
+	ConversationID *uuid.UUID             `json:"conversationId"`
+	Content        string                 `gorm:"type:text" json:"content"`
+	FileURL        *string                `gorm:"type:text" json:"fileUrl"`
+	SendAt         time.Time              `gorm:"index" json:"sendAt"`
+	Recurrence     string                 `json:"recurrence"`
+	Timezone       string                 `gorm:"default:'UTC'" json:"timezone"`
+	Status         ScheduledMessageStatus `gorm:"type:varchar(100);default:'PENDING';index" json:"status"`
+	LastSentAt     *time.Time             `json:"lastSentAt"`
+	SentCount      int                    `gorm:"default:0" json:"sentCount"`
+	Error          string                 `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt      time.Time              `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+func (scheduledMessage *ScheduledMessage) BeforeCreate(tx *gorm.DB) (err error) {
+	scheduledMessage.ID = uuid.New()
+	return
+}
+
+func (scheduledMessage *ScheduledMessage) Validate() error {
+	if scheduledMessage.Content == "" {
+		return fmt.Errorf("Content cannot be empty")
+	}
+
+	if len(scheduledMessage.Content) > markdown.MAX_CONTENT_LENGTH {
+		return fmt.Errorf("Content is longer than %d bytes", markdown.MAX_CONTENT_LENGTH)
+	}
+
+	hasChannel := scheduledMessage.ServerID != nil && scheduledMessage.ChannelID != nil
+	hasConversation := scheduledMessage.ConversationID != nil
+	if hasChannel == hasConversation {
+		return fmt.Errorf("Either serverId and channelId or conversationId is required")
+	}
+
+	return nil
+}
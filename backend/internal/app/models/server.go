@@ -17,8 +17,12 @@ type Server struct {
 	Profile    Profile   `gorm:"foreignKey:ProfileID;references:ID;onDelete:CASCADE" json:"profile,omitempty"`
 	Members    []Member  `gorm:"foreignKey:ServerID;onDelete:CASCADE" json:"members,omitempty"`
 	Channels   []Channel `gorm:"foreignKey:ServerID;onDelete:CASCADE" json:"channels,omitempty"`
-	CreatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	Emojis     []Emoji   `gorm:"foreignKey:ServerID" json:"emojis,omitempty"`
+	// RequireMFA stops admins and moderators without two-factor authentication from moderating
+	RequireMFA bool           `json:"requireMFA"`
+	CreatedAt  time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 func (server *Server) BeforeCreate(tx *gorm.DB) (err error) {
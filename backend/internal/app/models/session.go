@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session is one signed in device. Its refresh tokens form a family, each
+// refresh replaces the token and replaying a replaced one revokes the session
+type Session struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	ProfileID  uuid.UUID  `gorm:"type:uuid;index;not null" json:"profileID"`
+	Profile    Profile    `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	DeviceName string     `gorm:"type:varchar(100)" json:"deviceName"`
+	IP         string     `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent  string     `gorm:"type:text" json:"userAgent"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	// Current marks the session of the request that listed it
+	Current   bool      `gorm:"-" json:"current"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (session *Session) BeforeCreate(tx *gorm.DB) (err error) {
+	session.ID = uuid.New()
+	return
+}
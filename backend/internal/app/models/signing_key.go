@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SigningKey is an Ed25519 key tokens are signed with, ID is the kid in the
+// token header. A key signs until ActiveUntil and is kept for verification
+// until ExpiresAt, when the last token it signed has expired. Seed is the
+// Ed25519 seed encrypted with AES-GCM under the key encryption key of the
+// config, prefixed by its nonce
+type SigningKey struct {
+	ID          string    `gorm:"type:varchar(32);primary_key;"`
+	Algorithm   string    `gorm:"type:varchar(10);not null"`
+	Seed        []byte    `gorm:"type:bytea;not null"`
+	ActiveUntil time.Time `gorm:"index"`
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "PENDING"
+	DeliverySucceeded WebhookDeliveryStatus = "SUCCEEDED"
+	DeliveryFailed    WebhookDeliveryStatus = "FAILED"
+)
+
+type Webhook struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	ServerID  uuid.UUID `gorm:"index" json:"serverID"`
+	Server    Server    `gorm:"foreignKey:ServerID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ProfileID uuid.UUID `json:"profileID"`
+	Profile   Profile   `gorm:"foreignKey:ProfileID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	URL       string    `gorm:"type:text" json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `gorm:"serializer:json" json:"events"`
+	Active    bool      `gorm:"default:true" json:"active"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (webhook *Webhook) BeforeCreate(tx *gorm.DB) (err error) {
+	webhook.ID = uuid.New()
+	return
+}
+
+func (webhook *Webhook) Subscribes(eventType string) bool {
+	for _, event := range webhook.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID             `gorm:"type:uuid;primary_key;" json:"id"`
+	WebhookID      uuid.UUID             `gorm:"index" json:"webhookID"`
+	Webhook        Webhook               `gorm:"foreignKey:WebhookID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	EventID        uuid.UUID             `json:"eventId"`
+	Event          string                `json:"event"`
+	Payload        string                `gorm:"type:text" json:"payload"`
+	Status         WebhookDeliveryStatus `gorm:"type:varchar(100);default:'PENDING';index" json:"status"`
+	Attempts       int                   `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time             `gorm:"index" json:"nextAttemptAt"`
+	LastStatusCode int                   `json:"lastStatusCode"`
+	LastResponse   string                `gorm:"type:text" json:"lastResponse,omitempty"`
+	LastError      string                `gorm:"type:text" json:"lastError,omitempty"`
+	DeliveredAt    *time.Time            `json:"deliveredAt"`
+	CreatedAt      time.Time             `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+func (delivery *WebhookDelivery) BeforeCreate(tx *gorm.DB) (err error) {
+	delivery.ID = uuid.New()
+	return
+}
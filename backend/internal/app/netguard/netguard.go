@@ -0,0 +1,140 @@
+// Package netguard keeps requests to urls users give us from reaching the
+// private network the server runs in
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var ErrBlockedAddress = errors.New("address is not allowed")
+
+// Ranges that IsPrivate and friends do not cover but must never be fetched
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"240.0.0.0/4",
+	"64:ff9b::/96",
+	"2001:db8::/32",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// IsPublicAddress reports whether ip is routable on the internet, loopback,
+// private, link local and reserved ranges are rejected
+func IsPublicAddress(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SafeDialContext runs allow on the resolved address right before connecting,
+// after DNS resolution, so rebinding tricks are covered too
+func SafeDialContext(timeout time.Duration, allow func(net.IP) bool) func(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !allow(ip) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+			}
+
+			return nil
+		},
+	}
+
+	return dialer.DialContext
+}
+
+// NewPublicClient returns a client for callbacks to urls users registered,
+// like webhooks. It only connects to public addresses and does not follow
+// redirects, a 3xx is returned as the response
+func NewPublicClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           SafeDialContext(timeout, IsPublicAddress),
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       time.Second * 30,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// CheckPublicURL rejects urls whose host is, or resolves to, anything but
+// public addresses. It is meant for when a url is registered, the client of
+// NewPublicClient still checks the address it dials since DNS can change
+func CheckPublicURL(ctx context.Context, target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrBlockedAddress, target.Scheme)
+	}
+
+	host := target.Hostname()
+	if host == "" || target.User != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, target.Redacted())
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !IsPublicAddress(ip) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+		}
+		return nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: cannot resolve %s", ErrBlockedAddress, host)
+	}
+
+	for _, address := range addresses {
+		if !IsPublicAddress(address.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, address.IP)
+		}
+	}
+
+	return nil
+}
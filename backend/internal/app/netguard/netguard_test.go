@@ -0,0 +1,108 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func allowAll(net.IP) bool { return true }
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"1.1.1.1", true},
+		{"93.184.216.34", true},
+		{"2606:4700:4700::1111", true},
+	}
+
+	for _, test := range tests {
+		if got := IsPublicAddress(net.ParseIP(test.ip)); got != test.public {
+			t.Errorf("IsPublicAddress(%s) = %v, want %v", test.ip, got, test.public)
+		}
+	}
+}
+
+func TestSafeDialContextRefusesPrivateAddresses(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	dial := SafeDialContext(time.Second, IsPublicAddress)
+	conn, err := dial(context.Background(), "tcp", listener.Addr().String())
+	if err == nil {
+		conn.Close()
+		t.Fatal("dialing loopback succeeded")
+	}
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("err = %v, want ErrBlockedAddress", err)
+	}
+}
+
+func TestCheckPublicURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://1.1.1.1/hook", true},
+		{"http://93.184.216.34:8080/hook", true},
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://10.0.0.5/hook", false},
+		{"http://[::1]/hook", false},
+		{"gopher://1.1.1.1/", false},
+		{"http://user@1.1.1.1/", false},
+	}
+
+	for _, test := range tests {
+		target, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckPublicURL(context.Background(), target); (err == nil) != test.allowed {
+			t.Errorf("CheckPublicURL(%s) = %v, allowed %v", test.url, err, test.allowed)
+		}
+	}
+}
+
+func TestNewPublicClientDoesNotFollowRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	client := NewPublicClient(time.Second)
+	client.Transport.(*http.Transport).DialContext = SafeDialContext(time.Second, allowAll)
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("status = %d, the redirect must be returned as is", resp.StatusCode)
+	}
+}
@@ -0,0 +1,75 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// publicKeys keeps the RSA and EC signing keys, anything else is skipped
+func (set jwks) publicKeys() map[string]crypto.PublicKey {
+	keys := make(map[string]crypto.PublicKey)
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		switch key.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(key.N)
+			e, errE := base64.RawURLEncoding.DecodeString(key.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				continue
+			}
+
+			keys[key.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			var curve elliptic.Curve
+			switch key.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+
+			x, errX := base64.RawURLEncoding.DecodeString(key.X)
+			y, errY := base64.RawURLEncoding.DecodeString(key.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+
+			publicKey := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+				continue
+			}
+			keys[key.Kid] = publicKey
+		}
+	}
+
+	return keys
+}
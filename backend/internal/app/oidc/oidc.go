@@ -0,0 +1,317 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	HTTP_TIMEOUT = time.Second * 10
+	// MAX_RESPONSE_SIZE bounds what is read from the provider
+	MAX_RESPONSE_SIZE = 1 << 20
+	// KEYS_REFRESH_INTERVAL limits how often an unknown key id refetches the JWKS
+	KEYS_REFRESH_INTERVAL = time.Minute
+	// CLOCK_SKEW is tolerated on the exp, iat and nbf claims of ID tokens
+	CLOCK_SKEW = time.Minute
+)
+
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+// Config is one OpenID Connect provider, RedirectURL is our callback
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Claims are the ID token claims used to find or create the profile
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+	Picture       string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider runs the authorization code flow against one issuer. The discovery
+// document and signing keys are fetched on first use and cached
+type Provider struct {
+	Config
+	Client *http.Client
+
+	mu            sync.Mutex
+	discovery     *discovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+func NewProvider(config Config) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = DefaultScopes
+	}
+
+	return &Provider{
+		Config: config,
+		Client: &http.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+// GenerateVerifier returns a random value for state, nonce or the PKCE verifier
+func GenerateVerifier() (string, error) {
+	value := make([]byte, 32)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
+
+// Challenge is the S256 PKCE challenge of a verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", target, resp.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, MAX_RESPONSE_SIZE)).Decode(v)
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc discovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("fetching discovery document: %w", err)
+	}
+
+	if doc.Issuer != p.Issuer {
+		return nil, fmt.Errorf("discovery document is for issuer %q, expected %q", doc.Issuer, p.Issuer)
+	}
+
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// AuthCodeURL is where the browser is sent to sign in
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(doc.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	values := authURL.Query()
+	values.Set("response_type", "code")
+	values.Set("client_id", p.ClientID)
+	values.Set("redirect_uri", p.RedirectURL)
+	values.Set("scope", strings.Join(p.Scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", Challenge(verifier))
+	values.Set("code_challenge_method", "S256")
+	authURL.RawQuery = values.Encode()
+
+	return authURL.String(), nil
+}
+
+// Exchange trades the authorization code for the raw ID token
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	// Public clients only send their id, confidential ones use client_secret_basic
+	if p.ClientSecret == "" {
+		form.Set("client_id", p.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, MAX_RESPONSE_SIZE)).Decode(&token); err != nil {
+		return "", fmt.Errorf("decoding token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s: %s %s", resp.Status, token.Error, token.ErrorDescription)
+	}
+
+	if token.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+
+	return token.IDToken, nil
+}
+
+// key returns the signing key with the given id, refetching the JWKS when the
+// provider rotated its keys. Without a kid the only key is used
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lookup := func() crypto.PublicKey {
+		if kid == "" && len(p.keys) == 1 {
+			for _, key := range p.keys {
+				return key
+			}
+		}
+		return p.keys[kid]
+	}
+
+	if key := lookup(); key != nil {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetchedAt) < KEYS_REFRESH_INTERVAL {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set jwks
+	if err := p.getJSON(ctx, doc.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetching signing keys: %w", err)
+	}
+
+	p.keys = set.publicKeys()
+	p.keysFetchedAt = time.Now()
+
+	if key := lookup(); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// the ID token and returns its claims
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(CLOCK_SKEW),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	// With several audiences the token must have been issued to us
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.ClientID {
+			return nil, errors.New("invalid id token: issued to another client")
+		}
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, errors.New("invalid id token: nonce mismatch")
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, errors.New("invalid id token: missing subject")
+	}
+
+	result := &Claims{Subject: subject}
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	result.Username, _ = claims["preferred_username"].(string)
+	result.Picture, _ = claims["picture"].(string)
+
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		result.EmailVerified = verified == "true"
+	}
+
+	return result, nil
+}
@@ -0,0 +1,223 @@
+package oidc_test
+
+import (
+	"context"
+	"discord-backend/internal/app/oidc"
+	"discord-backend/internal/app/oidc/oidctest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	clientID    = "client"
+	redirectURL = "https://app.example.com/api/auth/oidc/test/callback"
+)
+
+func newProvider(issuer *oidctest.Issuer) *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Name:         "test",
+		Issuer:       issuer.URL,
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  redirectURL,
+	})
+}
+
+// signIn runs the flow up to the ID token, claims are passed to the issuer
+func signIn(t *testing.T, issuer *oidctest.Issuer, provider *oidc.Provider, verifier string, claims jwt.MapClaims) (string, error) {
+	t.Helper()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := issuer.Authorize(t, authURL, claims)
+	return provider.Exchange(context.Background(), code, verifier)
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, clientID, "")
+	provider := newProvider(issuer)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := parsed.Query()
+	expected := map[string]string{
+		"response_type":         "code",
+		"client_id":             clientID,
+		"redirect_uri":          redirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        oidc.Challenge("verifier"),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range expected {
+		if query.Get(name) != value {
+			t.Errorf("%s = %q, want %q", name, query.Get(name), value)
+		}
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	for _, secret := range []string{"", "secret"} {
+		issuer := oidctest.NewIssuer(t, clientID, secret)
+		provider := newProvider(issuer)
+
+		rawIDToken, err := signIn(t, issuer, provider, "verifier", jwt.MapClaims{
+			"sub":            "user-1",
+			"email":          "user@example.com",
+			"email_verified": "true",
+			"name":           "User",
+		})
+		if err != nil {
+			t.Fatalf("secret %q: %v", secret, err)
+		}
+
+		claims, err := provider.VerifyIDToken(context.Background(), rawIDToken, "nonce")
+		if err != nil {
+			t.Fatalf("secret %q: %v", secret, err)
+		}
+
+		if claims.Subject != "user-1" || claims.Email != "user@example.com" || !claims.EmailVerified || claims.Name != "User" {
+			t.Errorf("got %+v", claims)
+		}
+	}
+}
+
+func TestExchangeRejectsAnotherVerifier(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, clientID, "")
+	provider := newProvider(issuer)
+
+	_, err := signIn(t, issuer, provider, "another verifier", nil)
+	if err == nil || !strings.Contains(err.Error(), "PKCE") {
+		t.Errorf("err = %v, want the PKCE check to fail", err)
+	}
+}
+
+func TestExchangeRejectsReusedCode(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, clientID, "")
+	provider := newProvider(issuer)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := issuer.Authorize(t, authURL, nil)
+	if _, err := provider.Exchange(context.Background(), code, "verifier"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.Exchange(context.Background(), code, "verifier"); err == nil {
+		t.Error("the code was exchanged twice")
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, clientID, "")
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		nonce  string
+	}{
+		{"nonce mismatch", nil, "another nonce"},
+		{"missing nonce", jwt.MapClaims{"nonce": nil}, "nonce"},
+		{"other audience", jwt.MapClaims{"aud": "another client"}, "nonce"},
+		{"several audiences without azp", jwt.MapClaims{"aud": []string{clientID, "another client"}}, "nonce"},
+		{"azp of another client", jwt.MapClaims{"aud": []string{clientID, "another client"}, "azp": "another client"}, "nonce"},
+		{"other issuer", jwt.MapClaims{"iss": "https://evil.example.com"}, "nonce"},
+		{"expired", jwt.MapClaims{"exp": now.Add(-time.Hour).Unix()}, "nonce"},
+		{"missing exp", jwt.MapClaims{"exp": nil}, "nonce"},
+		{"issued in the future", jwt.MapClaims{"iat": now.Add(time.Hour).Unix()}, "nonce"},
+		{"missing subject", jwt.MapClaims{"sub": nil}, "nonce"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newProvider(issuer)
+			rawIDToken, err := signIn(t, issuer, provider, "verifier", test.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if claims, err := provider.VerifyIDToken(context.Background(), rawIDToken, test.nonce); err == nil {
+				t.Errorf("accepted %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenAcceptsClockSkew(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, clientID, "")
+	provider := newProvider(issuer)
+
+	rawIDToken, err := signIn(t, issuer, provider, "verifier", jwt.MapClaims{
+		"exp": time.Now().Add(-oidc.CLOCK_SKEW / 2).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.VerifyIDToken(context.Background(), rawIDToken, "nonce"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyIDTokenRejectsOtherKeys(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, clientID, "")
+	other := oidctest.NewIssuer(t, clientID, "")
+	provider := newProvider(issuer)
+
+	now := time.Now()
+	rawIDToken := other.Sign(t, jwt.MapClaims{
+		"iss":   issuer.URL,
+		"aud":   clientID,
+		"sub":   "user-1",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": "nonce",
+	})
+
+	if _, err := provider.VerifyIDToken(context.Background(), rawIDToken, "nonce"); err == nil {
+		t.Error("accepted a token signed by another key")
+	}
+}
+
+func TestVerifyIDTokenRejectsUnsignedTokens(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, clientID, "")
+	provider := newProvider(issuer)
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"iss":   issuer.URL,
+		"aud":   clientID,
+		"sub":   "user-1",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": "nonce",
+	})
+	rawIDToken, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.VerifyIDToken(context.Background(), rawIDToken, "nonce"); err == nil {
+		t.Error("accepted an unsigned token")
+	}
+}
@@ -0,0 +1,230 @@
+// Package oidctest runs an OpenID Connect provider for tests. It serves the
+// discovery document, the JWKS and the token endpoint, the authorization step
+// is done by calling Authorize with the URL the browser would be sent to
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const KEY_ID = "test-key"
+
+type authorization struct {
+	challenge   string
+	redirectURI string
+	claims      jwt.MapClaims
+}
+
+type Issuer struct {
+	URL          string
+	ClientID     string
+	ClientSecret string
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu            sync.Mutex
+	codes         map[string]authorization
+	tokenRequests int
+}
+
+// NewIssuer starts a provider for the client, it is stopped with the test
+func NewIssuer(t testing.TB, clientID, clientSecret string) *Issuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &Issuer{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		codes:        make(map[string]authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("/jwks", issuer.jwks)
+	mux.HandleFunc("/token", issuer.token)
+
+	issuer.server = httptest.NewServer(mux)
+	issuer.URL = issuer.server.URL
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+// TokenRequests counts the calls to the token endpoint
+func (i *Issuer) TokenRequests() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.tokenRequests
+}
+
+// Authorize plays the user signing in at authURL and returns the code and
+// state the browser brings back to the callback. The ID token gets the usual
+// claims for the client and the nonce of authURL, claims are set over them and
+// a nil value removes the claim
+func (i *Issuer) Authorize(t testing.TB, authURL string, claims jwt.MapClaims) (code, state string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := parsed.Query()
+	if query.Get("client_id") != i.ClientID || query.Get("response_type") != "code" ||
+		query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   i.URL,
+		"aud":   i.ClientID,
+		"sub":   "subject",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(idClaims, name)
+			continue
+		}
+		idClaims[name] = value
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	code = base64.RawURLEncoding.EncodeToString(random)
+
+	i.mu.Lock()
+	i.codes[code] = authorization{
+		challenge:   query.Get("code_challenge"),
+		redirectURI: query.Get("redirect_uri"),
+		claims:      idClaims,
+	}
+	i.mu.Unlock()
+
+	return code, query.Get("state")
+}
+
+// Sign signs claims as they are with the key of the JWKS
+func (i *Issuer) Sign(t testing.TB, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = KEY_ID
+
+	signed, err := token.SignedString(i.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 i.URL,
+		"authorization_endpoint": i.URL + "/authorize",
+		"token_endpoint":         i.URL + "/token",
+		"jwks_uri":               i.URL + "/jwks",
+	})
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": KEY_ID,
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token checks the code, the client and the PKCE verifier like a provider
+// would. Codes can only be used once
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	i.tokenRequests++
+	i.mu.Unlock()
+
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+
+	if clientID != i.ClientID || clientSecret != i.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	i.mu.Lock()
+	auth, ok := i.codes[r.PostForm.Get("code")]
+	delete(i.codes, r.PostForm.Get("code"))
+	i.mu.Unlock()
+
+	if !ok || auth.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "invalid_grant",
+			"error_description": "PKCE verification failed",
+		})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, auth.claims)
+	token.Header["kid"] = KEY_ID
+	idToken, err := token.SignedString(i.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
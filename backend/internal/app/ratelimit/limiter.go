@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"log"
+	"time"
+)
+
+// Store keeps the token buckets. Take takes a token from the bucket of key,
+// which holds up to limit tokens and refills limit tokens every period. When
+// none is left it returns false and how long the caller has to wait for the
+// next one. MemoryStore works for a single instance, RedisStore shares the
+// buckets between instances
+type Store interface {
+	Take(key string, limit int, period time.Duration) (bool, time.Duration, error)
+}
+
+// Limiter is a token bucket per key in a Store, Name keeps the keys of
+// limiters sharing a store apart
+type Limiter struct {
+	Name   string
+	limit  int
+	period time.Duration
+	store  Store
+}
+
+// NewLimiter keeps its buckets in memory
+func NewLimiter(limit int, period time.Duration) *Limiter {
+	return NewStoreLimiter(NewMemoryStore(), "", limit, period)
+}
+
+func NewStoreLimiter(store Store, name string, limit int, period time.Duration) *Limiter {
+	return &Limiter{
+		Name:   name,
+		limit:  limit,
+		period: period,
+		store:  store,
+	}
+}
+
+// Allow takes a token for the key. When the store fails the request is let
+// through, an outage of Redis should not take the API down with it
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.Name != "" {
+		key = l.Name + ":" + key
+	}
+
+	allowed, retryAfter, err := l.store.Take(key, l.limit, l.period)
+	if err != nil {
+		log.Printf("Error checking rate limit %s: %v", key, err)
+		return true, 0
+	}
+
+	return allowed, retryAfter
+}
+
+// RunCleanup only has work to do when the buckets are kept in memory
+func (l *Limiter) RunCleanup(interval time.Duration) {
+	if store, ok := l.store.(*MemoryStore); ok {
+		store.RunCleanup(interval)
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens   float64
+	period   time.Duration
+	lastSeen time.Time
+}
+
+// MemoryStore keeps the buckets of this instance in a map
+type MemoryStore struct {
+	buckets map[string]*bucket
+	sync.Mutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *MemoryStore) Take(key string, limit int, period time.Duration) (bool, time.Duration, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	rate := float64(limit) / float64(period)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit), period: period, lastSeen: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens += float64(now.Sub(b.lastSeen)) * rate
+	if b.tokens > float64(limit) {
+		b.tokens = float64(limit)
+	}
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate), nil
+	}
+
+	b.tokens--
+	return true, 0, nil
+}
+
+// Cleanup drops buckets that have been full for a while so idle keys do not
+// grow the map forever
+func (s *MemoryStore) Cleanup() {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	for key, b := range s.buckets {
+		if b.lastSeen.Before(now.Add(-b.period)) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (s *MemoryStore) RunCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.Cleanup()
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	REDIS_KEY_PREFIX      = "ratelimit:"
+	REDIS_CONNECT_TIMEOUT = time.Second * 5
+)
+
+// takeScript refills and takes from the bucket in one step, so instances
+// never race on it. The bucket expires once it would be full again
+var takeScript = redis.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local rate = limit / period
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = limit
+	ts = now
+end
+
+tokens = math.min(limit, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], period)
+return {allowed, wait}
+`)
+
+// RedisStore keeps the buckets in Redis so every instance shares them
+type RedisStore struct {
+	Pool *redis.Pool
+}
+
+// NewRedisStore connects to a redis:// URL
+func NewRedisStore(url string) *RedisStore {
+	return &RedisStore{
+		Pool: &redis.Pool{
+			MaxIdle:     10,
+			IdleTimeout: time.Minute * 4,
+			Dial: func() (redis.Conn, error) {
+				return redis.DialURL(url, redis.DialConnectTimeout(REDIS_CONNECT_TIMEOUT))
+			},
+		},
+	}
+}
+
+func (s *RedisStore) Take(key string, limit int, period time.Duration) (bool, time.Duration, error) {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	reply, err := redis.Int64s(takeScript.Do(conn, REDIS_KEY_PREFIX+key, limit, period.Milliseconds(), time.Now().UnixMilli()))
+	if err != nil {
+		return false, 0, err
+	}
+
+	return reply[0] == 1, time.Duration(reply[1]) * time.Millisecond, nil
+}
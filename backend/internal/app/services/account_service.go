@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"discord-backend/internal/app/mail"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	EMAIL_VERIFICATION_TTL = time.Hour * 24
+	PASSWORD_RESET_TTL     = time.Hour
+	MIN_PASSWORD_LENGTH    = 8
+	MAIL_SEND_TIMEOUT      = time.Second * 30
+)
+
+type AccountService struct {
+	DB     *gorm.DB
+	Mailer mail.Mailer
+	// AppURL is the frontend the links in emails point to
+	AppURL string
+	// SecretKey signs the emailed tokens, JWTs use the signing keys
+	SecretKey string
+}
+
+func NewAccountService(db *gorm.DB, mailer mail.Mailer, appURL, secretKey string) *AccountService {
+	return &AccountService{DB: db, Mailer: mailer, AppURL: strings.TrimSuffix(appURL, "/"), SecretKey: secretKey}
+}
+
+// signAccountToken binds the random part of a token to its purpose, so a
+// verification token cannot be replayed as a reset token and forged tokens are
+// refused before hitting the database
+func (s *AccountService) signAccountToken(purpose models.AccountTokenPurpose, raw string) string {
+	return utils.SignPayload(s.SecretKey, string(purpose), []byte(raw))
+}
+
+// issueToken replaces any unused token of the same purpose and returns the
+// token to mail, "<random>.<signature>"
+func (s *AccountService) issueToken(tx *gorm.DB, profile *models.Profile, purpose models.AccountTokenPurpose, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if err := tx.Model(&models.AccountToken{}).
+		Where("profile_id = ? AND purpose = ? AND used_at IS NULL", profile.ID, purpose).
+		Update("used_at", now).Error; err != nil {
+		return "", err
+	}
+
+	raw, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	accountToken := models.AccountToken{
+		ProfileID: profile.ID,
+		Purpose:   purpose,
+		Email:     profile.Email,
+		TokenHash: utils.HashToken(raw),
+		ExpiresAt: now.Add(ttl),
+	}
+
+	if err := tx.Create(&accountToken).Error; err != nil {
+		return "", err
+	}
+
+	return raw + "." + s.signAccountToken(purpose, raw), nil
+}
+
+// consumeToken marks a valid token as used and returns it, the update only
+// succeeds once so a token cannot be used twice
+func (s *AccountService) consumeToken(tx *gorm.DB, purpose models.AccountTokenPurpose, token string) (*models.AccountToken, error) {
+	raw, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.signAccountToken(purpose, raw))) {
+		return nil, utils.ErrInvalidAccountToken
+	}
+
+	var accountToken models.AccountToken
+	err := tx.Where("token_hash = ? AND purpose = ?", utils.HashToken(raw), purpose).First(&accountToken).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.ErrInvalidAccountToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if accountToken.UsedAt != nil || now.After(accountToken.ExpiresAt) {
+		return nil, utils.ErrInvalidAccountToken
+	}
+
+	result := tx.Model(&models.AccountToken{}).Where("id = ? AND used_at IS NULL", accountToken.ID).Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, utils.ErrInvalidAccountToken
+	}
+
+	return &accountToken, nil
+}
+
+// send delivers in the background, request handlers answer the same way and
+// in the same time whether or not an email goes out
+func (s *AccountService) send(message mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), MAIL_SEND_TIMEOUT)
+		defer cancel()
+
+		if err := s.Mailer.Send(ctx, message); err != nil {
+			log.Printf("Error sending %q to %s: %v", message.Subject, message.To, err)
+		}
+	}()
+}
+
+func (s *AccountService) link(path, token string) string {
+	return s.AppURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// SendVerificationEmail mails a new verification link, earlier links stop
+// working. Nothing is sent when the email is verified already
+func (s *AccountService) SendVerificationEmail(profileID uuid.UUID) error {
+	var profile models.Profile
+	if err := s.DB.First(&profile, "id = ? AND is_bot = ?", profileID, false).Error; err != nil {
+		return err
+	}
+
+	if profile.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	token, err := s.issueToken(s.DB, &profile, models.AccountTokenVerifyEmail, EMAIL_VERIFICATION_TTL)
+	if err != nil {
+		return err
+	}
+
+	s.send(mail.Message{
+		To:      profile.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen this link to verify your email address:\n\n%s\n\nThe link expires in %s.\n",
+			profile.Name, s.link("/verify-email", token), EMAIL_VERIFICATION_TTL),
+	})
+
+	return nil
+}
+
+func (s *AccountService) VerifyEmail(token string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		accountToken, err := s.consumeToken(tx, models.AccountTokenVerifyEmail, token)
+		if err != nil {
+			return err
+		}
+
+		// The email changed since the link was sent
+		result := tx.Model(&models.Profile{}).Where("id = ? AND email = ?", accountToken.ProfileID, accountToken.Email).
+			Update("email_verified_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return utils.ErrInvalidAccountToken
+		}
+
+		return nil
+	})
+}
+
+// RequestPasswordReset mails a reset link when the email belongs to a user,
+// callers do not learn whether it does
+func (s *AccountService) RequestPasswordReset(email string) error {
+	var profile models.Profile
+	err := s.DB.First(&profile, "email = ? AND is_bot = ?", email, false).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := s.issueToken(s.DB, &profile, models.AccountTokenResetPassword, PASSWORD_RESET_TTL)
+	if err != nil {
+		return err
+	}
+
+	s.send(mail.Message{
+		To:      profile.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen this link to choose a new password:\n\n%s\n\nThe link expires in %s. If you did not ask for it you can ignore this email.\n",
+			profile.Name, s.link("/reset-password", token), PASSWORD_RESET_TTL),
+	})
+
+	return nil
+}
+
+// ResetPassword sets a new password and revokes every session of the
+// profile, which is returned so its sockets can be closed
+func (s *AccountService) ResetPassword(token, password string) (uuid.UUID, error) {
+	if utf8.RuneCountInString(password) < MIN_PASSWORD_LENGTH {
+		return uuid.Nil, fmt.Errorf("%w: password must be at least %d characters", utils.ErrInvalidPassword, MIN_PASSWORD_LENGTH)
+	}
+
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var profileID uuid.UUID
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		accountToken, err := s.consumeToken(tx, models.AccountTokenResetPassword, token)
+		if err != nil {
+			return err
+		}
+		profileID = accountToken.ProfileID
+
+		if err := tx.Model(&models.Profile{}).Where("id = ?", profileID).
+			Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+
+		_, err = revokeSessions(tx, profileID)
+		return err
+	})
+
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return profileID, nil
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdminService backs the operator commands, it acts on any profile or server
+// without the membership and ownership checks of the API
+type AdminService struct {
+	DB *gorm.DB
+}
+
+func NewAdminService(db *gorm.DB) *AdminService {
+	return &AdminService{DB: db}
+}
+
+// ServerSummary is a row of the server list
+type ServerSummary struct {
+	ID        uuid.UUID
+	Name      string
+	OwnerID   uuid.UUID
+	OwnerName string
+	Members   int64
+	Channels  int64
+	CreatedAt time.Time
+	DeletedAt *time.Time
+}
+
+// Stats counts what the instance holds
+type Stats struct {
+	Profiles         int64
+	DisabledProfiles int64
+	Bots             int64
+	Servers          int64
+	DeletedServers   int64
+	Channels         int64
+	Messages         int64
+	DirectMessages   int64
+	ActiveSessions   int64
+}
+
+// FindProfile looks a profile up by id, email or name
+func (a *AdminService) FindProfile(ref string) (*models.Profile, error) {
+	var profile models.Profile
+	query := a.DB.Where("name = ?", ref)
+	if id, err := uuid.Parse(ref); err == nil {
+		query = a.DB.Where("id = ?", id)
+	} else if strings.Contains(ref, "@") {
+		query = a.DB.Where("email = ? AND is_bot = ?", ref, false)
+	}
+
+	if err := query.First(&profile).Error; err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// DisableProfile stops the profile from signing in and revokes its sessions
+func (a *AdminService) DisableProfile(profileID uuid.UUID) ([]uuid.UUID, error) {
+	var revoked []uuid.UUID
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Profile{}).Where("id = ?", profileID).
+			Update("disabled_at", gorm.Expr("COALESCE(disabled_at, ?)", time.Now()))
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var err error
+		revoked, err = revokeSessions(tx, profileID)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+func (a *AdminService) EnableProfile(profileID uuid.UUID) error {
+	result := a.DB.Model(&models.Profile{}).Where("id = ?", profileID).Update("disabled_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// SetPassword replaces the password of the profile and revokes its sessions
+func (a *AdminService) SetPassword(profileID uuid.UUID, password string) ([]uuid.UUID, error) {
+	if utf8.RuneCountInString(password) < MIN_PASSWORD_LENGTH {
+		return nil, fmt.Errorf("%w: password must be at least %d characters", utils.ErrInvalidPassword, MIN_PASSWORD_LENGTH)
+	}
+
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var revoked []uuid.UUID
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Profile{}).Where("id = ? AND is_bot = ?", profileID, false).
+			Update("password", hashedPassword)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		revoked, err = revokeSessions(tx, profileID)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+// ListServers lists the servers newest first, soft deleted ones included
+func (a *AdminService) ListServers(limit, offset int) ([]ServerSummary, error) {
+	var servers []ServerSummary
+	if err := a.DB.Table("servers").
+		Select(`servers.id, servers.name, servers.profile_id AS owner_id, profiles.name AS owner_name,
+			(SELECT count(*) FROM members WHERE members.server_id = servers.id) AS members,
+			(SELECT count(*) FROM channels WHERE channels.server_id = servers.id AND channels.deleted_at IS NULL) AS channels,
+			servers.created_at, servers.deleted_at`).
+		Joins("JOIN profiles ON profiles.id = servers.profile_id").
+		Order("servers.created_at DESC").Limit(limit).Offset(offset).
+		Scan(&servers).Error; err != nil {
+		return nil, err
+	}
+
+	return servers, nil
+}
+
+// GetServer loads a server with its owner, channels and members, soft deleted
+// servers included
+func (a *AdminService) GetServer(serverID uuid.UUID) (*models.Server, error) {
+	var server models.Server
+	if err := a.DB.Unscoped().
+		Preload("Profile").
+		Preload("Channels", func(db *gorm.DB) *gorm.DB {
+			return db.Where("deleted_at IS NULL").Order("created_at ASC")
+		}).
+		Preload("Members", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).
+		Preload("Members.Profile").
+		First(&server, "id = ?", serverID).Error; err != nil {
+		return nil, err
+	}
+
+	return &server, nil
+}
+
+// TransferServer makes a member the owner of the server, the new owner becomes
+// an admin and the previous one stays an admin
+func (a *AdminService) TransferServer(serverID, profileID uuid.UUID) (*models.Server, error) {
+	var server models.Server
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&server, "id = ?", serverID).Error; err != nil {
+			return err
+		}
+
+		if server.ProfileID == profileID {
+			return errors.New("the profile already owns the server")
+		}
+
+		result := tx.Model(&models.Member{}).Where("server_id = ? AND profile_id = ?", serverID, profileID).
+			Update("role", models.Admin)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("the new owner must be a member of the server: %w", gorm.ErrRecordNotFound)
+		}
+
+		return tx.Model(&server).Update("profile_id", profileID).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &server, nil
+}
+
+func (a *AdminService) Stats() (*Stats, error) {
+	var stats Stats
+	now := time.Now()
+	counts := []struct {
+		count *int64
+		query *gorm.DB
+	}{
+		{&stats.Profiles, a.DB.Model(&models.Profile{}).Where("is_bot = ?", false)},
+		{&stats.DisabledProfiles, a.DB.Model(&models.Profile{}).Where("disabled_at IS NOT NULL")},
+		{&stats.Bots, a.DB.Model(&models.Profile{}).Where("is_bot = ?", true)},
+		{&stats.Servers, a.DB.Model(&models.Server{})},
+		{&stats.DeletedServers, a.DB.Unscoped().Model(&models.Server{}).Where("deleted_at IS NOT NULL")},
+		{&stats.Channels, a.DB.Model(&models.Channel{})},
+		{&stats.Messages, a.DB.Model(&models.Message{})},
+		{&stats.DirectMessages, a.DB.Model(&models.DirectMessage{})},
+		{&stats.ActiveSessions, a.DB.Model(&models.Session{}).Where("revoked_at IS NULL AND expires_at > ?", now)},
+	}
+
+	for _, c := range counts {
+		if err := c.query.Count(c.count).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	MAX_BOTS_PER_OWNER = 20
+	MAX_TOKENS_PER_BOT = 10
+	BOT_TOKEN_PREFIX   = "bot_"
+)
+
+type BotService struct {
+	DB *gorm.DB
+}
+
+func NewBotService(db *gorm.DB) *BotService {
+	return &BotService{DB: db}
+}
+
+func (b *BotService) ownedBot(tx *gorm.DB, ownerID, botID uuid.UUID) (*models.Profile, error) {
+	var bot models.Profile
+	if err := tx.Where("id = ? AND is_bot = ? AND bot_owner_id = ?", botID, true, ownerID).
+		First(&bot).Error; err != nil {
+		return nil, err
+	}
+
+	return &bot, nil
+}
+
+func (b *BotService) createToken(tx *gorm.DB, botID uuid.UUID, name string) (*models.BotToken, string, error) {
+	var count int64
+	if err := tx.Model(&models.BotToken{}).Where("bot_id = ? AND revoked_at IS NULL", botID).
+		Count(&count).Error; err != nil {
+		return nil, "", err
+	}
+
+	if count >= MAX_TOKENS_PER_BOT {
+		return nil, "", fmt.Errorf("%w: a bot can have at most %d active tokens", utils.ErrInvalidBot, MAX_TOKENS_PER_BOT)
+	}
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := BOT_TOKEN_PREFIX + secret
+	botToken := models.BotToken{
+		BotID:     botID,
+		Name:      name,
+		TokenHash: utils.HashToken(token),
+		Prefix:    token[:len(BOT_TOKEN_PREFIX)+8],
+	}
+
+	if err := tx.Create(&botToken).Error; err != nil {
+		return nil, "", err
+	}
+
+	return &botToken, token, nil
+}
+
+// CreateBot creates the bot profile together with its first token
+func (b *BotService) CreateBot(ownerID uuid.UUID, name, imageURL string) (*models.Profile, *models.BotToken, string, error) {
+	if name == "" {
+		return nil, nil, "", fmt.Errorf("%w: name is required", utils.ErrInvalidBot)
+	}
+
+	var bot models.Profile
+	var botToken *models.BotToken
+	var token string
+
+	err := b.DB.Transaction(func(tx *gorm.DB) error {
+		var owner models.Profile
+		if err := tx.First(&owner, "id = ? AND is_bot = ?", ownerID, false).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Profile{}).Where("bot_owner_id = ?", ownerID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count >= MAX_BOTS_PER_OWNER {
+			return fmt.Errorf("%w: a profile can own at most %d bots", utils.ErrInvalidBot, MAX_BOTS_PER_OWNER)
+		}
+
+		if err := tx.Where("name = ?", name).First(&models.Profile{}).Error; err == nil {
+			return utils.ErrEmailOrUsernameTaken
+		}
+
+		bot = models.Profile{
+			Name:       name,
+			ImageURL:   imageURL,
+			IsBot:      true,
+			BotOwnerID: &ownerID,
+		}
+		if err := tx.Create(&bot).Error; err != nil {
+			return err
+		}
+
+		var err error
+		botToken, token, err = b.createToken(tx, bot.ID, "default")
+		return err
+	})
+
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return &bot, botToken, token, nil
+}
+
+func (b *BotService) GetBots(ownerID uuid.UUID) ([]models.Profile, error) {
+	var bots []models.Profile
+	if err := b.DB.Where("is_bot = ? AND bot_owner_id = ?", true, ownerID).
+		Order("created_at ASC").Find(&bots).Error; err != nil {
+		return nil, err
+	}
+
+	return bots, nil
+}
+
+func (b *BotService) CreateBotToken(ownerID, botID uuid.UUID, name string) (*models.BotToken, string, error) {
+	var botToken *models.BotToken
+	var token string
+
+	err := b.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := b.ownedBot(tx, ownerID, botID); err != nil {
+			return err
+		}
+
+		var err error
+		botToken, token, err = b.createToken(tx, botID, name)
+		return err
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return botToken, token, nil
+}
+
+func (b *BotService) GetBotTokens(ownerID, botID uuid.UUID) ([]models.BotToken, error) {
+	if _, err := b.ownedBot(b.DB, ownerID, botID); err != nil {
+		return nil, err
+	}
+
+	var botTokens []models.BotToken
+	if err := b.DB.Where("bot_id = ?", botID).Order("created_at ASC").Find(&botTokens).Error; err != nil {
+		return nil, err
+	}
+
+	return botTokens, nil
+}
+
+func (b *BotService) RevokeBotToken(ownerID, botID, tokenID uuid.UUID) error {
+	return b.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := b.ownedBot(tx, ownerID, botID); err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.BotToken{}).
+			Where("id = ? AND bot_id = ? AND revoked_at IS NULL", tokenID, botID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// AuthenticateBotToken resolves an "Authorization: Bot <token>" token, the bot
+// profile is preloaded on the returned token
+func (b *BotService) AuthenticateBotToken(token string) (*models.BotToken, error) {
+	var botToken models.BotToken
+	if err := b.DB.Preload("Bot").
+		Where("token_hash = ? AND revoked_at IS NULL", utils.HashToken(token)).
+		First(&botToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrInvalidBotToken
+		}
+		return nil, err
+	}
+
+	if !botToken.Bot.IsBot || botToken.Bot.DisabledAt != nil {
+		return nil, utils.ErrInvalidBotToken
+	}
+
+	// Only track usage at minute granularity so every request is not a write
+	now := time.Now()
+	if botToken.LastUsedAt == nil || now.Sub(*botToken.LastUsedAt) > time.Minute {
+		b.DB.Model(&botToken).Update("last_used_at", now)
+	}
+
+	return &botToken, nil
+}
+
+// AddBotToServer lets a server admin add one of their own bots as a member,
+// what the bot can do afterwards comes from the role like for every other member
+func (b *BotService) AddBotToServer(serverID, profileID, botID uuid.UUID, role models.MemberRole) (*models.Member, bool, error) {
+	var member models.Member
+	created := false
+
+	err := b.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Member{}).
+			Where("server_id = ? AND profile_id = ? AND role = ?", serverID, profileID, models.Admin).
+			Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return utils.ErrForbidden
+		}
+
+		// The bots of other profiles are not found
+		if _, err := b.ownedBot(tx, profileID, botID); err != nil {
+			return err
+		}
+
+		if err := tx.Where("server_id = ? AND profile_id = ?", serverID, botID).First(&member).Error; err == nil {
+			return tx.Model(&member).Update("role", role).Error
+		}
+
+		member = models.Member{
+			ProfileID: botID,
+			ServerID:  serverID,
+			Role:      role,
+		}
+		created = true
+		return tx.Create(&member).Error
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &member, created, nil
+}
+
+// GetBotServerIDs is used by the gateway to know which server events a bot receives
+func (b *BotService) GetBotServerIDs(botID uuid.UUID) ([]uuid.UUID, error) {
+	var serverIDs []uuid.UUID
+	if err := b.DB.Model(&models.Member{}).
+		Joins("JOIN servers ON servers.id = members.server_id AND servers.deleted_at IS NULL").
+		Where("members.profile_id = ?", botID).
+		Pluck("members.server_id", &serverIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return serverIDs, nil
+}
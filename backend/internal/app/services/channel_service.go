@@ -2,12 +2,18 @@ package services
 
 import (
 	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// MAX_SLOWMODE is the longest a channel can make members wait between messages, in seconds
+const MAX_SLOWMODE = 21600
+
 type ChannelService struct {
 	DB *gorm.DB
 }
@@ -16,8 +22,9 @@ func NewChannelService(db *gorm.DB) *ChannelService {
 	return &ChannelService{DB: db}
 }
 
-func (c *ChannelService) CreateChannel(serverID, profileID uuid.UUID, name string, channelType models.ChannelType) (*models.Server, error) {
+func (c *ChannelService) CreateChannel(serverID, profileID uuid.UUID, name string, channelType models.ChannelType) (*models.Server, *models.Channel, error) {
 	var updatedServer models.Server
+	var channel models.Channel
 
 	err := c.DB.Transaction(func(tx *gorm.DB) error {
 		var count int64
@@ -34,7 +41,7 @@ func (c *ChannelService) CreateChannel(serverID, profileID uuid.UUID, name strin
 			return errors.New("no matching members found")
 		}
 
-		channel := models.Channel{
+		channel = models.Channel{
 			ProfileID: profileID,
 			Name:      name,
 			Type:      channelType,
@@ -56,10 +63,10 @@ func (c *ChannelService) CreateChannel(serverID, profileID uuid.UUID, name strin
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return &updatedServer, nil
+	return &updatedServer, &channel, nil
 }
 
 func (c *ChannelService) DeleteChannel(serverID, profileID, channelID uuid.UUID) (*models.Server, error) {
@@ -99,7 +106,57 @@ func (c *ChannelService) DeleteChannel(serverID, profileID, channelID uuid.UUID)
 	return &updatedServer, nil
 }
 
-func (c *ChannelService) UpdateChannel(serverID, profileID, channelID uuid.UUID, updateData models.Channel) (*models.Server, error) {
+func (c *ChannelService) RestoreChannel(serverID, profileID, channelID uuid.UUID) (*models.Server, error) {
+	var updatedServer models.Server
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Member{}).
+			Where("server_id = ? AND profile_id = ? AND role IN ?",
+				serverID, profileID, []models.MemberRole{models.Admin, models.Moderator}).
+			Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return errors.New("no matching members found")
+		}
+
+		result := tx.Unscoped().Model(&models.Channel{}).
+			Where("id = ? AND server_id = ? AND deleted_at IS NOT NULL AND deleted_at > ?",
+				channelID, serverID, time.Now().Add(-SOFT_DELETE_RETENTION)).
+			Update("deleted_at", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Preload("Members", func(db *gorm.DB) *gorm.DB {
+			return db.Order("members.role ASC").Preload("Profile")
+		}).Preload("Channels").
+			First(&updatedServer, "id = ?", serverID).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &updatedServer, nil
+}
+
+// UpdateChannel renames the channel or changes its type, the general channel
+// keeps both. Slowmode is left alone when nil
+func (c *ChannelService) UpdateChannel(serverID, profileID, channelID uuid.UUID, updateData models.Channel, slowmode *int) (*models.Server, error) {
+	if slowmode != nil && (*slowmode < 0 || *slowmode > MAX_SLOWMODE) {
+		return nil, fmt.Errorf("%w: must be between 0 and %d seconds", utils.ErrInvalidSlowmode, MAX_SLOWMODE)
+	}
+
 	var updatedServer models.Server
 	err := c.DB.Transaction(func(tx *gorm.DB) error {
 		var count int64
@@ -119,6 +176,13 @@ func (c *ChannelService) UpdateChannel(serverID, profileID, channelID uuid.UUID,
 			return err
 		}
 
+		if slowmode != nil {
+			if err := tx.Model(&models.Channel{}).Where("id = ? AND server_id = ?", channelID, serverID).
+				Update("slowmode", *slowmode).Error; err != nil {
+				return err
+			}
+		}
+
 		if err := tx.Preload("Members", func(db *gorm.DB) *gorm.DB {
 			return db.Order("members.role ASC").Preload("Profile")
 		}).Preload("Channels").
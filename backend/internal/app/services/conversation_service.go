@@ -2,9 +2,21 @@ package services
 
 import (
 	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MAX_GROUP_PARTICIPANTS   = 10
+	MAX_CONVERSATION_NAME    = 100
+	CONVERSATIONS_LIST_LIMIT = 100
 )
 
 type ConversationService struct {
@@ -15,12 +27,11 @@ func NewConversationService(db *gorm.DB) *ConversationService {
 	return &ConversationService{DB: db}
 }
 
-func (c *ConversationService) GetConversation(conversationID, profileID uuid.UUID) (*models.Conversation, error) {
+func (c *ConversationService) getConversation(tx *gorm.DB, conversationID uuid.UUID) (*models.Conversation, error) {
 	var conversation models.Conversation
-	err := c.DB.Preload("MemberOne.Profile").Preload("MemberTwo.Profile").
-		Where("id = ? AND (member_one_id IN (SELECT id FROM members WHERE profile_id = ?) OR member_two_id IN (SELECT id FROM members WHERE profile_id = ?))",
-			conversationID, profileID, profileID).
-		First(&conversation).Error
+	err := tx.Preload("Participants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("conversation_participants.created_at ASC")
+	}).Preload("Participants.Profile").First(&conversation, "id = ?", conversationID).Error
 
 	if err != nil {
 		return nil, err
@@ -29,35 +40,435 @@ func (c *ConversationService) GetConversation(conversationID, profileID uuid.UUI
 	return &conversation, nil
 }
 
-func (c *ConversationService) FindConversation(memberOneID, memberTwoID uuid.UUID) (*models.Conversation, error) {
+// GetConversation only returns conversations the profile takes part in
+func (c *ConversationService) GetConversation(conversationID, profileID uuid.UUID) (*models.Conversation, error) {
+	conversation, err := c.getConversation(c.DB, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conversation.HasParticipant(profileID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return conversation, nil
+}
+
+// GetConversations lists the inbox of the profile, or with requests the
+// conversations it has not accepted yet
+func (c *ConversationService) GetConversations(profileID uuid.UUID, requests bool) ([]models.Conversation, error) {
+	var conversations []models.Conversation
+	err := c.DB.Preload("Participants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("conversation_participants.created_at ASC")
+	}).Preload("Participants.Profile").
+		Where("id IN (?)", c.DB.Model(&models.ConversationParticipant{}).Select("conversation_id").
+			Where("profile_id = ? AND request = ?", profileID, requests)).
+		Order("updated_at DESC").Limit(CONVERSATIONS_LIST_LIMIT).
+		Find(&conversations).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return conversations, nil
+}
+
+func (c *ConversationService) FindConversation(profileOneID, profileTwoID uuid.UUID) (*models.Conversation, error) {
 	var conversation models.Conversation
-	err := c.DB.Preload("MemberOne.Profile").Preload("MemberTwo.Profile").
-		Where("member_one_id = ? AND member_two_id = ?", memberOneID, memberTwoID).
-		Or("member_one_id = ? AND member_two_id = ?", memberTwoID, memberOneID).
-		First(&conversation).Error
+	if err := c.DB.Select("id").Where("direct_key = ?", models.DirectKey(profileOneID, profileTwoID)).
+		First(&conversation).Error; err != nil {
+		return nil, err
+	}
+
+	return c.getConversation(c.DB, conversation.ID)
+}
+
+// GetMemberProfileIDs maps server members to their profiles, the conversation
+// routes used to take member ids before DMs moved to profiles
+func (c *ConversationService) GetMemberProfileIDs(memberIDs ...uuid.UUID) ([]uuid.UUID, error) {
+	profileIDs := make([]uuid.UUID, len(memberIDs))
+	for i, memberID := range memberIDs {
+		var member models.Member
+		if err := c.DB.Select("profile_id").First(&member, "id = ?", memberID).Error; err != nil {
+			return nil, err
+		}
+		profileIDs[i] = member.ProfileID
+	}
+
+	return profileIDs, nil
+}
+
+func (c *ConversationService) ensureProfiles(tx *gorm.DB, profileIDs []uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.Profile{}).Where("id IN ?", profileIDs).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if int(count) != len(profileIDs) {
+		return fmt.Errorf("%w: unknown profile", utils.ErrInvalidConversation)
+	}
+
+	return nil
+}
+
+// GetOrCreateConversation returns the 1:1 conversation of the two profiles,
+// created reports whether it had to be made
+func (c *ConversationService) GetOrCreateConversation(profileID, otherProfileID uuid.UUID) (*models.Conversation, bool, error) {
+	if profileID == otherProfileID {
+		return nil, false, fmt.Errorf("%w: cannot start a conversation with yourself", utils.ErrInvalidConversation)
+	}
+
+	key := models.DirectKey(profileID, otherProfileID)
+	created := false
+
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		if err := c.ensureProfiles(tx, []uuid.UUID{profileID, otherProfileID}); err != nil {
+			return err
+		}
+
+		if err := ensureNotBlocked(tx, profileID, otherProfileID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Conversation{}).Where("direct_key = ?", key).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return nil
+		}
+
+		request, err := canStartConversation(tx, profileID, otherProfileID)
+		if err != nil {
+			return err
+		}
+
+		conversation := models.Conversation{DirectKey: &key}
+		result := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "direct_key"}}, DoNothing: true}).
+			Create(&conversation)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		created = true
+		return tx.Create(&[]models.ConversationParticipant{
+			{ConversationID: conversation.ID, ProfileID: profileID},
+			{ConversationID: conversation.ID, ProfileID: otherProfileID, Request: request},
+		}).Error
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	conversation, err := c.FindConversation(profileID, otherProfileID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return conversation, created, nil
+}
+
+// ensureNotBlocked refuses to put profiles that blocked each other in a conversation
+func ensureNotBlocked(tx *gorm.DB, profileID uuid.UUID, otherIDs ...uuid.UUID) error {
+	isBlocked, err := blocked(tx, profileID, otherIDs...)
+	if err != nil {
+		return err
+	}
+
+	if isBlocked {
+		return fmt.Errorf("%w: you cannot message this user", utils.ErrForbidden)
+	}
+
+	return nil
+}
+
+func validateConversationName(name string) error {
+	if utf8.RuneCountInString(name) > MAX_CONVERSATION_NAME {
+		return fmt.Errorf("%w: name must be at most %d characters", utils.ErrInvalidConversation, MAX_CONVERSATION_NAME)
+	}
+	return nil
+}
+
+// CreateGroupConversation makes the owner and the given profiles the participants
+// of a new group, at most MAX_GROUP_PARTICIPANTS in total
+func (c *ConversationService) CreateGroupConversation(ownerID uuid.UUID, name, imageURL string, profileIDs []uuid.UUID) (*models.Conversation, error) {
+	name = strings.TrimSpace(name)
+	if err := validateConversationName(name); err != nil {
+		return nil, err
+	}
+
+	participants := []uuid.UUID{ownerID}
+	seen := map[uuid.UUID]bool{ownerID: true}
+	for _, profileID := range profileIDs {
+		if !seen[profileID] {
+			seen[profileID] = true
+			participants = append(participants, profileID)
+		}
+	}
+
+	if len(participants) < 2 {
+		return nil, fmt.Errorf("%w: a group needs at least one other participant", utils.ErrInvalidConversation)
+	}
+
+	if len(participants) > MAX_GROUP_PARTICIPANTS {
+		return nil, fmt.Errorf("%w: a group can have at most %d participants", utils.ErrInvalidConversation, MAX_GROUP_PARTICIPANTS)
+	}
+
+	conversation := models.Conversation{
+		Name:     name,
+		ImageURL: imageURL,
+		IsGroup:  true,
+		OwnerID:  &ownerID,
+	}
+
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		if err := c.ensureProfiles(tx, participants); err != nil {
+			return err
+		}
+
+		if err := ensureNotBlocked(tx, ownerID, participants[1:]...); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&conversation).Error; err != nil {
+			return err
+		}
+
+		rows := make([]models.ConversationParticipant, len(participants))
+		for i, profileID := range participants {
+			rows[i] = models.ConversationParticipant{ConversationID: conversation.ID, ProfileID: profileID}
+			if profileID == ownerID {
+				continue
+			}
+
+			request, err := canStartConversation(tx, ownerID, profileID)
+			if err != nil {
+				return err
+			}
+			rows[i].Request = request
+		}
+
+		return tx.Create(&rows).Error
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	return c.getConversation(c.DB, conversation.ID)
+}
+
+// lockGroup loads a group the profile takes part in, holding the row until the
+// transaction ends so participant changes do not race
+func (c *ConversationService) lockGroup(tx *gorm.DB, conversationID, profileID uuid.UUID) (*models.Conversation, error) {
+	var conversation models.Conversation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&conversation, "id = ?", conversationID).Error; err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := tx.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND profile_id = ?", conversationID, profileID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	if !conversation.IsGroup {
+		return nil, fmt.Errorf("%w: only group conversations can be changed", utils.ErrInvalidConversation)
+	}
+
 	return &conversation, nil
 }
 
-func (c *ConversationService) CreateConversation(memberOneID, memberTwoID uuid.UUID) (*models.Conversation, error) {
-	conversation := models.Conversation{
-		MemberOneID: memberOneID,
-		MemberTwoID: memberTwoID,
+// UpdateConversation renames a group or changes its icon, any participant can
+func (c *ConversationService) UpdateConversation(conversationID, profileID uuid.UUID, name, imageURL *string) (*models.Conversation, error) {
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		conversation, err := c.lockGroup(tx, conversationID, profileID)
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{}
+		if name != nil {
+			trimmed := strings.TrimSpace(*name)
+			if err := validateConversationName(trimmed); err != nil {
+				return err
+			}
+			updates["name"] = trimmed
+		}
+
+		if imageURL != nil {
+			updates["image_url"] = *imageURL
+		}
+
+		if len(updates) == 0 {
+			return nil
+		}
+
+		return tx.Model(conversation).Updates(updates).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c.getConversation(c.DB, conversationID)
+}
+
+func (c *ConversationService) AddParticipant(conversationID, profileID, newProfileID uuid.UUID) (*models.Conversation, error) {
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := c.lockGroup(tx, conversationID, profileID); err != nil {
+			return err
+		}
+
+		if err := c.ensureProfiles(tx, []uuid.UUID{newProfileID}); err != nil {
+			return err
+		}
+
+		if err := ensureNotBlocked(tx, profileID, newProfileID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.ConversationParticipant{}).Where("conversation_id = ?", conversationID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count >= MAX_GROUP_PARTICIPANTS {
+			return fmt.Errorf("%w: a group can have at most %d participants", utils.ErrInvalidConversation, MAX_GROUP_PARTICIPANTS)
+		}
+
+		request, err := canStartConversation(tx, profileID, newProfileID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.ConversationParticipant{ConversationID: conversationID, ProfileID: newProfileID, Request: request}).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c.getConversation(c.DB, conversationID)
+}
+
+// AcceptConversation moves a message request into the inbox of the profile
+func (c *ConversationService) AcceptConversation(conversationID, profileID uuid.UUID) (*models.Conversation, error) {
+	result := c.DB.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND profile_id = ?", conversationID, profileID).
+		Update("request", false)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
 	}
 
-	if err := c.DB.Create(&conversation).Error; err != nil {
+	return c.getConversation(c.DB, conversationID)
+}
+
+// RemoveParticipant lets the owner of a group remove someone else, everybody
+// can remove themselves which is the same as leaving
+func (c *ConversationService) RemoveParticipant(conversationID, profileID, targetID uuid.UUID) (*models.Conversation, error) {
+	if profileID == targetID {
+		return c.LeaveConversation(conversationID, profileID)
+	}
+
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		conversation, err := c.lockGroup(tx, conversationID, profileID)
+		if err != nil {
+			return err
+		}
+
+		if conversation.OwnerID == nil || *conversation.OwnerID != profileID {
+			return fmt.Errorf("%w: only the owner can remove participants", utils.ErrForbidden)
+		}
+
+		result := tx.Where("conversation_id = ? AND profile_id = ?", conversationID, targetID).
+			Delete(&models.ConversationParticipant{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
-	var fetchedConversation models.Conversation
-	if err := c.DB.Preload("MemberOne.Profile").Preload("MemberTwo.Profile").
-		First(&fetchedConversation, conversation.ID).Error; err != nil {
+	return c.getConversation(c.DB, conversationID)
+}
+
+// LeaveConversation removes the profile from a group. Ownership goes to the
+// longest standing participant and the last one out deletes the group, in
+// which case the returned conversation is nil
+func (c *ConversationService) LeaveConversation(conversationID, profileID uuid.UUID) (*models.Conversation, error) {
+	deleted := false
+
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		conversation, err := c.lockGroup(tx, conversationID, profileID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("conversation_id = ? AND profile_id = ?", conversationID, profileID).
+			Delete(&models.ConversationParticipant{}).Error; err != nil {
+			return err
+		}
+
+		var next models.ConversationParticipant
+		err = tx.Where("conversation_id = ?", conversationID).Order("created_at ASC").First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			deleted = true
+			if err := tx.Where("conversation_id = ?", conversationID).Delete(&models.ScheduledMessage{}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Where("conversation_id = ?", conversationID).Delete(&models.DirectMessage{}).Error; err != nil {
+				return err
+			}
+
+			return tx.Delete(conversation).Error
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if conversation.OwnerID != nil && *conversation.OwnerID == profileID {
+			return tx.Model(conversation).Update("owner_id", next.ProfileID).Error
+		}
+
+		return nil
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
-	return &fetchedConversation, nil
+	if deleted {
+		return nil, nil
+	}
+
+	return c.getConversation(c.DB, conversationID)
 }
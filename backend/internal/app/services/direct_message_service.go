@@ -2,13 +2,18 @@ package services
 
 import (
 	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const DIRECT_MESSAGES_BATCH = 10
 
+const MAX_PINNED_DIRECT_MESSAGES = 50
+
 type DirectMessageService struct {
 	DB *gorm.DB
 }
@@ -17,20 +22,74 @@ func NewDirectMessageService(db *gorm.DB) *DirectMessageService {
 	return &DirectMessageService{DB: db}
 }
 
-func (s *DirectMessageService) CreateDirectMessage(conversationID, memberID uuid.UUID, content, fileUrl string) (*models.DirectMessage, error) {
+// CreateDirectMessage refuses to send in a 1:1 conversation when one side
+// blocked the other, in groups the blocker just does not see the messages.
+// Until the other side replies their privacy settings are checked again, and
+// replying accepts a message request
+func (s *DirectMessageService) CreateDirectMessage(conversationID, profileID uuid.UUID, content, fileUrl string) (*models.DirectMessage, error) {
 	directMessage := models.DirectMessage{
-		Content:        content,
 		FileURL:        &fileUrl,
 		ConversationID: conversationID,
-		MemberID:       memberID,
+		ProfileID:      profileID,
+	}
+
+	if err := directMessage.SetContent(content); err != nil {
+		return nil, err
+	}
+
+	var conversation models.Conversation
+	if err := s.DB.Preload("Participants").First(&conversation, "id = ?", conversationID).Error; err != nil {
+		return nil, err
+	}
+
+	if !conversation.IsGroup {
+		var others []uuid.UUID
+		for _, participant := range conversation.Participants {
+			if participant.ProfileID != profileID {
+				others = append(others, participant.ProfileID)
+			}
+		}
+
+		if err := ensureNotBlocked(s.DB, profileID, others...); err != nil {
+			return nil, err
+		}
+
+		for _, otherID := range others {
+			var replies int64
+			if err := s.DB.Model(&models.DirectMessage{}).
+				Where("conversation_id = ? AND profile_id = ?", conversationID, otherID).
+				Count(&replies).Error; err != nil {
+				return nil, err
+			}
+
+			if replies > 0 {
+				continue
+			}
+
+			if _, err := canStartConversation(s.DB, profileID, otherID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.DB.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND profile_id = ? AND request = ?", conversationID, profileID, true).
+		Update("request", false).Error; err != nil {
+		return nil, err
 	}
 
 	if err := s.DB.Create(&directMessage).Error; err != nil {
 		return nil, err
 	}
 
+	// Conversations are listed by their last activity
+	if err := s.DB.Model(&models.Conversation{}).Where("id = ?", conversationID).
+		Update("updated_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+
 	var reponseMessage models.DirectMessage
-	if err := s.DB.Preload("Member.Profile").Where("id = ?", directMessage.ID).
+	if err := s.DB.Preload("Profile").Where("id = ?", directMessage.ID).
 		First(&reponseMessage).Error; err != nil {
 		return nil, err
 	}
@@ -38,10 +97,12 @@ func (s *DirectMessageService) CreateDirectMessage(conversationID, memberID uuid
 	return &reponseMessage, nil
 }
 
-func (s *DirectMessageService) GetDirectMessages(conversationID uuid.UUID, cursor string) ([]models.DirectMessage, string, error) {
+// GetDirectMessages leaves out the messages of profiles the viewer blocked
+func (s *DirectMessageService) GetDirectMessages(conversationID, viewerID uuid.UUID, cursor string) ([]models.DirectMessage, string, error) {
 	var directMessages []models.DirectMessage
 
-	query := s.DB.Preload("Member.Profile").Where("conversation_id = ?", conversationID).
+	query := s.DB.Preload("Profile").Where("conversation_id = ?", conversationID).
+		Where("profile_id NOT IN (?)", blockedBy(s.DB, viewerID)).
 		Order("created_at DESC").Limit(DIRECT_MESSAGES_BATCH)
 
 	if cursor != "" {
@@ -66,7 +127,7 @@ func (s *DirectMessageService) GetDirectMessages(conversationID uuid.UUID, curso
 
 func (s *DirectMessageService) GetDirectMessage(conversationID, directMessageID uuid.UUID) (*models.DirectMessage, error) {
 	var directMessage models.DirectMessage
-	if err := s.DB.Preload("Member.Profile").Where("id = ? AND conversation_id = ? AND deleted = false", directMessageID, conversationID).
+	if err := s.DB.Preload("Profile").Where("id = ? AND conversation_id = ? AND deleted = false", directMessageID, conversationID).
 		First(&directMessage).Error; err != nil {
 		return nil, err
 	}
@@ -75,32 +136,183 @@ func (s *DirectMessageService) GetDirectMessage(conversationID, directMessageID
 }
 
 func (s *DirectMessageService) UpdateDirectMessage(conversationID, directMessageID uuid.UUID, content string) (*models.DirectMessage, error) {
-	if err := s.DB.Model(&models.DirectMessage{}).
-		Where("id = ? AND conversation_id = ?", directMessageID, conversationID).
-		Update("content", content).Error; err != nil {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var directMessage models.DirectMessage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND conversation_id = ?", directMessageID, conversationID).
+			First(&directMessage).Error; err != nil {
+			return err
+		}
+
+		if directMessage.Content == content {
+			return nil
+		}
+
+		revision := models.DirectMessageRevision{
+			DirectMessageID: directMessage.ID,
+			Content:         directMessage.Content,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		edited := models.DirectMessage{EditedAt: &now}
+		if err := edited.SetContent(content); err != nil {
+			return err
+		}
+
+		return tx.Model(&directMessage).Select("content", "ast", "mentions", "edited_at").Updates(&edited).Error
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
 	var directMessage models.DirectMessage
-	if err := s.DB.Preload("Member.Profile").First(&directMessage, directMessageID).Error; err != nil {
+	if err := s.DB.Preload("Profile").First(&directMessage, directMessageID).Error; err != nil {
 		return nil, err
 	}
 
 	return &directMessage, nil
 }
 
+func (s *DirectMessageService) GetDirectMessageRevisions(conversationID, directMessageID uuid.UUID) ([]models.DirectMessageRevision, error) {
+	var revisions []models.DirectMessageRevision
+	err := s.DB.Joins("JOIN direct_messages ON direct_messages.id = direct_message_revisions.direct_message_id").
+		Where("direct_message_revisions.direct_message_id = ? AND direct_messages.conversation_id = ?",
+			directMessageID, conversationID).
+		Order("direct_message_revisions.created_at ASC").
+		Find(&revisions).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
 func (s *DirectMessageService) DeleteDirectMessage(conversationID, directMessageID uuid.UUID) (*models.DirectMessage, error) {
-	if err := s.DB.Model(&models.DirectMessage{}).
-		Where("id = ? AND conversation_id = ?", directMessageID, conversationID).
-		Updates(models.DirectMessage{FileURL: nil, Content: "This message has been deleted.", Deleted: true}).
-		Error; err != nil {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var directMessage models.DirectMessage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND conversation_id = ?", directMessageID, conversationID).
+			First(&directMessage).Error; err != nil {
+			return err
+		}
+
+		if directMessage.Deleted {
+			return nil
+		}
+
+		revision := models.DirectMessageRevision{
+			DirectMessageID: directMessage.ID,
+			Content:         directMessage.Content,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+
+		deleted := models.DirectMessage{FileURL: nil, Deleted: true}
+		if err := deleted.SetContent("This message has been deleted."); err != nil {
+			return err
+		}
+
+		return tx.Model(&directMessage).Updates(deleted).Error
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
 	var directMessage models.DirectMessage
-	if err := s.DB.Preload("Member.Profile").First(&directMessage, directMessageID).Error; err != nil {
+	if err := s.DB.Preload("Profile").First(&directMessage, directMessageID).Error; err != nil {
 		return nil, err
 	}
 
 	return &directMessage, nil
 }
+
+func (s *DirectMessageService) PinDirectMessage(conversationID, directMessageID uuid.UUID) (*models.DirectMessage, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the conversation so concurrent pins cannot go over the limit
+		var conversation models.Conversation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&conversation, "id = ?", conversationID).Error; err != nil {
+			return err
+		}
+
+		var directMessage models.DirectMessage
+		if err := tx.Where("id = ? AND conversation_id = ? AND deleted = false", directMessageID, conversationID).
+			First(&directMessage).Error; err != nil {
+			return err
+		}
+
+		if directMessage.Pinned {
+			return nil
+		}
+
+		var count int64
+		if err := tx.Model(&models.DirectMessage{}).
+			Where("conversation_id = ? AND pinned = true", conversationID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count >= MAX_PINNED_DIRECT_MESSAGES {
+			return utils.ErrPinLimitReached
+		}
+
+		return tx.Model(&directMessage).Updates(map[string]interface{}{
+			"pinned":    true,
+			"pinned_at": time.Now(),
+		}).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var directMessage models.DirectMessage
+	if err := s.DB.Preload("Profile").First(&directMessage, directMessageID).Error; err != nil {
+		return nil, err
+	}
+
+	return &directMessage, nil
+}
+
+func (s *DirectMessageService) UnpinDirectMessage(conversationID, directMessageID uuid.UUID) (*models.DirectMessage, error) {
+	result := s.DB.Model(&models.DirectMessage{}).
+		Where("id = ? AND conversation_id = ?", directMessageID, conversationID).
+		Updates(map[string]interface{}{
+			"pinned":    false,
+			"pinned_at": nil,
+		})
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var directMessage models.DirectMessage
+	if err := s.DB.Preload("Profile").First(&directMessage, directMessageID).Error; err != nil {
+		return nil, err
+	}
+
+	return &directMessage, nil
+}
+
+func (s *DirectMessageService) GetPinnedDirectMessages(conversationID uuid.UUID) ([]models.DirectMessage, error) {
+	var directMessages []models.DirectMessage
+	if err := s.DB.Preload("Profile").
+		Where("conversation_id = ? AND pinned = true", conversationID).
+		Order("pinned_at DESC").
+		Find(&directMessages).Error; err != nil {
+		return nil, err
+	}
+
+	return directMessages, nil
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/storage"
+	"discord-backend/internal/app/utils"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	MAX_EMOJIS_PER_SERVER          = 50
+	MAX_ANIMATED_EMOJIS_PER_SERVER = 50
+	MAX_EMOJI_SIZE                 = 256 * 1024
+	MAX_EMOJI_DIMENSION            = 256
+)
+
+var emojiNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{2,32}$`)
+
+type EmojiService struct {
+	DB      *gorm.DB
+	Storage storage.Storage
+}
+
+func NewEmojiService(db *gorm.DB, store storage.Storage) *EmojiService {
+	return &EmojiService{DB: db, Storage: store}
+}
+
+func (s *EmojiService) ensureAdmin(tx *gorm.DB, serverID, profileID uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.Member{}).
+		Where("server_id = ? AND profile_id = ? AND role = ?", serverID, profileID, models.Admin).
+		Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return utils.ErrForbidden
+	}
+
+	return nil
+}
+
+func (s *EmojiService) validateName(tx *gorm.DB, serverID uuid.UUID, emojiID uuid.UUID, name string) error {
+	if !emojiNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: name must be 2 to 32 letters, digits or underscores", utils.ErrInvalidEmoji)
+	}
+
+	var count int64
+	if err := tx.Model(&models.Emoji{}).
+		Where("server_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", serverID, name, emojiID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return fmt.Errorf("%w: the server already has an emoji named %q", utils.ErrInvalidEmoji, name)
+	}
+
+	return nil
+}
+
+// CreateEmoji checks the upload, stores the image and then the row, the file
+// is removed again when the row cannot be created
+func (s *EmojiService) CreateEmoji(serverID, profileID uuid.UUID, name string, data []byte) (*models.Emoji, error) {
+	if len(data) > MAX_EMOJI_SIZE {
+		return nil, fmt.Errorf("%w: image is larger than %d KB", utils.ErrInvalidEmoji, MAX_EMOJI_SIZE/1024)
+	}
+
+	info, err := storage.InspectImage(data, MAX_EMOJI_DIMENSION)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", utils.ErrInvalidEmoji, err.Error())
+	}
+
+	emoji := models.Emoji{
+		ID:        uuid.New(),
+		ServerID:  serverID,
+		Name:      name,
+		Animated:  info.Animated,
+		ProfileID: profileID,
+	}
+	emoji.StorageKey = fmt.Sprintf("emojis/%s/%s.%s", serverID, emoji.ID, info.Extension)
+	emoji.ImageURL = s.Storage.URL(emoji.StorageKey)
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.ensureAdmin(tx, serverID, profileID); err != nil {
+			return err
+		}
+
+		if err := s.validateName(tx, serverID, emoji.ID, name); err != nil {
+			return err
+		}
+
+		limit := MAX_EMOJIS_PER_SERVER
+		if emoji.Animated {
+			limit = MAX_ANIMATED_EMOJIS_PER_SERVER
+		}
+
+		var count int64
+		if err := tx.Model(&models.Emoji{}).Where("server_id = ? AND animated = ?", serverID, emoji.Animated).
+			Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count >= int64(limit) {
+			return fmt.Errorf("%w: a server can have at most %d emojis of this kind", utils.ErrInvalidEmoji, limit)
+		}
+
+		if err := s.Storage.Put(context.Background(), emoji.StorageKey, info.ContentType, data); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&emoji).Error; err != nil {
+			s.deleteObject(emoji.StorageKey)
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &emoji, nil
+}
+
+func (s *EmojiService) deleteObject(key string) {
+	if err := s.Storage.Delete(context.Background(), key); err != nil {
+		log.Printf("Error deleting stored object %s: %v", key, err)
+	}
+}
+
+func (s *EmojiService) GetEmojis(serverID, profileID uuid.UUID) ([]models.Emoji, error) {
+	var count int64
+	if err := s.DB.Model(&models.Member{}).Where("server_id = ? AND profile_id = ?", serverID, profileID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, utils.ErrForbidden
+	}
+
+	var emojis []models.Emoji
+	if err := s.DB.Where("server_id = ?", serverID).Order("name ASC").Find(&emojis).Error; err != nil {
+		return nil, err
+	}
+
+	return emojis, nil
+}
+
+func (s *EmojiService) UpdateEmoji(serverID, profileID, emojiID uuid.UUID, name string) (*models.Emoji, error) {
+	var emoji models.Emoji
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.ensureAdmin(tx, serverID, profileID); err != nil {
+			return err
+		}
+
+		if err := tx.Where("id = ? AND server_id = ?", emojiID, serverID).First(&emoji).Error; err != nil {
+			return err
+		}
+
+		if err := s.validateName(tx, serverID, emojiID, name); err != nil {
+			return err
+		}
+
+		emoji.Name = name
+		return tx.Select("name").Updates(&emoji).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &emoji, nil
+}
+
+// DeleteEmoji also drops the reactions that used it, the image is removed
+// once the row is gone
+func (s *EmojiService) DeleteEmoji(serverID, profileID, emojiID uuid.UUID) error {
+	var emoji models.Emoji
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.ensureAdmin(tx, serverID, profileID); err != nil {
+			return err
+		}
+
+		if err := tx.Where("id = ? AND server_id = ?", emojiID, serverID).First(&emoji).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("emoji_id = ?", emojiID).Delete(&models.Reaction{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&emoji).Error
+	})
+
+	if err != nil {
+		return err
+	}
+
+	s.deleteObject(emoji.StorageKey)
+
+	return nil
+}
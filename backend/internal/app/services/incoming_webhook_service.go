@@ -0,0 +1,217 @@
+package services
+
+import (
+	"crypto/subtle"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	MAX_INCOMING_WEBHOOKS_PER_CHANNEL = 10
+	MAX_WEBHOOK_NAME                  = 80
+	MAX_WEBHOOK_CONTENT               = 2000
+)
+
+type IncomingWebhookService struct {
+	DB *gorm.DB
+}
+
+func NewIncomingWebhookService(db *gorm.DB) *IncomingWebhookService {
+	return &IncomingWebhookService{DB: db}
+}
+
+func (w *IncomingWebhookService) ensureModerator(tx *gorm.DB, serverID, profileID uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.Member{}).
+		Where("server_id = ? AND profile_id = ? AND role IN ?",
+			serverID, profileID, []models.MemberRole{models.Admin, models.Moderator}).
+		Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return utils.ErrForbidden
+	}
+
+	return nil
+}
+
+func validateWebhookName(name string) error {
+	if name == "" || len(name) > MAX_WEBHOOK_NAME {
+		return fmt.Errorf("%w: name must be between 1 and %d characters", utils.ErrInvalidWebhook, MAX_WEBHOOK_NAME)
+	}
+	return nil
+}
+
+// CreateIncomingWebhook returns the token separately, it is only shown once
+func (w *IncomingWebhookService) CreateIncomingWebhook(serverID, channelID, profileID uuid.UUID, name, avatarURL string) (*models.IncomingWebhook, string, error) {
+	if err := validateWebhookName(name); err != nil {
+		return nil, "", err
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	webhook := models.IncomingWebhook{
+		Name:      name,
+		AvatarURL: avatarURL,
+		TokenHash: utils.HashToken(token),
+		ServerID:  serverID,
+		ChannelID: channelID,
+		ProfileID: profileID,
+	}
+
+	err = w.DB.Transaction(func(tx *gorm.DB) error {
+		if err := w.ensureModerator(tx, serverID, profileID); err != nil {
+			return err
+		}
+
+		var channel models.Channel
+		if err := tx.Where("id = ? AND server_id = ?", channelID, serverID).First(&channel).Error; err != nil {
+			return err
+		}
+
+		if channel.Type != models.Text {
+			return fmt.Errorf("%w: webhooks can only post into text channels", utils.ErrInvalidWebhook)
+		}
+
+		var count int64
+		if err := tx.Model(&models.IncomingWebhook{}).Where("channel_id = ?", channelID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count >= MAX_INCOMING_WEBHOOKS_PER_CHANNEL {
+			return fmt.Errorf("%w: a channel can have at most %d webhooks", utils.ErrInvalidWebhook, MAX_INCOMING_WEBHOOKS_PER_CHANNEL)
+		}
+
+		return tx.Create(&webhook).Error
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &webhook, token, nil
+}
+
+func (w *IncomingWebhookService) GetIncomingWebhooks(serverID, channelID, profileID uuid.UUID) ([]models.IncomingWebhook, error) {
+	if err := w.ensureModerator(w.DB, serverID, profileID); err != nil {
+		return nil, err
+	}
+
+	var webhooks []models.IncomingWebhook
+	if err := w.DB.Where("server_id = ? AND channel_id = ?", serverID, channelID).
+		Order("created_at ASC").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (w *IncomingWebhookService) UpdateIncomingWebhook(serverID, channelID, profileID, webhookID uuid.UUID, name, avatarURL *string) (*models.IncomingWebhook, error) {
+	var webhook models.IncomingWebhook
+
+	err := w.DB.Transaction(func(tx *gorm.DB) error {
+		if err := w.ensureModerator(tx, serverID, profileID); err != nil {
+			return err
+		}
+
+		if err := tx.Where("id = ? AND server_id = ? AND channel_id = ?", webhookID, serverID, channelID).
+			First(&webhook).Error; err != nil {
+			return err
+		}
+
+		if name != nil {
+			if err := validateWebhookName(*name); err != nil {
+				return err
+			}
+			webhook.Name = *name
+		}
+
+		if avatarURL != nil {
+			webhook.AvatarURL = *avatarURL
+		}
+
+		return tx.Select("name", "avatar_url").Updates(&webhook).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (w *IncomingWebhookService) DeleteIncomingWebhook(serverID, channelID, profileID, webhookID uuid.UUID) error {
+	return w.DB.Transaction(func(tx *gorm.DB) error {
+		if err := w.ensureModerator(tx, serverID, profileID); err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ? AND server_id = ? AND channel_id = ?", webhookID, serverID, channelID).
+			Delete(&models.IncomingWebhook{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// GetIncomingWebhookByToken returns gorm.ErrRecordNotFound for an unknown id
+// and a wrong token alike, so callers cannot probe for valid ids
+func (w *IncomingWebhookService) GetIncomingWebhookByToken(webhookID uuid.UUID, token string) (*models.IncomingWebhook, error) {
+	var webhook models.IncomingWebhook
+	if err := w.DB.Preload("Channel").Preload("Server").First(&webhook, "id = ?", webhookID).Error; err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(webhook.TokenHash), []byte(utils.HashToken(token))) != 1 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	// The channel or server is soft deleted, the webhook stays until it is purged
+	if webhook.Channel.ID == uuid.Nil || webhook.Server.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &webhook, nil
+}
+
+// ExecuteIncomingWebhook posts a message into the webhook channel, the display
+// name and avatar fall back to the ones configured on the webhook
+func (w *IncomingWebhookService) ExecuteIncomingWebhook(webhook *models.IncomingWebhook, content, username, avatarURL string, embeds []models.Embed) (*models.Message, error) {
+	if content == "" && len(embeds) == 0 {
+		return nil, fmt.Errorf("%w: content or embeds are required", utils.ErrInvalidWebhook)
+	}
+
+	if len(content) > MAX_WEBHOOK_CONTENT {
+		return nil, fmt.Errorf("%w: content is longer than %d characters", utils.ErrInvalidWebhook, MAX_WEBHOOK_CONTENT)
+	}
+
+	if err := models.ValidateEmbeds(embeds); err != nil {
+		return nil, fmt.Errorf("%w: %s", utils.ErrInvalidWebhook, err.Error())
+	}
+
+	if username == "" {
+		username = webhook.Name
+	} else if err := validateWebhookName(username); err != nil {
+		return nil, err
+	}
+
+	if avatarURL == "" {
+		avatarURL = webhook.AvatarURL
+	}
+
+	return NewMessageService(w.DB).CreateWebhookMessage(webhook.ChannelID, webhook.ID, username, avatarURL, content, embeds)
+}
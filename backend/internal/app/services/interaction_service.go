@@ -0,0 +1,738 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/netguard"
+	"discord-backend/internal/app/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	MAX_COMMANDS_PER_SERVER         = 50
+	MAX_COMMAND_OPTIONS             = 25
+	MAX_COMMAND_CHOICES             = 25
+	MAX_COMMAND_DESCRIPTION         = 100
+	MAX_OPTION_STRING               = 1000
+	MAX_INTERACTION_CONTENT         = 2000
+	INTERACTION_CALLBACK_TIMEOUT    = time.Second * 3
+	INTERACTION_DEFERRED_TIMEOUT    = time.Minute * 15
+	INTERACTION_RESPONSE_MAX_SIZE   = 64 * 1024
+	INTERACTION_APPLICATION_COMMAND = "APPLICATION_COMMAND"
+)
+
+var commandNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+type InteractionService struct {
+	DB     *gorm.DB
+	Client *http.Client
+}
+
+func NewInteractionService(db *gorm.DB) *InteractionService {
+	return &InteractionService{
+		DB:     db,
+		Client: netguard.NewPublicClient(INTERACTION_CALLBACK_TIMEOUT),
+	}
+}
+
+// InteractionPayload is the body posted to the application callback, user and
+// channel options are resolved so the application does not need to look them up
+type InteractionPayload struct {
+	ID            uuid.UUID              `json:"id"`
+	Type          string                 `json:"type"`
+	ApplicationID uuid.UUID              `json:"applicationId"`
+	ServerID      uuid.UUID              `json:"serverId"`
+	ChannelID     uuid.UUID              `json:"channelId"`
+	Command       InteractionCommand     `json:"command"`
+	Options       map[string]interface{} `json:"options"`
+	Resolved      InteractionResolved    `json:"resolved"`
+	Member        *models.Member         `json:"member"`
+	OccurredAt    time.Time              `json:"occurredAt"`
+}
+
+type InteractionCommand struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+type InteractionResolved struct {
+	Members  map[string]models.Member  `json:"members,omitempty"`
+	Channels map[string]models.Channel `json:"channels,omitempty"`
+}
+
+func validateCallbackURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: callback url must be an absolute http or https url", utils.ErrInvalidBot)
+	}
+
+	// The client checks the address again when it dials, the DNS answer can change
+	ctx, cancel := context.WithTimeout(context.Background(), INTERACTION_CALLBACK_TIMEOUT)
+	defer cancel()
+	if err := netguard.CheckPublicURL(ctx, parsed); err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrInvalidBot, err)
+	}
+
+	return nil
+}
+
+// UpsertApplication returns the secret only when the application is created,
+// changing the callback url keeps the existing secret
+func (s *InteractionService) UpsertApplication(ownerID, botID uuid.UUID, callbackURL string) (*models.Application, string, error) {
+	if err := validateCallbackURL(callbackURL); err != nil {
+		return nil, "", err
+	}
+
+	var application models.Application
+	var secret string
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := NewBotService(tx).ownedBot(tx, ownerID, botID); err != nil {
+			return err
+		}
+
+		err := tx.Where("bot_id = ?", botID).First(&application).Error
+		if err == nil {
+			application.CallbackURL = callbackURL
+			return tx.Select("callback_url").Updates(&application).Error
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		secret, err = utils.GenerateToken(32)
+		if err != nil {
+			return err
+		}
+
+		application = models.Application{
+			BotID:       botID,
+			CallbackURL: callbackURL,
+			Secret:      secret,
+		}
+		return tx.Create(&application).Error
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &application, secret, nil
+}
+
+func (s *InteractionService) GetApplication(ownerID, botID uuid.UUID) (*models.Application, error) {
+	if _, err := NewBotService(s.DB).ownedBot(s.DB, ownerID, botID); err != nil {
+		return nil, err
+	}
+
+	var application models.Application
+	if err := s.DB.Where("bot_id = ?", botID).First(&application).Error; err != nil {
+		return nil, err
+	}
+
+	return &application, nil
+}
+
+func (s *InteractionService) RotateApplicationSecret(ownerID, botID uuid.UUID) (string, error) {
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := NewBotService(tx).ownedBot(tx, ownerID, botID); err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.Application{}).Where("bot_id = ?", botID).Update("secret", secret)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+func validateDescription(description string) error {
+	if description == "" || len(description) > MAX_COMMAND_DESCRIPTION {
+		return fmt.Errorf("%w: description must be between 1 and %d characters", utils.ErrInvalidCommand, MAX_COMMAND_DESCRIPTION)
+	}
+	return nil
+}
+
+func validateChoiceValue(optionType models.CommandOptionType, value interface{}) bool {
+	switch optionType {
+	case models.OptionString:
+		_, ok := value.(string)
+		return ok
+	case models.OptionInteger:
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case models.OptionNumber:
+		_, ok := value.(float64)
+		return ok
+	}
+	return false
+}
+
+func validateCommand(name, description string, options []models.CommandOption) error {
+	if !commandNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: name must be 1 to 32 lowercase letters, digits, - or _", utils.ErrInvalidCommand)
+	}
+
+	if err := validateDescription(description); err != nil {
+		return err
+	}
+
+	if len(options) > MAX_COMMAND_OPTIONS {
+		return fmt.Errorf("%w: a command can have at most %d options", utils.ErrInvalidCommand, MAX_COMMAND_OPTIONS)
+	}
+
+	names := make(map[string]bool, len(options))
+	optional := false
+	for _, option := range options {
+		if !commandNamePattern.MatchString(option.Name) {
+			return fmt.Errorf("%w: option name %q must be 1 to 32 lowercase letters, digits, - or _", utils.ErrInvalidCommand, option.Name)
+		}
+
+		if names[option.Name] {
+			return fmt.Errorf("%w: duplicate option %q", utils.ErrInvalidCommand, option.Name)
+		}
+		names[option.Name] = true
+
+		if err := validateDescription(option.Description); err != nil {
+			return err
+		}
+
+		switch option.Type {
+		case models.OptionString, models.OptionInteger, models.OptionNumber,
+			models.OptionBoolean, models.OptionUser, models.OptionChannel:
+		default:
+			return fmt.Errorf("%w: option %q has an unknown type %q", utils.ErrInvalidCommand, option.Name, option.Type)
+		}
+
+		// Required options come first so clients can prompt for them in order
+		if option.Required && optional {
+			return fmt.Errorf("%w: required option %q must come before optional ones", utils.ErrInvalidCommand, option.Name)
+		}
+		optional = optional || !option.Required
+
+		if len(option.Choices) > MAX_COMMAND_CHOICES {
+			return fmt.Errorf("%w: option %q can have at most %d choices", utils.ErrInvalidCommand, option.Name, MAX_COMMAND_CHOICES)
+		}
+
+		for _, choice := range option.Choices {
+			if choice.Name == "" || len(choice.Name) > MAX_COMMAND_DESCRIPTION {
+				return fmt.Errorf("%w: choice names of %q must be between 1 and %d characters", utils.ErrInvalidCommand, option.Name, MAX_COMMAND_DESCRIPTION)
+			}
+
+			if !validateChoiceValue(option.Type, choice.Value) {
+				return fmt.Errorf("%w: choice %q does not match the type of option %q", utils.ErrInvalidCommand, choice.Name, option.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RegisterCommand is called with the bot token, registering a name the bot
+// already owns in the server replaces the previous definition
+func (s *InteractionService) RegisterCommand(botID, serverID uuid.UUID, name, description string, options []models.CommandOption) (*models.Command, error) {
+	if options == nil {
+		options = []models.CommandOption{}
+	}
+
+	if err := validateCommand(name, description, options); err != nil {
+		return nil, err
+	}
+
+	var command models.Command
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var application models.Application
+		if err := tx.Where("bot_id = ?", botID).First(&application).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: the bot has no application", utils.ErrInvalidCommand)
+			}
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Member{}).Where("server_id = ? AND profile_id = ?", serverID, botID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return utils.ErrForbidden
+		}
+
+		err := tx.Where("server_id = ? AND name = ?", serverID, name).First(&command).Error
+		if err == nil {
+			if command.ApplicationID != application.ID {
+				return fmt.Errorf("%w: command %q is already registered by another application", utils.ErrInvalidCommand, name)
+			}
+
+			command.Description = description
+			command.Options = options
+			return tx.Select("description", "options").Updates(&command).Error
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := tx.Model(&models.Command{}).Where("server_id = ? AND application_id = ?", serverID, application.ID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count >= MAX_COMMANDS_PER_SERVER {
+			return fmt.Errorf("%w: an application can register at most %d commands per server", utils.ErrInvalidCommand, MAX_COMMANDS_PER_SERVER)
+		}
+
+		command = models.Command{
+			ApplicationID: application.ID,
+			ServerID:      serverID,
+			Name:          name,
+			Description:   description,
+			Options:       options,
+		}
+		return tx.Create(&command).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &command, nil
+}
+
+func (s *InteractionService) GetCommands(serverID, profileID uuid.UUID) ([]models.Command, error) {
+	var count int64
+	if err := s.DB.Model(&models.Member{}).Where("server_id = ? AND profile_id = ?", serverID, profileID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, utils.ErrForbidden
+	}
+
+	var commands []models.Command
+	if err := s.DB.Where("server_id = ?", serverID).Order("name ASC").Find(&commands).Error; err != nil {
+		return nil, err
+	}
+
+	return commands, nil
+}
+
+// DeleteCommand can be called by the bot that registered the command or by a
+// server admin
+func (s *InteractionService) DeleteCommand(serverID, profileID, commandID uuid.UUID) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var command models.Command
+		if err := tx.Preload("Application").Where("id = ? AND server_id = ?", commandID, serverID).
+			First(&command).Error; err != nil {
+			return err
+		}
+
+		if command.Application.BotID != profileID {
+			var count int64
+			if err := tx.Model(&models.Member{}).
+				Where("server_id = ? AND profile_id = ? AND role = ?", serverID, profileID, models.Admin).
+				Count(&count).Error; err != nil {
+				return err
+			}
+
+			if count == 0 {
+				return utils.ErrForbidden
+			}
+		}
+
+		if err := tx.Where("command_id = ?", command.ID).Delete(&models.Interaction{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&command).Error
+	})
+}
+
+func (s *InteractionService) resolveOptions(tx *gorm.DB, serverID uuid.UUID, command *models.Command, values map[string]interface{}) (map[string]interface{}, InteractionResolved, error) {
+	resolved := InteractionResolved{
+		Members:  make(map[string]models.Member),
+		Channels: make(map[string]models.Channel),
+	}
+
+	known := make(map[string]bool, len(command.Options))
+	for _, option := range command.Options {
+		known[option.Name] = true
+	}
+
+	for name := range values {
+		if !known[name] {
+			return nil, resolved, fmt.Errorf("%w: unknown option %q", utils.ErrInvalidInteraction, name)
+		}
+	}
+
+	options := make(map[string]interface{}, len(values))
+	for _, option := range command.Options {
+		value, ok := values[option.Name]
+		if !ok || value == nil {
+			if option.Required {
+				return nil, resolved, fmt.Errorf("%w: option %q is required", utils.ErrInvalidInteraction, option.Name)
+			}
+			continue
+		}
+
+		invalid := fmt.Errorf("%w: option %q must be a %s", utils.ErrInvalidInteraction, option.Name, option.Type)
+
+		switch option.Type {
+		case models.OptionString:
+			text, ok := value.(string)
+			if !ok {
+				return nil, resolved, invalid
+			}
+
+			if len(text) > MAX_OPTION_STRING {
+				return nil, resolved, fmt.Errorf("%w: option %q is longer than %d characters", utils.ErrInvalidInteraction, option.Name, MAX_OPTION_STRING)
+			}
+		case models.OptionInteger, models.OptionNumber:
+			if !validateChoiceValue(option.Type, value) {
+				return nil, resolved, invalid
+			}
+		case models.OptionBoolean:
+			if _, ok := value.(bool); !ok {
+				return nil, resolved, invalid
+			}
+		case models.OptionUser:
+			text, _ := value.(string)
+			profileID, err := uuid.Parse(text)
+			if err != nil {
+				return nil, resolved, invalid
+			}
+
+			var member models.Member
+			if err := tx.Preload("Profile").Where("server_id = ? AND profile_id = ?", serverID, profileID).
+				First(&member).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, resolved, fmt.Errorf("%w: option %q is not a member of the server", utils.ErrInvalidInteraction, option.Name)
+				}
+				return nil, resolved, err
+			}
+			resolved.Members[profileID.String()] = member
+		case models.OptionChannel:
+			text, _ := value.(string)
+			channelID, err := uuid.Parse(text)
+			if err != nil {
+				return nil, resolved, invalid
+			}
+
+			var channel models.Channel
+			if err := tx.Where("id = ? AND server_id = ?", channelID, serverID).First(&channel).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, resolved, fmt.Errorf("%w: option %q is not a channel of the server", utils.ErrInvalidInteraction, option.Name)
+				}
+				return nil, resolved, err
+			}
+			resolved.Channels[channelID.String()] = channel
+		}
+
+		if len(option.Choices) > 0 {
+			matched := false
+			for _, choice := range option.Choices {
+				if fmt.Sprint(choice.Value) == fmt.Sprint(value) {
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return nil, resolved, fmt.Errorf("%w: option %q must be one of its choices", utils.ErrInvalidInteraction, option.Name)
+			}
+		}
+
+		options[option.Name] = value
+	}
+
+	return options, resolved, nil
+}
+
+// CreateInteraction validates an invocation of a command by a member and
+// returns the payload to post to the application callback
+func (s *InteractionService) CreateInteraction(serverID, channelID, profileID uuid.UUID, name string, values map[string]interface{}) (*models.Interaction, *InteractionPayload, error) {
+	var interaction models.Interaction
+	var payload InteractionPayload
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var member models.Member
+		if err := tx.Preload("Profile").Where("server_id = ? AND profile_id = ?", serverID, profileID).
+			First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrForbidden
+			}
+			return err
+		}
+
+		var channel models.Channel
+		if err := tx.Where("id = ? AND server_id = ?", channelID, serverID).First(&channel).Error; err != nil {
+			return err
+		}
+
+		if channel.Type != models.Text {
+			return fmt.Errorf("%w: commands can only be used in text channels", utils.ErrInvalidInteraction)
+		}
+
+		var command models.Command
+		if err := tx.Where("server_id = ? AND name = ?", serverID, name).First(&command).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: unknown command %q", utils.ErrInvalidInteraction, name)
+			}
+			return err
+		}
+
+		options, resolved, err := s.resolveOptions(tx, serverID, &command, values)
+		if err != nil {
+			return err
+		}
+
+		interaction = models.Interaction{
+			CommandID:     command.ID,
+			ApplicationID: command.ApplicationID,
+			ServerID:      serverID,
+			ChannelID:     channelID,
+			MemberID:      member.ID,
+			ProfileID:     profileID,
+			Options:       options,
+			Status:        models.InteractionPending,
+		}
+		if err := tx.Create(&interaction).Error; err != nil {
+			return err
+		}
+
+		payload = InteractionPayload{
+			ID:            interaction.ID,
+			Type:          INTERACTION_APPLICATION_COMMAND,
+			ApplicationID: command.ApplicationID,
+			ServerID:      serverID,
+			ChannelID:     channelID,
+			Command:       InteractionCommand{ID: command.ID, Name: command.Name},
+			Options:       options,
+			Resolved:      resolved,
+			Member:        &member,
+			OccurredAt:    interaction.CreatedAt,
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &interaction, &payload, nil
+}
+
+// SendInteraction posts the signed payload to the application callback and
+// waits at most INTERACTION_CALLBACK_TIMEOUT for its response
+func (s *InteractionService) SendInteraction(ctx context.Context, payload *InteractionPayload) (*models.InteractionResponse, error) {
+	var application models.Application
+	if err := s.DB.First(&application, "id = ?", payload.ApplicationID).Error; err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, INTERACTION_CALLBACK_TIMEOUT)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, application.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "discord-clone-interactions")
+	req.Header.Set("X-Interaction-Id", payload.ID.String())
+	req.Header.Set("X-Interaction-Timestamp", timestamp)
+	req.Header.Set("X-Interaction-Signature", "sha256="+utils.SignPayload(application.Secret, timestamp, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: the application did not respond in time", utils.ErrInvalidInteraction)
+		}
+		if errors.Is(err, netguard.ErrBlockedAddress) {
+			return nil, fmt.Errorf("%w: the callback url does not point to a public address", utils.ErrInvalidInteraction)
+		}
+		return nil, fmt.Errorf("%w: the application could not be reached", utils.ErrInvalidInteraction)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%w: the application responded with status %d", utils.ErrInvalidInteraction, resp.StatusCode)
+	}
+
+	var response models.InteractionResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, INTERACTION_RESPONSE_MAX_SIZE)).Decode(&response); err != nil {
+		return nil, fmt.Errorf("%w: the application sent an invalid response", utils.ErrInvalidInteraction)
+	}
+
+	return &response, nil
+}
+
+func validateInteractionResponse(response *models.InteractionResponse, deferred bool) error {
+	switch response.Type {
+	case models.ResponseMessage, models.ResponseEphemeral:
+	case models.ResponseDeferred:
+		if deferred {
+			return fmt.Errorf("%w: a deferred interaction cannot be deferred again", utils.ErrInvalidInteraction)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown response type %q", utils.ErrInvalidInteraction, response.Type)
+	}
+
+	if response.Data.Content == "" && len(response.Data.Embeds) == 0 {
+		return fmt.Errorf("%w: content or embeds are required", utils.ErrInvalidInteraction)
+	}
+
+	if len(response.Data.Content) > MAX_INTERACTION_CONTENT {
+		return fmt.Errorf("%w: content is longer than %d characters", utils.ErrInvalidInteraction, MAX_INTERACTION_CONTENT)
+	}
+
+	if err := models.ValidateEmbeds(response.Data.Embeds); err != nil {
+		return fmt.Errorf("%w: %s", utils.ErrInvalidInteraction, err.Error())
+	}
+
+	return nil
+}
+
+// RecordResponse stores the outcome of an interaction, a MESSAGE response is
+// posted in the channel as the bot member and returned
+func (s *InteractionService) RecordResponse(interaction *models.Interaction, response *models.InteractionResponse) (*models.Message, error) {
+	from := models.InteractionPending
+	if interaction.Status == models.InteractionDeferred {
+		from = models.InteractionDeferred
+	}
+
+	if err := validateInteractionResponse(response, from == models.InteractionDeferred); err != nil {
+		return nil, err
+	}
+
+	var message *models.Message
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"status":        models.InteractionResponded,
+			"response_type": string(response.Type),
+			"responded_at":  time.Now(),
+		}
+
+		if response.Type == models.ResponseDeferred {
+			updates["status"] = models.InteractionDeferred
+		}
+
+		if response.Type == models.ResponseMessage {
+			var application models.Application
+			if err := tx.First(&application, "id = ?", interaction.ApplicationID).Error; err != nil {
+				return err
+			}
+
+			var member models.Member
+			if err := tx.Where("server_id = ? AND profile_id = ?", interaction.ServerID, application.BotID).
+				First(&member).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: the bot is no longer a member of the server", utils.ErrInvalidInteraction)
+				}
+				return err
+			}
+
+			var err error
+			message, err = NewMessageService(tx).CreateMessageWithEmbeds(interaction.ChannelID, member.ID, response.Data.Content, response.Data.Embeds)
+			if err != nil {
+				return err
+			}
+			updates["message_id"] = message.ID
+		}
+
+		// The status guard makes a second response to the same interaction a no-op
+		result := tx.Model(&models.Interaction{}).Where("id = ? AND status = ?", interaction.ID, from).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: the interaction was already answered", utils.ErrInvalidInteraction)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+func (s *InteractionService) FailInteraction(interaction *models.Interaction, cause error) {
+	s.DB.Model(&models.Interaction{}).
+		Where("id = ? AND status IN ?", interaction.ID,
+			[]models.InteractionStatus{models.InteractionPending, models.InteractionDeferred}).
+		Updates(map[string]interface{}{
+			"status": models.InteractionFailed,
+			"error":  cause.Error(),
+		})
+}
+
+// GetDeferredInteraction returns an interaction the bot deferred and can still
+// answer, after INTERACTION_DEFERRED_TIMEOUT it is marked as failed
+func (s *InteractionService) GetDeferredInteraction(botID, interactionID uuid.UUID) (*models.Interaction, error) {
+	var interaction models.Interaction
+	if err := s.DB.Joins("JOIN applications ON applications.id = interactions.application_id").
+		Where("interactions.id = ? AND applications.bot_id = ?", interactionID, botID).
+		First(&interaction).Error; err != nil {
+		return nil, err
+	}
+
+	if interaction.Status != models.InteractionDeferred {
+		return nil, fmt.Errorf("%w: the interaction is not deferred", utils.ErrInvalidInteraction)
+	}
+
+	if time.Since(interaction.CreatedAt) > INTERACTION_DEFERRED_TIMEOUT {
+		err := fmt.Errorf("%w: the interaction expired", utils.ErrInvalidInteraction)
+		s.FailInteraction(&interaction, err)
+		return nil, err
+	}
+
+	return &interaction, nil
+}
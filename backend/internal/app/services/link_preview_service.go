@@ -0,0 +1,97 @@
+package services
+
+import (
+	"discord-backend/internal/app/models"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	LINK_PREVIEW_TTL        = time.Hour * 24
+	LINK_PREVIEW_FAILED_TTL = time.Hour
+)
+
+type LinkPreviewService struct {
+	DB *gorm.DB
+}
+
+func NewLinkPreviewService(db *gorm.DB) *LinkPreviewService {
+	return &LinkPreviewService{DB: db}
+}
+
+// GetLinkPreview returns the cached preview of url, or nil when there is none
+// or it is stale. Failed fetches are cached for a shorter time
+func (s *LinkPreviewService) GetLinkPreview(url string) (*models.LinkPreview, error) {
+	var linkPreview models.LinkPreview
+	if err := s.DB.Where("url = ?", url).First(&linkPreview).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ttl := LINK_PREVIEW_TTL
+	if linkPreview.Embed == nil {
+		ttl = LINK_PREVIEW_FAILED_TTL
+	}
+
+	if time.Since(linkPreview.FetchedAt) > ttl {
+		return nil, nil
+	}
+
+	return &linkPreview, nil
+}
+
+func (s *LinkPreviewService) SaveLinkPreview(url string, embed *models.Embed, fetchErr error) error {
+	linkPreview := models.LinkPreview{
+		URL:       url,
+		Embed:     embed,
+		FetchedAt: time.Now(),
+	}
+
+	if fetchErr != nil {
+		linkPreview.Error = fetchErr.Error()
+	}
+
+	return s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "url"}},
+		DoUpdates: clause.AssignmentColumns([]string{"embed", "error", "fetched_at", "updated_at"}),
+	}).Create(&linkPreview).Error
+}
+
+// AttachLinkPreviews stores the previews as the embeds of a message. Messages
+// that were deleted or already carry embeds of their own are left alone, nil
+// is returned for them
+func (s *LinkPreviewService) AttachLinkPreviews(messageID uuid.UUID, embeds []models.Embed) (*models.Message, error) {
+	attached := false
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var message models.Message
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&message, "id = ?", messageID).Error; err != nil {
+			return err
+		}
+
+		if message.Deleted || len(message.Embeds) > 0 {
+			return nil
+		}
+
+		message.Embeds = embeds
+		attached = true
+		return tx.Model(&message).Select("embeds").Updates(&message).Error
+	})
+
+	if err != nil || !attached {
+		return nil, err
+	}
+
+	var message models.Message
+	if err := s.DB.Preload("Member.Profile").First(&message, "id = ?", messageID).Error; err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
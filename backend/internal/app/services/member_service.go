@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MemberService struct {
@@ -41,7 +42,9 @@ func (m *MemberService) UpdateMemberRole(serverID uuid.UUID, profileID uuid.UUID
 	return &server, nil
 }
 
-func (m *MemberService) KickMember(serverID uuid.UUID, profileID uuid.UUID, memberID uuid.UUID) (*models.Server, error) {
+// KickMember also returns the profile of the member that was kicked
+func (m *MemberService) KickMember(serverID uuid.UUID, profileID uuid.UUID, memberID uuid.UUID) (*models.Server, uuid.UUID, error) {
+	var kicked models.Member
 	err := m.DB.Transaction(func(tx *gorm.DB) error {
 		var server models.Server
 		if err := tx.Where("id = ? AND profile_id = ?", serverID, profileID).
@@ -49,8 +52,12 @@ func (m *MemberService) KickMember(serverID uuid.UUID, profileID uuid.UUID, memb
 			return err
 		}
 
-		if err := tx.Where("id = ? AND profile_id <> ?", memberID, profileID).
-			Delete(&models.Member{}).Error; err != nil {
+		if err := ensureModeratorMFA(tx, serverID, profileID); err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Returning{}).Where("id = ? AND profile_id <> ?", memberID, profileID).
+			Delete(&kicked).Error; err != nil {
 			return err
 		}
 
@@ -58,25 +65,32 @@ func (m *MemberService) KickMember(serverID uuid.UUID, profileID uuid.UUID, memb
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, uuid.Nil, err
 	}
 
 	var updatedServer models.Server
 	if err := m.DB.Preload("Members", func(db *gorm.DB) *gorm.DB {
 		return db.Order("role ASC").Preload("Profile")
 	}).First(&updatedServer, serverID).Error; err != nil {
-		return nil, err
+		return nil, uuid.Nil, err
 	}
 
-	return &updatedServer, nil
+	return &updatedServer, kicked.ProfileID, nil
 }
 
 func (m *MemberService) GetMember(serverID, profileID uuid.UUID) (*models.Member, error) {
 	var member models.Member
-	if err := m.DB.Preload("Profile").Where("server_id = ? AND profile_id = ?", serverID, profileID).
+	if err := m.DB.Preload("Profile").Scopes(liveServerMembers).
+		Where("members.server_id = ? AND members.profile_id = ?", serverID, profileID).
 		First(&member).Error; err != nil {
 		return nil, err
 	}
 
 	return &member, nil
 }
+
+// liveServerMembers leaves out the members of soft deleted servers, they are
+// kept with the server until it is restored or purged
+func liveServerMembers(db *gorm.DB) *gorm.DB {
+	return db.Joins("JOIN servers ON servers.id = members.server_id AND servers.deleted_at IS NULL")
+}
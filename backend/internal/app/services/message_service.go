@@ -1,14 +1,26 @@
 package services
 
 import (
+	"discord-backend/internal/app/markdown"
 	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const MESSAGES_BATCH = 10
 
+const MAX_PINNED_MESSAGES = 50
+
+const MAX_REACTIONS_PER_MESSAGE = 20
+
 type MessageService struct {
 	DB *gorm.DB
 }
@@ -19,10 +31,13 @@ func NewMessageService(db *gorm.DB) *MessageService {
 
 func (s *MessageService) CreateMessage(channelID, memberID uuid.UUID, content, fileUrl string) (*models.Message, error) {
 	message := models.Message{
-		Content:   content,
 		FileURL:   &fileUrl,
 		ChannelID: channelID,
-		MemberID:  memberID,
+		MemberID:  &memberID,
+	}
+
+	if err := message.SetContent(content); err != nil {
+		return nil, err
 	}
 
 	if err := s.DB.Create(&message).Error; err != nil {
@@ -38,10 +53,127 @@ func (s *MessageService) CreateMessage(channelID, memberID uuid.UUID, content, f
 	return &reponseMessage, nil
 }
 
-func (s *MessageService) GetMessages(channelID uuid.UUID, cursor string) ([]models.Message, string, error) {
+// PostMessage creates the message of a member unless the slowmode of the
+// channel holds it back, then nothing is created and the wait is returned.
+// The member row stays locked until the message is in, so concurrent posts of
+// the member see each other
+func (s *MessageService) PostMessage(channel *models.Channel, member *models.Member, content, fileUrl string) (*models.Message, time.Duration, error) {
+	var message *models.Message
+	var retryAfter time.Duration
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			First(&models.Member{}, "id = ?", member.ID).Error; err != nil {
+			return err
+		}
+
+		// The slowmode can not change while the message is checked against it
+		var locked models.Channel
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Select("id", "slowmode").
+			First(&locked, "id = ?", channel.ID).Error; err != nil {
+			return err
+		}
+
+		wait, err := slowmodeRetryAfter(tx, &locked, member)
+		if err != nil {
+			return err
+		}
+
+		if wait > 0 {
+			retryAfter = wait
+			return nil
+		}
+
+		message, err = NewMessageService(tx).CreateMessage(channel.ID, member.ID, content, fileUrl)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return message, retryAfter, nil
+}
+
+// slowmodeRetryAfter is how long the member has to wait before posting in the
+// channel again, admins and moderators are not slowed down
+func slowmodeRetryAfter(tx *gorm.DB, channel *models.Channel, member *models.Member) (time.Duration, error) {
+	if channel.Slowmode <= 0 || member.Role == models.Admin || member.Role == models.Moderator {
+		return 0, nil
+	}
+
+	var last models.Message
+	err := tx.Select("created_at").
+		Where("channel_id = ? AND member_id = ?", channel.ID, member.ID).
+		Order("created_at DESC").
+		First(&last).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	wait := time.Until(last.CreatedAt.Add(time.Duration(channel.Slowmode) * time.Second))
+	if wait < 0 {
+		return 0, nil
+	}
+
+	return wait, nil
+}
+
+// CreateMessageWithEmbeds is used for messages posted by bots on behalf of
+// their member, like interaction responses
+func (s *MessageService) CreateMessageWithEmbeds(channelID, memberID uuid.UUID, content string, embeds []models.Embed) (*models.Message, error) {
+	message := models.Message{
+		ChannelID: channelID,
+		MemberID:  &memberID,
+		Embeds:    embeds,
+	}
+
+	if err := message.SetContent(content); err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.Create(&message).Error; err != nil {
+		return nil, err
+	}
+
+	var reponseMessage models.Message
+	if err := s.DB.Preload("Member.Profile").Where("id = ?", message.ID).
+		First(&reponseMessage).Error; err != nil {
+		return nil, err
+	}
+
+	return &reponseMessage, nil
+}
+
+func (s *MessageService) CreateWebhookMessage(channelID, webhookID uuid.UUID, authorName, authorAvatarURL, content string, embeds []models.Embed) (*models.Message, error) {
+	message := models.Message{
+		ChannelID:       channelID,
+		WebhookID:       &webhookID,
+		AuthorName:      authorName,
+		AuthorAvatarURL: authorAvatarURL,
+		Embeds:          embeds,
+	}
+
+	if err := message.SetContent(content); err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.Create(&message).Error; err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+// GetMessages leaves out the messages of profiles the viewer blocked
+func (s *MessageService) GetMessages(channelID, viewerID uuid.UUID, cursor string) ([]models.Message, string, error) {
 	var messages []models.Message
 
-	query := s.DB.Preload("Member.Profile").Where("channel_id = ?", channelID).
+	blockedMembers := s.DB.Model(&models.Member{}).Select("id").Where("profile_id IN (?)", blockedBy(s.DB, viewerID))
+	query := s.DB.Preload("Member.Profile").Preload("Reactions.Custom").Where("channel_id = ?", channelID).
+		Where("member_id IS NULL OR member_id NOT IN (?)", blockedMembers).
 		Order("created_at DESC").Limit(MESSAGES_BATCH)
 
 	if cursor != "" {
@@ -75,8 +207,37 @@ func (s *MessageService) GetMessage(channelID, messageID uuid.UUID) (*models.Mes
 }
 
 func (s *MessageService) UpdateMessage(channelID, messageID uuid.UUID, content string) (*models.Message, error) {
-	if err := s.DB.Model(&models.Message{}).Where("id = ? AND channel_id = ?", messageID, channelID).
-		Update("content", content).Error; err != nil {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var message models.Message
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND channel_id = ?", messageID, channelID).
+			First(&message).Error; err != nil {
+			return err
+		}
+
+		if message.Content == content {
+			return nil
+		}
+
+		// Keep the previous content so moderators can see what was changed
+		revision := models.MessageRevision{
+			MessageID: message.ID,
+			Content:   message.Content,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		edited := models.Message{EditedAt: &now}
+		if err := edited.SetContent(content); err != nil {
+			return err
+		}
+
+		return tx.Model(&message).Select("content", "ast", "mentions", "edited_at").Updates(&edited).Error
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
@@ -88,11 +249,54 @@ func (s *MessageService) UpdateMessage(channelID, messageID uuid.UUID, content s
 	return &message, nil
 }
 
+func (s *MessageService) GetMessageRevisions(serverID, channelID, messageID uuid.UUID) ([]models.MessageRevision, error) {
+	var revisions []models.MessageRevision
+	err := s.DB.Joins("JOIN messages ON messages.id = message_revisions.message_id").
+		Joins("JOIN channels ON channels.id = messages.channel_id").
+		Where("message_revisions.message_id = ? AND messages.channel_id = ? AND channels.server_id = ?",
+			messageID, channelID, serverID).
+		Order("message_revisions.created_at ASC").
+		Find(&revisions).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
 func (s *MessageService) DeleteMessage(channelID, messageID uuid.UUID) (*models.Message, error) {
-	if err := s.DB.Model(&models.Message{}).
-		Where("id = ? AND channel_id = ?", messageID, channelID).
-		Updates(models.Message{FileURL: nil, Content: "This message has been deleted.", Deleted: true}).
-		Error; err != nil {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var message models.Message
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND channel_id = ?", messageID, channelID).
+			First(&message).Error; err != nil {
+			return err
+		}
+
+		if message.Deleted {
+			return nil
+		}
+
+		// The last content goes to the revisions like an edit, moderators can
+		// still see what was removed
+		revision := models.MessageRevision{
+			MessageID: message.ID,
+			Content:   message.Content,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+
+		deleted := models.Message{FileURL: nil, Deleted: true}
+		if err := deleted.SetContent("This message has been deleted."); err != nil {
+			return err
+		}
+
+		return tx.Model(&message).Updates(deleted).Error
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
@@ -103,3 +307,221 @@ func (s *MessageService) DeleteMessage(channelID, messageID uuid.UUID) (*models.
 
 	return &message, nil
 }
+
+func (s *MessageService) PinMessage(channelID, messageID uuid.UUID) (*models.Message, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the channel so concurrent pins cannot go over the limit
+		var channel models.Channel
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&channel, "id = ?", channelID).Error; err != nil {
+			return err
+		}
+
+		var message models.Message
+		if err := tx.Where("id = ? AND channel_id = ? AND deleted = false", messageID, channelID).
+			First(&message).Error; err != nil {
+			return err
+		}
+
+		if message.Pinned {
+			return nil
+		}
+
+		var count int64
+		if err := tx.Model(&models.Message{}).
+			Where("channel_id = ? AND pinned = true", channelID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count >= MAX_PINNED_MESSAGES {
+			return utils.ErrPinLimitReached
+		}
+
+		return tx.Model(&message).Updates(map[string]interface{}{
+			"pinned":    true,
+			"pinned_at": time.Now(),
+		}).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var message models.Message
+	if err := s.DB.Preload("Member.Profile").First(&message, messageID).Error; err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+func (s *MessageService) UnpinMessage(channelID, messageID uuid.UUID) (*models.Message, error) {
+	result := s.DB.Model(&models.Message{}).
+		Where("id = ? AND channel_id = ?", messageID, channelID).
+		Updates(map[string]interface{}{
+			"pinned":    false,
+			"pinned_at": nil,
+		})
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var message models.Message
+	if err := s.DB.Preload("Member.Profile").First(&message, messageID).Error; err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+func (s *MessageService) GetPinnedMessages(serverID, channelID uuid.UUID) ([]models.Message, error) {
+	var messages []models.Message
+	if err := s.DB.Preload("Member.Profile").
+		Joins("JOIN channels ON channels.id = messages.channel_id AND channels.server_id = ?", serverID).
+		Where("messages.channel_id = ? AND messages.pinned = true", channelID).
+		Order("messages.pinned_at DESC").
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// validateUnicodeEmoji accepts a short run of non ASCII symbols, keycaps like
+// 1️⃣ are the only place ASCII may appear
+func validateUnicodeEmoji(emoji string) error {
+	if emoji == "" || len(emoji) > 32 || !utf8.ValidString(emoji) {
+		return fmt.Errorf("%w: emoji is required", utils.ErrInvalidEmoji)
+	}
+
+	symbol := false
+	for _, r := range emoji {
+		switch {
+		case unicode.IsSpace(r) || (unicode.IsLetter(r) && r < utf8.RuneSelf):
+			return fmt.Errorf("%w: %q is not an emoji", utils.ErrInvalidEmoji, emoji)
+		case r > 0x2000:
+			symbol = true
+		}
+	}
+
+	if !symbol {
+		return fmt.Errorf("%w: %q is not an emoji", utils.ErrInvalidEmoji, emoji)
+	}
+
+	return nil
+}
+
+// customEmojiID returns emojiID, or the id of a custom emoji written the way it
+// is in content, <:name:id>
+func customEmojiID(emoji string, emojiID *uuid.UUID) *uuid.UUID {
+	if emojiID != nil {
+		return emojiID
+	}
+
+	if root, err := markdown.Parse(emoji); err == nil && len(root.Children) == 1 && root.Children[0].Type == markdown.CustomEmoji {
+		id := uuid.MustParse(root.Children[0].ID)
+		return &id
+	}
+
+	return nil
+}
+
+// AddReaction reacts with a unicode emoji, or with a custom emoji of the
+// server when emojiID is set. Reacting twice with the same emoji is a no-op
+func (s *MessageService) AddReaction(serverID, channelID, messageID, memberID uuid.UUID, emoji string, emojiID *uuid.UUID) (*models.Message, error) {
+	emojiID = customEmojiID(emoji, emojiID)
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var message models.Message
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND channel_id = ? AND deleted = false", messageID, channelID).
+			First(&message).Error; err != nil {
+			return err
+		}
+
+		reaction := models.Reaction{MessageID: messageID, MemberID: memberID, EmojiID: emojiID}
+		if emojiID != nil {
+			var count int64
+			if err := tx.Model(&models.Emoji{}).Where("id = ? AND server_id = ?", *emojiID, serverID).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return fmt.Errorf("%w: unknown emoji", utils.ErrInvalidEmoji)
+			}
+		} else {
+			if err := validateUnicodeEmoji(emoji); err != nil {
+				return err
+			}
+			reaction.Emoji = emoji
+		}
+
+		// Same as Reaction.Key
+		var keys []string
+		if err := tx.Model(&models.Reaction{}).Where("message_id = ?", messageID).
+			Distinct("COALESCE(emoji_id::text, emoji)").Pluck("COALESCE(emoji_id::text, emoji)", &keys).Error; err != nil {
+			return err
+		}
+
+		known := false
+		for _, existing := range keys {
+			known = known || existing == reaction.Key()
+		}
+
+		if !known && len(keys) >= MAX_REACTIONS_PER_MESSAGE {
+			return fmt.Errorf("%w: a message can have at most %d different reactions", utils.ErrInvalidEmoji, MAX_REACTIONS_PER_MESSAGE)
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getMessageWithReactions(messageID)
+}
+
+// RemoveReaction takes the unicode emoji, or the custom emoji as emojiID or
+// written like in content
+func (s *MessageService) RemoveReaction(channelID, messageID, memberID uuid.UUID, emoji string, emojiID *uuid.UUID) (*models.Message, error) {
+	var message models.Message
+	if err := s.DB.Where("id = ? AND channel_id = ?", messageID, channelID).First(&message).Error; err != nil {
+		return nil, err
+	}
+
+	query := s.DB.Where("message_id = ? AND member_id = ?", messageID, memberID)
+	if emojiID = customEmojiID(emoji, emojiID); emojiID != nil {
+		query = query.Where("emoji_id = ?", *emojiID)
+	} else {
+		query = query.Where("emoji = ? AND emoji_id IS NULL", emoji)
+	}
+
+	result := query.Delete(&models.Reaction{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return s.getMessageWithReactions(messageID)
+}
+
+func (s *MessageService) getMessageWithReactions(messageID uuid.UUID) (*models.Message, error) {
+	var message models.Message
+	if err := s.DB.Preload("Member.Profile").Preload("Reactions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("reactions.created_at ASC")
+	}).Preload("Reactions.Custom").First(&message, "id = ?", messageID).Error; err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
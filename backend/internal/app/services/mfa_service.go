@@ -0,0 +1,265 @@
+package services
+
+import (
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/totp"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MFA_ISSUER          = "Discord"
+	RECOVERY_CODE_COUNT = 10
+)
+
+type MFAService struct {
+	DB *gorm.DB
+}
+
+func NewMFAService(db *gorm.DB) *MFAService {
+	return &MFAService{DB: db}
+}
+
+type MFAStatus struct {
+	Enabled           bool  `json:"enabled"`
+	RecoveryCodesLeft int64 `json:"recoveryCodesLeft"`
+}
+
+// normalizeRecoveryCode lets users type codes with or without the dash
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// replaceRecoveryCodes drops the old codes and returns new ones as "xxxxx-xxxxx"
+func replaceRecoveryCodes(tx *gorm.DB, profileID uuid.UUID) ([]string, error) {
+	if err := tx.Where("profile_id = ?", profileID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, RECOVERY_CODE_COUNT)
+	rows := make([]models.RecoveryCode, RECOVERY_CODE_COUNT)
+	for i := range codes {
+		raw, err := utils.GenerateToken(5)
+		if err != nil {
+			return nil, err
+		}
+
+		codes[i] = raw[:5] + "-" + raw[5:]
+		rows[i] = models.RecoveryCode{ProfileID: profileID, CodeHash: utils.HashToken(raw)}
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// ensureModeratorMFA refuses moderation by a profile without two-factor
+// authentication in a server that requires it. Bots need their owner to have it
+func ensureModeratorMFA(tx *gorm.DB, serverID, profileID uuid.UUID) error {
+	var server models.Server
+	if err := tx.Select("id", "require_mfa").First(&server, "id = ?", serverID).Error; err != nil {
+		return err
+	}
+
+	if !server.RequireMFA {
+		return nil
+	}
+
+	var profile models.Profile
+	if err := tx.Select("id", "mfa_enabled", "is_bot", "bot_owner_id").First(&profile, "id = ?", profileID).Error; err != nil {
+		return err
+	}
+
+	mfaEnabled := profile.MFAEnabled
+	if profile.IsBot && profile.BotOwnerID != nil {
+		var owner models.Profile
+		if err := tx.Select("id", "mfa_enabled").First(&owner, "id = ?", *profile.BotOwnerID).Error; err != nil {
+			return err
+		}
+		mfaEnabled = owner.MFAEnabled
+	}
+
+	if !mfaEnabled {
+		return fmt.Errorf("%w: this server requires two-factor authentication to moderate", utils.ErrMFARequired)
+	}
+
+	return nil
+}
+
+func (s *MFAService) GetStatus(profileID uuid.UUID) (*MFAStatus, error) {
+	var profile models.Profile
+	if err := s.DB.Select("id", "mfa_enabled").First(&profile, "id = ?", profileID).Error; err != nil {
+		return nil, err
+	}
+
+	status := &MFAStatus{Enabled: profile.MFAEnabled}
+	if err := s.DB.Model(&models.RecoveryCode{}).Where("profile_id = ? AND used_at IS NULL", profileID).
+		Count(&status.RecoveryCodesLeft).Error; err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// EnrollTOTP starts over with a new pending secret and returns it with the
+// otpauth URI to show as a QR code
+func (s *MFAService) EnrollTOTP(profileID uuid.UUID) (string, string, error) {
+	var profile models.Profile
+	if err := s.DB.First(&profile, "id = ? AND is_bot = ?", profileID, false).Error; err != nil {
+		return "", "", err
+	}
+
+	if profile.MFAEnabled {
+		return "", "", fmt.Errorf("%w: two-factor authentication is already enabled", utils.ErrInvalidMFA)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "profile_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"secret": secret, "last_counter": 0, "enabled_at": nil, "updated_at": time.Now()}),
+	}).Create(&models.MFASecret{ProfileID: profileID, Secret: secret}).Error; err != nil {
+		return "", "", err
+	}
+
+	return secret, totp.URI(MFA_ISSUER, profile.Email, secret), nil
+}
+
+// ActivateTOTP turns two-factor authentication on once the app produced a
+// valid code, the recovery codes are only returned this once
+func (s *MFAService) ActivateTOTP(profileID uuid.UUID, code string) ([]string, error) {
+	var codes []string
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var secret models.MFASecret
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&secret, "profile_id = ?", profileID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: start the enrollment first", utils.ErrInvalidMFA)
+		}
+		if err != nil {
+			return err
+		}
+
+		if secret.EnabledAt != nil {
+			return fmt.Errorf("%w: two-factor authentication is already enabled", utils.ErrInvalidMFA)
+		}
+
+		counter, ok := totp.Validate(secret.Secret, code, time.Now())
+		if !ok {
+			return fmt.Errorf("%w: invalid code", utils.ErrInvalidMFA)
+		}
+
+		if err := tx.Model(&secret).Updates(map[string]interface{}{"last_counter": counter, "enabled_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Profile{}).Where("id = ?", profileID).Update("mfa_enabled", true).Error; err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, profileID)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// verify accepts a TOTP code newer than the last one used, or an unused
+// recovery code which is then spent
+func (s *MFAService) verify(tx *gorm.DB, profileID uuid.UUID, code, recoveryCode string) error {
+	var secret models.MFASecret
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&secret, "profile_id = ? AND enabled_at IS NOT NULL", profileID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: two-factor authentication is not enabled", utils.ErrInvalidMFA)
+	}
+	if err != nil {
+		return err
+	}
+
+	if code != "" {
+		counter, ok := totp.Validate(secret.Secret, code, time.Now())
+		if !ok || counter <= secret.LastCounter {
+			return fmt.Errorf("%w: invalid code", utils.ErrInvalidMFA)
+		}
+
+		return tx.Model(&secret).Update("last_counter", counter).Error
+	}
+
+	if recoveryCode != "" {
+		result := tx.Model(&models.RecoveryCode{}).
+			Where("profile_id = ? AND code_hash = ? AND used_at IS NULL", profileID, utils.HashToken(normalizeRecoveryCode(recoveryCode))).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 1 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: invalid code", utils.ErrInvalidMFA)
+}
+
+// Verify is the second step of signing in
+func (s *MFAService) Verify(profileID uuid.UUID, code, recoveryCode string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		return s.verify(tx, profileID, code, recoveryCode)
+	})
+}
+
+// Disable needs a valid code so a stolen session cannot turn it off
+func (s *MFAService) Disable(profileID uuid.UUID, code, recoveryCode string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.verify(tx, profileID, code, recoveryCode); err != nil {
+			return err
+		}
+
+		if err := tx.Where("profile_id = ?", profileID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("profile_id = ?", profileID).Delete(&models.MFASecret{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Profile{}).Where("id = ?", profileID).Update("mfa_enabled", false).Error
+	})
+}
+
+func (s *MFAService) RegenerateRecoveryCodes(profileID uuid.UUID, code string) ([]string, error) {
+	var codes []string
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.verify(tx, profileID, code, ""); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, profileID)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
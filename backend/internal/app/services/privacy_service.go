@@ -0,0 +1,187 @@
+package services
+
+import (
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PrivacyService struct {
+	DB *gorm.DB
+}
+
+func NewPrivacyService(db *gorm.DB) *PrivacyService {
+	return &PrivacyService{DB: db}
+}
+
+func privacySettings(tx *gorm.DB, profileID uuid.UUID) (models.PrivacySettings, error) {
+	settings := models.DefaultPrivacySettings(profileID)
+	err := tx.First(&settings, "profile_id = ?", profileID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return settings, err
+	}
+
+	return settings, nil
+}
+
+func areFriends(tx *gorm.DB, profileID, otherID uuid.UUID) (bool, error) {
+	var count int64
+	err := tx.Model(&models.Relationship{}).
+		Where("profile_id = ? AND target_id = ? AND type = ?", profileID, otherID, models.RelationshipFriend).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+// canStartConversation checks the recipient accepts DMs from the sender.
+// Friends always can, anybody else needs a shared server the recipient allows
+// DMs from. request is true when the conversation goes to the requests inbox
+func canStartConversation(tx *gorm.DB, senderID, recipientID uuid.UUID) (request bool, err error) {
+	friends, err := areFriends(tx, recipientID, senderID)
+	if err != nil || friends {
+		return false, err
+	}
+
+	settings, err := privacySettings(tx, recipientID)
+	if err != nil {
+		return false, err
+	}
+
+	var count int64
+	if err := tx.Table("members AS recipient").
+		Joins("JOIN members AS sender ON sender.server_id = recipient.server_id").
+		Joins("JOIN servers ON servers.id = recipient.server_id AND servers.deleted_at IS NULL").
+		Joins("LEFT JOIN server_privacy_overrides ON server_privacy_overrides.profile_id = recipient.profile_id AND server_privacy_overrides.server_id = recipient.server_id").
+		Where("recipient.profile_id = ? AND sender.profile_id = ?", recipientID, senderID).
+		Where("COALESCE(server_privacy_overrides.allow_dms, ?)", settings.AllowServerDMs).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	if count == 0 {
+		return false, fmt.Errorf("%w: this user does not accept direct messages from you", utils.ErrForbidden)
+	}
+
+	return settings.MessageRequests, nil
+}
+
+// canSendFriendRequest applies the friend request policy of the target
+func canSendFriendRequest(tx *gorm.DB, senderID, targetID uuid.UUID) error {
+	settings, err := privacySettings(tx, targetID)
+	if err != nil {
+		return err
+	}
+
+	switch settings.FriendRequests {
+	case models.FriendRequestsNobody:
+		return fmt.Errorf("%w: this user does not accept friend requests", utils.ErrInvalidRelationship)
+	case models.FriendRequestsFriendsOfFriends:
+		var count int64
+		if err := tx.Table("relationships AS mine").
+			Joins("JOIN relationships AS theirs ON theirs.target_id = mine.target_id").
+			Where("mine.profile_id = ? AND mine.type = ? AND theirs.profile_id = ? AND theirs.type = ?",
+				senderID, models.RelationshipFriend, targetID, models.RelationshipFriend).
+			Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return fmt.Errorf("%w: this user only accepts friend requests from friends of friends", utils.ErrInvalidRelationship)
+		}
+	}
+
+	return nil
+}
+
+func (s *PrivacyService) GetPrivacySettings(profileID uuid.UUID) (*models.PrivacySettings, error) {
+	settings, err := privacySettings(s.DB, profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.Where("profile_id = ?", profileID).Find(&settings.ServerOverrides).Error; err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// UpdatePrivacySettings only changes the fields that are set
+func (s *PrivacyService) UpdatePrivacySettings(profileID uuid.UUID, allowServerDMs *bool, friendRequests *models.FriendRequestPolicy, messageRequests *bool) (*models.PrivacySettings, error) {
+	if friendRequests != nil {
+		switch *friendRequests {
+		case models.FriendRequestsEveryone, models.FriendRequestsFriendsOfFriends, models.FriendRequestsNobody:
+		default:
+			return nil, fmt.Errorf("%w: unknown friend request policy %q", utils.ErrInvalidPrivacySettings, *friendRequests)
+		}
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		settings, err := privacySettings(tx, profileID)
+		if err != nil {
+			return err
+		}
+
+		if allowServerDMs != nil {
+			settings.AllowServerDMs = *allowServerDMs
+		}
+
+		if friendRequests != nil {
+			settings.FriendRequests = *friendRequests
+		}
+
+		if messageRequests != nil {
+			settings.MessageRequests = *messageRequests
+		}
+
+		settings.ServerOverrides = nil
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "profile_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"allow_server_dms", "friend_requests", "message_requests", "updated_at"}),
+		}).Create(&settings).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetPrivacySettings(profileID)
+}
+
+// SetServerOverride decides for one server whether its members can DM the profile
+func (s *PrivacyService) SetServerOverride(profileID, serverID uuid.UUID, allowDMs bool) (*models.PrivacySettings, error) {
+	var count int64
+	if err := s.DB.Model(&models.Member{}).Where("server_id = ? AND profile_id = ?", serverID, profileID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	override := models.ServerPrivacyOverride{ProfileID: profileID, ServerID: serverID, AllowDMs: allowDMs}
+	if err := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "profile_id"}, {Name: "server_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"allow_dms", "updated_at"}),
+	}).Create(&override).Error; err != nil {
+		return nil, err
+	}
+
+	return s.GetPrivacySettings(profileID)
+}
+
+// DeleteServerOverride goes back to the global setting for the server
+func (s *PrivacyService) DeleteServerOverride(profileID, serverID uuid.UUID) (*models.PrivacySettings, error) {
+	if err := s.DB.Where("profile_id = ? AND server_id = ?", profileID, serverID).
+		Delete(&models.ServerPrivacyOverride{}).Error; err != nil {
+		return nil, err
+	}
+
+	return s.GetPrivacySettings(profileID)
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
 	"errors"
 
 	"github.com/google/uuid"
@@ -23,15 +24,18 @@ func (p *ProfileService) GetProfileByID(profileID uuid.UUID) (*models.ProfileRes
 	}
 
 	profileResponse := models.ProfileResponse{
-		ID:        profile.ID,
-		Name:      profile.Name,
-		ImageURL:  profile.ImageURL,
-		Email:     profile.Email,
-		Servers:   profile.Servers,
-		Members:   profile.Members,
-		Channels:  profile.Channels,
-		CreatedAt: profile.CreatedAt,
-		UpdatedAt: profile.UpdatedAt,
+		ID:              profile.ID,
+		Name:            profile.Name,
+		ImageURL:        profile.ImageURL,
+		Email:           profile.Email,
+		EmailVerifiedAt: profile.EmailVerifiedAt,
+		MFAEnabled:      profile.MFAEnabled,
+		Servers:         profile.Servers,
+		Members:         profile.Members,
+		Channels:        profile.Channels,
+		IsBot:           profile.IsBot,
+		CreatedAt:       profile.CreatedAt,
+		UpdatedAt:       profile.UpdatedAt,
 	}
 
 	return &profileResponse, nil
@@ -43,6 +47,16 @@ func (p *ProfileService) UpdateProfile(userID uuid.UUID, updatedData models.Prof
 		return nil, result.Error
 	}
 
+	// Verification is only done through VerifyEmail, a new email has to be verified again
+	updatedData.EmailVerifiedAt = nil
+	// Two-factor authentication is only turned on and off through MFAService
+	updatedData.MFAEnabled = false
+	if updatedData.Email != "" && updatedData.Email != profile.Email {
+		if err := p.DB.Model(&profile).Update("email_verified_at", nil).Error; err != nil {
+			return nil, err
+		}
+	}
+
 	if result := p.DB.Model(&profile).Updates(updatedData); result.Error != nil {
 		return nil, result.Error
 	}
@@ -72,21 +86,22 @@ func (p *ProfileService) CreateProfile(profile *models.Profile) (*models.Profile
 	}
 
 	return &models.ProfileResponse{
-		ID:        profileResponse.ID,
-		Name:      profileResponse.Name,
-		ImageURL:  profileResponse.ImageURL,
-		Email:     profileResponse.Email,
-		Servers:   profileResponse.Servers,
-		Members:   profileResponse.Members,
-		Channels:  profileResponse.Channels,
-		CreatedAt: profileResponse.CreatedAt,
-		UpdatedAt: profileResponse.UpdatedAt,
+		ID:              profileResponse.ID,
+		Name:            profileResponse.Name,
+		ImageURL:        profileResponse.ImageURL,
+		Email:           profileResponse.Email,
+		EmailVerifiedAt: profileResponse.EmailVerifiedAt,
+		Servers:         profileResponse.Servers,
+		Members:         profileResponse.Members,
+		Channels:        profileResponse.Channels,
+		CreatedAt:       profileResponse.CreatedAt,
+		UpdatedAt:       profileResponse.UpdatedAt,
 	}, nil
 }
 
 func (p *ProfileService) Authenticate(email, password string) (*models.ProfileResponse, error) {
 	var profile models.Profile
-	if err := p.DB.Preload("Servers").Where("email = ?", email).First(&profile).Error; err != nil {
+	if err := p.DB.Preload("Servers").Where("email = ? AND is_bot = ?", email, false).First(&profile).Error; err != nil {
 		return nil, err
 	}
 
@@ -94,16 +109,23 @@ func (p *ProfileService) Authenticate(email, password string) (*models.ProfileRe
 		return nil, errors.New("Invalid Credentials")
 	}
 
+	if profile.DisabledAt != nil {
+		return nil, utils.ErrAccountDisabled
+	}
+
 	profileResponse := models.ProfileResponse{
-		ID:        profile.ID,
-		Name:      profile.Name,
-		ImageURL:  profile.ImageURL,
-		Email:     profile.Email,
-		Servers:   profile.Servers,
-		Members:   profile.Members,
-		Channels:  profile.Channels,
-		CreatedAt: profile.CreatedAt,
-		UpdatedAt: profile.UpdatedAt,
+		ID:              profile.ID,
+		Name:            profile.Name,
+		ImageURL:        profile.ImageURL,
+		Email:           profile.Email,
+		EmailVerifiedAt: profile.EmailVerifiedAt,
+		MFAEnabled:      profile.MFAEnabled,
+		Servers:         profile.Servers,
+		Members:         profile.Members,
+		Channels:        profile.Channels,
+		IsBot:           profile.IsBot,
+		CreatedAt:       profile.CreatedAt,
+		UpdatedAt:       profile.UpdatedAt,
 	}
 
 	return &profileResponse, nil
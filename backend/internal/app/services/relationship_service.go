@@ -0,0 +1,303 @@
+package services
+
+import (
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const MAX_RELATIONSHIPS = 1000
+
+type RelationshipService struct {
+	DB *gorm.DB
+}
+
+func NewRelationshipService(db *gorm.DB) *RelationshipService {
+	return &RelationshipService{DB: db}
+}
+
+// RelationshipUpdate is a relationship seen from both sides after a change,
+// a nil side has no row anymore. NotifyTarget is false when the target could
+// not see any difference, a profile is never told it was blocked
+type RelationshipUpdate struct {
+	ProfileID    uuid.UUID
+	TargetID     uuid.UUID
+	Own          *models.Relationship
+	Their        *models.Relationship
+	NotifyTarget bool
+}
+
+func visible(relationship *models.Relationship) bool {
+	return relationship != nil && relationship.Type != models.RelationshipBlocked
+}
+
+// blocked reports whether profileID and any of otherIDs blocked each other
+func blocked(tx *gorm.DB, profileID uuid.UUID, otherIDs ...uuid.UUID) (bool, error) {
+	if len(otherIDs) == 0 {
+		return false, nil
+	}
+
+	var count int64
+	err := tx.Model(&models.Relationship{}).
+		Where("type = ? AND ((profile_id = ? AND target_id IN ?) OR (target_id = ? AND profile_id IN ?))",
+			models.RelationshipBlocked, profileID, otherIDs, profileID, otherIDs).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+// blockedBy selects the profiles profileID blocked, to leave their messages out
+func blockedBy(tx *gorm.DB, profileID uuid.UUID) *gorm.DB {
+	return tx.Model(&models.Relationship{}).Select("target_id").
+		Where("profile_id = ? AND type = ?", profileID, models.RelationshipBlocked)
+}
+
+func (s *RelationshipService) GetRelationships(profileID uuid.UUID, types ...models.RelationshipType) ([]models.Relationship, error) {
+	var relationships []models.Relationship
+	if err := s.DB.Preload("Target").Where("profile_id = ? AND type IN ?", profileID, types).
+		Order("updated_at DESC").Limit(MAX_RELATIONSHIPS).
+		Find(&relationships).Error; err != nil {
+		return nil, err
+	}
+
+	return relationships, nil
+}
+
+// GetBlockedIDs lists the profiles profileID blocked
+func (s *RelationshipService) GetBlockedIDs(profileID uuid.UUID) ([]uuid.UUID, error) {
+	var blockedIDs []uuid.UUID
+	if err := blockedBy(s.DB, profileID).Scan(&blockedIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return blockedIDs, nil
+}
+
+// GetFriendIDs is used to tell friends when a profile comes online or leaves
+func (s *RelationshipService) GetFriendIDs(profileID uuid.UUID) ([]uuid.UUID, error) {
+	var friendIDs []uuid.UUID
+	if err := s.DB.Model(&models.Relationship{}).
+		Where("profile_id = ? AND type = ?", profileID, models.RelationshipFriend).
+		Pluck("target_id", &friendIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return friendIDs, nil
+}
+
+// lockPair loads both sides of a relationship for update, missing sides are nil
+func (s *RelationshipService) lockPair(tx *gorm.DB, profileID, targetID uuid.UUID) (own, their *models.Relationship, err error) {
+	var rows []models.Relationship
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("(profile_id = ? AND target_id = ?) OR (profile_id = ? AND target_id = ?)", profileID, targetID, targetID, profileID).
+		Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+
+	for i := range rows {
+		if rows[i].ProfileID == profileID {
+			own = &rows[i]
+		} else {
+			their = &rows[i]
+		}
+	}
+
+	return own, their, nil
+}
+
+func (s *RelationshipService) set(tx *gorm.DB, profileID, targetID uuid.UUID, relationshipType models.RelationshipType) (*models.Relationship, error) {
+	relationship := models.Relationship{ProfileID: profileID, TargetID: targetID, Type: relationshipType}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "profile_id"}, {Name: "target_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"type", "updated_at"}),
+	}).Create(&relationship).Error; err != nil {
+		return nil, err
+	}
+
+	return &relationship, nil
+}
+
+func (s *RelationshipService) clear(tx *gorm.DB, profileID, targetID uuid.UUID) error {
+	return tx.Where("profile_id = ? AND target_id = ?", profileID, targetID).Delete(&models.Relationship{}).Error
+}
+
+// change runs fn on the locked pair and reloads both sides with their target
+func (s *RelationshipService) change(profileID, targetID uuid.UUID, fn func(tx *gorm.DB, own, their *models.Relationship) error) (*RelationshipUpdate, error) {
+	if profileID == targetID {
+		return nil, fmt.Errorf("%w: that is you", utils.ErrInvalidRelationship)
+	}
+
+	update := &RelationshipUpdate{ProfileID: profileID, TargetID: targetID}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		own, their, err := s.lockPair(tx, profileID, targetID)
+		if err != nil {
+			return err
+		}
+
+		update.NotifyTarget = visible(their)
+		return fn(tx, own, their)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, side := range []struct {
+		from, to uuid.UUID
+		into     **models.Relationship
+	}{{profileID, targetID, &update.Own}, {targetID, profileID, &update.Their}} {
+		var relationship models.Relationship
+		err := s.DB.Preload("Target").Where("profile_id = ? AND target_id = ?", side.from, side.to).First(&relationship).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		*side.into = &relationship
+	}
+
+	update.NotifyTarget = update.NotifyTarget || visible(update.Their)
+
+	return update, nil
+}
+
+// SendFriendRequest accepts the request of the target instead when there is
+// one, otherwise the friend request policy of the target has to allow it
+func (s *RelationshipService) SendFriendRequest(profileID, targetID uuid.UUID) (*RelationshipUpdate, error) {
+	return s.change(profileID, targetID, func(tx *gorm.DB, own, their *models.Relationship) error {
+		var target models.Profile
+		if err := tx.First(&target, "id = ?", targetID).Error; err != nil {
+			return err
+		}
+
+		if target.IsBot {
+			return fmt.Errorf("%w: bots cannot be added as friends", utils.ErrInvalidRelationship)
+		}
+
+		if own != nil {
+			switch own.Type {
+			case models.RelationshipBlocked:
+				return fmt.Errorf("%w: unblock this user first", utils.ErrInvalidRelationship)
+			case models.RelationshipFriend:
+				return fmt.Errorf("%w: you are already friends", utils.ErrInvalidRelationship)
+			case models.RelationshipOutgoing:
+				return nil
+			case models.RelationshipIncoming:
+				return s.makeFriends(tx, profileID, targetID)
+			}
+		}
+
+		if their != nil && their.Type == models.RelationshipBlocked {
+			return fmt.Errorf("%w: this user does not accept friend requests", utils.ErrInvalidRelationship)
+		}
+
+		if err := canSendFriendRequest(tx, profileID, targetID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Relationship{}).
+			Where("profile_id = ? AND type IN ?", profileID, []models.RelationshipType{models.RelationshipFriend, models.RelationshipOutgoing}).
+			Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count >= MAX_RELATIONSHIPS {
+			return fmt.Errorf("%w: you can have at most %d friends and requests", utils.ErrInvalidRelationship, MAX_RELATIONSHIPS)
+		}
+
+		if _, err := s.set(tx, profileID, targetID, models.RelationshipOutgoing); err != nil {
+			return err
+		}
+
+		_, err := s.set(tx, targetID, profileID, models.RelationshipIncoming)
+		return err
+	})
+}
+
+func (s *RelationshipService) makeFriends(tx *gorm.DB, profileID, targetID uuid.UUID) error {
+	if _, err := s.set(tx, profileID, targetID, models.RelationshipFriend); err != nil {
+		return err
+	}
+
+	_, err := s.set(tx, targetID, profileID, models.RelationshipFriend)
+	return err
+}
+
+func (s *RelationshipService) AcceptFriendRequest(profileID, targetID uuid.UUID) (*RelationshipUpdate, error) {
+	return s.change(profileID, targetID, func(tx *gorm.DB, own, their *models.Relationship) error {
+		if own == nil || own.Type != models.RelationshipIncoming {
+			return gorm.ErrRecordNotFound
+		}
+
+		return s.makeFriends(tx, profileID, targetID)
+	})
+}
+
+// removePair drops both sides when the own side has the expected type, a
+// block on the other side is left alone
+func (s *RelationshipService) removePair(profileID, targetID uuid.UUID, expected models.RelationshipType) (*RelationshipUpdate, error) {
+	return s.change(profileID, targetID, func(tx *gorm.DB, own, their *models.Relationship) error {
+		if own == nil || own.Type != expected {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := s.clear(tx, profileID, targetID); err != nil {
+			return err
+		}
+
+		if their == nil || their.Type == models.RelationshipBlocked {
+			return nil
+		}
+
+		return s.clear(tx, targetID, profileID)
+	})
+}
+
+func (s *RelationshipService) DeclineFriendRequest(profileID, targetID uuid.UUID) (*RelationshipUpdate, error) {
+	return s.removePair(profileID, targetID, models.RelationshipIncoming)
+}
+
+func (s *RelationshipService) CancelFriendRequest(profileID, targetID uuid.UUID) (*RelationshipUpdate, error) {
+	return s.removePair(profileID, targetID, models.RelationshipOutgoing)
+}
+
+func (s *RelationshipService) RemoveFriend(profileID, targetID uuid.UUID) (*RelationshipUpdate, error) {
+	return s.removePair(profileID, targetID, models.RelationshipFriend)
+}
+
+// Block ends any friendship or pending request between the two profiles
+func (s *RelationshipService) Block(profileID, targetID uuid.UUID) (*RelationshipUpdate, error) {
+	return s.change(profileID, targetID, func(tx *gorm.DB, own, their *models.Relationship) error {
+		if err := tx.First(&models.Profile{}, "id = ?", targetID).Error; err != nil {
+			return err
+		}
+
+		if _, err := s.set(tx, profileID, targetID, models.RelationshipBlocked); err != nil {
+			return err
+		}
+
+		if their == nil || their.Type == models.RelationshipBlocked {
+			return nil
+		}
+
+		return s.clear(tx, targetID, profileID)
+	})
+}
+
+func (s *RelationshipService) Unblock(profileID, targetID uuid.UUID) (*RelationshipUpdate, error) {
+	return s.change(profileID, targetID, func(tx *gorm.DB, own, their *models.Relationship) error {
+		if own == nil || own.Type != models.RelationshipBlocked {
+			return gorm.ErrRecordNotFound
+		}
+
+		return s.clear(tx, profileID, targetID)
+	})
+}
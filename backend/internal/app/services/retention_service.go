@@ -0,0 +1,225 @@
+package services
+
+import (
+	"discord-backend/internal/app/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Soft deleted servers and channels can be restored until the retention period is over
+const SOFT_DELETE_RETENTION = time.Hour * 24 * 30
+
+const PURGE_BATCH = 100
+
+// Revoked sessions are kept a day so replaying one of their tokens still counts as reuse
+const REVOKED_SESSION_TTL = time.Hour * 24
+
+type RetentionService struct {
+	DB *gorm.DB
+}
+
+func NewRetentionService(db *gorm.DB) *RetentionService {
+	return &RetentionService{DB: db}
+}
+
+func (r *RetentionService) PurgeExpiredServers(batchSize int) (int, error) {
+	var serverIDs []uuid.UUID
+	if err := r.DB.Unscoped().Model(&models.Server{}).
+		Where("deleted_at IS NOT NULL AND deleted_at <= ?", time.Now().Add(-SOFT_DELETE_RETENTION)).
+		Order("deleted_at ASC").Limit(batchSize).
+		Pluck("id", &serverIDs).Error; err != nil {
+		return 0, err
+	}
+
+	if len(serverIDs) == 0 {
+		return 0, nil
+	}
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		channels := tx.Unscoped().Model(&models.Channel{}).Select("id").Where("server_id IN ?", serverIDs)
+		if err := tx.Where("server_id IN ?", serverIDs).Delete(&models.ScheduledMessage{}).Error; err != nil {
+			return err
+		}
+
+		messages := tx.Model(&models.Message{}).Select("id").Where("channel_id IN (?)", channels)
+		if err := tx.Where("message_id IN (?)", messages).Delete(&models.Reaction{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("channel_id IN (?)", channels).Delete(&models.Message{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("server_id IN ?", serverIDs).Delete(&models.Emoji{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("server_id IN ?", serverIDs).Delete(&models.ServerPrivacyOverride{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("server_id IN ?", serverIDs).Delete(&models.IncomingWebhook{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("server_id IN ?", serverIDs).Delete(&models.Interaction{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("server_id IN ?", serverIDs).Delete(&models.Command{}).Error; err != nil {
+			return err
+		}
+
+		webhooks := tx.Model(&models.Webhook{}).Select("id").Where("server_id IN ?", serverIDs)
+		if err := tx.Where("webhook_id IN (?)", webhooks).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("server_id IN ?", serverIDs).Delete(&models.Webhook{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("server_id IN ?", serverIDs).Delete(&models.Member{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("server_id IN ?", serverIDs).Delete(&models.Channel{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("id IN ?", serverIDs).Delete(&models.Server{}).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return len(serverIDs), nil
+}
+
+func (r *RetentionService) PurgeExpiredChannels(batchSize int) (int, error) {
+	var channelIDs []uuid.UUID
+	if err := r.DB.Unscoped().Model(&models.Channel{}).
+		Where("deleted_at IS NOT NULL AND deleted_at <= ?", time.Now().Add(-SOFT_DELETE_RETENTION)).
+		Order("deleted_at ASC").Limit(batchSize).
+		Pluck("id", &channelIDs).Error; err != nil {
+		return 0, err
+	}
+
+	if len(channelIDs) == 0 {
+		return 0, nil
+	}
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("channel_id IN ?", channelIDs).Delete(&models.ScheduledMessage{}).Error; err != nil {
+			return err
+		}
+
+		messages := tx.Model(&models.Message{}).Select("id").Where("channel_id IN ?", channelIDs)
+		if err := tx.Where("message_id IN (?)", messages).Delete(&models.Reaction{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("channel_id IN ?", channelIDs).Delete(&models.Message{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("channel_id IN ?", channelIDs).Delete(&models.IncomingWebhook{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("channel_id IN ?", channelIDs).Delete(&models.Interaction{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("id IN ?", channelIDs).Delete(&models.Channel{}).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return len(channelIDs), nil
+}
+
+// PurgeStaleLinkPreviews drops cached previews nobody asked for since they expired,
+// they are fetched again on the next message that links them
+func (r *RetentionService) PurgeStaleLinkPreviews(batchSize int) (int, error) {
+	stale := r.DB.Model(&models.LinkPreview{}).Select("id").
+		Where("fetched_at <= ?", time.Now().Add(-LINK_PREVIEW_TTL)).Limit(batchSize)
+
+	result := r.DB.Where("id IN (?)", stale).Delete(&models.LinkPreview{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+// PurgeExpiredAccountTokens drops verification and reset tokens that can no
+// longer be used
+func (r *RetentionService) PurgeExpiredAccountTokens(batchSize int) (int, error) {
+	expired := r.DB.Model(&models.AccountToken{}).Select("id").
+		Where("expires_at <= ?", time.Now()).Limit(batchSize)
+
+	result := r.DB.Where("id IN (?)", expired).Delete(&models.AccountToken{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+// PurgeExpiredSessions drops sessions that expired or were revoked a day ago
+// with their refresh tokens. Revoked ones are kept a little so a replayed
+// token is still recognised as reused
+func (r *RetentionService) PurgeExpiredSessions(batchSize int) (int, error) {
+	now := time.Now()
+	var purged int64
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var sessionIDs []uuid.UUID
+		if err := tx.Model(&models.Session{}).
+			Where("expires_at <= ? OR revoked_at <= ?", now, now.Add(-REVOKED_SESSION_TTL)).
+			Limit(batchSize).Pluck("id", &sessionIDs).Error; err != nil {
+			return err
+		}
+
+		if len(sessionIDs) == 0 {
+			return nil
+		}
+
+		if err := tx.Where("session_id IN ?", sessionIDs).Delete(&models.RefreshToken{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("id IN ?", sessionIDs).Delete(&models.Session{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+
+	return int(purged), err
+}
+
+// PurgeExpiredSigningKeys drops keys no unexpired token can be signed with
+func (r *RetentionService) PurgeExpiredSigningKeys(batchSize int) (int, error) {
+	expired := r.DB.Model(&models.SigningKey{}).Select("id").
+		Where("expires_at <= ?", time.Now()).Limit(batchSize)
+
+	result := r.DB.Where("id IN (?)", expired).Delete(&models.SigningKey{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return int(result.RowsAffected), nil
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ScheduledMessageService struct {
+	DB *gorm.DB
+}
+
+func NewScheduledMessageService(db *gorm.DB) *ScheduledMessageService {
+	return &ScheduledMessageService{DB: db}
+}
+
+// ScheduledDelivery is the result of delivering one due scheduled message,
+// exactly one of Message or DirectMessage is set when the delivery succeeded
+type ScheduledDelivery struct {
+	ScheduledMessage models.ScheduledMessage
+	Message          *models.Message
+	DirectMessage    *models.DirectMessage
+}
+
+func NextOccurrence(recurrence, timezone string, after time.Time) (time.Time, error) {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: unknown timezone %q", utils.ErrInvalidSchedule, timezone)
+	}
+
+	schedule, err := utils.ParseCron(recurrence)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s", utils.ErrInvalidSchedule, err.Error())
+	}
+
+	next := schedule.Next(after.In(location))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("%w: recurrence never matches", utils.ErrInvalidSchedule)
+	}
+
+	return next.UTC(), nil
+}
+
+// prepare validates the scheduled message and computes its next run. The
+// send time is only required to be in the future when it is being set, a
+// pending message that is already due can still have its content edited.
+func (s *ScheduledMessageService) prepare(scheduledMessage *models.ScheduledMessage, scheduleChanged bool) error {
+	if err := scheduledMessage.Validate(); err != nil {
+		return fmt.Errorf("%w: %s", utils.ErrInvalidSchedule, err.Error())
+	}
+
+	if scheduledMessage.Timezone == "" {
+		scheduledMessage.Timezone = "UTC"
+	}
+
+	if scheduledMessage.Recurrence != "" {
+		next, err := NextOccurrence(scheduledMessage.Recurrence, scheduledMessage.Timezone, time.Now())
+		if err != nil {
+			return err
+		}
+
+		if scheduledMessage.SendAt.IsZero() {
+			scheduledMessage.SendAt = next
+		}
+	}
+
+	if scheduleChanged && !scheduledMessage.SendAt.After(time.Now()) {
+		return fmt.Errorf("%w: sendAt must be in the future", utils.ErrInvalidSchedule)
+	}
+
+	return nil
+}
+
+func (s *ScheduledMessageService) CreateScheduledMessage(scheduledMessage *models.ScheduledMessage) (*models.ScheduledMessage, error) {
+	if err := s.prepare(scheduledMessage, true); err != nil {
+		return nil, err
+	}
+
+	scheduledMessage.Status = models.ScheduledPending
+	if err := s.DB.Create(scheduledMessage).Error; err != nil {
+		return nil, err
+	}
+
+	return scheduledMessage, nil
+}
+
+func (s *ScheduledMessageService) GetScheduledMessages(profileID uuid.UUID) ([]models.ScheduledMessage, error) {
+	var scheduledMessages []models.ScheduledMessage
+	if err := s.DB.Where("profile_id = ? AND status = ?", profileID, models.ScheduledPending).
+		Order("send_at ASC").
+		Find(&scheduledMessages).Error; err != nil {
+		return nil, err
+	}
+
+	return scheduledMessages, nil
+}
+
+func (s *ScheduledMessageService) UpdateScheduledMessage(profileID, scheduledMessageID uuid.UUID, content *string, sendAt *time.Time, recurrence, timezone *string) (*models.ScheduledMessage, error) {
+	var scheduledMessage models.ScheduledMessage
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND profile_id = ? AND status = ?", scheduledMessageID, profileID, models.ScheduledPending).
+			First(&scheduledMessage).Error; err != nil {
+			return err
+		}
+
+		if content != nil {
+			scheduledMessage.Content = *content
+		}
+
+		if recurrence != nil {
+			scheduledMessage.Recurrence = *recurrence
+		}
+
+		if timezone != nil {
+			scheduledMessage.Timezone = *timezone
+		}
+
+		if sendAt != nil {
+			scheduledMessage.SendAt = *sendAt
+		} else if recurrence != nil || timezone != nil {
+			// Recompute the next run from the new rule
+			scheduledMessage.SendAt = time.Time{}
+		}
+
+		scheduleChanged := sendAt != nil || recurrence != nil || timezone != nil
+		if err := s.prepare(&scheduledMessage, scheduleChanged); err != nil {
+			return err
+		}
+
+		return tx.Model(&scheduledMessage).Select("content", "send_at", "recurrence", "timezone").
+			Updates(&scheduledMessage).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &scheduledMessage, nil
+}
+
+func (s *ScheduledMessageService) CancelScheduledMessage(profileID, scheduledMessageID uuid.UUID) (*models.ScheduledMessage, error) {
+	var scheduledMessage models.ScheduledMessage
+
+	result := s.DB.Model(&scheduledMessage).Clauses(clause.Returning{}).
+		Where("id = ? AND profile_id = ? AND status = ?", scheduledMessageID, profileID, models.ScheduledPending).
+		Update("status", models.ScheduledCanceled)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &scheduledMessage, nil
+}
+
+// DeliverNextDue creates the message for the oldest due scheduled message and
+// advances its schedule in the same transaction, so a restart can never send it twice.
+// It returns nil when nothing is due.
+func (s *ScheduledMessageService) DeliverNextDue(now time.Time) (*ScheduledDelivery, error) {
+	var delivery *ScheduledDelivery
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var scheduledMessage models.ScheduledMessage
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND send_at <= ?", models.ScheduledPending, now).
+			Order("send_at ASC").
+			First(&scheduledMessage).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		delivery = &ScheduledDelivery{ScheduledMessage: scheduledMessage}
+
+		if err := tx.SavePoint("delivery").Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{}
+		if err := s.deliver(tx, delivery); err != nil {
+			if err := tx.RollbackTo("delivery").Error; err != nil {
+				return err
+			}
+
+			delivery.Message = nil
+			delivery.DirectMessage = nil
+			updates["status"] = models.ScheduledFailed
+			updates["error"] = err.Error()
+		} else {
+			updates["sent_count"] = scheduledMessage.SentCount + 1
+			updates["last_sent_at"] = now
+			updates["error"] = ""
+
+			// Occurrences missed while the server was down are skipped, not replayed
+			if scheduledMessage.Recurrence != "" {
+				next, err := NextOccurrence(scheduledMessage.Recurrence, scheduledMessage.Timezone, now)
+				if err != nil {
+					updates["status"] = models.ScheduledSent
+				} else {
+					updates["send_at"] = next
+				}
+			} else {
+				updates["status"] = models.ScheduledSent
+			}
+		}
+
+		return tx.Model(&scheduledMessage).Updates(updates).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+func (s *ScheduledMessageService) deliver(tx *gorm.DB, delivery *ScheduledDelivery) error {
+	scheduledMessage := delivery.ScheduledMessage
+
+	var fileURL string
+	if scheduledMessage.FileURL != nil {
+		fileURL = *scheduledMessage.FileURL
+	}
+
+	if scheduledMessage.ChannelID != nil {
+		server, err := NewServerService(tx).GetServer(scheduledMessage.ProfileID, *scheduledMessage.ServerID)
+		if err != nil {
+			return fmt.Errorf("server not available: %w", err)
+		}
+
+		channel, err := NewChannelService(tx).GetChannel(*scheduledMessage.ChannelID)
+		if err != nil || channel.ServerID != server.ID {
+			return errors.New("channel not available")
+		}
+
+		var memberID uuid.UUID
+		for _, member := range server.Members {
+			if member.ProfileID == scheduledMessage.ProfileID {
+				memberID = member.ID
+			}
+		}
+		if memberID == uuid.Nil {
+			return errors.New("member not found")
+		}
+
+		message, err := NewMessageService(tx).CreateMessage(channel.ID, memberID, scheduledMessage.Content, fileURL)
+		if err != nil {
+			return err
+		}
+
+		delivery.Message = message
+		return nil
+	}
+
+	conversation, err := NewConversationService(tx).GetConversation(*scheduledMessage.ConversationID, scheduledMessage.ProfileID)
+	if err != nil {
+		return fmt.Errorf("conversation not available: %w", err)
+	}
+
+	directMessage, err := NewDirectMessageService(tx).CreateDirectMessage(conversation.ID, scheduledMessage.ProfileID, scheduledMessage.Content, fileURL)
+	if err != nil {
+		return err
+	}
+
+	delivery.DirectMessage = directMessage
+	return nil
+}
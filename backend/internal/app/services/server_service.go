@@ -2,6 +2,9 @@ package services
 
 import (
 	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -97,6 +100,8 @@ func (s *ServerService) GetServerDetails(profileID uuid.UUID, serverID uuid.UUID
 		return db.Order("members.role ASC").Preload("Profile")
 	}).Preload("Channels", func(db *gorm.DB) *gorm.DB {
 		return db.Order("channels.created_at ASC")
+	}).Preload("Emojis", func(db *gorm.DB) *gorm.DB {
+		return db.Order("emojis.name ASC")
 	}).Joins("JOIN members ON members.server_id = servers.id").
 		Where("servers.id = ? AND members.profile_id = ?", serverID, profileID).First(&server).Error
 
@@ -159,29 +164,61 @@ func (s *ServerService) UpdateServerMember(inviteCode uuid.UUID, profileID uuid.
 	return &server, nil
 }
 
-func (s *ServerService) UpdateServer(profileID uuid.UUID, serverID uuid.UUID, name string, imageUrl string) (*models.Server, error) {
+// UpdateServer only turns on requireMFA when the owner has two-factor
+// authentication, so they are not locked out of moderating their own server
+func (s *ServerService) UpdateServer(profileID uuid.UUID, serverID uuid.UUID, name string, imageUrl string, requireMFA *bool) (*models.Server, error) {
 	var server models.Server
 
-	updateData := models.Server{
-		Name:     name,
-		ImageURL: imageUrl,
-	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		updateData := models.Server{
+			Name:     name,
+			ImageURL: imageUrl,
+		}
 
-	result := s.DB.Model(&models.Server{}).Clauses(clause.Returning{}).
-		Where("id = ? AND profile_id = ?", serverID, profileID).
-		Updates(updateData).Scan(&server)
+		result := tx.Model(&models.Server{}).Clauses(clause.Returning{}).
+			Where("id = ? AND profile_id = ?", serverID, profileID).
+			Updates(updateData).Scan(&server)
 
-	if result.Error != nil {
-		return nil, result.Error
-	}
+		if result.Error != nil {
+			return result.Error
+		}
 
-	if result.RowsAffected == 0 {
-		return nil, gorm.ErrRecordNotFound
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if requireMFA == nil {
+			return nil
+		}
+
+		if *requireMFA {
+			var profile models.Profile
+			if err := tx.Select("id", "mfa_enabled").First(&profile, "id = ?", profileID).Error; err != nil {
+				return err
+			}
+
+			if !profile.MFAEnabled {
+				return fmt.Errorf("%w: enable two-factor authentication before requiring it for moderators", utils.ErrMFARequired)
+			}
+		}
+
+		server.RequireMFA = *requireMFA
+		return tx.Model(&server).Update("require_mfa", *requireMFA).Error
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
 	return &server, nil
 }
 
+// EnsureModeratorMFA is for moderation actions that do not go through a
+// service transaction
+func (s *ServerService) EnsureModeratorMFA(serverID, profileID uuid.UUID) error {
+	return ensureModeratorMFA(s.DB, serverID, profileID)
+}
+
 func (s *ServerService) LeaveServer(profileID, serverID uuid.UUID) (*models.Server, error) {
 
 	err := s.DB.Transaction(func(tx *gorm.DB) error {
@@ -216,13 +253,81 @@ func (s *ServerService) LeaveServer(profileID, serverID uuid.UUID) (*models.Serv
 	return &updatedServer, nil
 }
 
-func (s *ServerService) DeleteServer(profileID, serverID uuid.UUID) error {
-	if err := s.DB.Where("id = ? AND profile_id = ?", serverID, profileID).
-		Delete(&models.Server{}).Error; err != nil {
-		return err
+func (s *ServerService) DeleteServer(profileID, serverID uuid.UUID) (*models.Server, error) {
+	var server models.Server
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Channels").Where("id = ? AND profile_id = ?", serverID, profileID).
+			First(&server).Error; err != nil {
+			return err
+		}
+
+		// Soft delete, the rows are kept until the purger removes them after the
+		// retention period. The channels share the deleted_at of the server so a
+		// restore brings back those and not the ones deleted before
+		now := time.Now()
+		if err := tx.Model(&models.Channel{}).Where("server_id = ?", serverID).
+			Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&server).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		server.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &server, nil
+}
+
+func (s *ServerService) GetDeletedServers(profileID uuid.UUID) ([]models.Server, error) {
+	var servers []models.Server
+	err := s.DB.Unscoped().
+		Where("profile_id = ? AND deleted_at IS NOT NULL AND deleted_at > ?", profileID, time.Now().Add(-SOFT_DELETE_RETENTION)).
+		Order("deleted_at DESC").
+		Find(&servers).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return servers, nil
+}
+
+func (s *ServerService) RestoreServer(profileID, serverID uuid.UUID) (*models.Server, error) {
+	var server models.Server
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var deleted models.Server
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND profile_id = ? AND deleted_at IS NOT NULL AND deleted_at > ?", serverID, profileID, time.Now().Add(-SOFT_DELETE_RETENTION)).
+			First(&deleted).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&models.Channel{}).
+			Where("server_id = ? AND deleted_at = ?", serverID, deleted.DeletedAt.Time).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&deleted).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		return tx.Preload("Channels").First(&server, "id = ?", serverID).Error
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &server, nil
 }
 
 func (s *ServerService) GetServerDefaultChannel(profileID, serverID uuid.UUID) (*models.Server, error) {
@@ -243,7 +348,8 @@ func (s *ServerService) GetServerDefaultChannel(profileID, serverID uuid.UUID) (
 
 func (s *ServerService) GetMember(serverID, profileID uuid.UUID) (*models.Member, error) {
 	var member models.Member
-	if err := s.DB.First(&member, "server_id = ? AND profile_id = ?", serverID, profileID).
+	if err := s.DB.Scopes(liveServerMembers).
+		First(&member, "members.server_id = ? AND members.profile_id = ?", serverID, profileID).
 		Error; err != nil {
 		return nil, err
 	}
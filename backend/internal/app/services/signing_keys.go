@@ -0,0 +1,360 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// SIGNING_KEY_ROTATION is how long a key signs before the next one takes over
+	SIGNING_KEY_ROTATION = time.Hour * 24 * 7
+	// SIGNING_KEY_LEAD_TIME is how early the next key is created, so every
+	// instance and JWKS client knows it before tokens are signed with it
+	SIGNING_KEY_LEAD_TIME = time.Hour
+	// SIGNING_KEYS_RELOAD_INTERVAL limits how often an unknown kid reloads the
+	// keys, another instance may have rotated
+	SIGNING_KEYS_RELOAD_INTERVAL = time.Minute
+	TOKEN_ISSUER                 = "discord-backend"
+)
+
+// TokenType is the typ claim of a token, each type also has its own audience
+// so one kind of token is never accepted as another
+type TokenType string
+
+const (
+	AccessTokenType  TokenType = "access"
+	RefreshTokenType TokenType = "refresh"
+	MFAChallengeType TokenType = "mfa"
+	SSOFlowType      TokenType = "oidc"
+)
+
+func (t TokenType) Audience() string {
+	return TOKEN_ISSUER + ":" + string(t)
+}
+
+type signingKey struct {
+	ID          string
+	PrivateKey  ed25519.PrivateKey
+	ActiveUntil time.Time
+	ExpiresAt   time.Time
+}
+
+// keyRing caches the signing keys of the database. Every instance signs with
+// the key whose turn it is and verifies with any key that has not expired.
+// Seeds are stored encrypted with the key encryption key of the config
+type keyRing struct {
+	DB  *gorm.DB
+	KEK cipher.AEAD
+
+	mu       sync.RWMutex
+	keys     map[string]signingKey
+	current  *signingKey
+	loadedAt time.Time
+}
+
+var signingKeys = &keyRing{}
+
+// LoadSigningKeys loads the key ring and creates the first key when there is
+// none, it has to run before any token is signed. Seeds are encrypted with
+// AES-256-GCM under kek, seeds stored in plain text are encrypted on the way
+func LoadSigningKeys(db *gorm.DB, kek []byte) error {
+	if len(kek) != 32 {
+		return errors.New("the key encryption key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	signingKeys.DB = db
+	signingKeys.KEK = aead
+	_, err = signingKeys.rotate()
+	return err
+}
+
+// RotateSigningKeys creates the next key shortly before the current one stops
+// signing and picks up keys created by other instances
+func RotateSigningKeys() (bool, error) {
+	return signingKeys.rotate()
+}
+
+// sealSeed encrypts the seed of the key, the nonce comes first. The kid is
+// authenticated so a seed cannot be moved to another row
+func (r *keyRing) sealSeed(kid string, seed []byte) ([]byte, error) {
+	nonce := make([]byte, r.KEK.NonceSize(), r.KEK.NonceSize()+len(seed)+r.KEK.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return r.KEK.Seal(nonce, nonce, seed, []byte(kid)), nil
+}
+
+func (r *keyRing) openSeed(kid string, sealed []byte) ([]byte, error) {
+	if len(sealed) != r.KEK.NonceSize()+ed25519.SeedSize+r.KEK.Overhead() {
+		return nil, fmt.Errorf("signing key %s is not encrypted", kid)
+	}
+
+	nonce, ciphertext := sealed[:r.KEK.NonceSize()], sealed[r.KEK.NonceSize():]
+	seed, err := r.KEK.Open(nil, nonce, ciphertext, []byte(kid))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting signing key %s, it was encrypted with another SIGNING_KEY_ENCRYPTION_KEY: %w", kid, err)
+	}
+	return seed, nil
+}
+
+// sealPlainSeeds encrypts the seeds stored before they were encrypted
+func (r *keyRing) sealPlainSeeds(tx *gorm.DB) error {
+	var rows []models.SigningKey
+	if err := tx.Where("octet_length(seed) = ?", ed25519.SeedSize).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		sealed, err := r.sealSeed(row.ID, row.Seed)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&row).Update("seed", sealed).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *keyRing) load() error {
+	var rows []models.SigningKey
+	if err := r.DB.Where("expires_at > ?", time.Now()).Order("active_until ASC").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	// The current key is the first one still active, the last one otherwise
+	now := time.Now()
+	keys := make(map[string]signingKey, len(rows))
+	var current *signingKey
+	for _, row := range rows {
+		seed, err := r.openSeed(row.ID, row.Seed)
+		if err != nil {
+			return err
+		}
+
+		key := signingKey{
+			ID:          row.ID,
+			PrivateKey:  ed25519.NewKeyFromSeed(seed),
+			ActiveUntil: row.ActiveUntil,
+			ExpiresAt:   row.ExpiresAt,
+		}
+		keys[key.ID] = key
+
+		if current == nil || current.ActiveUntil.Before(now) {
+			current = &key
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys = keys
+	r.current = current
+	r.loadedAt = time.Now()
+	return nil
+}
+
+func (r *keyRing) rotate() (bool, error) {
+	rotated := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		// Instances rotating at the same time wait for each other here
+		if err := tx.Exec("LOCK TABLE signing_keys IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		if err := r.sealPlainSeeds(tx); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var last models.SigningKey
+		err := tx.Order("active_until DESC").First(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err == nil && last.ActiveUntil.After(now.Add(SIGNING_KEY_LEAD_TIME)) {
+			return nil
+		}
+
+		// The next key takes over when the last one stops signing
+		start := now
+		if err == nil && last.ActiveUntil.After(now) {
+			start = last.ActiveUntil
+		}
+
+		kid, err := utils.GenerateToken(8)
+		if err != nil {
+			return err
+		}
+
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return err
+		}
+
+		sealed, err := r.sealSeed(kid, seed)
+		if err != nil {
+			return err
+		}
+
+		key := models.SigningKey{
+			ID:          kid,
+			Algorithm:   jwt.SigningMethodEdDSA.Alg(),
+			Seed:        sealed,
+			ActiveUntil: start.Add(SIGNING_KEY_ROTATION),
+			ExpiresAt:   start.Add(SIGNING_KEY_ROTATION + REFRESH_TOKEN_TTL),
+		}
+		if err := tx.Create(&key).Error; err != nil {
+			return err
+		}
+
+		rotated = true
+		return nil
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return rotated, r.load()
+}
+
+func (r *keyRing) sign(claims jwt.MapClaims) (string, error) {
+	r.mu.RLock()
+	current := r.current
+	r.mu.RUnlock()
+
+	if current == nil {
+		return "", errors.New("no signing key loaded")
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = current.ID
+	return token.SignedString(current.PrivateKey)
+}
+
+func (r *keyRing) publicKey(kid string) (ed25519.PublicKey, error) {
+	r.mu.RLock()
+	key, ok := r.keys[kid]
+	stale := time.Since(r.loadedAt) >= SIGNING_KEYS_RELOAD_INTERVAL
+	r.mu.RUnlock()
+
+	if !ok && stale && r.DB != nil {
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+
+		r.mu.RLock()
+		key, ok = r.keys[kid]
+		r.mu.RUnlock()
+	}
+
+	if !ok || time.Now().After(key.ExpiresAt) {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return key.PrivateKey.Public().(ed25519.PublicKey), nil
+}
+
+// signToken sets the type, audience and issuer of the claims and signs them
+// with the current key
+func signToken(typ TokenType, claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	return signTokenAt(typ, claims, time.Now(), ttl)
+}
+
+// signTokenAt is signToken with the issue time given, the same claims issued
+// at the same second give the same token while the key is the same
+func signTokenAt(typ TokenType, claims jwt.MapClaims, issuedAt time.Time, ttl time.Duration) (string, error) {
+	claims["typ"] = string(typ)
+	claims["aud"] = typ.Audience()
+	claims["iss"] = TOKEN_ISSUER
+	claims["iat"] = issuedAt.Unix()
+	claims["exp"] = issuedAt.Add(ttl).Unix()
+
+	return signingKeys.sign(claims)
+}
+
+// VerifyToken checks the signature and expiry of the token and that it is of
+// the given type
+func VerifyToken(tokenString string, typ TokenType) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return signingKeys.publicKey(kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(TOKEN_ISSUER),
+		jwt.WithAudience(typ.Audience()),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenType, _ := claims["typ"].(string); tokenType != string(typ) {
+		return nil, fmt.Errorf("expected a %s token", typ)
+	}
+
+	return claims, nil
+}
+
+// JSONWebKey is the public half of a signing key as published in the JWKS
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKS lists every key tokens may still be signed with, so other services
+// can verify them without a shared secret
+func JWKS() []JSONWebKey {
+	signingKeys.mu.RLock()
+	defer signingKeys.mu.RUnlock()
+
+	keys := make([]JSONWebKey, 0, len(signingKeys.keys))
+	for _, key := range signingKeys.keys {
+		if time.Now().After(key.ExpiresAt) {
+			continue
+		}
+
+		keys = append(keys, JSONWebKey{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key.PrivateKey.Public().(ed25519.PublicKey)),
+			Kid: key.ID,
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Use: "sig",
+		})
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Kid < keys[j].Kid })
+	return keys
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+)
+
+func newTestKEK(t *testing.T) cipher.AEAD {
+	t.Helper()
+
+	kek := make([]byte, 32)
+	if _, err := rand.Read(kek); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestSealSeed(t *testing.T) {
+	ring := &keyRing{KEK: newTestKEK(t)}
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := ring.sealSeed("kid", seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, seed) {
+		t.Fatal("the seed is stored in plain text")
+	}
+
+	opened, err := ring.openSeed("kid", sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, seed) {
+		t.Error("the opened seed is not the sealed one")
+	}
+
+	if _, err := ring.openSeed("other kid", sealed); err == nil {
+		t.Error("the seed was opened for another key")
+	}
+
+	if _, err := (&keyRing{KEK: newTestKEK(t)}).openSeed("kid", sealed); err == nil {
+		t.Error("the seed was opened with another key encryption key")
+	}
+
+	if _, err := ring.openSeed("kid", seed); err == nil {
+		t.Error("a plain text seed was accepted")
+	}
+}
+
+func TestLoadSigningKeysRequiresKEK(t *testing.T) {
+	for _, kek := range [][]byte{nil, make([]byte, 16)} {
+		if err := LoadSigningKeys(nil, kek); err == nil {
+			t.Errorf("loaded with a key encryption key of %d bytes", len(kek))
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/oidc"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// SSO_FLOW_TTL is how long the user has to sign in at the provider
+	SSO_FLOW_TTL     = time.Minute * 10
+	MAX_PROFILE_NAME = 32
+)
+
+type SSOService struct {
+	DB        *gorm.DB
+	Providers map[string]*oidc.Provider
+}
+
+func NewSSOService(db *gorm.DB, providers map[string]*oidc.Provider) *SSOService {
+	return &SSOService{DB: db, Providers: providers}
+}
+
+// SSOLogin is a finished sign in at a provider. RevokedSessions are the
+// sessions that were signed out when the profile was claimed by the verified
+// owner of its email
+type SSOLogin struct {
+	ProfileID       uuid.UUID
+	DeviceName      string
+	RevokedSessions []uuid.UUID
+}
+
+func (s *SSOService) ProviderNames() []string {
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *SSOService) provider(name string) (*oidc.Provider, error) {
+	provider, ok := s.Providers[name]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return provider, nil
+}
+
+// BeginLogin returns the URL of the provider to send the browser to and the
+// flow token the callback needs. The flow token holds the state, nonce and
+// PKCE verifier and is kept by the browser in a cookie
+func (s *SSOService) BeginLogin(ctx context.Context, providerName, deviceName string) (string, string, error) {
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	values := make([]string, 3)
+	for i := range values {
+		if values[i], err = oidc.GenerateVerifier(); err != nil {
+			return "", "", err
+		}
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	flow, err := signToken(SSOFlowType, jwt.MapClaims{
+		"provider":    providerName,
+		"state":       state,
+		"nonce":       nonce,
+		"verifier":    verifier,
+		"device_name": deviceName,
+	}, SSO_FLOW_TTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, flow, nil
+}
+
+// CompleteLogin checks the callback belongs to the flow, exchanges the code
+// and finds or creates the profile of the ID token
+func (s *SSOService) CompleteLogin(ctx context.Context, providerName, flow, state, code string) (*SSOLogin, error) {
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := VerifyToken(flow, SSOFlowType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: sign in expired, try again", utils.ErrInvalidSSO)
+	}
+
+	flowProvider, _ := claims["provider"].(string)
+	flowState, _ := claims["state"].(string)
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["verifier"].(string)
+	deviceName, _ := claims["device_name"].(string)
+
+	if flowProvider != providerName || flowState == "" ||
+		subtle.ConstantTimeCompare([]byte(flowState), []byte(state)) != 1 {
+		return nil, fmt.Errorf("%w: state mismatch", utils.ErrInvalidSSO)
+	}
+
+	if code == "" {
+		return nil, fmt.Errorf("%w: missing authorization code", utils.ErrInvalidSSO)
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrInvalidSSO, err)
+	}
+
+	idClaims, err := provider.VerifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrInvalidSSO, err)
+	}
+
+	login := &SSOLogin{DeviceName: deviceName}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		login.ProfileID, login.RevokedSessions, err = linkIdentity(tx, providerName, idClaims)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return login, nil
+}
+
+// linkIdentity returns the profile linked to the identity. A new identity is
+// linked to the profile with the same email when the provider verified it,
+// otherwise a profile is created for it
+func linkIdentity(tx *gorm.DB, providerName string, claims *oidc.Claims) (uuid.UUID, []uuid.UUID, error) {
+	var identity models.ExternalIdentity
+	err := tx.First(&identity, "provider = ? AND subject = ?", providerName, claims.Subject).Error
+	if err == nil {
+		if claims.Email != "" && claims.Email != identity.Email {
+			if err := tx.Model(&identity).Update("email", claims.Email).Error; err != nil {
+				return uuid.Nil, nil, err
+			}
+		}
+		return identity.ProfileID, nil, nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return uuid.Nil, nil, fmt.Errorf("%w: the provider did not share a verified email", utils.ErrInvalidSSO)
+	}
+
+	var revoked []uuid.UUID
+	var profile models.Profile
+	err = tx.Where("email = ? AND is_bot = ?", claims.Email, false).First(&profile).Error
+	switch {
+	case err == nil:
+		// Whoever signed up with this email never proved they own it, so the
+		// password and sessions they have could belong to somebody else
+		if profile.EmailVerifiedAt == nil {
+			password, err := unusablePassword()
+			if err != nil {
+				return uuid.Nil, nil, err
+			}
+
+			if err := tx.Model(&profile).Updates(map[string]interface{}{
+				"password":          password,
+				"email_verified_at": time.Now(),
+			}).Error; err != nil {
+				return uuid.Nil, nil, err
+			}
+
+			if revoked, err = revokeSessions(tx, profile.ID); err != nil {
+				return uuid.Nil, nil, err
+			}
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if profile, err = createSSOProfile(tx, claims); err != nil {
+			return uuid.Nil, nil, err
+		}
+	default:
+		return uuid.Nil, nil, err
+	}
+
+	identity = models.ExternalIdentity{
+		ProfileID: profile.ID,
+		Provider:  providerName,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+	}
+	if err := tx.Create(&identity).Error; err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	return profile.ID, revoked, nil
+}
+
+// unusablePassword is stored for profiles that sign in through a provider,
+// they can still set a password with the reset flow
+func unusablePassword() (string, error) {
+	raw, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+	return HashPassword(raw)
+}
+
+// createSSOProfile names the profile after the provider's username, display
+// name or email, adding a suffix when the name is taken
+func createSSOProfile(tx *gorm.DB, claims *oidc.Claims) (models.Profile, error) {
+	base := claims.Username
+	if base == "" {
+		base = claims.Name
+	}
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	runes := []rune(strings.TrimSpace(base))
+	if len(runes) > MAX_PROFILE_NAME-5 {
+		runes = runes[:MAX_PROFILE_NAME-5]
+	}
+	base = string(runes)
+
+	name := base
+	for attempt := 0; ; attempt++ {
+		var count int64
+		if err := tx.Model(&models.Profile{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return models.Profile{}, err
+		}
+
+		if count == 0 {
+			break
+		}
+
+		if attempt == 5 {
+			return models.Profile{}, utils.ErrEmailOrUsernameTaken
+		}
+
+		suffix, err := utils.GenerateToken(2)
+		if err != nil {
+			return models.Profile{}, err
+		}
+		name = base + "-" + suffix
+	}
+
+	password, err := unusablePassword()
+	if err != nil {
+		return models.Profile{}, err
+	}
+
+	now := time.Now()
+	profile := models.Profile{
+		Name:            name,
+		ImageURL:        claims.Picture,
+		Email:           claims.Email,
+		Password:        password,
+		EmailVerifiedAt: &now,
+	}
+	if err := tx.Create(&profile).Error; err != nil {
+		return models.Profile{}, err
+	}
+
+	return profile, nil
+}
+
+// GetIdentities lists the providers linked to the profile
+func (s *SSOService) GetIdentities(profileID uuid.UUID) ([]models.ExternalIdentity, error) {
+	var identities []models.ExternalIdentity
+	if err := s.DB.Where("profile_id = ?", profileID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/oidc"
+	"discord-backend/internal/app/oidc/oidctest"
+	"discord-backend/internal/app/utils"
+	"discord-backend/internal/db/dbtest"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// useTestSigningKey signs the tokens of the test with a key that is not
+// stored, the key ring is put back afterwards
+func useTestSigningKey(t *testing.T) {
+	t.Helper()
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	key := signingKey{
+		ID:          "test",
+		PrivateKey:  ed25519.NewKeyFromSeed(seed),
+		ActiveUntil: now.Add(time.Hour),
+		ExpiresAt:   now.Add(REFRESH_TOKEN_TTL),
+	}
+
+	signingKeys.mu.Lock()
+	store, keys, current, loadedAt := signingKeys.DB, signingKeys.keys, signingKeys.current, signingKeys.loadedAt
+	signingKeys.DB, signingKeys.keys, signingKeys.current, signingKeys.loadedAt = nil, map[string]signingKey{key.ID: key}, &key, now
+	signingKeys.mu.Unlock()
+
+	t.Cleanup(func() {
+		signingKeys.mu.Lock()
+		signingKeys.DB, signingKeys.keys, signingKeys.current, signingKeys.loadedAt = store, keys, current, loadedAt
+		signingKeys.mu.Unlock()
+	})
+}
+
+func newTestSSO(t *testing.T, tx *gorm.DB) (*SSOService, *oidctest.Issuer) {
+	t.Helper()
+
+	issuer := oidctest.NewIssuer(t, "client", "secret")
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "test",
+		Issuer:       issuer.URL,
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  "https://app.example.com/api/auth/oidc/test/callback",
+	})
+
+	return NewSSOService(tx, map[string]*oidc.Provider{"test": provider}), issuer
+}
+
+// signIn begins a login, signs in at the issuer with claims and completes it
+func signIn(t *testing.T, sso *SSOService, issuer *oidctest.Issuer, claims jwt.MapClaims) (*SSOLogin, error) {
+	t.Helper()
+
+	authURL, flow, err := sso.BeginLogin(context.Background(), "test", "Test device")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state := issuer.Authorize(t, authURL, claims)
+	return sso.CompleteLogin(context.Background(), "test", flow, state, code)
+}
+
+func TestCompleteLoginRejectsStateMismatch(t *testing.T) {
+	useTestSigningKey(t)
+	sso, issuer := newTestSSO(t, nil)
+
+	authURL, flow, err := sso.BeginLogin(context.Background(), "test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := issuer.Authorize(t, authURL, nil)
+	for _, state := range []string{"", "another state"} {
+		if _, err := sso.CompleteLogin(context.Background(), "test", flow, state, code); !errors.Is(err, utils.ErrInvalidSSO) {
+			t.Errorf("state %q: err = %v, want ErrInvalidSSO", state, err)
+		}
+	}
+
+	if requests := issuer.TokenRequests(); requests != 0 {
+		t.Errorf("the code was exchanged %d times before the state was checked", requests)
+	}
+}
+
+func TestCompleteLoginRejectsFlowOfAnotherProvider(t *testing.T) {
+	useTestSigningKey(t)
+	sso, issuer := newTestSSO(t, nil)
+	sso.Providers["other"] = sso.Providers["test"]
+
+	authURL, flow, err := sso.BeginLogin(context.Background(), "other", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state := issuer.Authorize(t, authURL, nil)
+	if _, err := sso.CompleteLogin(context.Background(), "test", flow, state, code); !errors.Is(err, utils.ErrInvalidSSO) {
+		t.Errorf("err = %v, want ErrInvalidSSO", err)
+	}
+}
+
+func TestCompleteLoginRejectsInvalidFlow(t *testing.T) {
+	useTestSigningKey(t)
+	sso, _ := newTestSSO(t, nil)
+
+	// An access token is signed by the same key but is not a flow token
+	tokens, err := GenerateTokens(uuid.New(), "name", uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, flow := range []string{"", "not a token", tokens["accessToken"]} {
+		if _, err := sso.CompleteLogin(context.Background(), "test", flow, "state", "code"); !errors.Is(err, utils.ErrInvalidSSO) {
+			t.Errorf("err = %v, want ErrInvalidSSO", err)
+		}
+	}
+}
+
+// The code of one login is completed with the flow of another, so the
+// verifier sent does not match the challenge the code was issued for
+func TestCompleteLoginRejectsVerifierMismatch(t *testing.T) {
+	useTestSigningKey(t)
+	sso, issuer := newTestSSO(t, nil)
+
+	authURL, _, err := sso.BeginLogin(context.Background(), "test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherURL, otherFlow, err := sso.BeginLogin(context.Background(), "test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := issuer.Authorize(t, authURL, nil)
+	_, otherState := issuer.Authorize(t, otherURL, nil)
+
+	if _, err := sso.CompleteLogin(context.Background(), "test", otherFlow, otherState, code); !errors.Is(err, utils.ErrInvalidSSO) {
+		t.Errorf("err = %v, want ErrInvalidSSO", err)
+	}
+
+	if requests := issuer.TokenRequests(); requests != 1 {
+		t.Errorf("token requests = %d, want the exchange to be refused by the issuer", requests)
+	}
+}
+
+func TestCompleteLoginRejectsInvalidIDToken(t *testing.T) {
+	useTestSigningKey(t)
+	sso, issuer := newTestSSO(t, nil)
+
+	tests := map[string]jwt.MapClaims{
+		"nonce mismatch": {"nonce": "another nonce"},
+		"other audience": {"aud": "another client"},
+		"other issuer":   {"iss": "https://evil.example.com"},
+		"expired":        {"exp": time.Now().Add(-time.Hour).Unix()},
+	}
+
+	for name, claims := range tests {
+		if _, err := signIn(t, sso, issuer, claims); !errors.Is(err, utils.ErrInvalidSSO) {
+			t.Errorf("%s: err = %v, want ErrInvalidSSO", name, err)
+		}
+	}
+}
+
+func createTestProfile(t *testing.T, tx *gorm.DB, verified bool) *models.Profile {
+	t.Helper()
+
+	profile := models.Profile{
+		Name:     "test-" + uuid.NewString()[:8],
+		Email:    uuid.NewString() + "@example.com",
+		Password: "password hash",
+	}
+	if verified {
+		now := time.Now()
+		profile.EmailVerifiedAt = &now
+	}
+
+	if err := tx.Create(&profile).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &profile
+}
+
+func TestCompleteLoginLinksOnlyVerifiedEmails(t *testing.T) {
+	tx := dbtest.Open(t)
+	useTestSigningKey(t)
+	sso, issuer := newTestSSO(t, tx)
+	profile := createTestProfile(t, tx, true)
+
+	for _, verified := range []interface{}{false, "false", nil} {
+		_, err := signIn(t, sso, issuer, jwt.MapClaims{
+			"sub":            uuid.NewString(),
+			"email":          profile.Email,
+			"email_verified": verified,
+		})
+		if !errors.Is(err, utils.ErrInvalidSSO) {
+			t.Errorf("email_verified %v: err = %v, want ErrInvalidSSO", verified, err)
+		}
+	}
+
+	var count int64
+	if err := tx.Model(&models.ExternalIdentity{}).Where("profile_id = ?", profile.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d identities were linked from unverified emails", count)
+	}
+
+	login, err := signIn(t, sso, issuer, jwt.MapClaims{
+		"sub":            "verified-subject",
+		"email":          profile.Email,
+		"email_verified": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if login.ProfileID != profile.ID || len(login.RevokedSessions) != 0 {
+		t.Errorf("got %+v, want the existing profile without revoked sessions", login)
+	}
+
+	// The identity is known now, the email does not matter anymore
+	login, err = signIn(t, sso, issuer, jwt.MapClaims{"sub": "verified-subject", "email_verified": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.ProfileID != profile.ID {
+		t.Errorf("profile = %s, want %s", login.ProfileID, profile.ID)
+	}
+}
+
+// A profile whose email was never verified is taken over by the verified
+// owner, the password and sessions of whoever signed up are dropped
+func TestCompleteLoginClaimsUnverifiedProfile(t *testing.T) {
+	tx := dbtest.Open(t)
+	useTestSigningKey(t)
+	sso, issuer := newTestSSO(t, tx)
+	profile := createTestProfile(t, tx, false)
+
+	_, session, err := NewTokenService(tx).StartSession(profile.ID, profile.Name, DeviceInfo{Name: "Squatter"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login, err := signIn(t, sso, issuer, jwt.MapClaims{"email": profile.Email, "email_verified": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if login.ProfileID != profile.ID {
+		t.Errorf("profile = %s, want %s", login.ProfileID, profile.ID)
+	}
+	if len(login.RevokedSessions) != 1 || login.RevokedSessions[0] != session.ID {
+		t.Errorf("revoked = %v, want %s", login.RevokedSessions, session.ID)
+	}
+
+	var claimed models.Profile
+	if err := tx.First(&claimed, "id = ?", profile.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if claimed.Password == profile.Password || claimed.EmailVerifiedAt == nil {
+		t.Error("the password was kept or the email is still unverified")
+	}
+}
+
+func TestCompleteLoginCreatesProfile(t *testing.T) {
+	tx := dbtest.Open(t)
+	useTestSigningKey(t)
+	sso, issuer := newTestSSO(t, tx)
+
+	email := uuid.NewString() + "@example.com"
+	login, err := signIn(t, sso, issuer, jwt.MapClaims{
+		"sub":                uuid.NewString(),
+		"email":              email,
+		"email_verified":     true,
+		"preferred_username": "newcomer",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var profile models.Profile
+	if err := tx.First(&profile, "id = ?", login.ProfileID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if profile.Email != email || profile.EmailVerifiedAt == nil || login.DeviceName != "Test device" {
+		t.Errorf("got profile %+v and login %+v", profile, login)
+	}
+}
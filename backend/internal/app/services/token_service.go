@@ -2,8 +2,9 @@ package services
 
 import (
 	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/utils"
+	"errors"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -12,7 +13,16 @@ import (
 	"gorm.io/gorm/clause"
 )
 
-var jwtSecretKey = []byte(os.Getenv("SECRET_KEY"))
+const (
+	ACCESS_TOKEN_TTL  = time.Minute * 15
+	REFRESH_TOKEN_TTL = time.Hour * 72
+	MFA_CHALLENGE_TTL = time.Minute * 5
+	MAX_DEVICE_NAME   = 100
+	SESSIONS_LIMIT    = 100
+	// REFRESH_TOKEN_REUSE_GRACE lets tabs that refresh at the same time all
+	// exchange the same token, they get the same successor
+	REFRESH_TOKEN_REUSE_GRACE = time.Second * 5
+)
 
 type TokenService struct {
 	DB *gorm.DB
@@ -22,30 +32,61 @@ func NewTokenService(db *gorm.DB) *TokenService {
 	return &TokenService{DB: db}
 }
 
-func GenerateTokens(profileID uuid.UUID, name string) (map[string]string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+// GenerateTokens signs an access and a refresh token for the session. The
+// refresh token carries a random jti so every rotation gives a new token
+func GenerateTokens(profileID uuid.UUID, name string, sessionID uuid.UUID) (map[string]string, error) {
+	accessToken, err := signToken(AccessTokenType, jwt.MapClaims{
+		"profile_id": profileID,
+		"name":       name,
+		"session_id": sessionID,
+	}, ACCESS_TOKEN_TTL)
 
-	claims := token.Claims.(jwt.MapClaims)
+	if err != nil {
+		return nil, err
+	}
 
-	claims["profile_id"] = profileID
-	claims["name"] = name
-	claims["exp"] = time.Now().Add(time.Minute * 15).Unix()
+	jti, err := utils.GenerateToken(16)
+	if err != nil {
+		return nil, err
+	}
 
-	accessToken, err := token.SignedString(jwtSecretKey)
+	refreshToken, err := signToken(RefreshTokenType, jwt.MapClaims{
+		"profile_id": profileID,
+		"name":       name,
+		"session_id": sessionID,
+		"jti":        jti,
+	}, REFRESH_TOKEN_TTL)
 
 	if err != nil {
 		return nil, err
 	}
 
-	rtToken := jwt.New(jwt.SigningMethodHS256)
+	return map[string]string{
+		"accessToken":  accessToken,
+		"refreshToken": refreshToken,
+	}, nil
+}
 
-	rtClaims := rtToken.Claims.(jwt.MapClaims)
+// successorTokens signs the tokens that replace previous. The refresh token
+// is derived from previous and the time it was exchanged, so exchanging it
+// again in the grace window gives the same successor
+func successorTokens(profileID uuid.UUID, name string, sessionID uuid.UUID, previous string, exchangedAt time.Time) (map[string]string, error) {
+	accessToken, err := signToken(AccessTokenType, jwt.MapClaims{
+		"profile_id": profileID,
+		"name":       name,
+		"session_id": sessionID,
+	}, ACCESS_TOKEN_TTL)
 
-	rtClaims["profile_id"] = profileID
-	rtClaims["name"] = name
-	rtClaims["exp"] = time.Now().Add(time.Hour * 72).Unix()
+	if err != nil {
+		return nil, err
+	}
 
-	refreshToken, err := rtToken.SignedString(jwtSecretKey)
+	refreshToken, err := signTokenAt(RefreshTokenType, jwt.MapClaims{
+		"profile_id": profileID,
+		"name":       name,
+		"session_id": sessionID,
+		"jti":        utils.HashToken(previous)[:32],
+	}, exchangedAt, REFRESH_TOKEN_TTL)
 
 	if err != nil {
 		return nil, err
@@ -57,69 +98,372 @@ func GenerateTokens(profileID uuid.UUID, name string) (map[string]string, error)
 	}, nil
 }
 
-func VerifyToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+// GenerateMFAChallenge is handed out after the password when the profile has
+// two-factor authentication, it is only good for the second step of signing in
+func GenerateMFAChallenge(profileID uuid.UUID, deviceName string) (string, error) {
+	return signToken(MFAChallengeType, jwt.MapClaims{
+		"profile_id":  profileID,
+		"device_name": deviceName,
+	}, MFA_CHALLENGE_TTL)
+}
+
+func VerifyMFAChallenge(challenge string) (uuid.UUID, string, error) {
+	claims, err := VerifyToken(challenge, MFAChallengeType)
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("%w: challenge expired, sign in again", utils.ErrInvalidMFA)
+	}
+
+	profileIDStr, _ := claims["profile_id"].(string)
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("%w: invalid challenge", utils.ErrInvalidMFA)
+	}
+
+	deviceName, _ := claims["device_name"].(string)
+	return profileID, deviceName, nil
+}
+
+// DeviceInfo describes where a session signs in from
+type DeviceInfo struct {
+	Name      string
+	IP        string
+	UserAgent string
+}
+
+func (d DeviceInfo) name() string {
+	name := d.Name
+	if name == "" {
+		name = d.UserAgent
+	}
+	if name == "" {
+		name = "Unknown device"
+	}
+
+	runes := []rune(name)
+	if len(runes) > MAX_DEVICE_NAME {
+		name = string(runes[:MAX_DEVICE_NAME])
+	}
+	return name
+}
+
+func (t *TokenService) storeRefreshToken(tx *gorm.DB, session *models.Session, refreshToken string) error {
+	return tx.Create(&models.RefreshToken{
+		ProfileID: session.ProfileID,
+		SessionID: session.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: session.ExpiresAt,
+	}).Error
+}
+
+// StartSession signs a profile in on a new device
+func (t *TokenService) StartSession(profileID uuid.UUID, name string, device DeviceInfo) (map[string]string, *models.Session, error) {
+	now := time.Now()
+	session := models.Session{
+		ProfileID:  profileID,
+		DeviceName: device.name(),
+		IP:         device.IP,
+		UserAgent:  device.UserAgent,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(REFRESH_TOKEN_TTL),
+	}
+
+	var tokens map[string]string
+	err := t.DB.Transaction(func(tx *gorm.DB) error {
+		// The share lock keeps an operator from disabling the profile halfway
+		var profile models.Profile
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Select("id", "disabled_at").
+			First(&profile, "id = ?", profileID).Error; err != nil {
+			return err
+		}
+
+		if profile.DisabledAt != nil {
+			return utils.ErrAccountDisabled
+		}
+
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		tokens, err = GenerateTokens(profileID, name, session.ID)
+		if err != nil {
+			return err
 		}
-		return jwtSecretKey, nil
+
+		return t.storeRefreshToken(tx, &session, tokens["refreshToken"])
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tokens, &session, nil
+}
+
+// refreshClaims reads a refresh token, access tokens are refused
+func refreshClaims(refreshToken string) (profileID, sessionID uuid.UUID, name string, err error) {
+	claims, err := VerifyToken(refreshToken, RefreshTokenType)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", utils.ErrInvalidSession
+	}
+
+	profileIDStr, _ := claims["profile_id"].(string)
+	sessionIDStr, _ := claims["session_id"].(string)
+	name, _ = claims["name"].(string)
+
+	profileID, err = uuid.Parse(profileIDStr)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", utils.ErrInvalidSession
+	}
+
+	sessionID, err = uuid.Parse(sessionIDStr)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", utils.ErrInvalidSession
+	}
+
+	return profileID, sessionID, name, nil
+}
+
+// lockRefreshToken loads the token and its session for update, both have to
+// belong to the claims and the session has to be live
+func (t *TokenService) lockRefreshToken(tx *gorm.DB, refreshToken string, profileID, sessionID uuid.UUID) (*models.RefreshToken, *models.Session, error) {
+	var stored models.RefreshToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND profile_id = ? AND session_id = ?", utils.HashToken(refreshToken), profileID, sessionID).
+		First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, utils.ErrInvalidSession
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var session models.Session
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, "id = ?", sessionID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, nil, utils.ErrInvalidSession
+	}
+
+	return &stored, &session, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for new tokens. The token just
+// exchanged can be exchanged again for REFRESH_TOKEN_REUSE_GRACE and gives the
+// same successor, as long as that one is unused. Any other reuse means the
+// token leaked, the whole session is revoked and ErrRefreshTokenReused is
+// returned with the session so its sockets can close
+func (t *TokenService) RotateRefreshToken(refreshToken string, device DeviceInfo) (map[string]string, *models.Session, error) {
+	profileID, sessionID, name, err := refreshClaims(refreshToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tokens map[string]string
+	var session *models.Session
+	reused := false
+
+	err = t.DB.Transaction(func(tx *gorm.DB) error {
+		stored, locked, err := t.lockRefreshToken(tx, refreshToken, profileID, sessionID)
+		if err != nil {
+			return err
+		}
+		session = locked
+
+		now := time.Now()
+		if stored.UsedAt != nil {
+			if now.Sub(*stored.UsedAt) <= REFRESH_TOKEN_REUSE_GRACE {
+				tokens, err = successorTokens(profileID, name, sessionID, refreshToken, *stored.UsedAt)
+				if err != nil {
+					return err
+				}
+
+				err = tx.Where("token_hash = ? AND session_id = ? AND used_at IS NULL", utils.HashToken(tokens["refreshToken"]), sessionID).
+					First(&models.RefreshToken{}).Error
+				if err == nil {
+					return nil
+				}
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+			}
+
+			reused = true
+			session.RevokedAt = &now
+			return tx.Model(session).Update("revoked_at", now).Error
+		}
+
+		if err := tx.Model(stored).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		tokens, err = successorTokens(profileID, name, sessionID, refreshToken, now)
+		if err != nil {
+			return err
+		}
+
+		session.LastUsedAt = now
+		session.ExpiresAt = now.Add(REFRESH_TOKEN_TTL)
+		session.IP = device.IP
+		session.UserAgent = device.UserAgent
+		if err := tx.Model(session).Updates(map[string]interface{}{
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+			"ip":           session.IP,
+			"user_agent":   session.UserAgent,
+		}).Error; err != nil {
+			return err
+		}
+
+		return t.storeRefreshToken(tx, session, tokens["refreshToken"])
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if reused {
+		return nil, session, utils.ErrRefreshTokenReused
+	}
+
+	return tokens, session, nil
+}
+
+// AccessTokens issues new access tokens without rotating the refresh token,
+// for server side rendering where the new refresh cookie cannot be kept
+func (t *TokenService) AccessTokens(refreshToken string) (map[string]string, error) {
+	profileID, sessionID, name, err := refreshClaims(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.DB.Transaction(func(tx *gorm.DB) error {
+		stored, session, err := t.lockRefreshToken(tx, refreshToken, profileID, sessionID)
+		if err != nil {
+			return err
+		}
+
+		if stored.UsedAt != nil {
+			return utils.ErrInvalidSession
+		}
+
+		return tx.Model(session).Update("last_used_at", time.Now()).Error
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
-	} else {
-		return nil, fmt.Errorf("invalid refresh token")
+	tokens, err := GenerateTokens(profileID, name, sessionID)
+	if err != nil {
+		return nil, err
 	}
+
+	// Only the access token leaves, the refresh token stays the current one
+	delete(tokens, "refreshToken")
+	return tokens, nil
 }
 
-func (t *TokenService) StoreRefreshToken(profileID uuid.UUID, refreshToken string, expiresAt time.Time) error {
-	newRefreshToken := models.RefreshToken{
-		ProfileID: profileID,
-		Token:     refreshToken,
-		ExpiresAt: expiresAt,
+// EndSession signs out the session of the refresh token, unknown tokens are ignored
+func (t *TokenService) EndSession(refreshToken string) (*models.Session, error) {
+	profileID, sessionID, _, err := refreshClaims(refreshToken)
+	if err != nil {
+		return nil, nil
+	}
+
+	session, err := t.RevokeSession(profileID, sessionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
 	}
 
-	result := t.DB.Create(&newRefreshToken)
-	return result.Error
+	return session, err
+}
+
+// IsSessionActive is checked on every request signed in with an access token,
+// the token of a revoked session or of a disabled profile stays valid until it
+// expires but is refused
+func (t *TokenService) IsSessionActive(profileID, sessionID uuid.UUID) (bool, error) {
+	var count int64
+	err := activeSessions(t.DB).Where("sessions.id = ? AND sessions.profile_id = ?", sessionID, profileID).
+		Count(&count).Error
+
+	return count > 0, err
 }
 
-func (t *TokenService) UpdateRefreshToken(profileID uuid.UUID, oldToken, newToken string) error {
-	var refreshToken models.RefreshToken
-	if err := t.DB.Where("profile_id = ? AND token = ?", profileID, oldToken).First(&refreshToken).Error; err != nil {
-		return err
+// ActiveSessions keeps the sessions of sessionIDs that may still be used, the
+// open sockets of the others are closed
+func (t *TokenService) ActiveSessions(sessionIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	active := make(map[uuid.UUID]bool)
+	if len(sessionIDs) == 0 {
+		return active, nil
 	}
 
-	refreshToken.Token = newToken
-	refreshToken.ExpiresAt = time.Now().Add(time.Hour * 72)
+	var activeIDs []uuid.UUID
+	if err := activeSessions(t.DB).Where("sessions.id IN ?", sessionIDs).
+		Pluck("sessions.id", &activeIDs).Error; err != nil {
+		return nil, err
+	}
 
-	return t.DB.Save(&refreshToken).Error
+	for _, sessionID := range activeIDs {
+		active[sessionID] = true
+	}
+	return active, nil
+}
+
+// activeSessions selects the sessions that are neither revoked nor expired and
+// whose profile is not disabled
+func activeSessions(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&models.Session{}).
+		Joins("JOIN profiles ON profiles.id = sessions.profile_id AND profiles.disabled_at IS NULL").
+		Where("sessions.revoked_at IS NULL AND sessions.expires_at > ?", time.Now())
+}
+
+func (t *TokenService) GetSessions(profileID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	if err := t.DB.Where("profile_id = ? AND revoked_at IS NULL AND expires_at > ?", profileID, time.Now()).
+		Order("last_used_at DESC").Limit(SESSIONS_LIMIT).
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
 }
 
-func (t *TokenService) FindRefreshToken(profileID uuid.UUID, refreshToken string) error {
-	var refreshTokenModel models.RefreshToken
-	if err := t.DB.Where("profile_id = ? AND token = ?", profileID, refreshToken).First(&refreshTokenModel).Error; err != nil {
-		return err
+func (t *TokenService) RevokeSession(profileID, sessionID uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	if err := t.DB.Where("id = ? AND profile_id = ? AND revoked_at IS NULL", sessionID, profileID).
+		First(&session).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := t.DB.Model(&session).Update("revoked_at", now).Error; err != nil {
+		return nil, err
 	}
-	return nil
+	session.RevokedAt = &now
+
+	return &session, nil
 }
 
-func (t *TokenService) DeleteRefreshToken(profileID uuid.UUID, refreshToken string) error {
-	return t.DB.Where("profile_id = ? AND token = ?", profileID, refreshToken).Delete(&models.RefreshToken{}).Error
+// RevokeSessions signs the profile out everywhere and returns the revoked ids
+func (t *TokenService) RevokeSessions(profileID uuid.UUID) ([]uuid.UUID, error) {
+	return revokeSessions(t.DB, profileID)
 }
 
-func (t *TokenService) UpsertRefreshToken(profileID uuid.UUID, refreshToken string) error {
-	newRefreshToken := models.RefreshToken{
-		ProfileID: profileID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().Add(time.Hour * 72),
+func revokeSessions(tx *gorm.DB, profileID uuid.UUID) ([]uuid.UUID, error) {
+	var sessions []models.Session
+	if err := tx.Model(&sessions).Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("profile_id = ? AND revoked_at IS NULL", profileID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+
+	sessionIDs := make([]uuid.UUID, len(sessions))
+	for i, session := range sessions {
+		sessionIDs[i] = session.ID
 	}
 
-	return t.DB.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "profile_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"token", "expires_at"}),
-	}).Create(&newRefreshToken).Error
+	return sessionIDs, nil
 }
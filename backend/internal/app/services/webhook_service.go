@@ -0,0 +1,392 @@
+package services
+
+import (
+	"context"
+	"discord-backend/internal/app/events"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/netguard"
+	"discord-backend/internal/app/utils"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MAX_WEBHOOKS_PER_SERVER   = 10
+	WEBHOOK_MAX_ATTEMPTS      = 8
+	WEBHOOK_RETRY_BASE        = time.Second * 10
+	WEBHOOK_RETRY_MAX         = time.Hour
+	WEBHOOK_DELIVERY_LEASE    = time.Minute * 2
+	WEBHOOK_DELIVERIES_BATCH  = 50
+	WEBHOOK_RESPONSE_MAX_SIZE = 1024
+	WEBHOOK_TIMEOUT           = time.Second * 10
+)
+
+type WebhookService struct {
+	DB *gorm.DB
+}
+
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	return &WebhookService{DB: db}
+}
+
+// WebhookPayload is the body posted to subscribers
+type WebhookPayload struct {
+	ID         uuid.UUID        `json:"id"`
+	Type       events.EventType `json:"type"`
+	ServerID   uuid.UUID        `json:"serverId"`
+	OccurredAt time.Time        `json:"occurredAt"`
+	Data       interface{}      `json:"data"`
+}
+
+func (w *WebhookService) ensureAdmin(tx *gorm.DB, serverID, profileID uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.Member{}).
+		Where("server_id = ? AND profile_id = ? AND role = ?", serverID, profileID, models.Admin).
+		Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return utils.ErrForbidden
+	}
+
+	return nil
+}
+
+func validateWebhook(rawURL string, eventTypes []string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https url", utils.ErrInvalidWebhook)
+	}
+
+	// Deliveries and their responses would otherwise reach the internal network
+	ctx, cancel := context.WithTimeout(context.Background(), WEBHOOK_TIMEOUT)
+	defer cancel()
+	if err := netguard.CheckPublicURL(ctx, parsed); err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrInvalidWebhook, err)
+	}
+
+	if len(eventTypes) == 0 {
+		return fmt.Errorf("%w: at least one event is required", utils.ErrInvalidWebhook)
+	}
+
+	for _, eventType := range eventTypes {
+		if !events.IsValidEventType(events.EventType(eventType)) {
+			return fmt.Errorf("%w: unknown event %q", utils.ErrInvalidWebhook, eventType)
+		}
+	}
+
+	return nil
+}
+
+// CreateWebhook returns the secret separately because it is never serialized
+// with the webhook again
+func (w *WebhookService) CreateWebhook(serverID, profileID uuid.UUID, rawURL string, eventTypes []string) (*models.Webhook, string, error) {
+	if err := validateWebhook(rawURL, eventTypes); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	webhook := models.Webhook{
+		ServerID:  serverID,
+		ProfileID: profileID,
+		URL:       rawURL,
+		Secret:    secret,
+		Events:    eventTypes,
+		Active:    true,
+	}
+
+	err = w.DB.Transaction(func(tx *gorm.DB) error {
+		if err := w.ensureAdmin(tx, serverID, profileID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Webhook{}).Where("server_id = ?", serverID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count >= MAX_WEBHOOKS_PER_SERVER {
+			return fmt.Errorf("%w: a server can have at most %d webhooks", utils.ErrInvalidWebhook, MAX_WEBHOOKS_PER_SERVER)
+		}
+
+		return tx.Create(&webhook).Error
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &webhook, secret, nil
+}
+
+func (w *WebhookService) GetWebhooks(serverID, profileID uuid.UUID) ([]models.Webhook, error) {
+	if err := w.ensureAdmin(w.DB, serverID, profileID); err != nil {
+		return nil, err
+	}
+
+	var webhooks []models.Webhook
+	if err := w.DB.Where("server_id = ?", serverID).Order("created_at ASC").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (w *WebhookService) UpdateWebhook(serverID, profileID, webhookID uuid.UUID, rawURL *string, eventTypes []string, active *bool) (*models.Webhook, error) {
+	var webhook models.Webhook
+
+	err := w.DB.Transaction(func(tx *gorm.DB) error {
+		if err := w.ensureAdmin(tx, serverID, profileID); err != nil {
+			return err
+		}
+
+		if err := tx.Where("id = ? AND server_id = ?", webhookID, serverID).First(&webhook).Error; err != nil {
+			return err
+		}
+
+		if rawURL != nil {
+			webhook.URL = *rawURL
+		}
+
+		if eventTypes != nil {
+			webhook.Events = eventTypes
+		}
+
+		if active != nil {
+			webhook.Active = *active
+		}
+
+		if err := validateWebhook(webhook.URL, webhook.Events); err != nil {
+			return err
+		}
+
+		return tx.Select("url", "events", "active").Updates(&webhook).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (w *WebhookService) RotateWebhookSecret(serverID, profileID, webhookID uuid.UUID) (*models.Webhook, string, error) {
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var webhook models.Webhook
+	err = w.DB.Transaction(func(tx *gorm.DB) error {
+		if err := w.ensureAdmin(tx, serverID, profileID); err != nil {
+			return err
+		}
+
+		if err := tx.Where("id = ? AND server_id = ?", webhookID, serverID).First(&webhook).Error; err != nil {
+			return err
+		}
+
+		webhook.Secret = secret
+		return tx.Model(&webhook).Update("secret", secret).Error
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &webhook, secret, nil
+}
+
+func (w *WebhookService) DeleteWebhook(serverID, profileID, webhookID uuid.UUID) error {
+	return w.DB.Transaction(func(tx *gorm.DB) error {
+		if err := w.ensureAdmin(tx, serverID, profileID); err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ? AND server_id = ?", webhookID, serverID).Delete(&models.Webhook{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+func (w *WebhookService) GetDeliveries(serverID, profileID, webhookID uuid.UUID, cursor string) ([]models.WebhookDelivery, string, error) {
+	if err := w.ensureAdmin(w.DB, serverID, profileID); err != nil {
+		return nil, "", err
+	}
+
+	var webhook models.Webhook
+	if err := w.DB.Where("id = ? AND server_id = ?", webhookID, serverID).First(&webhook).Error; err != nil {
+		return nil, "", err
+	}
+
+	query := w.DB.Where("webhook_id = ?", webhookID)
+	if cursor != "" {
+		cursorTime, err := time.Parse(time.RFC3339Nano, cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: invalid cursor", utils.ErrInvalidWebhook)
+		}
+		query = query.Where("created_at < ?", cursorTime)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at DESC").Limit(WEBHOOK_DELIVERIES_BATCH).Find(&deliveries).Error; err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(deliveries) == WEBHOOK_DELIVERIES_BATCH {
+		nextCursor = deliveries[len(deliveries)-1].CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	return deliveries, nextCursor, nil
+}
+
+// EnqueueEvent records one pending delivery for every active webhook of the
+// server that subscribes to the event
+func (w *WebhookService) EnqueueEvent(event events.Event) error {
+	var webhooks []models.Webhook
+	if err := w.DB.Where("server_id = ? AND active = ?", event.ServerID, true).Find(&webhooks).Error; err != nil {
+		return err
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(string(event.Type)) {
+			continue
+		}
+
+		payload, err := json.Marshal(WebhookPayload{
+			ID:         event.ID,
+			Type:       event.Type,
+			ServerID:   event.ServerID,
+			OccurredAt: event.OccurredAt,
+			Data:       event.Data,
+		})
+		if err != nil {
+			return err
+		}
+
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			Event:         string(event.Type),
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: event.OccurredAt,
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return w.DB.Create(&deliveries).Error
+}
+
+// ClaimDueDeliveries leases due deliveries by pushing their next attempt past
+// the lease, so several dispatchers never send the same delivery at once
+func (w *WebhookService) ClaimDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+
+	err := w.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at ASC").Limit(limit).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+		}
+
+		if err := tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(WEBHOOK_DELIVERY_LEASE)).Error; err != nil {
+			return err
+		}
+
+		return tx.Preload("Webhook").Where("id IN ?", ids).Order("next_attempt_at ASC").Find(&deliveries).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func WebhookRetryDelay(attempts int) time.Duration {
+	delay := WEBHOOK_RETRY_BASE
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= WEBHOOK_RETRY_MAX {
+			return WEBHOOK_RETRY_MAX
+		}
+	}
+	return delay
+}
+
+// RecordAttempt stores the outcome of one attempt, and either finishes the
+// delivery or schedules the next retry with exponential backoff
+func (w *WebhookService) RecordAttempt(delivery *models.WebhookDelivery, statusCode int, response string, attemptErr error) error {
+	now := time.Now()
+	if len(response) > WEBHOOK_RESPONSE_MAX_SIZE {
+		response = response[:WEBHOOK_RESPONSE_MAX_SIZE]
+	}
+
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.LastResponse = response
+	delivery.LastError = ""
+
+	switch {
+	case attemptErr == nil && statusCode >= 200 && statusCode < 300:
+		delivery.Status = models.DeliverySucceeded
+		delivery.DeliveredAt = &now
+	default:
+		if attemptErr != nil {
+			delivery.LastError = attemptErr.Error()
+		} else {
+			delivery.LastError = fmt.Sprintf("unexpected status code %d", statusCode)
+		}
+
+		if delivery.Attempts >= WEBHOOK_MAX_ATTEMPTS {
+			delivery.Status = models.DeliveryFailed
+		} else {
+			delivery.NextAttemptAt = now.Add(WebhookRetryDelay(delivery.Attempts))
+		}
+	}
+
+	return w.DB.Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+		"status":           delivery.Status,
+		"attempts":         delivery.Attempts,
+		"last_status_code": delivery.LastStatusCode,
+		"last_response":    delivery.LastResponse,
+		"last_error":       delivery.LastError,
+		"next_attempt_at":  delivery.NextAttemptAt,
+		"delivered_at":     delivery.DeliveredAt,
+	}).Error
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+var (
+	ErrUnsupportedImage = errors.New("image must be a png, jpeg or gif")
+	ErrImageTooLarge    = errors.New("image is too large")
+)
+
+type ImageInfo struct {
+	Format      string
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Animated    bool
+}
+
+// InspectImage reads the header of an uploaded image without decoding the
+// pixels, images wider or higher than maxDimension are refused before anything
+// else is read. An animated png is recognised by its acTL chunk and a gif by
+// having more than one frame
+func InspectImage(data []byte, maxDimension int) (*ImageInfo, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, fmt.Errorf("%w: it must be at most %dx%d pixels", ErrImageTooLarge, maxDimension, maxDimension)
+	}
+
+	info := &ImageInfo{Format: format, Width: config.Width, Height: config.Height}
+
+	switch format {
+	case "png":
+		info.ContentType, info.Extension = "image/png", "png"
+		info.Animated = isAnimatedPNG(data)
+	case "jpeg":
+		info.ContentType, info.Extension = "image/jpeg", "jpg"
+	case "gif":
+		info.ContentType, info.Extension = "image/gif", "gif"
+		frames, err := gifFrames(data, 2)
+		if err != nil {
+			return nil, ErrUnsupportedImage
+		}
+		info.Animated = frames > 1
+	default:
+		return nil, ErrUnsupportedImage
+	}
+
+	return info, nil
+}
+
+func isAnimatedPNG(data []byte) bool {
+	// Chunks start after the 8 byte signature: length, type, data and crc
+	for offset := 8; offset+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		chunkType := string(data[offset+4 : offset+8])
+
+		switch chunkType {
+		case "acTL":
+			return true
+		case "IDAT", "IEND":
+			return false
+		}
+
+		offset += 12 + length
+	}
+
+	return false
+}
+
+// gifFrames counts the image descriptors of a gif up to limit, it skips over
+// the compressed pixel data block by block instead of decoding it
+func gifFrames(data []byte, limit int) (int, error) {
+	// Header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, ErrUnsupportedImage
+	}
+	offset := 13 + colorTableSize(data[10])
+
+	frames := 0
+	for frames < limit {
+		if offset >= len(data) {
+			return 0, ErrUnsupportedImage
+		}
+
+		switch data[offset] {
+		case 0x21:
+			// Extension: introducer, label and data sub-blocks
+			next, err := skipSubBlocks(data, offset+2)
+			if err != nil {
+				return 0, err
+			}
+			offset = next
+		case 0x2C:
+			// Image descriptor, optional local color table, LZW minimum code
+			// size and the pixel data sub-blocks
+			if offset+10 > len(data) {
+				return 0, ErrUnsupportedImage
+			}
+			next, err := skipSubBlocks(data, offset+10+colorTableSize(data[offset+9])+1)
+			if err != nil {
+				return 0, err
+			}
+			offset = next
+			frames++
+		case 0x3B:
+			return frames, nil
+		default:
+			return 0, ErrUnsupportedImage
+		}
+	}
+
+	return frames, nil
+}
+
+// colorTableSize is the size of the color table the packed field of a
+// descriptor announces
+func colorTableSize(packed byte) int {
+	if packed&0x80 == 0 {
+		return 0
+	}
+	return 3 << (packed&0x07 + 1)
+}
+
+// skipSubBlocks returns the offset after the sub-blocks starting at offset,
+// each is a length byte followed by that many bytes and a zero length ends them
+func skipSubBlocks(data []byte, offset int) (int, error) {
+	for {
+		if offset >= len(data) {
+			return 0, ErrUnsupportedImage
+		}
+
+		length := int(data[offset])
+		offset++
+		if length == 0 {
+			return offset, nil
+		}
+		offset += length
+	}
+}
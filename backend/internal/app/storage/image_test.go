@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+func encodeGIF(t *testing.T, frames int, size int) []byte {
+	t.Helper()
+
+	palette := color.Palette{color.Black, color.White}
+	animation := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		animation.Image = append(animation.Image, image.NewPaletted(image.Rect(0, 0, size, size), palette))
+		animation.Delay = append(animation.Delay, 10)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, animation); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestInspectImage(t *testing.T) {
+	var still bytes.Buffer
+	if err := png.Encode(&still, image.NewGray(image.Rect(0, 0, 32, 16))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		format   string
+		animated bool
+	}{
+		{"png", still.Bytes(), "png", false},
+		{"gif", encodeGIF(t, 1, 32), "gif", false},
+		{"animated gif", encodeGIF(t, 3, 32), "gif", true},
+	}
+
+	for _, test := range tests {
+		info, err := InspectImage(test.data, 128)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if info.Format != test.format || info.Animated != test.animated {
+			t.Errorf("%s: got %+v", test.name, info)
+		}
+	}
+}
+
+// A tiny gif can announce a huge logical screen, it is refused from the
+// header without allocating anything for it
+func TestInspectImageRefusesLargeDimensions(t *testing.T) {
+	data := encodeGIF(t, 1, 8)
+	binary.LittleEndian.PutUint16(data[6:8], 65535)
+	binary.LittleEndian.PutUint16(data[8:10], 65535)
+
+	if _, err := InspectImage(data, 128); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("err = %v, want ErrImageTooLarge", err)
+	}
+
+	if _, err := InspectImage(encodeGIF(t, 1, 129), 128); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("err = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestInspectImageRefusesTruncatedGIF(t *testing.T) {
+	data := encodeGIF(t, 2, 32)
+
+	if _, err := InspectImage(data[:len(data)/2], 128); !errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("err = %v, want ErrUnsupportedImage", err)
+	}
+}
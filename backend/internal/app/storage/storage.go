@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage keeps uploaded files, keys are slash separated paths chosen by the
+// caller and URL returns where clients can download the object
+type Storage interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// LocalStorage writes objects under Dir, the router serves Dir at URLPrefix
+type LocalStorage struct {
+	Dir       string
+	URLPrefix string
+}
+
+func NewLocalStorage(dir, urlPrefix string) *LocalStorage {
+	return &LocalStorage{Dir: dir, URLPrefix: strings.TrimSuffix(urlPrefix, "/")}
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if key == "" || cleaned != "/"+key {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(s.Dir, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Written next to the target and renamed so readers never see a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return s.URLPrefix + "/" + key
+}
@@ -0,0 +1,145 @@
+package unfurl
+
+import (
+	"discord-backend/internal/app/models"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+type metadata struct {
+	Title       string
+	Description string
+	URL         string
+	ImageURL    string
+	SiteName    string
+	Color       int
+	OEmbedURL   string
+}
+
+// parseMetadata reads the OpenGraph and twitter tags of the document head,
+// falling back to <title> and the plain description meta tag
+func parseMetadata(r io.Reader) *metadata {
+	tags := make(map[string]string)
+	var title string
+
+	tokenizer := html.NewTokenizer(r)
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return newMetadata(tags, title)
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if string(name) == "head" {
+				return newMetadata(tags, title)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "body":
+				return newMetadata(tags, title)
+			case "title":
+				if tokenizer.Next() == html.TextToken && title == "" {
+					title = string(tokenizer.Text())
+				}
+			case "meta":
+				key := strings.ToLower(attr(token, "property"))
+				if key == "" {
+					key = strings.ToLower(attr(token, "name"))
+				}
+
+				// The first value wins, pages often repeat og:image for alternates
+				if _, ok := tags[key]; key != "" && !ok {
+					tags[key] = attr(token, "content")
+				}
+			case "link":
+				if strings.Contains(strings.ToLower(attr(token, "rel")), "alternate") &&
+					strings.ToLower(attr(token, "type")) == "application/json+oembed" {
+					tags["oembed"] = attr(token, "href")
+				}
+			}
+		}
+	}
+}
+
+func attr(token html.Token, key string) string {
+	for _, attribute := range token.Attr {
+		if attribute.Key == key {
+			return strings.TrimSpace(attribute.Val)
+		}
+	}
+	return ""
+}
+
+func first(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func newMetadata(tags map[string]string, title string) *metadata {
+	m := &metadata{
+		Title:       first(tags["og:title"], tags["twitter:title"], title),
+		Description: first(tags["og:description"], tags["twitter:description"], tags["description"]),
+		URL:         tags["og:url"],
+		ImageURL:    first(tags["og:image:secure_url"], tags["og:image"], tags["twitter:image"]),
+		SiteName:    tags["og:site_name"],
+		OEmbedURL:   tags["oembed"],
+	}
+
+	if color := strings.TrimPrefix(tags["theme-color"], "#"); len(color) == 6 {
+		if value, err := strconv.ParseInt(color, 16, 32); err == nil {
+			m.Color = int(value)
+		}
+	}
+
+	return m
+}
+
+// merge fills what the page tags left out with the oEmbed answer
+func (m *metadata) merge(oembed *oEmbed) {
+	m.Title = first(m.Title, oembed.Title)
+	m.SiteName = first(m.SiteName, oembed.ProviderName)
+
+	if oembed.Type == "photo" {
+		m.ImageURL = first(m.ImageURL, oembed.URL)
+	}
+	m.ImageURL = first(m.ImageURL, oembed.ThumbnailURL)
+
+	if m.Description == "" && oembed.AuthorName != "" {
+		m.Description = oembed.AuthorName
+	}
+}
+
+// resolve makes a possibly relative link absolute, anything that is not a web
+// url is dropped
+func resolve(base *url.URL, link string) string {
+	if link == "" {
+		return ""
+	}
+
+	resolved, err := base.Parse(link)
+	if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") || len(resolved.String()) > MAX_URL_LENGTH {
+		return ""
+	}
+
+	return resolved.String()
+}
+
+func (m *metadata) embed(base *url.URL) *models.Embed {
+	return &models.Embed{
+		Title:       truncate(m.Title, models.MAX_EMBED_TITLE),
+		Description: truncate(m.Description, MAX_PREVIEW_DESCRIPTION),
+		URL:         resolve(base, m.URL),
+		ImageURL:    resolve(base, m.ImageURL),
+		Footer:      truncate(m.SiteName, MAX_PREVIEW_FOOTER),
+		Color:       m.Color,
+	}
+}
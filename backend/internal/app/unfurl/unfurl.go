@@ -0,0 +1,247 @@
+package unfurl
+
+import (
+	"bytes"
+	"context"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/netguard"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	MAX_URLS_PER_MESSAGE = 3
+	MAX_URL_LENGTH       = 2048
+	MAX_BODY_SIZE        = 512 * 1024
+	MAX_OEMBED_SIZE      = 64 * 1024
+	MAX_REDIRECTS        = 3
+	FETCH_TIMEOUT        = time.Second * 5
+	// Kept well under the embed limits so the previews of a message always fit
+	MAX_PREVIEW_DESCRIPTION = 1024
+	MAX_PREVIEW_FOOTER      = 256
+)
+
+var urlPattern = regexp.MustCompile("https?://[^\\s<>\"'`]+")
+
+// ExtractURLs returns the distinct links of a message in order, links written
+// as <https://...> are skipped so users can opt out of a preview
+func ExtractURLs(content string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+
+	for _, match := range urlPattern.FindAllStringIndex(content, -1) {
+		if match[0] > 0 && content[match[0]-1] == '<' {
+			continue
+		}
+
+		link := strings.TrimRight(content[match[0]:match[1]], ".,;:!?*_~")
+		// Keep the parenthesis of wiki style links, drop the one closing a sentence
+		for strings.HasSuffix(link, ")") && strings.Count(link, ")") > strings.Count(link, "(") {
+			link = strings.TrimSuffix(link, ")")
+		}
+
+		if len(link) > MAX_URL_LENGTH || seen[link] {
+			continue
+		}
+
+		seen[link] = true
+		urls = append(urls, link)
+		if len(urls) == MAX_URLS_PER_MESSAGE {
+			break
+		}
+	}
+
+	return urls
+}
+
+// checkURL only lets plain web urls through, the address itself is checked
+// when dialing so a DNS answer cannot point us back inside the network
+func checkURL(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", netguard.ErrBlockedAddress, target.Scheme)
+	}
+
+	if target.Hostname() == "" || target.User != nil {
+		return fmt.Errorf("%w: %s", netguard.ErrBlockedAddress, target.Redacted())
+	}
+
+	if port := target.Port(); port != "" && port != "80" && port != "443" {
+		return fmt.Errorf("%w: port %s", netguard.ErrBlockedAddress, port)
+	}
+
+	return nil
+}
+
+// Fetcher downloads pages for previews, the default client refuses to connect
+// to anything but public addresses on the standard ports
+type Fetcher struct {
+	Client   *http.Client
+	Timeout  time.Duration
+	CheckURL func(target *url.URL) error
+}
+
+func NewFetcher() *Fetcher {
+	fetcher := &Fetcher{
+		Timeout:  FETCH_TIMEOUT,
+		CheckURL: checkURL,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           netguard.SafeDialContext(FETCH_TIMEOUT, netguard.IsPublicAddress),
+		TLSHandshakeTimeout:   FETCH_TIMEOUT,
+		ResponseHeaderTimeout: FETCH_TIMEOUT,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       time.Second * 30,
+	}
+
+	fetcher.Client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= MAX_REDIRECTS {
+				return errors.New("too many redirects")
+			}
+			return fetcher.CheckURL(req.URL)
+		},
+	}
+
+	return fetcher
+}
+
+type response struct {
+	URL         *url.URL
+	ContentType string
+	Body        []byte
+}
+
+func (f *Fetcher) get(ctx context.Context, target *url.URL, accept string, maxSize int64) (*response, error) {
+	if err := f.CheckURL(target); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; discord-clone-unfurler)")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// Whatever is past the limit is ignored, the head of a page is all we need
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &response{URL: resp.Request.URL, ContentType: resp.Header.Get("Content-Type"), Body: body}, nil
+}
+
+// Fetch builds a preview for rawURL from its OpenGraph tags, completed by the
+// oEmbed endpoint the page advertises. It returns nil when there is nothing to show
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*models.Embed, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.Timeout)
+	defer cancel()
+
+	page, err := f.get(ctx, target, "text/html,application/xhtml+xml;q=0.9,image/*;q=0.8", MAX_BODY_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(page.ContentType)
+	if strings.HasPrefix(mediaType, "image/") {
+		return &models.Embed{URL: rawURL, ImageURL: page.URL.String()}, nil
+	}
+
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, nil
+	}
+
+	reader, err := charset.NewReader(bytes.NewReader(page.Body), page.ContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := parseMetadata(reader)
+
+	if metadata.OEmbedURL != "" {
+		if endpoint, err := page.URL.Parse(metadata.OEmbedURL); err == nil {
+			if oembed, err := f.fetchOEmbed(ctx, endpoint); err == nil {
+				metadata.merge(oembed)
+			}
+		}
+	}
+
+	embed := metadata.embed(page.URL)
+	if embed.Title == "" && embed.Description == "" && embed.ImageURL == "" {
+		return nil, nil
+	}
+
+	if embed.URL == "" {
+		embed.URL = rawURL
+	}
+
+	return embed, nil
+}
+
+type oEmbed struct {
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	ProviderName string `json:"provider_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	URL          string `json:"url"`
+}
+
+func (f *Fetcher) fetchOEmbed(ctx context.Context, endpoint *url.URL) (*oEmbed, error) {
+	resp, err := f.get(ctx, endpoint, "application/json", MAX_OEMBED_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	var oembed oEmbed
+	if err := json.Unmarshal(resp.Body, &oembed); err != nil {
+		return nil, err
+	}
+
+	return &oembed, nil
+}
+
+// truncate cuts s to at most max bytes without splitting a rune
+func truncate(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= max {
+		return s
+	}
+
+	s = s[:max]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed five field cron expression: minute hour day-of-month month day-of-week
+type CronSchedule struct {
+	minutes  [60]bool
+	hours    [24]bool
+	days     [32]bool
+	months   [13]bool
+	weekdays [7]bool
+	anyDay   bool
+	anyWeek  bool
+}
+
+var cronDescriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+func ParseCron(expression string) (*CronSchedule, error) {
+	expression = strings.TrimSpace(expression)
+	if descriptor, ok := cronDescriptors[expression]; ok {
+		expression = descriptor
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	schedule := &CronSchedule{
+		anyDay:  fields[2] == "*",
+		anyWeek: fields[4] == "*",
+	}
+
+	if err := parseCronField(fields[0], 0, 59, schedule.minutes[:]); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, schedule.hours[:]); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, schedule.days[:]); err != nil {
+		return nil, fmt.Errorf("invalid day of month field: %w", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, schedule.months[:]); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+
+	// Both 0 and 7 mean sunday
+	var weekdays [8]bool
+	if err := parseCronField(fields[4], 0, 7, weekdays[:]); err != nil {
+		return nil, fmt.Errorf("invalid day of week field: %w", err)
+	}
+	copy(schedule.weekdays[:], weekdays[:7])
+	schedule.weekdays[0] = schedule.weekdays[0] || weekdays[7]
+
+	return schedule, nil
+}
+
+func parseCronField(field string, min, max int, values []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[index+1:])
+			if err != nil || step <= 0 {
+				return fmt.Errorf("invalid step %q", part)
+			}
+			part = part[:index]
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return fmt.Errorf("invalid range %q", part)
+			}
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return fmt.Errorf("invalid value %q", part)
+			}
+			start, end = value, value
+			if step > 1 {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return fmt.Errorf("value out of range %q", part)
+		}
+
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+
+	return nil
+}
+
+// Next returns the first time after t that matches the schedule, or the zero
+// time when nothing matches within the next five years
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	location := t.Location()
+
+	for t.Before(limit) {
+		if !s.months[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, location)
+			continue
+		}
+
+		if !s.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	dayMatch := s.days[t.Day()]
+	weekMatch := s.weekdays[t.Weekday()]
+
+	// Like cron, when both day fields are restricted either one can match
+	if !s.anyDay && !s.anyWeek {
+		return dayMatch || weekMatch
+	}
+
+	return dayMatch && weekMatch
+}
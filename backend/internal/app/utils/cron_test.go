@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronRejects(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"1,,2 * * * *",
+		"a * * * *",
+		"@often",
+	}
+
+	for _, expression := range tests {
+		if _, err := ParseCron(expression); err == nil {
+			t.Errorf("ParseCron(%q) accepted", expression)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2024-01-01 is a monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.January, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		expression string
+		after      time.Time
+		want       time.Time
+	}{
+		{"* * * * *", at(1, 10, 7).Add(30 * time.Second), at(1, 10, 8)},
+		{"5 * * * *", at(1, 10, 5), at(1, 11, 5)},
+		{"*/15 * * * *", at(1, 10, 7), at(1, 10, 15)},
+		{"10/20 * * * *", at(1, 10, 31), at(1, 10, 50)},
+		{"0 9-17/4 * * *", at(1, 10, 0), at(1, 13, 0)},
+		{"0 9-11 * * *", at(1, 11, 0), at(2, 9, 0)},
+		{"15,45 8 * * *", at(1, 8, 15), at(1, 8, 45)},
+		{"30 8 * * 1,3,5", at(1, 9, 0), at(3, 8, 30)},
+		{"0 0 * * 1-5", at(5, 12, 0), at(8, 0, 0)},
+		{"0 0 * * 0", at(1, 0, 0), at(7, 0, 0)},
+		{"0 0 * * 7", at(1, 0, 0), at(7, 0, 0)},
+		{"0 12 1 * *", at(1, 12, 0), time.Date(2024, time.February, 1, 12, 0, 0, 0, time.UTC)},
+		// Day of month and day of week restricted, either one matches
+		{"0 0 13 * 5", at(1, 0, 0), at(5, 0, 0)},
+		{"0 0 2 * 5", at(1, 0, 0), at(2, 0, 0)},
+		{"0 0 29 2 *", at(1, 0, 0), time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", at(15, 0, 0), time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", at(1, 0, 0), at(7, 0, 0)},
+		{"0 0 31 2 *", at(1, 0, 0), time.Time{}},
+	}
+
+	for _, test := range tests {
+		schedule, err := ParseCron(test.expression)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", test.expression, err)
+			continue
+		}
+
+		if next := schedule.Next(test.after); !next.Equal(test.want) {
+			t.Errorf("%q after %s = %s, want %s", test.expression, test.after, next, test.want)
+		}
+	}
+}
+
+func TestCronNextKeepsLocation(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	schedule, err := ParseCron("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := schedule.Next(time.Date(2024, time.January, 1, 10, 0, 0, 0, location))
+	if want := time.Date(2024, time.January, 2, 9, 0, 0, 0, location); !next.Equal(want) {
+		t.Errorf("next = %s, want %s", next, want)
+	}
+}
@@ -3,5 +3,26 @@ package utils
 import "errors"
 
 var (
-	ErrEmailOrUsernameTaken = errors.New("email or username already taken")
+	ErrEmailOrUsernameTaken   = errors.New("email or username already taken")
+	ErrPinLimitReached        = errors.New("pin limit reached")
+	ErrInvalidSchedule        = errors.New("invalid schedule")
+	ErrForbidden              = errors.New("forbidden")
+	ErrInvalidWebhook         = errors.New("invalid webhook")
+	ErrInvalidBotToken        = errors.New("invalid bot token")
+	ErrInvalidBot             = errors.New("invalid bot")
+	ErrInvalidCommand         = errors.New("invalid command")
+	ErrInvalidInteraction     = errors.New("invalid interaction")
+	ErrInvalidEmoji           = errors.New("invalid emoji")
+	ErrInvalidConversation    = errors.New("invalid conversation")
+	ErrInvalidRelationship    = errors.New("invalid relationship")
+	ErrInvalidPrivacySettings = errors.New("invalid privacy settings")
+	ErrInvalidAccountToken    = errors.New("invalid or expired token")
+	ErrInvalidPassword        = errors.New("invalid password")
+	ErrInvalidSession         = errors.New("invalid session")
+	ErrRefreshTokenReused     = errors.New("refresh token reused")
+	ErrInvalidMFA             = errors.New("invalid two-factor authentication")
+	ErrMFARequired            = errors.New("two-factor authentication required")
+	ErrInvalidSSO             = errors.New("single sign-on failed")
+	ErrInvalidSlowmode        = errors.New("invalid slowmode")
+	ErrAccountDisabled        = errors.New("account disabled")
 )
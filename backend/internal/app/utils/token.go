@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// GenerateToken returns size random bytes hex encoded
+func GenerateToken(size int) (string, error) {
+	token := make([]byte, size)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// HashToken is used for high entropy tokens that are looked up by their hash,
+// passwords still go through bcrypt
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SignPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>", receivers
+// recompute it with their secret to check the request came from us
+func SignPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -18,9 +18,13 @@ type Client struct {
 	ID                  string
 	Username            string
 	ProfileID           uuid.UUID
+	SessionID           uuid.UUID
 	PeerConnectionState *PeerConnectionState
 	StreamID            string
 	ImageURL            string
+	// blocked are the profiles this client blocked, see Hub.SetBlocked
+	blocked   map[uuid.UUID]bool
+	blockedMu sync.RWMutex
 	sync.Mutex
 	sync.WaitGroup
 }
@@ -39,6 +43,9 @@ type Message struct {
 	Channel  string           `json:"channel,omitempty"`
 	ServerID string           `json:"serverId,omitempty"`
 	Content  ContentInterface `json:"content,omitempty"`
+	// SenderID is the profile that wrote the content, clients that blocked it
+	// do not get the message
+	SenderID uuid.UUID `json:"-"`
 }
 
 type ClientMessage struct {
@@ -58,8 +65,20 @@ const (
 	pongWait       = 60 * time.Second
 	writeWait      = 10 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
+	// A profile may send SOCKET_RATE_LIMIT messages in a burst over all of its
+	// connections, refilled over SOCKET_RATE_PERIOD. The connection that sends
+	// more is closed
+	SOCKET_RATE_LIMIT  = 50
+	SOCKET_RATE_PERIOD = 10 * time.Second
 )
 
+// closeRateLimited tells the peer why the connection is being closed, the
+// caller closes it
+func closeRateLimited(conn *websocket.Conn) {
+	message := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limited")
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+}
+
 var (
 	newline = []byte{'\n'}
 	space   = []byte{' '}
@@ -72,10 +91,13 @@ var upgrader = websocket.Upgrader{
 
 func (c *Client) ReadPump() {
 	defer func() {
-		c.Hub.Unregister <- c
+		select {
+		case c.Hub.Unregister <- c:
+		case <-c.Hub.done:
+		}
 		c.Conn.Close()
-		if c.PeerConnectionState != nil {
-			c.PeerConnectionState.closePeerConnection()
+		if peer := c.currentPeer(); peer != nil {
+			peer.closePeerConnection()
 		}
 	}()
 
@@ -95,6 +117,12 @@ func (c *Client) ReadPump() {
 			break
 		}
 
+		if allowed, _ := c.Hub.Limiter.Allow(c.ProfileID.String()); !allowed {
+			log.Printf("Client %s sent too many messages, closing", c.ID)
+			closeRateLimited(c.Conn)
+			break
+		}
+
 		c.Add(1)
 		switch msg.Type {
 		case "joined":
@@ -141,38 +169,41 @@ func (c *Client) ReadPump() {
 				}
 			}
 		case "message":
+			msg.SenderID = c.ProfileID
 			c.Hub.BroadcastToChannel(msg)
 		case "initializeCall":
-			if c.PeerConnectionState == nil {
+			if peer := c.currentPeer(); peer == nil {
 				log.Printf("Client %s in Server %s initializeCall", msg.Channel, msg.ServerID)
 				webrtcMsg := msg.Content.(WebRTCMessage)
 				c.StreamID = webrtcMsg.StreamID
-				c.PeerConnectionState, err = NewPeerConnectionState(c, msg.ServerID, msg.Channel)
+				peer, err = NewPeerConnectionState(c, msg.ServerID, msg.Channel)
+				c.setPeer(peer)
 				if err != nil {
 					log.Println("Error creating PeerConnection:", err)
 					continue
 				}
-			} else if c.PeerConnectionState.currentChannel != msg.Channel {
-				log.Printf("Client %s changing channel from %s to %s", c.ID, c.PeerConnectionState.currentChannel, msg.Channel)
+			} else if peer.currentChannel != msg.Channel {
+				log.Printf("Client %s changing channel from %s to %s", c.ID, peer.currentChannel, msg.Channel)
 				webrtcMsg := msg.Content.(WebRTCMessage)
 				c.StreamID = webrtcMsg.StreamID
-				c.PeerConnectionState, err = c.ChangeChannel(msg.ServerID, msg.Channel)
+				peer, err = c.ChangeChannel(msg.ServerID, msg.Channel)
+				c.setPeer(peer)
 				if err != nil {
 					log.Println("Error changing channel:", err)
 					continue
 				}
 			}
 		case "answer":
-			if c.PeerConnectionState != nil {
+			if peer := c.currentPeer(); peer != nil {
 				webrtcMsg := msg.Content.(WebRTCMessage)
-				if err := c.PeerConnectionState.SetRemoteDescription(webrtcMsg.Answer); err != nil {
+				if err := peer.SetRemoteDescription(webrtcMsg.Answer); err != nil {
 					log.Println("Failed to set remote description:", err)
 				}
 			}
 		case "candidate":
-			if c.PeerConnectionState != nil {
+			if peer := c.currentPeer(); peer != nil {
 				webrtcMsg := msg.Content.(WebRTCMessage)
-				if err := c.PeerConnectionState.AddICECandidate(webrtcMsg.Candidate); err != nil {
+				if err := peer.AddICECandidate(webrtcMsg.Candidate); err != nil {
 					log.Println("Failed to add ICE candidate:", err)
 				}
 
@@ -191,9 +222,9 @@ func (c *Client) ReadPump() {
 				}
 			}
 		case "leave":
-			if c.PeerConnectionState != nil {
-				c.PeerConnectionState.closePeerConnection()
-				c.PeerConnectionState = nil
+			if peer := c.currentPeer(); peer != nil {
+				peer.closePeerConnection()
+				c.clearPeer(peer)
 			}
 		default:
 			log.Printf("Unknown message type received: %v", msg.Type)
@@ -210,6 +241,7 @@ func (c *Client) WritePump() {
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
+		c.Hub.writers.Done()
 	}()
 
 	for {
@@ -218,7 +250,7 @@ func (c *Client) WritePump() {
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				log.Printf("Closing Send channel for client %s", c.ID)
-				// c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 
@@ -254,6 +286,54 @@ func (c *Client) WritePump() {
 	}
 }
 
+// currentPeer returns the voice connection of the client, it is also cleared
+// by the hub when a server goes away so it is only read under the lock
+func (c *Client) currentPeer() *PeerConnectionState {
+	c.Lock()
+	defer c.Unlock()
+	return c.PeerConnectionState
+}
+
+func (c *Client) setPeer(peer *PeerConnectionState) {
+	c.Lock()
+	defer c.Unlock()
+	c.PeerConnectionState = peer
+}
+
+// clearPeer forgets peer unless the client already moved to another one
+func (c *Client) clearPeer(peer *PeerConnectionState) {
+	c.Lock()
+	defer c.Unlock()
+	if c.PeerConnectionState == peer {
+		c.PeerConnectionState = nil
+	}
+}
+
+func (c *Client) setBlocked(profileID uuid.UUID, blocked bool) {
+	c.blockedMu.Lock()
+	defer c.blockedMu.Unlock()
+	if !blocked {
+		delete(c.blocked, profileID)
+		return
+	}
+
+	if c.blocked == nil {
+		c.blocked = make(map[uuid.UUID]bool)
+	}
+	c.blocked[profileID] = true
+}
+
+// skips reports whether msg was written by a profile this client blocked
+func (c *Client) skips(msg Message) bool {
+	if msg.SenderID == uuid.Nil {
+		return false
+	}
+
+	c.blockedMu.RLock()
+	defer c.blockedMu.RUnlock()
+	return c.blocked[msg.SenderID]
+}
+
 func (c *Client) WriteJSON(v interface{}) error {
 	c.Lock()
 	defer c.Unlock()
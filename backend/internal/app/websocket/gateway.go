@@ -0,0 +1,321 @@
+package websocket
+
+import (
+	"context"
+	"discord-backend/internal/app/events"
+	"discord-backend/internal/app/ratelimit"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const gatewaySendBuffer = 256
+
+// GatewayPayload is the envelope of every frame on the bot gateway, Op is one
+// of ready, dispatch, heartbeat_ack or reconnect and Type carries the event
+// type for dispatch
+type GatewayPayload struct {
+	Op       string      `json:"op"`
+	Type     string      `json:"t,omitempty"`
+	Sequence int64       `json:"s,omitempty"`
+	ServerID string      `json:"serverId,omitempty"`
+	Data     interface{} `json:"d,omitempty"`
+}
+
+type GatewayClient struct {
+	Gateway   *Gateway
+	Conn      *websocket.Conn
+	Send      chan GatewayPayload
+	ProfileID uuid.UUID
+	TokenID   uuid.UUID
+	servers   map[uuid.UUID]bool
+	sequence  int64
+	sync.Mutex
+}
+
+// Gateway streams server events from the event bus to bots, unlike the Hub it
+// has no channel subscriptions, a bot receives every event of its servers
+type Gateway struct {
+	clients   map[*GatewayClient]bool
+	ServerIDs func(profileID uuid.UUID) ([]uuid.UUID, error)
+	// Limiter counts the frames of each bot over its connections, reconnecting
+	// does not refill it
+	Limiter *ratelimit.Limiter
+	writers sync.WaitGroup
+	// droppedMessages counts events a bot was too slow to take
+	droppedMessages atomic.Uint64
+	sync.RWMutex
+}
+
+// GatewayStats is a snapshot of the gateway for the metrics
+type GatewayStats struct {
+	Clients         int
+	DroppedMessages uint64
+}
+
+func NewGateway(serverIDs func(profileID uuid.UUID) ([]uuid.UUID, error)) *Gateway {
+	return &Gateway{
+		clients:   make(map[*GatewayClient]bool),
+		ServerIDs: serverIDs,
+		Limiter:   ratelimit.NewLimiter(SOCKET_RATE_LIMIT, SOCKET_RATE_PERIOD),
+	}
+}
+
+func (g *Gateway) Connect(conn *websocket.Conn, profileID, tokenID uuid.UUID, profile interface{}) error {
+	client := &GatewayClient{
+		Gateway:   g,
+		Conn:      conn,
+		Send:      make(chan GatewayPayload, gatewaySendBuffer),
+		ProfileID: profileID,
+		TokenID:   tokenID,
+	}
+
+	if err := client.refreshServers(); err != nil {
+		return err
+	}
+
+	client.Lock()
+	serverIDs := make([]uuid.UUID, 0, len(client.servers))
+	for serverID := range client.servers {
+		serverIDs = append(serverIDs, serverID)
+	}
+	client.Unlock()
+
+	client.Send <- GatewayPayload{
+		Op: "ready",
+		Data: map[string]interface{}{
+			"profile": profile,
+			"servers": serverIDs,
+		},
+	}
+
+	// Registered after ready is queued so no dispatch goes out before it
+	g.Lock()
+	g.clients[client] = true
+	g.Unlock()
+
+	g.writers.Add(1)
+	go client.writePump()
+	go client.readPump()
+
+	return nil
+}
+
+func (g *Gateway) disconnect(client *GatewayClient) {
+	g.Lock()
+	if _, ok := g.clients[client]; ok {
+		delete(g.clients, client)
+		close(client.Send)
+	}
+	g.Unlock()
+}
+
+// DisconnectToken closes every connection opened with a revoked token
+func (g *Gateway) DisconnectToken(tokenID uuid.UUID) {
+	g.Lock()
+	defer g.Unlock()
+
+	for client := range g.clients {
+		if client.TokenID == tokenID {
+			delete(g.clients, client)
+			close(client.Send)
+		}
+	}
+}
+
+// Shutdown asks every bot to reconnect and returns once the reconnect frames
+// are written and the connections closed, or when ctx is done
+func (g *Gateway) Shutdown(ctx context.Context) error {
+	g.Lock()
+	for client := range g.clients {
+		select {
+		case client.Send <- GatewayPayload{Op: "reconnect"}:
+		default:
+		}
+		delete(g.clients, client)
+		close(client.Send)
+	}
+	g.Unlock()
+
+	closed := make(chan struct{})
+	go func() {
+		g.writers.Wait()
+		close(closed)
+	}()
+
+	return wait(ctx, closed)
+}
+
+func (g *Gateway) Stats() GatewayStats {
+	g.RLock()
+	defer g.RUnlock()
+
+	return GatewayStats{Clients: len(g.clients), DroppedMessages: g.droppedMessages.Load()}
+}
+
+// Dispatch is subscribed to the event bus
+func (g *Gateway) Dispatch(event events.Event) {
+	g.RLock()
+	clients := make([]*GatewayClient, 0, len(g.clients))
+	for client := range g.clients {
+		clients = append(clients, client)
+	}
+	g.RUnlock()
+
+	// A bot that just joined gets its own join event, a bot that left or was
+	// kicked still gets the event that removed it
+	if event.Type == events.MemberJoined {
+		g.setServer(clients, event.ProfileID, event.ServerID, true)
+	}
+
+	for _, client := range clients {
+		client.Lock()
+		inServer := client.servers[event.ServerID]
+		if inServer {
+			client.sequence++
+		}
+		sequence := client.sequence
+		client.Unlock()
+
+		if !inServer {
+			continue
+		}
+
+		payload := GatewayPayload{
+			Op:       "dispatch",
+			Type:     string(event.Type),
+			Sequence: sequence,
+			ServerID: event.ServerID.String(),
+			Data:     event.Data,
+		}
+
+		g.RLock()
+		_, connected := g.clients[client]
+		if connected {
+			select {
+			case client.Send <- payload:
+			default:
+				// Slow consumers are dropped instead of blocking the publisher
+				log.Printf("Gateway client %s is too slow, disconnecting", client.ProfileID)
+				g.droppedMessages.Add(1)
+				go g.disconnect(client)
+			}
+		}
+		g.RUnlock()
+	}
+
+	if event.Type == events.MemberLeft || event.Type == events.MemberKicked {
+		g.setServer(clients, event.ProfileID, event.ServerID, false)
+	}
+}
+
+// setServer adds or removes the server of a member event on the connections
+// of the bot it is about, the servers are only read from the database on connect
+func (g *Gateway) setServer(clients []*GatewayClient, profileID, serverID uuid.UUID, joined bool) {
+	for _, client := range clients {
+		if client.ProfileID != profileID {
+			continue
+		}
+
+		client.Lock()
+		if joined {
+			client.servers[serverID] = true
+		} else {
+			delete(client.servers, serverID)
+		}
+		client.Unlock()
+	}
+}
+
+func (c *GatewayClient) refreshServers() error {
+	serverIDs, err := c.Gateway.ServerIDs(c.ProfileID)
+	if err != nil {
+		return err
+	}
+
+	servers := make(map[uuid.UUID]bool, len(serverIDs))
+	for _, serverID := range serverIDs {
+		servers[serverID] = true
+	}
+
+	c.Lock()
+	c.servers = servers
+	c.Unlock()
+
+	return nil
+}
+
+func (c *GatewayClient) readPump() {
+	defer func() {
+		c.Gateway.disconnect(c)
+		c.Conn.Close()
+	}()
+
+	c.Conn.SetReadLimit(maxMessageSize)
+	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.Conn.SetPongHandler(func(string) error { c.Conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+
+	for {
+		var payload GatewayPayload
+		if err := c.Conn.ReadJSON(&payload); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("Gateway unexpected close error: %v", err)
+			}
+			return
+		}
+
+		if allowed, _ := c.Gateway.Limiter.Allow(c.ProfileID.String()); !allowed {
+			closeRateLimited(c.Conn)
+			return
+		}
+
+		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		switch payload.Op {
+		case "heartbeat":
+			c.Gateway.RLock()
+			if _, ok := c.Gateway.clients[c]; ok {
+				select {
+				case c.Send <- GatewayPayload{Op: "heartbeat_ack"}:
+				default:
+				}
+			}
+			c.Gateway.RUnlock()
+		default:
+			log.Printf("Unknown gateway op received: %v", payload.Op)
+		}
+	}
+}
+
+func (c *GatewayClient) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Conn.Close()
+		c.Gateway.writers.Done()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-c.Send:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := c.Conn.WriteJSON(payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
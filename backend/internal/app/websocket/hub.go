@@ -1,43 +1,125 @@
 package websocket
 
 import (
+	"context"
+	"discord-backend/internal/app/ratelimit"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/pion/webrtc/v3"
 	pionwebrtc "github.com/pion/webrtc/v3"
 )
 
+// hubQueueSize is how many outgoing messages wait for the hub loop before
+// their senders block
+const hubQueueSize = 256
+
+var ErrHubStopped = errors.New("hub stopped")
+
+type ServerTeardown struct {
+	ServerID   string
+	ChannelIDs []string
+}
+
+// ProfileMessage is delivered to every connection of a single profile
+// instead of a channel, like ephemeral interaction replies
+type ProfileMessage struct {
+	ProfileID uuid.UUID
+	Message   Message
+}
+
+// SessionClose disconnects the sockets of some sessions of a profile, or all
+// of its sockets when SessionIDs is empty
+type SessionClose struct {
+	ProfileID  uuid.UUID
+	SessionIDs []uuid.UUID
+}
+
 type Hub struct {
 	Clients         map[*Client]bool
 	BroadcastServer chan Message
 	Broadcast       chan Message
 	ClientMessage   chan ClientMessage
+	SendProfile     chan ProfileMessage
 	Register        chan *Client
 	RegisterServer  chan ClientMessage
 	Unregister      chan *Client
 	UnregisterPeer  chan *PeerConnectionState
+	CloseServer     chan ServerTeardown
+	CloseSessions   chan SessionClose
 	Channels        map[string]map[*Client]bool
 	Servers         map[string]map[*Client]bool
 	PeerChannels    map[string]map[string]map[*PeerConnectionState]bool
 	TrackChannels   map[string]map[string]*pionwebrtc.TrackLocalStaticRTP
+	// ICEServers are handed to every peer connection, the TURN relay
+	ICEServers []webrtc.ICEServer
+	// Limiter counts the messages of each profile over its connections,
+	// reconnecting does not refill it
+	Limiter *ratelimit.Limiter
+	// OnPresence is told when the first connection of a profile opens and when
+	// its last one closes, it never runs on the hub loop
+	OnPresence func(profileID uuid.UUID, online bool)
+	// BlockedProfiles lists the profiles a profile blocked, their messages are
+	// not sent to its clients
+	BlockedProfiles func(profileID uuid.UUID) ([]uuid.UUID, error)
+	online          map[uuid.UUID]map[*Client]bool
+	onlineMu        sync.RWMutex
+	// stop ends the hub loop, done is closed once it has ended
+	stop chan struct{}
+	done chan struct{}
+	// writers are the write pumps of the registered clients
+	writers       sync.WaitGroup
+	statsRequests chan chan HubStats
+	// droppedMessages counts messages a client was too slow to take, it is
+	// disconnected for it
+	droppedMessages atomic.Uint64
+	rtpPackets      atomic.Uint64
 	sync.RWMutex
 }
 
+// HubStats is a snapshot of the hub for the metrics
+type HubStats struct {
+	Clients int
+	// Subscriptions counts the clients subscribed to each channel
+	Subscriptions map[string]int
+	// Queues is how many messages wait for the hub loop in each queue
+	Queues map[string]int
+	// PeerConnections and Tracks are counted per voice channel
+	PeerConnections     map[string]int
+	Tracks              map[string]int
+	DroppedMessages     uint64
+	RTPPacketsForwarded uint64
+}
+
 func NewHub() *Hub {
 	return &Hub{
-		BroadcastServer: make(chan Message),
-		Broadcast:       make(chan Message),
-		ClientMessage:   make(chan ClientMessage),
+		BroadcastServer: make(chan Message, hubQueueSize),
+		Broadcast:       make(chan Message, hubQueueSize),
+		ClientMessage:   make(chan ClientMessage, hubQueueSize),
+		SendProfile:     make(chan ProfileMessage, hubQueueSize),
 		Register:        make(chan *Client),
 		RegisterServer:  make(chan ClientMessage),
 		Unregister:      make(chan *Client),
 		UnregisterPeer:  make(chan *PeerConnectionState),
+		CloseServer:     make(chan ServerTeardown),
+		CloseSessions:   make(chan SessionClose),
 		Clients:         make(map[*Client]bool),
 		Channels:        make(map[string]map[*Client]bool),
 		Servers:         make(map[string]map[*Client]bool),
 		PeerChannels:    make(map[string]map[string]map[*PeerConnectionState]bool),
 		TrackChannels:   make(map[string]map[string]*webrtc.TrackLocalStaticRTP),
+		online:          make(map[uuid.UUID]map[*Client]bool),
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+		statsRequests:   make(chan chan HubStats),
+		Limiter:         ratelimit.NewLimiter(SOCKET_RATE_LIMIT, SOCKET_RATE_PERIOD),
 	}
 }
 
@@ -45,7 +127,10 @@ func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.Register:
+			// Every registered client starts a write pump, shutdown waits for it
+			h.writers.Add(1)
 			h.Clients[client] = true
+			h.setPresence(client, true)
 		case client := <-h.Unregister:
 			if _, ok := h.Clients[client]; ok {
 				log.Printf("Closing Client : %s", client.ID)
@@ -57,6 +142,16 @@ func (h *Hub) Run() {
 			if _, ok := h.PeerChannels[peer.currentServer][peer.currentChannel][peer]; ok {
 				delete(h.PeerChannels[peer.currentServer][peer.currentChannel], peer)
 			}
+		case teardown := <-h.CloseServer:
+			h.closeServer(teardown)
+		case sessionClose := <-h.CloseSessions:
+			h.closeSessions(sessionClose)
+		case reply := <-h.statsRequests:
+			reply <- h.loopStats()
+		case <-h.stop:
+			h.closeClients()
+			close(h.done)
+			return
 		case clientMessage := <-h.RegisterServer:
 			if _, ok := h.Servers[clientMessage.ServerID]; !ok {
 				h.Servers[clientMessage.ServerID] = make(map[*Client]bool)
@@ -66,17 +161,22 @@ func (h *Hub) Run() {
 		case message := <-h.Broadcast:
 			subscribers := h.Channels[message.Channel]
 			for client := range subscribers {
+				if client.skips(message) {
+					continue
+				}
+
 				select {
 				case client.Send <- message:
 				default:
 					log.Printf("Closing Broadcasting to channel : %s", message.Channel)
+					h.droppedMessages.Add(1)
 					close(client.Send)
 					delete(h.Clients, client)
 					h.cleanupClient(client)
 				}
 			}
 		case message := <-h.BroadcastServer:
-			log.Println("Broadcasting to server : %s", message.ServerID)
+			log.Printf("Broadcasting to server : %s", message.ServerID)
 			for client := range h.Servers[message.ServerID] {
 				client.Add(1)
 				go func(client *Client) {
@@ -84,7 +184,8 @@ func (h *Hub) Run() {
 					select {
 					case client.Send <- message:
 					default:
-						log.Println("Closing Broadcasting to server : %s", message.ServerID)
+						log.Printf("Closing Broadcasting to server : %s", message.ServerID)
+						h.droppedMessages.Add(1)
 						client.Wait()
 						close(client.Send)
 						delete(h.Clients, client)
@@ -94,7 +195,7 @@ func (h *Hub) Run() {
 				// select {
 				// case client.Send <- message:
 				// default:
-				// 	log.Println("Closing Broadcasting to server : %s", message.ServerID)
+				// 	log.Printf("Closing Broadcasting to server : %s", message.ServerID)
 				// 	close(client.Send)
 				// 	delete(h.Clients, client)
 				// 	h.cleanupClient(client)
@@ -109,6 +210,7 @@ func (h *Hub) Run() {
 				case client.Send <- clientMessage.Message:
 				default:
 					log.Printf("Closing Client : %s", client.ID)
+					h.droppedMessages.Add(1)
 					client.Wait()
 					close(client.Send)
 					delete(h.Clients, client)
@@ -123,11 +225,305 @@ func (h *Hub) Run() {
 			// 	delete(h.Clients, client)
 			// 	h.cleanupClient(client)
 			// }
+		case profileMessage := <-h.SendProfile:
+			for client := range h.Clients {
+				if client.ProfileID != profileMessage.ProfileID {
+					continue
+				}
+
+				select {
+				case client.Send <- profileMessage.Message:
+				default:
+					log.Printf("Closing Client : %s", client.ID)
+					h.droppedMessages.Add(1)
+					close(client.Send)
+					delete(h.Clients, client)
+					h.cleanupClient(client)
+				}
+			}
+		}
+	}
+}
+
+func (h *Hub) SendToProfile(profileID uuid.UUID, msg Message) {
+	select {
+	case h.SendProfile <- ProfileMessage{ProfileID: profileID, Message: msg}:
+	case <-h.done:
+	}
+}
+
+// DisconnectSessions closes the sockets opened with the given sessions, all
+// sockets of the profile when none are given
+func (h *Hub) DisconnectSessions(profileID uuid.UUID, sessionIDs ...uuid.UUID) {
+	select {
+	case h.CloseSessions <- SessionClose{ProfileID: profileID, SessionIDs: sessionIDs}:
+	case <-h.done:
+	}
+}
+
+// Shutdown closes every voice connection, asks every client to reconnect,
+// which during a deploy lands them on another instance, and stops the hub
+// loop. It returns once the write pumps have sent what was queued, or when
+// ctx is done. New clients must not connect anymore
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.Lock()
+	var peers []*PeerConnectionState
+	for _, channels := range h.PeerChannels {
+		for _, channelPeers := range channels {
+			for peer := range channelPeers {
+				peers = append(peers, peer)
+			}
+		}
+	}
+	h.Unlock()
+
+	// Closing a peer connection talks back to the hub loop, so the loop keeps
+	// running until they are closed
+	peersClosed := make(chan struct{})
+	go func() {
+		for _, peer := range peers {
+			peer.client.clearPeer(peer)
+			peer.closePeerConnection()
 		}
+		close(peersClosed)
+	}()
+
+	if err := wait(ctx, peersClosed); err != nil {
+		return err
+	}
+
+	select {
+	case h.stop <- struct{}{}:
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if err := wait(ctx, h.done); err != nil {
+		return err
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(flushed)
+	}()
+
+	return wait(ctx, flushed)
+}
+
+// Stats asks the hub loop for a snapshot, an answer also proves the loop is
+// running
+func (h *Hub) Stats(ctx context.Context) (HubStats, error) {
+	reply := make(chan HubStats, 1)
+	select {
+	case h.statsRequests <- reply:
+	case <-h.done:
+		return HubStats{}, ErrHubStopped
+	case <-ctx.Done():
+		return HubStats{}, ctx.Err()
+	}
+
+	var stats HubStats
+	select {
+	case stats = <-reply:
+	case <-ctx.Done():
+		return HubStats{}, ctx.Err()
+	}
+
+	// Voice state is guarded by the lock, which the loop must not wait for as
+	// closing a peer connection holds it while talking to the loop
+	h.RLock()
+	stats.PeerConnections = make(map[string]int)
+	for _, channels := range h.PeerChannels {
+		for channel, peers := range channels {
+			if len(peers) > 0 {
+				stats.PeerConnections[channel] += len(peers)
+			}
+		}
+	}
+
+	stats.Tracks = make(map[string]int)
+	for channel, tracks := range h.TrackChannels {
+		if len(tracks) > 0 {
+			stats.Tracks[channel] = len(tracks)
+		}
+	}
+	h.RUnlock()
+
+	stats.DroppedMessages = h.droppedMessages.Load()
+	stats.RTPPacketsForwarded = h.rtpPackets.Load()
+	return stats, nil
+}
+
+// loopStats reads what only the hub loop may touch
+func (h *Hub) loopStats() HubStats {
+	stats := HubStats{
+		Clients:       len(h.Clients),
+		Subscriptions: make(map[string]int),
+		Queues: map[string]int{
+			"broadcast":        len(h.Broadcast),
+			"broadcast_server": len(h.BroadcastServer),
+			"client_message":   len(h.ClientMessage),
+			"send_profile":     len(h.SendProfile),
+		},
+	}
+
+	for channel, clients := range h.Channels {
+		if len(clients) > 0 {
+			stats.Subscriptions[channel] = len(clients)
+		}
+	}
+
+	return stats
+}
+
+// Done is closed once the hub loop has stopped
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
+func wait(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeClients runs on the hub loop as it stops. Each client gets a reconnect
+// message, its write pump sends it and closes the connection once Send is
+// closed
+func (h *Hub) closeClients() {
+	// The clients are moving to another instance, not going offline
+	h.OnPresence = nil
+
+	for client := range h.Clients {
+		delete(h.Clients, client)
+		h.cleanupClient(client)
+
+		go func(client *Client) {
+			select {
+			case client.Send <- Message{Type: "reconnect"}:
+			case <-time.After(writeWait):
+			}
+			close(client.Send)
+		}(client)
+	}
+}
+
+// closeSessions only closes the connections, the read pump of each client then
+// unregisters it like any other disconnect
+func (h *Hub) closeSessions(sessionClose SessionClose) {
+	revoked := make(map[uuid.UUID]bool, len(sessionClose.SessionIDs))
+	for _, sessionID := range sessionClose.SessionIDs {
+		revoked[sessionID] = true
+	}
+
+	for client := range h.Clients {
+		if client.ProfileID != sessionClose.ProfileID {
+			continue
+		}
+
+		if len(revoked) > 0 && !revoked[client.SessionID] {
+			continue
+		}
+
+		client.Conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked"),
+			time.Now().Add(writeWait))
+		client.Conn.Close()
+	}
+}
+
+// LoadBlocked fills the profiles the client blocked before it registers
+func (h *Hub) LoadBlocked(client *Client) error {
+	if h.BlockedProfiles == nil {
+		return nil
+	}
+
+	blockedIDs, err := h.BlockedProfiles(client.ProfileID)
+	if err != nil {
+		return err
+	}
+
+	for _, blockedID := range blockedIDs {
+		client.setBlocked(blockedID, true)
+	}
+	return nil
+}
+
+// SetBlocked updates every open connection of profileID after it blocked or
+// unblocked targetID
+func (h *Hub) SetBlocked(profileID, targetID uuid.UUID, blocked bool) {
+	h.onlineMu.RLock()
+	defer h.onlineMu.RUnlock()
+
+	for client := range h.online[profileID] {
+		client.setBlocked(targetID, blocked)
+	}
+}
+
+// ConnectedSessions maps the session of every open connection to its profile,
+// connections without a session are left out
+func (h *Hub) ConnectedSessions() map[uuid.UUID]uuid.UUID {
+	h.onlineMu.RLock()
+	defer h.onlineMu.RUnlock()
+
+	sessions := make(map[uuid.UUID]uuid.UUID)
+	for profileID, clients := range h.online {
+		for client := range clients {
+			if client.SessionID != uuid.Nil {
+				sessions[client.SessionID] = profileID
+			}
+		}
+	}
+	return sessions
+}
+
+// IsOnline reports whether the profile has at least one open connection
+func (h *Hub) IsOnline(profileID uuid.UUID) bool {
+	h.onlineMu.RLock()
+	defer h.onlineMu.RUnlock()
+
+	return len(h.online[profileID]) > 0
+}
+
+// setPresence tracks the connections of each profile, removing a client twice
+// is fine as it can be dropped from several places
+func (h *Hub) setPresence(client *Client, connected bool) {
+	if client.ProfileID == uuid.Nil {
+		return
+	}
+
+	h.onlineMu.Lock()
+	clients := h.online[client.ProfileID]
+	before := len(clients)
+
+	if connected {
+		if clients == nil {
+			clients = make(map[*Client]bool)
+			h.online[client.ProfileID] = clients
+		}
+		clients[client] = true
+	} else {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(h.online, client.ProfileID)
+		}
+	}
+
+	after := len(clients)
+	h.onlineMu.Unlock()
+
+	if h.OnPresence != nil && (before == 0) != (after == 0) {
+		go h.OnPresence(client.ProfileID, after > 0)
 	}
 }
 
 func (h *Hub) cleanupClient(client *Client) {
+	h.setPresence(client, false)
 	for channel := range h.Channels {
 		delete(h.Channels[channel], client)
 	}
@@ -136,18 +532,70 @@ func (h *Hub) cleanupClient(client *Client) {
 	}
 }
 
+// closeServer drops every subscription and voice session that belongs to a deleted server
+func (h *Hub) closeServer(teardown ServerTeardown) {
+	h.Lock()
+	var peers []*PeerConnectionState
+	for _, channelPeers := range h.PeerChannels[teardown.ServerID] {
+		for peer := range channelPeers {
+			peers = append(peers, peer)
+		}
+	}
+	delete(h.PeerChannels, teardown.ServerID)
+
+	for _, channelID := range teardown.ChannelIDs {
+		delete(h.TrackChannels, channelID)
+	}
+	h.Unlock()
+
+	for _, channelID := range teardown.ChannelIDs {
+		prefix := fmt.Sprintf("chat:%s:", channelID)
+		for channel := range h.Channels {
+			if strings.HasPrefix(channel, prefix) {
+				delete(h.Channels, channel)
+			}
+		}
+	}
+
+	for client := range h.Servers[teardown.ServerID] {
+		select {
+		case client.Send <- Message{Type: "server:deleted", ServerID: teardown.ServerID}:
+		default:
+			log.Printf("Closing Client : %s", client.ID)
+			h.droppedMessages.Add(1)
+			close(client.Send)
+			delete(h.Clients, client)
+			h.cleanupClient(client)
+		}
+	}
+	delete(h.Servers, teardown.ServerID)
+
+	// Closing a peer connection talks back to the hub loop, so it cannot run on it
+	go func() {
+		for _, peer := range peers {
+			peer.client.clearPeer(peer)
+			peer.closePeerConnection()
+		}
+	}()
+
+	log.Printf("Closed server %s", teardown.ServerID)
+}
+
 func (h *Hub) BroadcastToChannel(msg Message) {
 	if clients, ok := h.Channels[msg.Channel]; ok {
 		for client := range clients {
+			if client.skips(msg) {
+				continue
+			}
+
 			select {
 			case client.Send <- msg:
 			default:
 				log.Println("BroadcastToChannel cause CLOSE")
+				h.droppedMessages.Add(1)
 				close(client.Send)
 				delete(h.Clients, client)
-				for ch := range h.Channels {
-					delete(h.Channels[ch], client)
-				}
+				h.cleanupClient(client)
 			}
 		}
 	} else {
@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+)
+
+func TestShutdownClosesPeerConnections(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	var peers []*PeerConnectionState
+	for _, channel := range []string{"voice-1", "voice-2"} {
+		client := &Client{Hub: hub, Send: make(chan Message, 1), ID: channel, ProfileID: uuid.New()}
+		peer, err := NewPeerConnectionState(client, "server", channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.setPeer(peer)
+		peers = append(peers, peer)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, peer := range peers {
+		if state := peer.peerConnection.ConnectionState(); state != webrtc.PeerConnectionStateClosed {
+			t.Errorf("peer connection of %s is %s", peer.currentChannel, state)
+		}
+		if peer.client.currentPeer() != nil {
+			t.Errorf("client %s still holds its peer connection", peer.client.ID)
+		}
+	}
+}
+
+// A client whose write pump never runs keeps the hub from flushing, shutdown
+// gives up when the context ends
+func TestShutdownTimeout(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	hub.Register <- &Client{Hub: hub, Send: make(chan Message), ID: "stuck", ProfileID: uuid.New()}
+
+	const timeout = 200 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := hub.Shutdown(ctx)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the deadline", err)
+	}
+	if elapsed < timeout || elapsed > timeout+time.Second {
+		t.Errorf("shutdown took %s with a timeout of %s", elapsed, timeout)
+	}
+}
+
+func TestBroadcastSkipsBlockedSender(t *testing.T) {
+	sender, other := uuid.New(), uuid.New()
+
+	hub := NewHub()
+	hub.BlockedProfiles = func(uuid.UUID) ([]uuid.UUID, error) { return []uuid.UUID{sender}, nil }
+
+	blocking := &Client{Hub: hub, Send: make(chan Message, 4), ID: "blocking", ProfileID: uuid.New()}
+	if err := hub.LoadBlocked(blocking); err != nil {
+		t.Fatal(err)
+	}
+	later := &Client{Hub: hub, Send: make(chan Message, 4), ID: "later", ProfileID: uuid.New()}
+	hub.setPresence(later, true)
+	hub.SetBlocked(later.ProfileID, sender, true)
+
+	hub.Channels["chat"] = map[*Client]bool{blocking: true, later: true}
+
+	hub.BroadcastToChannel(Message{Type: "message", Channel: "chat", SenderID: sender})
+	hub.BroadcastToChannel(Message{Type: "message", Channel: "chat", SenderID: other})
+
+	for _, client := range []*Client{blocking, later} {
+		if got := len(client.Send); got != 1 {
+			t.Fatalf("client %s got %d messages, want 1", client.ID, got)
+		}
+		if msg := <-client.Send; msg.SenderID != other {
+			t.Errorf("client %s got the message of the blocked profile", client.ID)
+		}
+	}
+
+	hub.SetBlocked(later.ProfileID, sender, false)
+	hub.BroadcastToChannel(Message{Type: "message", Channel: "chat", SenderID: sender})
+	if len(later.Send) != 1 || len(blocking.Send) != 0 {
+		t.Errorf("after unblocking got %d and %d messages, want 1 and 0", len(later.Send), len(blocking.Send))
+	}
+}
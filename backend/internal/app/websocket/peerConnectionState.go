@@ -26,13 +26,7 @@ func NewPeerConnectionState(c *Client, serverId string, channel string) (*PeerCo
 	// "stun:stun3.l.google.com:19302",
 	// "stun:stun4.l.google.com:19302",
 	peerConnection, err := webrtc.NewPeerConnection(webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{
-			{
-				URLs:       []string{"turn:turn.jkrn.me:3478"},
-				Username:   "user",
-				Credential: "root",
-			},
-		},
+		ICEServers: c.Hub.ICEServers,
 	})
 	if err != nil {
 		return nil, err
@@ -127,6 +121,7 @@ func NewPeerConnectionState(c *Client, serverId string, channel string) (*PeerCo
 			if _, err = trackLocal.Write(buf[:i]); err != nil {
 				return
 			}
+			c.Hub.rtpPackets.Add(1)
 		}
 	})
 
@@ -174,7 +169,9 @@ func (ps *PeerConnectionState) closePeerConnection() {
 }
 
 func (c *Client) ChangeChannel(newServerId, newChannel string) (*PeerConnectionState, error) {
-	c.PeerConnectionState.closePeerConnection()
+	if peer := c.currentPeer(); peer != nil {
+		peer.closePeerConnection()
+	}
 
 	peerConnectionState, err := NewPeerConnectionState(c, newServerId, newChannel)
 	if err != nil {
@@ -296,6 +293,7 @@ func (ps *PeerConnectionState) initNewPeerConnection(serverId string, channel st
 				log.Printf("Error writing to track: %v", err)
 				return
 			}
+			ps.client.Hub.rtpPackets.Add(1)
 		}
 	})
 
@@ -0,0 +1,46 @@
+package workers
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs the background workers of the server until Stop, each worker
+// finishes what it is doing and returns once its context is cancelled
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewGroup() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// Go runs run in its own goroutine with the context of the group
+func (g *Group) Go(run func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		run(g.ctx)
+	}()
+}
+
+// Stop cancels the workers and waits for them to return, or until ctx is done
+func (g *Group) Stop(ctx context.Context) error {
+	g.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
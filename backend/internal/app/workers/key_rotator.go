@@ -0,0 +1,41 @@
+package workers
+
+import (
+	"context"
+	"discord-backend/internal/app/services"
+	"log"
+	"time"
+)
+
+// KeyRotator creates the next signing key ahead of time and reloads the keys
+// other instances created
+type KeyRotator struct {
+	Interval time.Duration
+}
+
+func NewKeyRotator(interval time.Duration) *KeyRotator {
+	return &KeyRotator{Interval: interval}
+}
+
+func (k *KeyRotator) Run(ctx context.Context) {
+	ticker := time.NewTicker(k.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		rotated, err := services.RotateSigningKeys()
+		if err != nil {
+			log.Printf("Error rotating signing keys: %v", err)
+			continue
+		}
+
+		if rotated {
+			log.Printf("Created the next signing key")
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package workers
+
+import (
+	"context"
+	"discord-backend/internal/app/services"
+	"log"
+	"time"
+)
+
+type Purger struct {
+	RetentionService *services.RetentionService
+	Interval         time.Duration
+	BatchSize        int
+}
+
+func NewPurger(retentionService *services.RetentionService, interval time.Duration) *Purger {
+	return &Purger{
+		RetentionService: retentionService,
+		Interval:         interval,
+		BatchSize:        services.PURGE_BATCH,
+	}
+}
+
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Purger) purge() {
+	// Keep purging in batches until a batch comes back short, so a large backlog
+	// does not hold one huge transaction open
+	for {
+		purged, err := p.RetentionService.PurgeExpiredServers(p.BatchSize)
+		if err != nil {
+			log.Printf("Error purging expired servers: %v", err)
+			break
+		}
+
+		if purged > 0 {
+			log.Printf("Purged %d expired servers", purged)
+		}
+
+		if purged < p.BatchSize {
+			break
+		}
+	}
+
+	for {
+		purged, err := p.RetentionService.PurgeExpiredChannels(p.BatchSize)
+		if err != nil {
+			log.Printf("Error purging expired channels: %v", err)
+			break
+		}
+
+		if purged > 0 {
+			log.Printf("Purged %d expired channels", purged)
+		}
+
+		if purged < p.BatchSize {
+			break
+		}
+	}
+
+	for {
+		purged, err := p.RetentionService.PurgeStaleLinkPreviews(p.BatchSize)
+		if err != nil {
+			log.Printf("Error purging stale link previews: %v", err)
+			break
+		}
+
+		if purged > 0 {
+			log.Printf("Purged %d stale link previews", purged)
+		}
+
+		if purged < p.BatchSize {
+			break
+		}
+	}
+
+	for {
+		purged, err := p.RetentionService.PurgeExpiredAccountTokens(p.BatchSize)
+		if err != nil {
+			log.Printf("Error purging expired account tokens: %v", err)
+			break
+		}
+
+		if purged > 0 {
+			log.Printf("Purged %d expired account tokens", purged)
+		}
+
+		if purged < p.BatchSize {
+			break
+		}
+	}
+
+	for {
+		purged, err := p.RetentionService.PurgeExpiredSessions(p.BatchSize)
+		if err != nil {
+			log.Printf("Error purging expired sessions: %v", err)
+			break
+		}
+
+		if purged > 0 {
+			log.Printf("Purged %d expired sessions", purged)
+		}
+
+		if purged < p.BatchSize {
+			break
+		}
+	}
+	for {
+		purged, err := p.RetentionService.PurgeExpiredSigningKeys(p.BatchSize)
+		if err != nil {
+			log.Printf("Error purging expired signing keys: %v", err)
+			break
+		}
+
+		if purged > 0 {
+			log.Printf("Purged %d expired signing keys", purged)
+		}
+
+		if purged < p.BatchSize {
+			break
+		}
+	}
+}
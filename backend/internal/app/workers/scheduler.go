@@ -0,0 +1,83 @@
+package workers
+
+import (
+	"context"
+	"discord-backend/internal/app/events"
+	"discord-backend/internal/app/services"
+	ws "discord-backend/internal/app/websocket"
+	"fmt"
+	"log"
+	"time"
+
+	// Scheduled messages can use any IANA timezone, so do not depend on the host zoneinfo
+	_ "time/tzdata"
+)
+
+type Scheduler struct {
+	ScheduledMessageService *services.ScheduledMessageService
+	Hub                     *ws.Hub
+	Events                  *events.Bus
+	Interval                time.Duration
+}
+
+func NewScheduler(scheduledMessageService *services.ScheduledMessageService, hub *ws.Hub, bus *events.Bus, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		ScheduledMessageService: scheduledMessageService,
+		Hub:                     hub,
+		Events:                  bus,
+		Interval:                interval,
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.deliverDue(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliverDue delivers one due message at a time until none is left, or until
+// the scheduler is stopped
+func (s *Scheduler) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		delivery, err := s.ScheduledMessageService.DeliverNextDue(time.Now())
+		if err != nil {
+			log.Printf("Error delivering scheduled message: %v", err)
+			return
+		}
+
+		if delivery == nil {
+			return
+		}
+
+		scheduledMessage := delivery.ScheduledMessage
+		switch {
+		case delivery.Message != nil:
+			// Same broadcast as WebSocketMessageHandler
+			s.Hub.BroadcastToChannel(ws.Message{
+				Type:     "message",
+				Channel:  fmt.Sprintf("chat:%s:messages", delivery.Message.ChannelID),
+				Content:  delivery.Message,
+				SenderID: delivery.Message.AuthorProfileID(),
+			})
+			s.Events.Publish(events.MessageCreated, *scheduledMessage.ServerID, delivery.Message)
+		case delivery.DirectMessage != nil:
+			s.Hub.BroadcastToChannel(ws.Message{
+				Type:     "message",
+				Channel:  fmt.Sprintf("chat:%s:messages", delivery.DirectMessage.ConversationID),
+				Content:  delivery.DirectMessage,
+				SenderID: delivery.DirectMessage.ProfileID,
+			})
+		default:
+			log.Printf("Scheduled message %s failed and will not be retried", scheduledMessage.ID)
+		}
+	}
+}
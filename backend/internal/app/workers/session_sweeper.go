@@ -0,0 +1,61 @@
+package workers
+
+import (
+	"context"
+	"discord-backend/internal/app/services"
+	ws "discord-backend/internal/app/websocket"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionSweeper closes the sockets of sessions revoked outside of this
+// process, by the CLI or another instance, and of profiles that were disabled
+type SessionSweeper struct {
+	TokenService *services.TokenService
+	Hub          *ws.Hub
+	Interval     time.Duration
+}
+
+func NewSessionSweeper(tokenService *services.TokenService, hub *ws.Hub, interval time.Duration) *SessionSweeper {
+	return &SessionSweeper{TokenService: tokenService, Hub: hub, Interval: interval}
+}
+
+func (s *SessionSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *SessionSweeper) sweep() {
+	connected := s.Hub.ConnectedSessions()
+	if len(connected) == 0 {
+		return
+	}
+
+	sessionIDs := make([]uuid.UUID, 0, len(connected))
+	for sessionID := range connected {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+
+	active, err := s.TokenService.ActiveSessions(sessionIDs)
+	if err != nil {
+		log.Printf("Error checking the sessions of open sockets: %v", err)
+		return
+	}
+
+	for sessionID, profileID := range connected {
+		if !active[sessionID] {
+			s.Hub.DisconnectSessions(profileID, sessionID)
+		}
+	}
+}
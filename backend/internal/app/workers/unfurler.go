@@ -0,0 +1,138 @@
+package workers
+
+import (
+	"context"
+	"discord-backend/internal/app/events"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/unfurl"
+	ws "discord-backend/internal/app/websocket"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UNFURL_CONCURRENCY = 4
+	UNFURL_QUEUE_SIZE  = 256
+)
+
+type unfurlJob struct {
+	MessageID uuid.UUID
+	ChannelID uuid.UUID
+	URLs      []string
+}
+
+// Unfurler fetches link previews for new messages in the background, the
+// message is sent first and updated on messages:update once previews are in
+type Unfurler struct {
+	LinkPreviewService *services.LinkPreviewService
+	Fetcher            *unfurl.Fetcher
+	Hub                *ws.Hub
+	queue              chan unfurlJob
+}
+
+func NewUnfurler(linkPreviewService *services.LinkPreviewService, fetcher *unfurl.Fetcher, hub *ws.Hub) *Unfurler {
+	return &Unfurler{
+		LinkPreviewService: linkPreviewService,
+		Fetcher:            fetcher,
+		Hub:                hub,
+		queue:              make(chan unfurlJob, UNFURL_QUEUE_SIZE),
+	}
+}
+
+// Enqueue is subscribed to the event bus, it never blocks the publisher
+func (u *Unfurler) Enqueue(event events.Event) {
+	if event.Type != events.MessageCreated {
+		return
+	}
+
+	message, ok := event.Data.(*models.Message)
+	if !ok || message.Deleted || len(message.Embeds) > 0 {
+		return
+	}
+
+	urls := unfurl.ExtractURLs(message.Content)
+	if len(urls) == 0 {
+		return
+	}
+
+	select {
+	case u.queue <- unfurlJob{MessageID: message.ID, ChannelID: message.ChannelID, URLs: urls}:
+	default:
+		log.Printf("Unfurl queue is full, skipping previews for message %s", message.ID)
+	}
+}
+
+// Run unfurls the queued messages until ctx is cancelled, previews are best
+// effort so the jobs still queued then are dropped
+func (u *Unfurler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < UNFURL_CONCURRENCY; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case job := <-u.queue:
+					u.unfurl(job)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (u *Unfurler) unfurl(job unfurlJob) {
+	var embeds []models.Embed
+	for _, url := range job.URLs {
+		if embed := u.preview(url); embed != nil {
+			embeds = append(embeds, *embed)
+		}
+	}
+
+	if len(embeds) == 0 {
+		return
+	}
+
+	message, err := u.LinkPreviewService.AttachLinkPreviews(job.MessageID, embeds)
+	if err != nil {
+		log.Printf("Error attaching link previews to message %s: %v", job.MessageID, err)
+		return
+	}
+
+	if message == nil {
+		return
+	}
+
+	// Same broadcast as WebScoketEditMessageHandler
+	u.Hub.BroadcastToChannel(ws.Message{
+		Type:     "message",
+		Channel:  fmt.Sprintf("chat:%s:messages:update", job.ChannelID),
+		Content:  message,
+		SenderID: message.AuthorProfileID(),
+	})
+}
+
+func (u *Unfurler) preview(url string) *models.Embed {
+	cached, err := u.LinkPreviewService.GetLinkPreview(url)
+	if err != nil {
+		log.Printf("Error getting link preview for %s: %v", url, err)
+		return nil
+	}
+
+	if cached != nil {
+		return cached.Embed
+	}
+
+	embed, fetchErr := u.Fetcher.Fetch(context.Background(), url)
+	if err := u.LinkPreviewService.SaveLinkPreview(url, embed, fetchErr); err != nil {
+		log.Printf("Error saving link preview for %s: %v", url, err)
+	}
+
+	return embed
+}
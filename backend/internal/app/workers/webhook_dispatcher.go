@@ -0,0 +1,176 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"discord-backend/internal/app/events"
+	"discord-backend/internal/app/models"
+	"discord-backend/internal/app/netguard"
+	"discord-backend/internal/app/services"
+	"discord-backend/internal/app/utils"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	WEBHOOK_CONCURRENCY = 4
+	WEBHOOK_QUEUE_SIZE  = 256
+)
+
+type WebhookDispatcher struct {
+	WebhookService *services.WebhookService
+	Client         *http.Client
+	Interval       time.Duration
+	queue          chan events.Event
+}
+
+func NewWebhookDispatcher(webhookService *services.WebhookService, interval time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		WebhookService: webhookService,
+		Client:         netguard.NewPublicClient(services.WEBHOOK_TIMEOUT),
+		Interval:       interval,
+		queue:          make(chan events.Event, WEBHOOK_QUEUE_SIZE),
+	}
+}
+
+// Enqueue is subscribed to the event bus, it never blocks the publisher. The
+// deliveries are stored by Run
+func (d *WebhookDispatcher) Enqueue(event events.Event) {
+	select {
+	case d.queue <- event:
+	default:
+		log.Printf("Webhook queue is full, skipping deliveries for %s %s", event.Type, event.ID)
+	}
+}
+
+// Run stores the queued events as deliveries and sends the due ones until ctx
+// is cancelled, the events still queued then are stored before it returns
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.store(ctx)
+	}()
+	defer wg.Wait()
+
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatch()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *WebhookDispatcher) store(ctx context.Context) {
+	for {
+		select {
+		case event := <-d.queue:
+			d.enqueueEvent(event)
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-d.queue:
+					d.enqueueEvent(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *WebhookDispatcher) enqueueEvent(event events.Event) {
+	if err := d.WebhookService.EnqueueEvent(event); err != nil {
+		log.Printf("Error enqueueing webhook deliveries for %s: %v", event.Type, err)
+	}
+}
+
+func (d *WebhookDispatcher) dispatch() {
+	for {
+		deliveries, err := d.WebhookService.ClaimDueDeliveries(time.Now(), services.WEBHOOK_DELIVERIES_BATCH)
+		if err != nil {
+			log.Printf("Error claiming webhook deliveries: %v", err)
+			return
+		}
+
+		if len(deliveries) == 0 {
+			return
+		}
+
+		var wg sync.WaitGroup
+		queue := make(chan *models.WebhookDelivery)
+		for i := 0; i < WEBHOOK_CONCURRENCY; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for delivery := range queue {
+					d.deliver(delivery)
+				}
+			}()
+		}
+
+		for i := range deliveries {
+			queue <- &deliveries[i]
+		}
+		close(queue)
+		wg.Wait()
+
+		if len(deliveries) < services.WEBHOOK_DELIVERIES_BATCH {
+			return
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliver(delivery *models.WebhookDelivery) {
+	statusCode, response, err := d.send(delivery)
+	if err := d.WebhookService.RecordAttempt(delivery, statusCode, response, err); err != nil {
+		log.Printf("Error recording webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+func (d *WebhookDispatcher) send(delivery *models.WebhookDelivery) (int, string, error) {
+	if !delivery.Webhook.Active {
+		return 0, "", errors.New("webhook is disabled")
+	}
+
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, delivery.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "discord-clone-webhooks")
+	req.Header.Set("X-Webhook-Id", delivery.WebhookID.String())
+	req.Header.Set("X-Webhook-Delivery", delivery.ID.String())
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+utils.SignPayload(delivery.Webhook.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	response, err := io.ReadAll(io.LimitReader(resp.Body, services.WEBHOOK_RESPONSE_MAX_SIZE))
+	if err != nil {
+		return resp.StatusCode, "", err
+	}
+
+	return resp.StatusCode, string(response), nil
+}
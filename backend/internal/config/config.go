@@ -0,0 +1,312 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// REDACTED replaces the value of secrets when the config is printed
+const REDACTED = "[redacted]"
+
+// Config is everything the server reads at startup. Every field can be set in
+// the config file, fields with an env tag also from that environment variable
+// and from the flag of the same name, DB_HOST is -db-host. Fields tagged
+// secret are redacted when the config is printed
+type Config struct {
+	Server    ServerConfig   `yaml:"server" toml:"server"`
+	Database  DatabaseConfig `yaml:"database" toml:"database"`
+	SecretKey string         `yaml:"secret_key" toml:"secret_key" env:"SECRET_KEY" secret:"true"`
+	// SigningKeyEncryptionKey encrypts the token signing keys stored in the
+	// database, 32 bytes in base64 such as the output of openssl rand -base64 32
+	SigningKeyEncryptionKey string `yaml:"signing_key_encryption_key" toml:"signing_key_encryption_key" env:"SIGNING_KEY_ENCRYPTION_KEY" secret:"true"`
+	// AppURL is the frontend, links in emails and sign in redirects point to it
+	AppURL   string         `yaml:"app_url" toml:"app_url" env:"APP_URL"`
+	RedisURL string         `yaml:"redis_url" toml:"redis_url" env:"REDIS_URL" secret:"true"`
+	Uploads  UploadsConfig  `yaml:"uploads" toml:"uploads"`
+	Mail     MailConfig     `yaml:"mail" toml:"mail"`
+	OIDC     []OIDCProvider `yaml:"oidc" toml:"oidc"`
+	TURN     TURNConfig     `yaml:"turn" toml:"turn"`
+}
+
+type ServerConfig struct {
+	Port               int      `yaml:"port" toml:"port" env:"PORT"`
+	CORSAllowedOrigins []string `yaml:"cors_allowed_origins" toml:"cors_allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	// TrustedProxies are the addresses or CIDR ranges of the reverse proxies
+	// whose X-Forwarded-For is believed. When empty the client IP is the
+	// address of the connection
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	// ShutdownTimeout is how many seconds requests, sockets and database work
+	// get to finish after SIGINT or SIGTERM
+	ShutdownTimeout int `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// MetricsAddress is the host:port /metrics is served on, apart from the
+	// API so it stays off the public network. When empty /metrics is served on
+	// the API port and MetricsToken is required
+	MetricsAddress string `yaml:"metrics_address" toml:"metrics_address" env:"METRICS_ADDRESS"`
+	// MetricsToken is the bearer token /metrics asks for, it is open when empty
+	MetricsToken string `yaml:"metrics_token" toml:"metrics_token" env:"METRICS_TOKEN" secret:"true"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host" toml:"host" env:"DB_HOST"`
+	Port     int    `yaml:"port" toml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" toml:"user" env:"DB_USER"`
+	Password string `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name     string `yaml:"name" toml:"name" env:"DB_NAME"`
+	SSLMode  string `yaml:"ssl_mode" toml:"ssl_mode" env:"DB_SSLMODE"`
+}
+
+type UploadsConfig struct {
+	Dir string `yaml:"dir" toml:"dir" env:"UPLOADS_DIR"`
+	URL string `yaml:"url" toml:"url" env:"UPLOADS_URL"`
+}
+
+// MailConfig sends through SMTP when SMTP.Host is set, otherwise emails are
+// written to Dir or the log
+type MailConfig struct {
+	From string     `yaml:"from" toml:"from" env:"MAIL_FROM"`
+	Dir  string     `yaml:"dir" toml:"dir" env:"MAIL_DIR"`
+	SMTP SMTPConfig `yaml:"smtp" toml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" toml:"host" env:"SMTP_HOST"`
+	Port     int    `yaml:"port" toml:"port" env:"SMTP_PORT"`
+	Username string `yaml:"username" toml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" toml:"password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// OIDCProvider is read from the environment as OIDC_<NAME>_ISSUER, _CLIENT_ID,
+// _CLIENT_SECRET, _REDIRECT_URL and _SCOPES for every name in OIDC_PROVIDERS
+type OIDCProvider struct {
+	Name         string   `yaml:"name" toml:"name"`
+	Issuer       string   `yaml:"issuer" toml:"issuer"`
+	ClientID     string   `yaml:"client_id" toml:"client_id"`
+	ClientSecret string   `yaml:"client_secret" toml:"client_secret" secret:"true"`
+	RedirectURL  string   `yaml:"redirect_url" toml:"redirect_url"`
+	Scopes       []string `yaml:"scopes" toml:"scopes"`
+}
+
+// TURNConfig is the relay WebRTC peers connect through, there is none unless
+// it is configured. A turn: or turns: URL needs the username and password
+type TURNConfig struct {
+	URLs     []string `yaml:"urls" toml:"urls" env:"TURN_URLS"`
+	Username string   `yaml:"username" toml:"username" env:"TURN_USERNAME"`
+	Password string   `yaml:"password" toml:"password" env:"TURN_PASSWORD" secret:"true"`
+}
+
+// Default is the config of a local development setup
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:               8080,
+			CORSAllowedOrigins: []string{"http://localhost:3000"},
+			ShutdownTimeout:    30,
+			MetricsAddress:     "127.0.0.1:9090",
+		},
+		Database: DatabaseConfig{
+			Host:    "localhost",
+			Port:    5432,
+			User:    "postgres",
+			Name:    "discord",
+			SSLMode: "disable",
+		},
+		AppURL: "http://localhost:3000",
+		Uploads: UploadsConfig{
+			Dir: "uploads",
+			URL: "/uploads",
+		},
+		Mail: MailConfig{
+			From: "no-reply@localhost",
+			SMTP: SMTPConfig{Port: 587},
+		},
+	}
+}
+
+// DSN is the connection string of the database
+func (c DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quoteDSN(c.Host), c.Port, quoteDSN(c.User), quoteDSN(c.Password), quoteDSN(c.Name), quoteDSN(c.SSLMode))
+}
+
+// quoteDSN quotes a keyword/value connection string value so spaces and
+// quotes in a password do not break it
+func quoteDSN(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// SIGNING_KEY_KEK_SIZE is the size of SigningKeyEncryptionKey once decoded,
+// an AES-256 key
+const SIGNING_KEY_KEK_SIZE = 32
+
+// SigningKeyKEK decodes SigningKeyEncryptionKey, it is nil when it is not
+// valid base64
+func (c *Config) SigningKeyKEK() []byte {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(c.SigningKeyEncryptionKey))
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Validate reports every problem at once so a broken deployment is fixed in
+// one go
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.Server.Port), "PORT must be between 1 and 65535, got %d", c.Server.Port)
+	check(len(c.Server.CORSAllowedOrigins) > 0, "CORS_ALLOWED_ORIGINS is required")
+	check(c.Server.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be a positive number of seconds, got %d", c.Server.ShutdownTimeout)
+	for _, origin := range c.Server.CORSAllowedOrigins {
+		check(origin == "*" || strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://"),
+			"CORS_ALLOWED_ORIGINS: %q must be * or start with http:// or https://", origin)
+	}
+
+	if c.Server.MetricsAddress == "" {
+		check(c.Server.MetricsToken != "", "METRICS_TOKEN is required when METRICS_ADDRESS is empty and /metrics is served on the API port")
+	} else {
+		check(validAddress(c.Server.MetricsAddress), "METRICS_ADDRESS must be host:port, got %q", c.Server.MetricsAddress)
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		check(validIPOrCIDR(proxy), "TRUSTED_PROXIES: %q must be an IP address or a CIDR range", proxy)
+	}
+
+	check(c.Database.Host != "", "DB_HOST is required")
+	check(validPort(c.Database.Port), "DB_PORT must be between 1 and 65535, got %d", c.Database.Port)
+	check(c.Database.User != "", "DB_USER is required")
+	check(c.Database.Name != "", "DB_NAME is required")
+	check(contains(sslModes, c.Database.SSLMode), "DB_SSLMODE must be one of %s, got %q", strings.Join(sslModes, ", "), c.Database.SSLMode)
+
+	check(c.SecretKey != "", "SECRET_KEY is required")
+	check(c.SigningKeyEncryptionKey != "", "SIGNING_KEY_ENCRYPTION_KEY is required, generate one with openssl rand -base64 32")
+	if c.SigningKeyEncryptionKey != "" {
+		check(len(c.SigningKeyKEK()) == SIGNING_KEY_KEK_SIZE, "SIGNING_KEY_ENCRYPTION_KEY must be %d bytes in base64", SIGNING_KEY_KEK_SIZE)
+	}
+	check(validURL(c.AppURL, "http", "https"), "APP_URL must be an http or https URL, got %q", c.AppURL)
+	check(c.RedisURL == "" || validURL(c.RedisURL, "redis", "rediss"), "REDIS_URL must be a redis:// or rediss:// URL")
+
+	check(c.Uploads.Dir != "", "UPLOADS_DIR is required")
+	check(c.Uploads.URL != "", "UPLOADS_URL is required")
+
+	_, err := netmail.ParseAddress(c.Mail.From)
+	check(err == nil, "MAIL_FROM must be an email address, got %q", c.Mail.From)
+	if c.Mail.SMTP.Host != "" {
+		check(validPort(c.Mail.SMTP.Port), "SMTP_PORT must be between 1 and 65535, got %d", c.Mail.SMTP.Port)
+	}
+
+	names := make(map[string]bool)
+	for i, provider := range c.OIDC {
+		name := provider.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		check(provider.Name != "", "OIDC provider %s: name is required", name)
+		check(!names[provider.Name], "OIDC provider %s is configured twice", name)
+		names[provider.Name] = true
+
+		check(validURL(provider.Issuer, "http", "https"), "OIDC provider %s: issuer must be an http or https URL", name)
+		check(provider.ClientID != "", "OIDC provider %s: client id is required", name)
+		check(validURL(provider.RedirectURL, "http", "https"), "OIDC provider %s: redirect URL must be an http or https URL", name)
+	}
+
+	relay := false
+	for _, turnURL := range c.TURN.URLs {
+		check(strings.HasPrefix(turnURL, "turn:") || strings.HasPrefix(turnURL, "turns:") || strings.HasPrefix(turnURL, "stun:"),
+			"TURN_URLS: %q must start with turn:, turns: or stun:", turnURL)
+		relay = relay || !strings.HasPrefix(turnURL, "stun:")
+	}
+	if relay {
+		check(c.TURN.Username != "", "TURN_USERNAME is required with a turn: URL")
+		check(c.TURN.Password != "", "TURN_PASSWORD is required with a turn: URL")
+	}
+
+	return errors.Join(errs...)
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+func validAddress(address string) bool {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	number, err := strconv.Atoi(port)
+	return err == nil && validPort(number)
+}
+
+func validIPOrCIDR(value string) bool {
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
+	}
+	return net.ParseIP(value) != nil
+}
+
+func validURL(raw string, schemes ...string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && parsed.Host != "" && contains(schemes, parsed.Scheme)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted is a copy of the config with every secret that is set replaced
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.OIDC = append([]OIDCProvider(nil), c.OIDC...)
+	redact(reflect.ValueOf(&redacted).Elem())
+	return &redacted
+}
+
+func redact(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if v.Type().Field(i).Tag.Get("secret") == "true" && field.Kind() == reflect.String {
+				if field.String() != "" {
+					field.SetString(REDACTED)
+				}
+				continue
+			}
+			redact(field)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			redact(v.Index(i))
+		}
+	}
+}
+
+// String prints the config as YAML without its secrets
+func (c *Config) String() string {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// setting is a field that can be set from the environment and a flag
+type setting struct {
+	env   string
+	value reflect.Value
+}
+
+// Load builds the config from the defaults, the config file, the environment
+// and the flags in args, each overriding the one before, and validates it. The
+// config file is -config or CONFIG_FILE, a .env file is added to the
+// environment when there is one. The arguments after the flags are returned
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+	settings := collectSettings(reflect.ValueOf(cfg).Elem())
+
+	flags := flag.NewFlagSet("discord-backend", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML or TOML config file, also CONFIG_FILE")
+	envFile := flags.String("env-file", ".env", "file of environment variables to load when it exists")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.env] = flags.String(flagName(s.env), "", "overrides "+s.env)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if err := godotenv.Load(*envFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("loading %s: %w", *envFile, err)
+	}
+
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Empty variables are treated as unset, like the blank ones of .env.example
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			if err := set(s.value, value); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	loadOIDCEnv(cfg)
+
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if err == nil && f.Name == flagName(s.env) {
+				if setErr := set(s.value, *flagValues[s.env]); setErr != nil {
+					err = fmt.Errorf("-%s: %w", f.Name, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+func flagName(env string) string {
+	return strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+}
+
+// collectSettings finds the fields with an env tag, nested structs included
+func collectSettings(v reflect.Value) []setting {
+	var settings []setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if env := v.Type().Field(i).Tag.Get("env"); env != "" {
+			settings = append(settings, setting{env: env, value: field})
+			continue
+		}
+
+		if field.Kind() == reflect.Struct {
+			settings = append(settings, collectSettings(field)...)
+		}
+	}
+	return settings
+}
+
+// set parses a string into the field, lists are comma separated
+func set(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Slice:
+		var values []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// loadFile decodes the config file over the defaults, unknown keys are
+// reported instead of silently ignored
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(cfg)
+
+		var strictErr *toml.StrictMissingError
+		if errors.As(err, &strictErr) {
+			err = errors.New(strictErr.String())
+		}
+	default:
+		return fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadOIDCEnv replaces the providers with the ones named in OIDC_PROVIDERS,
+// a provider of the config file with the same name is the starting point
+func loadOIDCEnv(cfg *Config) {
+	names := os.Getenv("OIDC_PROVIDERS")
+	if names == "" {
+		return
+	}
+
+	var providers []OIDCProvider
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		provider := OIDCProvider{Name: name}
+		for _, existing := range cfg.OIDC {
+			if existing.Name == name {
+				provider = existing
+			}
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		fields := map[string]*string{
+			"ISSUER":        &provider.Issuer,
+			"CLIENT_ID":     &provider.ClientID,
+			"CLIENT_SECRET": &provider.ClientSecret,
+			"REDIRECT_URL":  &provider.RedirectURL,
+		}
+		for suffix, field := range fields {
+			if value := os.Getenv(prefix + suffix); value != "" {
+				*field = value
+			}
+		}
+
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			provider.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+
+		providers = append(providers, provider)
+	}
+
+	cfg.OIDC = providers
+}
@@ -1,27 +1,17 @@
 package db
 
 import (
+	"context"
+	"discord-backend/internal/config"
 	"fmt"
-	"log"
-	"os"
+	"time"
 
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func ConnectToDB() (*gorm.DB, error) {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
-
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s "+
-		"password=%s dbname=%s sslmode=disable",
-		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
-
-	db, err := gorm.Open(postgres.Open(psqlInfo), &gorm.Config{
+func ConnectToDB(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
 		// Logger: logger.New(
 		// 	log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer(stdout)
 		// 	logger.Config{
@@ -38,3 +28,28 @@ func ConnectToDB() (*gorm.DB, error) {
 
 	return db, nil
 }
+
+// DRAIN_POLL_INTERVAL is how often Drain checks the connections in use
+const DRAIN_POLL_INTERVAL = time.Millisecond * 50
+
+// Drain waits for the queries and transactions in flight to finish, they hold
+// a connection until then, and closes the pool. It gives up when ctx is done
+func Drain(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(DRAIN_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for sqlDB.Stats().InUse > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%d database connections still in use: %w", sqlDB.Stats().InUse, ctx.Err())
+		}
+	}
+
+	return sqlDB.Close()
+}
@@ -0,0 +1,51 @@
+// Package dbtest gives tests a migrated PostgreSQL database. The tests that
+// need one are skipped unless TEST_DATABASE_URL is set, and fail instead when
+// CI is set so a pipeline cannot pass without running them
+package dbtest
+
+import (
+	"discord-backend/internal/db"
+	"os"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open migrates the database of TEST_DATABASE_URL and returns a transaction
+// that is rolled back after the test
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatal("TEST_DATABASE_URL must be set in CI")
+		}
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Migrate(conn); err != nil {
+		sqlDB.Close()
+		t.Fatal(err)
+	}
+
+	tx := conn.Begin()
+	t.Cleanup(func() {
+		tx.Rollback()
+		sqlDB.Close()
+	})
+
+	return tx
+}
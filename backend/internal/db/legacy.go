@@ -0,0 +1,186 @@
+package db
+
+import (
+	"discord-backend/internal/app/models"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// upgradeLegacySchema brings a database from before versioned migrations up to
+// the baseline migration. Whatever the AutoMigrate of that database left
+// missing is created from the baseline SQL itself, never from the current
+// models, so later migrations still find the schema they were written against
+func upgradeLegacySchema(db *gorm.DB, baseline string) error {
+	if err := dropLegacyRefreshTokens(db); err != nil {
+		return err
+	}
+
+	statements, err := idempotentStatements(baseline)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("%w: %s", err, statement)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return migrateConversationParticipants(db)
+}
+
+var (
+	createTable   = regexp.MustCompile(`^CREATE TABLE (\w+) \($`)
+	createIndex   = regexp.MustCompile(`^CREATE (UNIQUE )?INDEX (\w+ ON .+;)$`)
+	addConstraint = regexp.MustCompile(`^ALTER TABLE \w+ ADD CONSTRAINT (\w+) .+;$`)
+)
+
+// idempotentStatements rewrites the baseline so it can run on a database that
+// already has part of it: tables and indexes are created when missing, the
+// columns of a table that exists are added one by one and foreign keys are
+// added unless a constraint of that name exists
+func idempotentStatements(baseline string) ([]string, error) {
+	var statements []string
+	var table string
+	var columns []string
+
+	for _, line := range strings.Split(baseline, "\n") {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "--"):
+		case table != "":
+			if line != ");" {
+				columns = append(columns, strings.TrimSuffix(line, ","))
+				continue
+			}
+
+			statements = append(statements, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n    %s\n)", table, strings.Join(columns, ",\n    ")))
+			for _, column := range columns {
+				if !strings.HasPrefix(column, "PRIMARY KEY") {
+					statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s", table, column))
+				}
+			}
+			table, columns = "", nil
+		case createTable.MatchString(line):
+			table = createTable.FindStringSubmatch(line)[1]
+		case createIndex.MatchString(line):
+			match := createIndex.FindStringSubmatch(line)
+			statements = append(statements, fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s", match[1], match[2]))
+		case addConstraint.MatchString(line):
+			name := addConstraint.FindStringSubmatch(line)[1]
+			statements = append(statements, fmt.Sprintf(
+				"DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '%s') THEN %s END IF; END $$", name, line))
+		default:
+			return nil, fmt.Errorf("unexpected statement in the baseline: %s", line)
+		}
+	}
+
+	if table != "" {
+		return nil, fmt.Errorf("table %s of the baseline is not closed", table)
+	}
+
+	return statements, nil
+}
+
+// dropLegacyRefreshTokens removes the old one token per profile table, its
+// tokens belong to no session so everybody signs in again once
+func dropLegacyRefreshTokens(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&models.RefreshToken{}) || db.Migrator().HasColumn(&models.RefreshToken{}, "session_id") {
+		return nil
+	}
+
+	return db.Migrator().DropTable(&models.RefreshToken{})
+}
+
+// migrateConversationParticipants moves conversations from the two server
+// members they used to hold to profile participants. Conversations between the
+// same two profiles in different servers are merged into the oldest one. It
+// runs once, the old columns are dropped at the end
+func migrateConversationParticipants(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Conversation{}, "member_one_id") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			ID           uuid.UUID
+			CreatedAt    time.Time
+			ProfileOneID uuid.UUID
+			ProfileTwoID uuid.UUID
+		}
+		if err := tx.Raw(`SELECT conversations.id, conversations.created_at,
+				one.profile_id AS profile_one_id, two.profile_id AS profile_two_id
+			FROM conversations
+			JOIN members one ON one.id = conversations.member_one_id
+			JOIN members two ON two.id = conversations.member_two_id
+			ORDER BY conversations.created_at ASC`).Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		kept := make(map[string]uuid.UUID)
+		for _, row := range rows {
+			key := models.DirectKey(row.ProfileOneID, row.ProfileTwoID)
+
+			if keptID, ok := kept[key]; ok {
+				if err := tx.Model(&models.DirectMessage{}).Where("conversation_id = ?", row.ID).
+					Update("conversation_id", keptID).Error; err != nil {
+					return err
+				}
+
+				if err := tx.Model(&models.ScheduledMessage{}).Where("conversation_id = ?", row.ID).
+					Update("conversation_id", keptID).Error; err != nil {
+					return err
+				}
+
+				if err := tx.Exec("DELETE FROM conversations WHERE id = ?", row.ID).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			kept[key] = row.ID
+			if err := tx.Model(&models.Conversation{}).Where("id = ?", row.ID).
+				Update("direct_key", key).Error; err != nil {
+				return err
+			}
+
+			participants := []models.ConversationParticipant{{ConversationID: row.ID, ProfileID: row.ProfileOneID, CreatedAt: row.CreatedAt}}
+			if row.ProfileTwoID != row.ProfileOneID {
+				participants = append(participants, models.ConversationParticipant{ConversationID: row.ID, ProfileID: row.ProfileTwoID, CreatedAt: row.CreatedAt})
+			}
+
+			if err := tx.Create(&participants).Error; err != nil {
+				return err
+			}
+		}
+
+		if tx.Migrator().HasColumn(&models.DirectMessage{}, "member_id") {
+			if err := tx.Exec(`UPDATE direct_messages SET profile_id = members.profile_id
+				FROM members WHERE members.id = direct_messages.member_id`).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Migrator().DropColumn(&models.DirectMessage{}, "member_id"); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Migrator().DropColumn(&models.Conversation{}, "member_one_id"); err != nil {
+			return err
+		}
+
+		return tx.Migrator().DropColumn(&models.Conversation{}, "member_two_id")
+	})
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIdempotentBaseline(t *testing.T) {
+	migrator, err := NewMigrator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	baseline := migrator.Migrations[0]
+	if baseline.Version != BASELINE_VERSION {
+		t.Fatalf("first migration is %d, want the baseline", baseline.Version)
+	}
+
+	statements, err := idempotentStatements(baseline.Up)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for _, statement := range statements {
+		switch {
+		case strings.HasPrefix(statement, "CREATE TABLE IF NOT EXISTS "):
+			counts["table"]++
+		case strings.HasPrefix(statement, "ALTER TABLE ") && strings.Contains(statement, " ADD COLUMN IF NOT EXISTS "):
+			counts["column"]++
+		case strings.HasPrefix(statement, "CREATE INDEX IF NOT EXISTS "), strings.HasPrefix(statement, "CREATE UNIQUE INDEX IF NOT EXISTS "):
+			counts["index"]++
+		case strings.HasPrefix(statement, "DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_constraint"):
+			counts["constraint"]++
+		default:
+			t.Errorf("statement is not idempotent: %s", statement)
+		}
+	}
+
+	for kind, want := range map[string]int{
+		"table":      strings.Count(baseline.Up, "CREATE TABLE "),
+		"index":      strings.Count(baseline.Up, "INDEX "),
+		"constraint": strings.Count(baseline.Up, "ADD CONSTRAINT "),
+	} {
+		if counts[kind] != want {
+			t.Errorf("%d %s statements, want %d", counts[kind], kind, want)
+		}
+	}
+	if counts["column"] == 0 {
+		t.Error("no columns are added to existing tables")
+	}
+}
+
+func TestIdempotentStatementsRejectsUnknown(t *testing.T) {
+	if _, err := idempotentStatements("DROP TABLE profiles;"); err == nil {
+		t.Error("an unexpected statement was accepted")
+	}
+	if _, err := idempotentStatements("CREATE TABLE profiles (\n    id uuid,"); err == nil {
+		t.Error("an unclosed table was accepted")
+	}
+}
@@ -0,0 +1,384 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MIGRATIONS_DIR is where create writes new migrations, relative to the backend
+const MIGRATIONS_DIR = "internal/db/migrations"
+
+const (
+	// MIGRATION_LOCK_ID is the advisory lock replicas take before migrating, so
+	// only one of them runs the migrations and the others wait for it
+	MIGRATION_LOCK_ID = 727_361_019_334
+	// NO_TRANSACTION as the first line runs the migration outside a
+	// transaction, for statements like CREATE INDEX CONCURRENTLY
+	NO_TRANSACTION = "-- migrate:no-transaction"
+	// BASELINE_VERSION is the schema AutoMigrate created before migrations
+	BASELINE_VERSION = 1
+)
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// migrationFile is <version>_<name>.up.sql or <version>_<name>.down.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration and when it was applied, nil when pending
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations reads the migrations of a directory ordered by version, every
+// version needs both an up and a down file
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies the embedded migrations and records them in
+// schema_migrations
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	dir, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Migrate brings the database up to date, it runs on every start
+func Migrate(db *gorm.DB) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(context.Background())
+	for _, migration := range applied {
+		log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+	}
+	return err
+}
+
+// withLock runs fn on one connection holding the migration lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	sqlDB, err := m.DB.DB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", MIGRATION_LOCK_ID); err != nil {
+		return fmt.Errorf("taking migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", MIGRATION_LOCK_ID)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// run executes the SQL and records or forgets the version in the same
+// transaction, unless the migration opts out of it
+func run(ctx context.Context, conn *sql.Conn, statements, record string, args ...interface{}) error {
+	if strings.HasPrefix(strings.TrimSpace(statements), NO_TRANSACTION) {
+		if _, err := conn.ExecContext(ctx, statements); err != nil {
+			return err
+		}
+		_, err := conn.ExecContext(ctx, record, args...)
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Up applies every pending migration in order and returns the ones it applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			adopted, err := m.adoptLegacySchema(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if adopted {
+				applied[BASELINE_VERSION] = time.Now()
+			}
+		}
+
+		for _, migration := range m.Migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			if err := run(ctx, conn, migration.Up,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// adoptLegacySchema records the baseline as applied on a database AutoMigrate
+// created, after bringing it up to the baseline SQL
+func (m *Migrator) adoptLegacySchema(ctx context.Context, conn *sql.Conn) (bool, error) {
+	var exists bool
+	if err := conn.QueryRowContext(ctx, "SELECT to_regclass('profiles') IS NOT NULL").Scan(&exists); err != nil {
+		return false, err
+	}
+
+	if !exists {
+		return false, nil
+	}
+
+	var baseline *Migration
+	for i := range m.Migrations {
+		if m.Migrations[i].Version == BASELINE_VERSION {
+			baseline = &m.Migrations[i]
+		}
+	}
+
+	if baseline == nil {
+		return false, fmt.Errorf("migration %d is missing", BASELINE_VERSION)
+	}
+
+	log.Printf("Adopting the schema created by AutoMigrate as migration %d", BASELINE_VERSION)
+	if err := upgradeLegacySchema(m.DB, baseline.Up); err != nil {
+		return false, err
+	}
+
+	_, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", BASELINE_VERSION, "baseline")
+	return err == nil, err
+}
+
+// Down rolls back the last steps applied migrations and returns them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	byVersion := make(map[int64]Migration, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions {
+			if len(done) == steps {
+				break
+			}
+
+			migration, ok := byVersion[version]
+			if !ok {
+				return fmt.Errorf("migration %d is applied but this build does not have it", version)
+			}
+
+			if err := run(ctx, conn, migration.Down,
+				"DELETE FROM schema_migrations WHERE version = $1", migration.Version); err != nil {
+				return fmt.Errorf("rolling back migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Status lists every migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			status := MigrationStatus{Migration: migration}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// CreateMigration writes empty up and down files for the next version to dir
+// and returns their paths
+func CreateMigration(dir, name string) (string, string, error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("migration name is required")
+	}
+
+	migrations, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+
+	version := int64(1)
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%05d_%s", version, name))
+	up, down := base+".up.sql", base+".down.sql"
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- Revert "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+
+	return up, down, nil
+}
@@ -0,0 +1,31 @@
+DROP TABLE IF EXISTS signing_keys CASCADE;
+DROP TABLE IF EXISTS external_identities CASCADE;
+DROP TABLE IF EXISTS recovery_codes CASCADE;
+DROP TABLE IF EXISTS mfa_secrets CASCADE;
+DROP TABLE IF EXISTS account_tokens CASCADE;
+DROP TABLE IF EXISTS server_privacy_overrides CASCADE;
+DROP TABLE IF EXISTS privacy_settings CASCADE;
+DROP TABLE IF EXISTS relationships CASCADE;
+DROP TABLE IF EXISTS reactions CASCADE;
+DROP TABLE IF EXISTS emojis CASCADE;
+DROP TABLE IF EXISTS link_previews CASCADE;
+DROP TABLE IF EXISTS interactions CASCADE;
+DROP TABLE IF EXISTS commands CASCADE;
+DROP TABLE IF EXISTS applications CASCADE;
+DROP TABLE IF EXISTS bot_tokens CASCADE;
+DROP TABLE IF EXISTS incoming_webhooks CASCADE;
+DROP TABLE IF EXISTS webhook_deliveries CASCADE;
+DROP TABLE IF EXISTS webhooks CASCADE;
+DROP TABLE IF EXISTS scheduled_messages CASCADE;
+DROP TABLE IF EXISTS direct_message_revisions CASCADE;
+DROP TABLE IF EXISTS message_revisions CASCADE;
+DROP TABLE IF EXISTS refresh_tokens CASCADE;
+DROP TABLE IF EXISTS sessions CASCADE;
+DROP TABLE IF EXISTS conversation_participants CASCADE;
+DROP TABLE IF EXISTS conversations CASCADE;
+DROP TABLE IF EXISTS direct_messages CASCADE;
+DROP TABLE IF EXISTS messages CASCADE;
+DROP TABLE IF EXISTS members CASCADE;
+DROP TABLE IF EXISTS channels CASCADE;
+DROP TABLE IF EXISTS servers CASCADE;
+DROP TABLE IF EXISTS profiles CASCADE;
@@ -0,0 +1,474 @@
+-- Baseline of the schema GORM AutoMigrate created before versioned migrations
+CREATE TABLE profiles (
+    id uuid,
+    name text,
+    image_url text,
+    email text,
+    password text,
+    email_verified_at timestamptz,
+    mfa_enabled boolean,
+    is_bot boolean DEFAULT false,
+    bot_owner_id text,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_profiles_bot_owner_id ON profiles (bot_owner_id);
+
+CREATE TABLE servers (
+    id uuid,
+    name text,
+    image_url text,
+    invite_code text UNIQUE,
+    profile_id uuid,
+    require_mfa boolean,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_servers_deleted_at ON servers (deleted_at);
+
+CREATE TABLE channels (
+    id uuid,
+    name text,
+    type varchar(100) DEFAULT 'TEXT',
+    slowmode bigint DEFAULT 0,
+    profile_id uuid,
+    server_id uuid,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_channels_deleted_at ON channels (deleted_at);
+
+CREATE TABLE members (
+    id uuid,
+    role varchar(100) DEFAULT 'GUEST',
+    profile_id uuid,
+    server_id uuid,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE messages (
+    id uuid,
+    content text,
+    file_url text,
+    member_id uuid,
+    channel_id uuid,
+    webhook_id uuid,
+    author_name text,
+    author_avatar_url text,
+    embeds text,
+    deleted boolean DEFAULT false,
+    edited_at timestamptz,
+    pinned boolean DEFAULT false,
+    pinned_at timestamptz,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_messages_pinned_at ON messages (pinned_at);
+CREATE INDEX idx_messages_webhook_id ON messages (webhook_id);
+
+CREATE TABLE direct_messages (
+    id uuid,
+    content text,
+    file_url text,
+    profile_id uuid,
+    conversation_id uuid,
+    deleted boolean DEFAULT false,
+    edited_at timestamptz,
+    pinned boolean DEFAULT false,
+    pinned_at timestamptz,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_direct_messages_pinned_at ON direct_messages (pinned_at);
+CREATE INDEX idx_direct_messages_profile_id ON direct_messages (profile_id);
+
+CREATE TABLE conversations (
+    id uuid,
+    name varchar(100),
+    image_url text,
+    is_group boolean DEFAULT false,
+    owner_id uuid,
+    direct_key text,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_conversations_direct_key ON conversations (direct_key);
+
+CREATE TABLE conversation_participants (
+    id uuid,
+    conversation_id uuid,
+    profile_id uuid,
+    request boolean DEFAULT false,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_conversation_participants_profile_id ON conversation_participants (profile_id);
+CREATE UNIQUE INDEX idx_conversation_participants_conversation_profile ON conversation_participants (conversation_id,profile_id);
+
+CREATE TABLE sessions (
+    id uuid,
+    profile_id uuid NOT NULL,
+    device_name varchar(100),
+    ip varchar(64),
+    user_agent text,
+    last_used_at timestamptz,
+    expires_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_sessions_expires_at ON sessions (expires_at);
+CREATE INDEX idx_sessions_profile_id ON sessions (profile_id);
+
+CREATE TABLE refresh_tokens (
+    id uuid,
+    profile_id uuid NOT NULL,
+    session_id uuid NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    expires_at timestamptz,
+    used_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_refresh_tokens_profile_id ON refresh_tokens (profile_id);
+CREATE INDEX idx_refresh_tokens_session_id ON refresh_tokens (session_id);
+CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+
+CREATE TABLE message_revisions (
+    id uuid,
+    message_id uuid,
+    content text,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_message_revisions_message_id ON message_revisions (message_id);
+
+CREATE TABLE direct_message_revisions (
+    id uuid,
+    direct_message_id uuid,
+    content text,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_direct_message_revisions_direct_message_id ON direct_message_revisions (direct_message_id);
+
+CREATE TABLE scheduled_messages (
+    id uuid,
+    profile_id uuid,
+    server_id text,
+    channel_id text,
+    conversation_id text,
+    content text,
+    file_url text,
+    send_at timestamptz,
+    recurrence text,
+    timezone text DEFAULT 'UTC',
+    status varchar(100) DEFAULT 'PENDING',
+    last_sent_at timestamptz,
+    sent_count bigint DEFAULT 0,
+    error text,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_scheduled_messages_profile_id ON scheduled_messages (profile_id);
+CREATE INDEX idx_scheduled_messages_send_at ON scheduled_messages (send_at);
+CREATE INDEX idx_scheduled_messages_status ON scheduled_messages (status);
+
+CREATE TABLE webhooks (
+    id uuid,
+    server_id uuid,
+    profile_id uuid,
+    url text,
+    secret text,
+    events text,
+    active boolean DEFAULT true,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_webhooks_server_id ON webhooks (server_id);
+
+CREATE TABLE webhook_deliveries (
+    id uuid,
+    webhook_id uuid,
+    event_id text,
+    event text,
+    payload text,
+    status varchar(100) DEFAULT 'PENDING',
+    attempts bigint DEFAULT 0,
+    next_attempt_at timestamptz,
+    last_status_code bigint,
+    last_response text,
+    last_error text,
+    delivered_at timestamptz,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at);
+CREATE INDEX idx_webhook_deliveries_status ON webhook_deliveries (status);
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
+
+CREATE TABLE incoming_webhooks (
+    id uuid,
+    name text,
+    avatar_url text,
+    token_hash text,
+    server_id uuid,
+    channel_id uuid,
+    profile_id uuid,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_incoming_webhooks_channel_id ON incoming_webhooks (channel_id);
+CREATE INDEX idx_incoming_webhooks_server_id ON incoming_webhooks (server_id);
+CREATE UNIQUE INDEX idx_incoming_webhooks_token_hash ON incoming_webhooks (token_hash);
+
+CREATE TABLE bot_tokens (
+    id uuid,
+    bot_id uuid,
+    name text,
+    token_hash text,
+    prefix text,
+    last_used_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_bot_tokens_bot_id ON bot_tokens (bot_id);
+CREATE UNIQUE INDEX idx_bot_tokens_token_hash ON bot_tokens (token_hash);
+
+CREATE TABLE applications (
+    id uuid,
+    bot_id uuid,
+    callback_url text,
+    secret text,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_applications_bot_id ON applications (bot_id);
+
+CREATE TABLE commands (
+    id uuid,
+    application_id uuid,
+    server_id uuid,
+    name text,
+    description text,
+    options text,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_commands_application_id ON commands (application_id);
+CREATE UNIQUE INDEX idx_commands_server_name ON commands (server_id,name);
+
+CREATE TABLE interactions (
+    id uuid,
+    command_id uuid,
+    application_id text,
+    server_id text,
+    channel_id text,
+    member_id text,
+    profile_id text,
+    options text,
+    status varchar(100) DEFAULT 'PENDING',
+    response_type text,
+    message_id text,
+    error text,
+    responded_at timestamptz,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_interactions_application_id ON interactions (application_id);
+CREATE INDEX idx_interactions_command_id ON interactions (command_id);
+CREATE INDEX idx_interactions_server_id ON interactions (server_id);
+
+CREATE TABLE link_previews (
+    id uuid,
+    url text,
+    embed text,
+    error text,
+    fetched_at timestamptz,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_link_previews_fetched_at ON link_previews (fetched_at);
+CREATE UNIQUE INDEX idx_link_previews_url ON link_previews (url);
+
+CREATE TABLE emojis (
+    id uuid,
+    server_id uuid,
+    name text,
+    animated boolean DEFAULT false,
+    image_url text,
+    storage_key text,
+    profile_id text,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_emojis_server_name ON emojis (server_id,name);
+
+CREATE TABLE reactions (
+    id uuid,
+    message_id uuid,
+    member_id uuid,
+    emoji text,
+    emoji_id uuid,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_reactions_emoji_id ON reactions (emoji_id);
+CREATE UNIQUE INDEX idx_reactions_message_member_emoji ON reactions (message_id,member_id,emoji);
+
+CREATE TABLE relationships (
+    id uuid,
+    profile_id uuid,
+    target_id uuid,
+    type varchar(20),
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_relationships_target_id ON relationships (target_id);
+CREATE UNIQUE INDEX idx_relationships_profile_target ON relationships (profile_id,target_id);
+
+CREATE TABLE privacy_settings (
+    profile_id uuid,
+    allow_server_d_ms boolean,
+    friend_requests varchar(20),
+    message_requests boolean,
+    updated_at timestamptz,
+    PRIMARY KEY (profile_id)
+);
+
+CREATE TABLE server_privacy_overrides (
+    profile_id uuid,
+    server_id uuid,
+    allow_d_ms boolean,
+    updated_at timestamptz,
+    PRIMARY KEY (profile_id,server_id)
+);
+CREATE INDEX idx_server_privacy_overrides_server_id ON server_privacy_overrides (server_id);
+
+CREATE TABLE account_tokens (
+    id uuid,
+    profile_id uuid NOT NULL,
+    purpose varchar(20) NOT NULL,
+    email text,
+    token_hash varchar(64) NOT NULL,
+    expires_at timestamptz,
+    used_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_account_tokens_expires_at ON account_tokens (expires_at);
+CREATE INDEX idx_account_tokens_profile_id ON account_tokens (profile_id);
+CREATE UNIQUE INDEX idx_account_tokens_token_hash ON account_tokens (token_hash);
+
+CREATE TABLE mfa_secrets (
+    profile_id uuid,
+    secret varchar(64) NOT NULL,
+    last_counter bigint NOT NULL,
+    enabled_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (profile_id)
+);
+
+CREATE TABLE recovery_codes (
+    id uuid,
+    profile_id uuid NOT NULL,
+    code_hash varchar(64) NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_recovery_codes_profile_id ON recovery_codes (profile_id);
+
+CREATE TABLE external_identities (
+    id uuid,
+    profile_id uuid NOT NULL,
+    provider varchar(50) NOT NULL,
+    subject varchar(255) NOT NULL,
+    email text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_external_identities_profile_id ON external_identities (profile_id);
+CREATE UNIQUE INDEX idx_external_identity_subject ON external_identities (provider,subject);
+
+CREATE TABLE signing_keys (
+    id varchar(32),
+    algorithm varchar(10) NOT NULL,
+    seed bytea NOT NULL,
+    active_until timestamptz,
+    expires_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_signing_keys_active_until ON signing_keys (active_until);
+CREATE INDEX idx_signing_keys_expires_at ON signing_keys (expires_at);
+
+ALTER TABLE servers ADD CONSTRAINT fk_profiles_servers FOREIGN KEY (profile_id) REFERENCES profiles(id);
+ALTER TABLE channels ADD CONSTRAINT fk_servers_channels FOREIGN KEY (server_id) REFERENCES servers(id);
+ALTER TABLE channels ADD CONSTRAINT fk_profiles_channels FOREIGN KEY (profile_id) REFERENCES profiles(id);
+ALTER TABLE members ADD CONSTRAINT fk_servers_members FOREIGN KEY (server_id) REFERENCES servers(id);
+ALTER TABLE members ADD CONSTRAINT fk_profiles_members FOREIGN KEY (profile_id) REFERENCES profiles(id);
+ALTER TABLE messages ADD CONSTRAINT fk_channels_messages FOREIGN KEY (channel_id) REFERENCES channels(id);
+ALTER TABLE messages ADD CONSTRAINT fk_messages_webhook FOREIGN KEY (webhook_id) REFERENCES incoming_webhooks(id) ON DELETE SET NULL;
+ALTER TABLE messages ADD CONSTRAINT fk_members_messages FOREIGN KEY (member_id) REFERENCES members(id);
+ALTER TABLE direct_messages ADD CONSTRAINT fk_conversations_direct_messages FOREIGN KEY (conversation_id) REFERENCES conversations(id);
+ALTER TABLE direct_messages ADD CONSTRAINT fk_direct_messages_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE conversation_participants ADD CONSTRAINT fk_conversation_participants_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE conversation_participants ADD CONSTRAINT fk_conversations_participants FOREIGN KEY (conversation_id) REFERENCES conversations(id);
+ALTER TABLE sessions ADD CONSTRAINT fk_sessions_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE refresh_tokens ADD CONSTRAINT fk_refresh_tokens_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE refresh_tokens ADD CONSTRAINT fk_refresh_tokens_session FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE;
+ALTER TABLE message_revisions ADD CONSTRAINT fk_message_revisions_message FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE;
+ALTER TABLE direct_message_revisions ADD CONSTRAINT fk_direct_message_revisions_direct_message FOREIGN KEY (direct_message_id) REFERENCES direct_messages(id) ON DELETE CASCADE;
+ALTER TABLE scheduled_messages ADD CONSTRAINT fk_scheduled_messages_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE webhooks ADD CONSTRAINT fk_webhooks_server FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE;
+ALTER TABLE webhooks ADD CONSTRAINT fk_webhooks_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE webhook_deliveries ADD CONSTRAINT fk_webhook_deliveries_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE;
+ALTER TABLE incoming_webhooks ADD CONSTRAINT fk_incoming_webhooks_channel FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE;
+ALTER TABLE incoming_webhooks ADD CONSTRAINT fk_incoming_webhooks_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE incoming_webhooks ADD CONSTRAINT fk_incoming_webhooks_server FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE;
+ALTER TABLE bot_tokens ADD CONSTRAINT fk_bot_tokens_bot FOREIGN KEY (bot_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE applications ADD CONSTRAINT fk_applications_bot FOREIGN KEY (bot_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE commands ADD CONSTRAINT fk_commands_application FOREIGN KEY (application_id) REFERENCES applications(id) ON DELETE CASCADE;
+ALTER TABLE commands ADD CONSTRAINT fk_commands_server FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE;
+ALTER TABLE interactions ADD CONSTRAINT fk_interactions_command FOREIGN KEY (command_id) REFERENCES commands(id) ON DELETE CASCADE;
+ALTER TABLE emojis ADD CONSTRAINT fk_servers_emojis FOREIGN KEY (server_id) REFERENCES servers(id);
+ALTER TABLE reactions ADD CONSTRAINT fk_reactions_member FOREIGN KEY (member_id) REFERENCES members(id) ON DELETE CASCADE;
+ALTER TABLE reactions ADD CONSTRAINT fk_reactions_custom FOREIGN KEY (emoji_id) REFERENCES emojis(id) ON DELETE CASCADE;
+ALTER TABLE reactions ADD CONSTRAINT fk_messages_reactions FOREIGN KEY (message_id) REFERENCES messages(id);
+ALTER TABLE relationships ADD CONSTRAINT fk_relationships_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE relationships ADD CONSTRAINT fk_relationships_target FOREIGN KEY (target_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE privacy_settings ADD CONSTRAINT fk_privacy_settings_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE server_privacy_overrides ADD CONSTRAINT fk_server_privacy_overrides_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE server_privacy_overrides ADD CONSTRAINT fk_server_privacy_overrides_server FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE;
+ALTER TABLE server_privacy_overrides ADD CONSTRAINT fk_privacy_settings_server_overrides FOREIGN KEY (profile_id) REFERENCES privacy_settings(profile_id);
+ALTER TABLE account_tokens ADD CONSTRAINT fk_account_tokens_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE mfa_secrets ADD CONSTRAINT fk_mfa_secrets_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE recovery_codes ADD CONSTRAINT fk_recovery_codes_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER TABLE external_identities ADD CONSTRAINT fk_external_identities_profile FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
@@ -0,0 +1,2 @@
+-- Operators can disable a profile, it cannot sign in until it is enabled again
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS disabled_at timestamptz;
@@ -0,0 +1,4 @@
+ALTER TABLE direct_messages DROP COLUMN IF EXISTS mentions;
+ALTER TABLE direct_messages DROP COLUMN IF EXISTS ast;
+ALTER TABLE messages DROP COLUMN IF EXISTS mentions;
+ALTER TABLE messages DROP COLUMN IF EXISTS ast;
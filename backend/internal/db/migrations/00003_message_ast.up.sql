@@ -0,0 +1,6 @@
+-- The markdown tree and mentions of a message are parsed when its content is
+-- written, messages from before have none and are served as the raw content
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS ast text;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS mentions text;
+ALTER TABLE direct_messages ADD COLUMN IF NOT EXISTS ast text;
+ALTER TABLE direct_messages ADD COLUMN IF NOT EXISTS mentions text;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_reactions_message_member_emoji_id;
+DROP INDEX IF EXISTS idx_reactions_message_member_emoji;
+
+UPDATE reactions SET emoji = emojis.name || ':' || emojis.id
+FROM emojis WHERE emojis.id = reactions.emoji_id;
+
+CREATE UNIQUE INDEX idx_reactions_message_member_emoji ON reactions (message_id,member_id,emoji);
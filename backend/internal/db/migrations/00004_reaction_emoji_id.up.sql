@@ -0,0 +1,16 @@
+-- Custom reactions are keyed on the emoji id instead of the name:id string,
+-- emoji only holds unicode emoji. Reactions made twice with the same custom
+-- emoji under different names are merged into the first one
+DROP INDEX IF EXISTS idx_reactions_message_member_emoji;
+
+DELETE FROM reactions later USING reactions earlier
+WHERE later.emoji_id IS NOT NULL
+    AND later.emoji_id = earlier.emoji_id
+    AND later.message_id = earlier.message_id
+    AND later.member_id = earlier.member_id
+    AND (later.created_at, later.id) > (earlier.created_at, earlier.id);
+
+UPDATE reactions SET emoji = '' WHERE emoji_id IS NOT NULL;
+
+CREATE UNIQUE INDEX idx_reactions_message_member_emoji ON reactions (message_id,member_id,emoji) WHERE emoji_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_message_member_emoji_id ON reactions (message_id,member_id,emoji_id);
@@ -2,14 +2,32 @@ package routes
 
 import (
 	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/middleware"
+	"discord-backend/internal/app/websocket"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthRoutes(router *gin.Engine, authHandler *handlers.AuthHandler) {
-	router.POST("/signup", authHandler.SignUp)
-	router.POST("/signin", authHandler.SignIn)
-	router.POST("/signout", authHandler.SignOut)
-	router.GET("/refresh", authHandler.Refresh)
+func AuthRoutes(router *gin.Engine, protected *gin.RouterGroup, authHandler *handlers.AuthHandler, wsHub *websocket.Hub, authLimit gin.HandlerFunc) {
+	router.POST("/signup", authLimit, authHandler.SignUp)
+	router.POST("/signin", authLimit, authHandler.SignIn)
+	router.POST("/signin/mfa", authLimit, authHandler.SignInMFA)
+	router.POST("/signout", authHandler.SignOut(wsHub))
+	router.GET("/refresh", authHandler.Refresh(wsHub))
 	router.GET("/server/refresh", authHandler.ServerRefresh)
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
+	router.POST("/verify-email", authLimit, authHandler.VerifyEmail)
+	router.POST("/forgot-password", authLimit, authHandler.ForgotPassword)
+	router.POST("/reset-password", authLimit, authHandler.ResetPassword(wsHub))
+
+	protected.POST("/verify-email/resend", middleware.UserOnly, authHandler.ResendVerificationEmail)
+
+	sessionsGroup := protected.Group("/sessions", middleware.UserOnly)
+	{
+		sessionsGroup.GET("", authHandler.GetSessions)
+
+		sessionsGroup.DELETE("", authHandler.RevokeSessions(wsHub))
+		sessionsGroup.DELETE("/:sessionId", authHandler.RevokeSession(wsHub))
+	}
 }
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/middleware"
+	"discord-backend/internal/app/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+func BotRoutes(protected *gin.RouterGroup, botHandler *handlers.BotHandler, gateway *websocket.Gateway) {
+	protected.GET("/gateway", middleware.BotOnly, botHandler.Gateway(gateway))
+
+	protected.POST("/servers/:serverId/bots", middleware.UserOnly, botHandler.AddBotToServer)
+
+	botsGroup := protected.Group("/bots", middleware.UserOnly)
+	{
+		botsGroup.GET("", botHandler.GetBots)
+		botsGroup.GET("/:botId/tokens", botHandler.GetBotTokens)
+
+		botsGroup.POST("", botHandler.CreateBot)
+		botsGroup.POST("/:botId/tokens", botHandler.CreateBotToken)
+
+		botsGroup.DELETE("/:botId/tokens/:tokenId", botHandler.RevokeBotToken(gateway))
+	}
+}
@@ -14,6 +14,8 @@ func ChannelRoutes(protected *gin.RouterGroup, channelHandler *handlers.ChannelH
 		channelGroup.POST("/servers/:serverId", channelHandler.CreateChannel)
 		channelGroup.POST("/:channelId/servers/:serverId", channelHandler.UpdateChannel)
 
+		channelGroup.PATCH("/:channelId/servers/:serverId/restore", channelHandler.RestoreChannel)
+
 		channelGroup.DELETE("/:channelId/servers/:serverId", channelHandler.DeleteChannel)
 	}
 }
@@ -2,15 +2,26 @@ package routes
 
 import (
 	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/websocket"
 
 	"github.com/gin-gonic/gin"
 )
 
-func ConversationRoutes(protected *gin.RouterGroup, conversationHandler *handlers.ConversationHandler) {
+func ConversationRoutes(protected *gin.RouterGroup, conversationHandler *handlers.ConversationHandler, wsHub *websocket.Hub) {
 	conversationsGroup := protected.Group("/conversations")
 	{
+		conversationsGroup.GET("", conversationHandler.GetConversations)
 		conversationsGroup.GET("/between/:memberOneId/:memberTwoId", conversationHandler.FindConversation)
+		conversationsGroup.GET("/:conversationId", conversationHandler.GetConversation)
 
+		conversationsGroup.POST("", conversationHandler.CreateGroupConversation(wsHub))
 		conversationsGroup.POST("/ensure", conversationHandler.GetOrCreateConversation)
+		conversationsGroup.POST("/:conversationId/participants", conversationHandler.AddParticipant(wsHub))
+		conversationsGroup.POST("/:conversationId/accept", conversationHandler.AcceptConversation(wsHub))
+
+		conversationsGroup.PATCH("/:conversationId", conversationHandler.UpdateConversation(wsHub))
+		conversationsGroup.PATCH("/:conversationId/leave", conversationHandler.LeaveConversation(wsHub))
+
+		conversationsGroup.DELETE("/:conversationId/participants/:profileId", conversationHandler.RemoveParticipant(wsHub))
 	}
 }
@@ -10,5 +10,7 @@ func DirectMessageRoutes(protected *gin.RouterGroup, directMessageHandler *handl
 	messageGroup := protected.Group("/direct-messages")
 	{
 		messageGroup.GET("", directMessageHandler.GetDirectMessages)
+		messageGroup.GET("/pins", directMessageHandler.GetPinnedDirectMessages)
+		messageGroup.GET("/:directMessageId/revisions", directMessageHandler.GetDirectMessageRevisions)
 	}
 }
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func EmojiRoutes(protected *gin.RouterGroup, emojiHandler *handlers.EmojiHandler) {
+	emojisGroup := protected.Group("/servers/:serverId/emojis")
+	{
+		emojisGroup.GET("", emojiHandler.GetEmojis)
+
+		emojisGroup.POST("", middleware.UserOnly, emojiHandler.CreateEmoji)
+
+		emojisGroup.PATCH("/:emojiId", middleware.UserOnly, emojiHandler.UpdateEmoji)
+
+		emojisGroup.DELETE("/:emojiId", middleware.UserOnly, emojiHandler.DeleteEmoji)
+	}
+}
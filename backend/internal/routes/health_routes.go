@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"discord-backend/internal/app/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func HealthRoutes(router *gin.Engine, healthHandler *handlers.HealthHandler) {
+	router.GET("/healthz", healthHandler.Healthz)
+	router.GET("/readyz", healthHandler.Readyz)
+}
+
+// MetricsRoutes is registered on the API router, or on its own router when
+// the metrics have an internal listen address
+func MetricsRoutes(router *gin.Engine, healthHandler *handlers.HealthHandler) {
+	router.GET("/metrics", healthHandler.Metrics)
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+func IncomingWebhookRoutes(router *gin.Engine, protected *gin.RouterGroup, incomingWebhookHandler *handlers.IncomingWebhookHandler, wsHub *websocket.Hub) {
+	// Called by external systems with the secret token instead of a session
+	router.POST("/webhooks/:webhookId/:token", incomingWebhookHandler.ExecuteIncomingWebhook(wsHub))
+
+	channelGroup := protected.Group("/channels")
+	{
+		channelGroup.GET("/:channelId/servers/:serverId/webhooks", incomingWebhookHandler.GetIncomingWebhooks)
+
+		channelGroup.POST("/:channelId/servers/:serverId/webhooks", incomingWebhookHandler.CreateIncomingWebhook)
+
+		channelGroup.PATCH("/:channelId/servers/:serverId/webhooks/:webhookId", incomingWebhookHandler.UpdateIncomingWebhook)
+
+		channelGroup.DELETE("/:channelId/servers/:serverId/webhooks/:webhookId", incomingWebhookHandler.DeleteIncomingWebhook)
+	}
+}
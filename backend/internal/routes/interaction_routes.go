@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/middleware"
+	"discord-backend/internal/app/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InteractionRoutes(protected *gin.RouterGroup, interactionHandler *handlers.InteractionHandler, wsHub *websocket.Hub) {
+	protected.POST("/ws/interactions", middleware.UserOnly, interactionHandler.WebSocketInteractionHandler(wsHub))
+	protected.POST("/interactions/:interactionId/response", middleware.BotOnly, interactionHandler.RespondToInteraction(wsHub))
+
+	applicationGroup := protected.Group("/bots/:botId/application", middleware.UserOnly)
+	{
+		applicationGroup.GET("", interactionHandler.GetApplication)
+
+		applicationGroup.POST("", interactionHandler.UpsertApplication)
+		applicationGroup.POST("/secret", interactionHandler.RotateApplicationSecret)
+	}
+
+	commandsGroup := protected.Group("/servers/:serverId/commands")
+	{
+		commandsGroup.GET("", interactionHandler.GetCommands)
+
+		commandsGroup.POST("", middleware.BotOnly, interactionHandler.RegisterCommand)
+
+		commandsGroup.DELETE("/:commandId", interactionHandler.DeleteCommand)
+	}
+}
@@ -10,5 +10,7 @@ func MessageRoutes(protected *gin.RouterGroup, messageHandler *handlers.MessageH
 	messageGroup := protected.Group("/messages")
 	{
 		messageGroup.GET("", messageHandler.GetMessages)
+		messageGroup.GET("/pins", messageHandler.GetPinnedMessages)
+		messageGroup.GET("/:messageId/revisions", messageHandler.GetMessageRevisions)
 	}
 }
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func MFARoutes(protected *gin.RouterGroup, mfaHandler *handlers.MFAHandler) {
+	mfaGroup := protected.Group("/mfa", middleware.UserOnly)
+	{
+		mfaGroup.GET("", mfaHandler.GetMFA)
+
+		mfaGroup.POST("/totp/enroll", mfaHandler.EnrollTOTP)
+		mfaGroup.POST("/totp/activate", mfaHandler.ActivateTOTP)
+		mfaGroup.POST("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+		mfaGroup.POST("/disable", mfaHandler.DisableMFA)
+	}
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PrivacyRoutes(protected *gin.RouterGroup, privacyHandler *handlers.PrivacyHandler) {
+	privacyGroup := protected.Group("/profile/privacy", middleware.UserOnly)
+	{
+		privacyGroup.GET("", privacyHandler.GetPrivacySettings)
+
+		privacyGroup.PATCH("", privacyHandler.UpdatePrivacySettings)
+		privacyGroup.PUT("/servers/:serverId", privacyHandler.SetServerOverride)
+
+		privacyGroup.DELETE("/servers/:serverId", privacyHandler.DeleteServerOverride)
+	}
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/middleware"
+	"discord-backend/internal/app/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RelationshipRoutes(protected *gin.RouterGroup, relationshipHandler *handlers.RelationshipHandler, wsHub *websocket.Hub) {
+	friendsGroup := protected.Group("/friends", middleware.UserOnly)
+	{
+		friendsGroup.GET("", relationshipHandler.GetFriends(wsHub))
+		friendsGroup.GET("/requests", relationshipHandler.GetFriendRequests(wsHub))
+
+		friendsGroup.POST("/requests/:profileId", relationshipHandler.SendFriendRequest(wsHub))
+		friendsGroup.POST("/requests/:profileId/accept", relationshipHandler.AcceptFriendRequest(wsHub))
+		friendsGroup.POST("/requests/:profileId/decline", relationshipHandler.DeclineFriendRequest(wsHub))
+
+		friendsGroup.DELETE("/requests/:profileId", relationshipHandler.CancelFriendRequest(wsHub))
+		friendsGroup.DELETE("/:profileId", relationshipHandler.RemoveFriend(wsHub))
+	}
+
+	blocksGroup := protected.Group("/blocks", middleware.UserOnly)
+	{
+		blocksGroup.GET("", relationshipHandler.GetBlocks(wsHub))
+
+		blocksGroup.POST("/:profileId", relationshipHandler.Block(wsHub))
+
+		blocksGroup.DELETE("/:profileId", relationshipHandler.Unblock(wsHub))
+	}
+}
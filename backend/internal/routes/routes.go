@@ -3,11 +3,13 @@ package routes
 import (
 	"discord-backend/internal/app/factory"
 	"discord-backend/internal/app/middleware"
+	"discord-backend/internal/app/storage"
+	"discord-backend/internal/app/websocket"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine, f *factory.Factory) {
+func SetupRoutes(router *gin.Engine, f *factory.Factory, wsHub *websocket.Hub, gateway *websocket.Gateway) {
 	profileHandler := f.NewProfileHandler()
 	authHandler := f.NewAuthHandler()
 	serverHandler := f.NewServerHandler()
@@ -17,19 +19,58 @@ func SetupRoutes(router *gin.Engine, f *factory.Factory) {
 	websocketHandler := f.NewWebsocketHandler()
 	messageHandler := f.NewMessageHandler()
 	directMessageHandler := f.NewDirectMessageHandler()
+	scheduledMessageHandler := f.NewScheduledMessageHandler()
+	webhookHandler := f.NewWebhookHandler()
+	incomingWebhookHandler := f.NewIncomingWebhookHandler()
+	botHandler := f.NewBotHandler()
+	interactionHandler := f.NewInteractionHandler()
+	emojiHandler := f.NewEmojiHandler()
+	relationshipHandler := f.NewRelationshipHandler()
+	privacyHandler := f.NewPrivacyHandler()
+	mfaHandler := f.NewMFAHandler()
+	ssoHandler := f.NewSSOHandler()
+	healthHandler := f.NewHealthHandler(wsHub, gateway)
 
-	AuthRoutes(router, authHandler)
+	// Registered first so every route below is measured
+	router.Use(middleware.Metrics(f.Metrics()))
+	HealthRoutes(router, healthHandler)
+	if f.Config().Server.MetricsAddress == "" {
+		MetricsRoutes(router, healthHandler)
+	}
+
+	// Uploads kept on disk are served by the API itself
+	if local, ok := f.Storage().(*storage.LocalStorage); ok {
+		router.Static(local.URLPrefix, local.Dir)
+	}
+
+	// Sign in and account recovery are limited per address, everything behind
+	// AuthMiddleware per profile or bot token
+	authLimit := middleware.RateLimit(f.NewRateLimiter("auth", factory.AUTH_RATE_LIMIT, factory.AUTH_RATE_PERIOD), middleware.ByIP)
+	apiLimit := middleware.RateLimit(f.NewRateLimiter("api", factory.API_RATE_LIMIT, factory.API_RATE_PERIOD), middleware.ByPrincipal)
+	messageLimit := middleware.RateLimit(f.NewRateLimiter("messages", factory.MESSAGE_RATE_LIMIT, factory.MESSAGE_RATE_PERIOD), middleware.ByPrincipal)
 
 	// AuthMiddleware
 	protected := router.Group("/")
-	protected.Use(middleware.AuthMiddleware)
+	protected.Use(middleware.AuthMiddleware(f.NewBotService(), f.NewTokenService()), apiLimit)
+
+	AuthRoutes(router, protected, authHandler, wsHub, authLimit)
+	SSORoutes(router, protected, ssoHandler, wsHub, authLimit)
 
-	SocketRoutes(protected, websocketHandler)
+	SocketRoutes(protected, websocketHandler, wsHub, messageLimit)
 	ProfileRoutes(protected, profileHandler)
-	ServerRoutes(protected, serverHandler)
+	ServerRoutes(protected, serverHandler, wsHub)
 	MemberRoutes(protected, memberHandler)
 	ChannelRoutes(protected, channelHandler)
-	ConversationRoutes(protected, converstaionHandler)
+	ConversationRoutes(protected, converstaionHandler, wsHub)
 	MessageRoutes(protected, messageHandler)
 	DirectMessageRoutes(protected, directMessageHandler)
+	ScheduledMessageRoutes(protected, scheduledMessageHandler)
+	WebhookRoutes(protected, webhookHandler)
+	IncomingWebhookRoutes(router, protected, incomingWebhookHandler, wsHub)
+	BotRoutes(protected, botHandler, gateway)
+	InteractionRoutes(protected, interactionHandler, wsHub)
+	EmojiRoutes(protected, emojiHandler)
+	RelationshipRoutes(protected, relationshipHandler, wsHub)
+	PrivacyRoutes(protected, privacyHandler)
+	MFARoutes(protected, mfaHandler)
 }
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"discord-backend/internal/app/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ScheduledMessageRoutes(protected *gin.RouterGroup, scheduledMessageHandler *handlers.ScheduledMessageHandler) {
+	scheduledGroup := protected.Group("/scheduled-messages")
+	{
+		scheduledGroup.GET("", scheduledMessageHandler.GetScheduledMessages)
+
+		scheduledGroup.POST("", scheduledMessageHandler.CreateScheduledMessage)
+
+		scheduledGroup.PATCH("/:scheduledMessageId", scheduledMessageHandler.UpdateScheduledMessage)
+
+		scheduledGroup.DELETE("/:scheduledMessageId", scheduledMessageHandler.CancelScheduledMessage)
+	}
+}
@@ -2,15 +2,17 @@ package routes
 
 import (
 	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/websocket"
 
 	"github.com/gin-gonic/gin"
 )
 
-func ServerRoutes(protected *gin.RouterGroup, serverHandler *handlers.ServerHandler) {
+func ServerRoutes(protected *gin.RouterGroup, serverHandler *handlers.ServerHandler, wsHub *websocket.Hub) {
 	serversGroup := protected.Group("/servers")
 	{
 		serversGroup.GET("", serverHandler.GetServers)
 		serversGroup.GET("/by-profile", serverHandler.GetServerByProfileID)
+		serversGroup.GET("/deleted", serverHandler.GetDeletedServers)
 		serversGroup.GET("/invite-code/:inviteCode", serverHandler.GetServerByInviteCode)
 		serversGroup.GET("/:serverId", serverHandler.GetServer)
 		serversGroup.GET("/:serverId/members", serverHandler.GetMember)
@@ -23,7 +25,8 @@ func ServerRoutes(protected *gin.RouterGroup, serverHandler *handlers.ServerHand
 		serversGroup.PATCH("/:serverId", serverHandler.UpdateServer)
 		serversGroup.PATCH("/:serverId/leave", serverHandler.LeaveServer)
 		serversGroup.PATCH("/:serverId/invite-code", serverHandler.UpdateServerInviteCode)
+		serversGroup.PATCH("/:serverId/restore", serverHandler.RestoreServer)
 
-		serversGroup.DELETE("/:serverId", serverHandler.DeleteServer)
+		serversGroup.DELETE("/:serverId", serverHandler.DeleteServer(wsHub))
 	}
 }
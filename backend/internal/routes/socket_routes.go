@@ -7,18 +7,21 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SocketRoutes(router *gin.RouterGroup, socketHandler *handlers.WebsocketHandler) {
-	wsHub := websocket.NewHub()
-	go wsHub.Run()
-
+func SocketRoutes(router *gin.RouterGroup, socketHandler *handlers.WebsocketHandler, wsHub *websocket.Hub, messageLimit gin.HandlerFunc) {
 	router.GET("/ws", socketHandler.WebSocketHandler(wsHub))
-	router.POST("/ws/messages", socketHandler.WebSocketMessageHandler(wsHub))
+	router.POST("/ws/messages", messageLimit, socketHandler.WebSocketMessageHandler(wsHub))
 	router.PATCH("/ws/messages/:messageId", socketHandler.WebScoketEditMessageHandler(wsHub))
 	router.DELETE("/ws/messages/:messageId", socketHandler.WebScoketDeleteMessageHandler(wsHub))
+	router.POST("/ws/messages/:messageId/pin", socketHandler.WebSocketPinMessageHandler(wsHub))
+	router.DELETE("/ws/messages/:messageId/pin", socketHandler.WebSocketUnpinMessageHandler(wsHub))
+	router.POST("/ws/messages/:messageId/reactions", socketHandler.WebSocketAddReactionHandler(wsHub))
+	router.DELETE("/ws/messages/:messageId/reactions", socketHandler.WebSocketRemoveReactionHandler(wsHub))
 
 	router.GET("/ws/servers/:serverId/participants", socketHandler.WebSocketGetParticipants(wsHub))
 
-	router.POST("/ws/direct-messages", socketHandler.WebSocketDirectMessageHandler(wsHub))
+	router.POST("/ws/direct-messages", messageLimit, socketHandler.WebSocketDirectMessageHandler(wsHub))
 	router.PATCH("/ws/direct-messages/:directMessageId", socketHandler.WebSocketEditDirectMessageHandler(wsHub))
 	router.DELETE("/ws/direct-messages/:directMessageId", socketHandler.WebSocketDeleteDirectMessageHandler(wsHub))
+	router.POST("/ws/direct-messages/:directMessageId/pin", socketHandler.WebSocketPinDirectMessageHandler(wsHub))
+	router.DELETE("/ws/direct-messages/:directMessageId/pin", socketHandler.WebSocketUnpinDirectMessageHandler(wsHub))
 }
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"discord-backend/internal/app/handlers"
+	"discord-backend/internal/app/middleware"
+	"discord-backend/internal/app/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SSORoutes(router *gin.Engine, protected *gin.RouterGroup, ssoHandler *handlers.SSOHandler, wsHub *websocket.Hub, authLimit gin.HandlerFunc) {
+	router.GET("/oidc/providers", ssoHandler.GetProviders)
+	router.GET("/oidc/:provider/login", authLimit, ssoHandler.Login)
+	router.GET("/oidc/:provider/callback", authLimit, ssoHandler.Callback(wsHub))
+
+	protected.GET("/profile/identities", middleware.UserOnly, ssoHandler.GetIdentities)
+}
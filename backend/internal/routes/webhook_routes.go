@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"discord-backend/internal/app/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func WebhookRoutes(protected *gin.RouterGroup, webhookHandler *handlers.WebhookHandler) {
+	webhooksGroup := protected.Group("/servers/:serverId/webhooks")
+	{
+		webhooksGroup.GET("", webhookHandler.GetWebhooks)
+		webhooksGroup.GET("/:webhookId/deliveries", webhookHandler.GetWebhookDeliveries)
+
+		webhooksGroup.POST("", webhookHandler.CreateWebhook)
+		webhooksGroup.POST("/:webhookId/secret", webhookHandler.RotateWebhookSecret)
+
+		webhooksGroup.PATCH("/:webhookId", webhookHandler.UpdateWebhook)
+
+		webhooksGroup.DELETE("/:webhookId", webhookHandler.DeleteWebhook)
+	}
+}
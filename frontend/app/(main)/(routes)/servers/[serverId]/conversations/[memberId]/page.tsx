@@ -54,21 +54,23 @@ const MemberIdPage = async ({
         return redirect(`/servers/${params.serverId}`)
     }
 
-    const { memberOne, memberTwo } = conversation;
+    const otherParticipant = conversation.participants.find((participant) => participant.profileID !== profile.id);
 
-    const otherMember = memberOne.profileID === profile.id ? memberTwo : memberOne;
+    if (!otherParticipant) {
+        return redirect(`/servers/${params.serverId}`)
+    }
  
     return (
         <div className="bg-white dark:bg-[#313338] flex flex-col h-full">
             <ChatHeader 
-                imageUrl={otherMember?.profile?.imageUrl}
-                name={otherMember?.profile?.name ?? ""}
+                imageUrl={otherParticipant.profile?.imageUrl}
+                name={otherParticipant.profile?.name ?? ""}
                 serverId={params.serverId}
                 type="conversation"
             />
             <ChatMessages 
                 member={currentMember}
-                name={otherMember.profile.name}
+                name={otherParticipant.profile.name}
                 chatId={conversation.id}
                 type="conversation"
                 apiUrl="/direct-messages"
@@ -80,7 +82,7 @@ const MemberIdPage = async ({
                 }}
             />
             <ChatInput 
-                name={otherMember.profile.name}
+                name={otherParticipant.profile.name}
                 type="conversation"
                 apiUrl="/ws/direct-messages"
                 query={{
@@ -0,0 +1,76 @@
+import { format } from "date-fns";
+
+import { Embed } from "@/types/models";
+import { cn } from "@/lib/utils";
+
+const DATE_FORMAT = "d MMM yyyy, HH:mm";
+
+interface ChatEmbedProps {
+    embed: Embed;
+}
+
+export const ChatEmbed = ({
+    embed
+}: ChatEmbedProps) => {
+    const color = embed.color ? `#${embed.color.toString(16).padStart(6, "0")}` : undefined;
+
+    return (
+        <div
+            className="mt-2 max-w-lg rounded-md border-l-4 border-zinc-300 dark:border-zinc-600
+            bg-zinc-100 dark:bg-zinc-800/60 p-3 flex flex-col gap-y-1"
+            style={{ borderLeftColor: color }}
+        >
+            {embed.title && (
+                embed.url ? (
+                    <a
+                        href={embed.url}
+                        target="_blank"
+                        rel="noopener noreferrer"
+                        className="text-sm font-semibold text-indigo-500 dark:text-indigo-400 hover:underline"
+                    >
+                        {embed.title}
+                    </a>
+                ) : (
+                    <p className="text-sm font-semibold text-zinc-700 dark:text-zinc-200">
+                        {embed.title}
+                    </p>
+                )
+            )}
+            {embed.description && (
+                <p className="text-sm text-zinc-600 dark:text-zinc-300 whitespace-pre-wrap">
+                    {embed.description}
+                </p>
+            )}
+            {!!embed.fields?.length && (
+                <div className="grid grid-cols-3 gap-2 mt-1">
+                    {embed.fields.map((field, i) => (
+                        <div key={i} className={cn(!field.inline && "col-span-3")}>
+                            <p className="text-xs font-semibold text-zinc-700 dark:text-zinc-200">
+                                {field.name}
+                            </p>
+                            <p className="text-xs text-zinc-600 dark:text-zinc-300 whitespace-pre-wrap">
+                                {field.value}
+                            </p>
+                        </div>
+                    ))}
+                </div>
+            )}
+            {embed.imageUrl && (
+                // Embed images come from anywhere, next/image only loads the configured domains
+                // eslint-disable-next-line @next/next/no-img-element
+                <img
+                    src={embed.imageUrl}
+                    alt={embed.title ?? ""}
+                    className="mt-2 max-h-72 rounded-md object-contain"
+                />
+            )}
+            {(embed.footer || embed.timestamp) && (
+                <span className="text-[10px] mt-1 text-zinc-500 dark:text-zinc-400">
+                    {[embed.footer, embed.timestamp && format(new Date(embed.timestamp), DATE_FORMAT)]
+                        .filter(Boolean)
+                        .join(" • ")}
+                </span>
+            )}
+        </div>
+    );
+};
@@ -11,9 +11,10 @@ import { useEffect, useState } from 'react';
 import { useRouter, useParams } from 'next/navigation';
 import { zodResolver } from '@hookform/resolvers/zod';
 
-import { Member, MemberRole } from "@/types/models";
+import { Embed, Member, MemberRole, Profile } from "@/types/models";
 import { UserAvatar } from "@/components/UserAvatar";
 import { ActionTooltip } from "@/components/ActionTooltip";
+import { ChatEmbed } from "@/components/chat/ChatEmbed";
 import { cn } from '@/lib/utils';
 import {
     Form,
@@ -29,7 +30,13 @@ import axios from '@/utils/axios';
 interface ChatItemProps {
     id: string;
     content: string;
-    member: Member;
+    member?: Member; // Direct messages are sent by a profile, not a member
+    profile?: Profile; // Webhook messages have neither, the author is given instead
+    webhook?: {
+        name: string;
+        avatarUrl?: string;
+    };
+    embeds?: Embed[];
     timestamp: string;
     fileUrl: string | undefined;
     deleted: boolean;
@@ -53,6 +60,9 @@ export const ChatItem = ({
     id,
     content,
     member,
+    profile,
+    webhook,
+    embeds,
     timestamp,
     fileUrl,
     deleted,
@@ -67,7 +77,7 @@ export const ChatItem = ({
     const params = useParams();
 
     const onMemberClick = () => {
-        if (member.id === currentMember.id) {
+        if (!member || member.id === currentMember.id) {
             return;
         }
 
@@ -120,29 +130,38 @@ export const ChatItem = ({
 
     const isAdmin = currentMember.role === MemberRole.ADMIN;
     const isModerator = currentMember.role === MemberRole.MODERATOR;
-    const isOwner = currentMember.id === member.id;
+    const isOwner = currentMember.profileID === profile?.id;
     const canDeleteMessage = !deleted && (isAdmin || isModerator || isOwner);
     const canEditMessage = !deleted && isOwner && !fileUrl;
     const isPDF = fileType === "pdf" && fileUrl;
     const isImage = !isPDF && fileUrl;
+    const authorName = webhook ? webhook.name : profile?.name;
+    const authorAvatarUrl = webhook ? webhook.avatarUrl : profile?.imageUrl;
 
     return (
         <div className="relative group flex items-center hover:bg-black/5 p-4 transition w-full">
             <div className="group flex gap-x-2 item-start w-full">
                 <div onClick={onMemberClick} className="cursor-pointer hover:drop-shadow-md transition">
                     <UserAvatar
-                        src={member?.profile?.imageUrl}
+                        src={authorAvatarUrl}
                     />
                 </div>
                 <div className="flex flex-col w-full">
                     <div className="flex items-center gap-x-2">
                         <div className="flex items-center">
                             <p onClick={onMemberClick} className="font-semibold text-sm hover:underline cursor-pointer">
-                                {member?.profile?.name}
+                                {authorName}
                             </p>
-                            <ActionTooltip label={member.role}>
-                                {roleIconMap[member.role]}
-                            </ActionTooltip>
+                            {member && (
+                                <ActionTooltip label={member.role}>
+                                    {roleIconMap[member.role]}
+                                </ActionTooltip>
+                            )}
+                            {webhook && (
+                                <span className="ml-2 rounded-sm bg-indigo-500 px-1 text-[10px] font-semibold text-white">
+                                    WEBHOOK
+                                </span>
+                            )}
                         </div>
                         <span className="text-xs text-zinc-500 dark:text-zinc-400">
                             {timestamp}
@@ -177,7 +196,7 @@ export const ChatItem = ({
                             </a>
                         </div>
                     )}
-                    {!fileUrl && !isEditing && (
+                    {!fileUrl && !isEditing && (content || deleted) && (
                         <p className={cn(
                             "text-sm text-zinc-600 dark:text-zinc-300",
                             deleted && "italic text-zinc-500 dark:text-zinc-400 text-xs mt-1"
@@ -227,6 +246,9 @@ export const ChatItem = ({
                             </span>
                         </Form>
                     )}
+                    {!deleted && embeds?.map((embed, i) => (
+                        <ChatEmbed key={i} embed={embed} />
+                    ))}
                 </div>
             </div>
             {canDeleteMessage && (
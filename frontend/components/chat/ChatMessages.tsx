@@ -5,7 +5,7 @@ import { Loader2, ServerCrash } from "lucide-react";
 import { Fragment, useRef, ElementRef, useEffect } from "react";
 import { format } from 'date-fns';
 
-import { Member, Message } from "@/types/models";
+import { DirectMessage, Member, Message } from "@/types/models";
 import { ChatWelcome } from "@/components/chat/ChatWelcome";
 import { ChatItem } from "@/components/chat/ChatItem";
 import { useChatQuery } from "@/hooks/useChatQuery";
@@ -98,12 +98,18 @@ export const ChatMessages = ({
             <div className="flex flex-col-reverse mt-auto">
                 {data?.pages?.map((group, i) => (
                     <Fragment key={i}>
-                        {group.items.map((message: Message) => (
+                        {group.items.map((message: Message | DirectMessage) => (
                             <ChatItem
                                 key={message.id}
                                 id={message.id}
                                 currentMember={member}
-                                member={message.member}
+                                member={"member" in message ? message.member : undefined}
+                                profile={"conversationId" in message ? message.profile : message.member?.profile}
+                                webhook={"webhookID" in message && message.webhookID ? {
+                                    name: message.authorName ?? "Webhook",
+                                    avatarUrl: message.authorAvatarUrl
+                                } : undefined}
+                                embeds={"embeds" in message ? message.embeds : undefined}
                                 content={message.content}
                                 fileUrl={message.fileUrl}
                                 deleted={message.deleted}
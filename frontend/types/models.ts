@@ -24,9 +24,6 @@ export interface Member {
     serverID: string;
     server?: Server; // Made optional to prevent circular reference issues
     messages?: Message[]; // Assuming Message is another interface you will define
-    directMessages?: DirectMessage[]; // Assuming DirectMessage is another interface you will define
-    conversationsInitiated?: Conversation[]; // Assuming Conversation is another interface you will define
-    conversationsReceived?: Conversation[]; // Assuming Conversation is another interface you will define
     created_at: Date;
     updated_at: Date;
 }
@@ -63,12 +60,33 @@ export interface Server {
     updated_at: Date;
 }
 
+export interface EmbedField {
+    name: string;
+    value: string;
+    inline?: boolean;
+}
+
+export interface Embed {
+    title?: string;
+    description?: string;
+    url?: string;
+    color?: number;
+    imageUrl?: string;
+    footer?: string;
+    timestamp?: string;
+    fields?: EmbedField[];
+}
+
 export interface Message {
     id: string;
     content: string;
     fileUrl?: string; // Optional because of the pointer type in Go, indicating it can be null
-    memberID: string;
-    member: Member; // Made optional to avoid deep nesting issues during type checking
+    memberID?: string; // Messages posted through a webhook have no member
+    member?: Member;
+    webhookID?: string;
+    authorName?: string; // Author shown for webhook messages
+    authorAvatarUrl?: string;
+    embeds?: Embed[];
     channelID: string;
     channel?: Channel; // Made optional to avoid deep nesting issues during type checking
     deleted: boolean;
@@ -80,9 +98,9 @@ export interface DirectMessage {
     id: string;
     content: string;
     fileUrl?: string; // Optional for the same reason as above
-    memberID: string;
-    member?: Member; // Optional to simplify type structure and usage
-    conversationID: string;
+    profileID: string;
+    profile: Profile;
+    conversationId: string;
     conversation?: Conversation; // Optional to simplify type structure and usage
     deleted: boolean;
     created_at: Date;
@@ -91,15 +109,25 @@ export interface DirectMessage {
 
 export interface Conversation {
     id: string;
-    memberOneID: string;
-    memberOne: Member; // Optional to simplify type structure and usage
-    memberTwoID: string;
-    memberTwo: Member; // Optional to simplify type structure and usage
+    name: string;
+    imageUrl: string;
+    isGroup: boolean;
+    ownerId?: string; // Only set on group conversations
+    participants: ConversationParticipant[];
     directMessages?: DirectMessage[]; // Assuming it can be optional
     created_at: Date;
     updated_at: Date;
 }
 
+export interface ConversationParticipant {
+    id: string;
+    conversationId: string;
+    profileID: string;
+    profile: Profile;
+    request: boolean; // Set while the conversation waits in the requests inbox
+    created_at: Date;
+}
+
 export interface WebRTCMessage {
     candidate?: RTCIceCandidate
     answer?: RTCLocalSessionDescriptionInit